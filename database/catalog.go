@@ -0,0 +1,552 @@
+package database
+
+import (
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+	"github.com/djbckr/godb/store"
+	"strings"
+	"sync"
+)
+
+//go:embed syscreate.sql
+var sysCreate string
+
+/*
+The catalog describes what is in the database. Its own tables and views are made by syscreate.sql
+when the database is made, the rest by CREATE TABLE and CREATE INDEX, and the dictionary - the one
+heap whose place is fixed - has a row for each of them:
+
+  name      schema.name
+  kind      TABLE, INDEX or VIEW
+  datafile  2 bytes: the datafile the table's heap or the index is in; empty for a view
+  page      4 bytes: the first page of the heap, or the root of the index; empty for a view
+  sql       the statement that made it
+
+When the database opens, the catalog is found again from these rows. A table or index in a
+tablespace that is offline is opened when it is first used.
+*/
+
+const (
+	kindTable = "TABLE"
+	kindIndex = "INDEX"
+	kindView  = "VIEW"
+)
+
+// the columns of [SYS].[_user], as syscreate.sql makes it
+const (
+	userTable    = "SYS._user"
+	userName     = 0
+	userPassword = 1
+)
+
+// Catalog is the tables and views that describe the database.
+type Catalog struct {
+	mu      sync.Mutex
+	db      *store.Database
+	objects map[string]*Object
+}
+
+// Object is a table, index or view of the catalog.
+type Object struct {
+	Schema string
+	Name   string
+	Kind   string
+	SQL    string
+	on     string       // the schema.name of the table an index is on
+	file   store.FileNo // where the heap or index is
+	page   store.PageNo
+	row    store.RowID  // the row of the dictionary
+	table  *store.Table // nil for a view or an index, or until it is opened
+	index  *store.BTree // nil for a table or a view, or until it is opened
+}
+
+// createCatalog runs a script of CREATE TABLE and CREATE VIEW statements to make the catalog of a
+// new database.
+func createCatalog(db *store.Database, script string) (*Catalog, error) {
+	c := &Catalog{db: db, objects: make(map[string]*Object)}
+
+	statements, err := token.Split(script)
+	if err != nil {
+		return nil, err
+	}
+
+	txns := db.Transactions()
+	txn := txns.Begin(store.ReadCommitted)
+	for _, stmt := range statements {
+		if _, err = c.create(txn, stmt, store.SystemTablespace); err != nil {
+			_ = txn.Rollback()
+			return nil, fmt.Errorf("line %v: %w", stmt.Start.Line, err)
+		}
+	}
+	if _, err = txn.Commit(); err != nil {
+		_ = txn.Rollback()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Create runs a CREATE TABLE, CREATE INDEX or CREATE VIEW. A table or index goes in the tablespace
+// its statement names, or else in the default tablespace.
+func (c *Catalog) Create(stmt *token.Statement) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	txn := c.db.Transactions().Begin(store.ReadCommitted)
+	key, err := c.create(txn, stmt, "")
+	if err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	if _, err = txn.Commit(); err != nil {
+		_ = txn.Rollback()
+		delete(c.objects, key)
+		return err
+	}
+	return nil
+}
+
+// create adds a table, index or view to the catalog, and returns its schema.name. A table or index
+// whose statement names no tablespace goes in the one given, or in the default tablespace if that is
+// empty.
+func (c *Catalog) create(txn *store.Txn, stmt *token.Statement, tablespace string) (string, error) {
+	var obj *Object
+	var err error
+
+	var words []*token.Token
+	for _, tkn := range stmt.Tokens {
+		if tkn.TokenType != token.TypeComment && tkn.TokenType != token.TypeHint && len(words) < 2 {
+			words = append(words, tkn)
+		}
+	}
+
+	switch {
+	case len(words) > 1 && isWord(words[1], "TABLE"):
+		obj, err = c.createTable(stmt, tablespace)
+	case len(words) > 1 && (isWord(words[1], "INDEX") || isWord(words[1], "UNIQUE")):
+		obj, err = c.createIndex(stmt, tablespace)
+	case len(words) > 1 && isWord(words[1], "VIEW"):
+		obj, err = createView(stmt)
+	default:
+		err = errors.New("only CREATE TABLE, CREATE INDEX and CREATE VIEW can make the catalog")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	key := obj.Schema + "." + obj.Name
+	if c.objects[key] != nil {
+		return "", fmt.Errorf("%v already exists", key)
+	}
+
+	row := [][]byte{[]byte(key), []byte(obj.Kind), nil, nil, []byte(obj.SQL)}
+	if obj.Kind != kindView {
+		row[2] = binary.LittleEndian.AppendUint16(nil, obj.file)
+		row[3] = binary.LittleEndian.AppendUint32(nil, obj.page)
+	}
+	if obj.row, err = c.db.Transactions().Table(c.db.Dictionary()).Insert(txn, store.EncodeRow(row)); err != nil {
+		return "", err
+	}
+
+	c.objects[key] = obj
+	return key, nil
+}
+
+func (c *Catalog) createTable(stmt *token.Statement, tablespace string) (*Object, error) {
+	create, err := ddl.ProcessCreateTable(stmt.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	if create.Schema == "" {
+		create.Schema = "SYS"
+	}
+
+	if create.Tablespace != "" {
+		tablespace = create.Tablespace
+	}
+	ts, err := c.db.Tablespaces().ForSegment(tablespace)
+	if err != nil {
+		return nil, err
+	}
+	heap, err := ts.CreateHeap()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Schema: create.Schema,
+		Name:   create.Name,
+		Kind:   kindTable,
+		SQL:    strings.TrimSpace(stmt.Text),
+		file:   heap.DataFile().No(),
+		page:   heap.First(),
+		table:  c.db.Transactions().Table(heap),
+	}, nil
+}
+
+// createIndex makes an index of the rows its table has.
+func (c *Catalog) createIndex(stmt *token.Statement, tablespace string) (*Object, error) {
+	create, err := parseIndex(stmt.Tokens)
+	if err != nil {
+		return nil, err
+	}
+	on := create.TableSchema + "." + create.Table
+	table := c.objects[on]
+	if table == nil || table.Kind != kindTable {
+		return nil, fmt.Errorf("table %v does not exist", on)
+	}
+	if err = c.open(table); err != nil {
+		return nil, err
+	}
+
+	tableColumns, err := columnsOf(table)
+	if err != nil {
+		return nil, err
+	}
+	var columns []int
+	var desc []bool
+	for _, column := range create.Columns {
+		i := 0
+		for i < len(tableColumns) && tableColumns[i].Name != column.Name {
+			i++
+		}
+		if i == len(tableColumns) {
+			return nil, fmt.Errorf("table %v has no column %v", on, column.Name)
+		}
+		columns = append(columns, i)
+		desc = append(desc, column.Desc)
+	}
+
+	if create.Tablespace != "" {
+		tablespace = create.Tablespace
+	}
+	ts, err := c.db.Tablespaces().ForSegment(tablespace)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := ts.CreateBTree(desc, create.Unique)
+	if err != nil {
+		return nil, err
+	}
+
+	s := c.db.Transactions().Snapshot()
+	defer s.Release()
+	err = table.table.Scan(s, func(id store.RowID, row []byte) error {
+		values, err := store.DecodeRow(row)
+		if err != nil {
+			return err
+		}
+		key := make([]interface{}, len(columns))
+		for i, column := range columns {
+			if column < len(values) && values[column] != nil {
+				key[i] = values[column]
+			}
+		}
+		return tree.Insert(key, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Object{
+		Schema: create.Schema,
+		Name:   create.Name,
+		Kind:   kindIndex,
+		SQL:    strings.TrimSpace(stmt.Text),
+		on:     on,
+		file:   tree.DataFile().No(),
+		page:   tree.Root(),
+		index:  tree,
+	}, nil
+}
+
+// columnsOf are the columns of a table, as its CREATE TABLE gives them.
+func columnsOf(table *Object) ([]*ddl.TColumn, error) {
+	tokens, err := token.Tokenize(table.SQL)
+	if err != nil {
+		return nil, err
+	}
+	create, err := ddl.ProcessCreateTable(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return create.Columns, nil
+}
+
+// parseIndex parses CREATE INDEX, filling in the schemas it leaves out.
+func parseIndex(tokens token.Tokens) (*ddl.CreateIndex, error) {
+	create, err := ddl.ProcessCreateIndex(tokens)
+	if err != nil {
+		return nil, err
+	}
+	if create.Schema == "" {
+		create.Schema = "SYS"
+	}
+	if create.TableSchema == "" {
+		create.TableSchema = create.Schema
+	}
+	return create, nil
+}
+
+// createView checks CREATE VIEW [ schema. ] view AS query.
+func createView(stmt *token.Statement) (*Object, error) {
+	var err error
+	p := dml.NewParser(stmt.Tokens)
+	obj := &Object{Schema: "SYS", Kind: kindView, SQL: strings.TrimSpace(stmt.Text)}
+
+	if err = p.ExpectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("VIEW"); err != nil {
+		return nil, err
+	}
+	if obj.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+	if p.AcceptPunct(".") {
+		obj.Schema = obj.Name
+		if obj.Name, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.ExpectKeyword("AS"); err != nil {
+		return nil, err
+	}
+
+	if _, err = p.ParseSelect(); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func isWord(tkn *token.Token, word string) bool {
+	value, ok := tkn.Value.(string)
+	return ok && tkn.TokenType != token.TypeString && value == word
+}
+
+// openCatalog finds the catalog of a database that has been opened.
+func openCatalog(db *store.Database) (*Catalog, error) {
+	c := &Catalog{db: db, objects: make(map[string]*Object)}
+	txns := db.Transactions()
+	s := txns.Snapshot()
+	defer s.Release()
+
+	err := txns.Table(db.Dictionary()).Scan(s, func(id store.RowID, row []byte) error {
+		values, err := store.DecodeRow(row)
+		if err != nil {
+			return err
+		}
+		if len(values) != 5 {
+			return errors.New("dictionary row is damaged")
+		}
+
+		schema, name, _ := strings.Cut(string(values[0]), ".")
+		obj := &Object{Schema: schema, Name: name, Kind: string(values[1]), SQL: string(values[4]), row: id}
+		if obj.Kind == kindIndex {
+			tokens, err := token.Tokenize(obj.SQL)
+			if err != nil {
+				return err
+			}
+			create, err := parseIndex(tokens)
+			if err != nil {
+				return err
+			}
+			obj.on = create.TableSchema + "." + create.Table
+		}
+		if obj.Kind != kindView {
+			if len(values[2]) != 2 || len(values[3]) != 4 {
+				return fmt.Errorf("dictionary row of %v is damaged", values[0])
+			}
+			obj.file, obj.page = binary.LittleEndian.Uint16(values[2]), binary.LittleEndian.Uint32(values[3])
+			df, err := db.DataFile(obj.file)
+			if err != nil {
+				return err
+			}
+			if df.Status() != store.FileOffline {
+				if err = c.open(obj); err != nil {
+					return err
+				}
+			}
+		}
+		c.objects[string(values[0])] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading the dictionary: %w", err)
+	}
+
+	return c, nil
+}
+
+// open opens the heap of a table or the index it has not opened yet.
+func (c *Catalog) open(obj *Object) error {
+	if obj.table != nil || obj.index != nil || obj.Kind == kindView {
+		return nil
+	}
+	df, err := c.db.DataFile(obj.file)
+	if err != nil {
+		return err
+	}
+	if obj.Kind == kindIndex {
+		obj.index, err = store.OpenBTree(c.db.Pool(), df, obj.page)
+		return err
+	}
+	heap, err := store.OpenHeap(c.db.Pool(), df, obj.page)
+	if err != nil {
+		return err
+	}
+	obj.table = c.db.Transactions().Table(heap)
+	return nil
+}
+
+// drop removes the tables and indexes that are in the datafiles from the catalog, along with the
+// indexes on those tables, then calls then, and keeps the change only if it succeeds.
+func (c *Catalog) drop(files []*store.DataFile, then func() error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	in := make(map[store.FileNo]bool)
+	for _, df := range files {
+		in[df.No()] = true
+	}
+	dropped := make(map[string]bool)
+	for key, obj := range c.objects {
+		if obj.Kind != kindView && in[obj.file] {
+			dropped[key] = true
+		}
+	}
+	for key, obj := range c.objects {
+		if obj.Kind == kindIndex && dropped[obj.on] {
+			dropped[key] = true
+		}
+	}
+
+	txn := c.db.Transactions().Begin(store.ReadCommitted)
+	dictionary := c.db.Transactions().Table(c.db.Dictionary())
+	for key := range dropped {
+		if err := dictionary.Delete(txn, c.objects[key].row); err != nil {
+			_ = txn.Rollback()
+			return err
+		}
+	}
+	if err := then(); err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	if _, err := txn.Commit(); err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+
+	for key := range dropped {
+		delete(c.objects, key)
+	}
+	return nil
+}
+
+// Object finds a table or view of the catalog by schema and name.
+func (c *Catalog) Object(schema, name string) *Object {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.objects[schema+"."+name]
+}
+
+// Table opens a table of the catalog for reading, and returns it with its columns.
+func (c *Catalog) Table(schema, name string) (*store.Table, []*ddl.TColumn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	obj := c.objects[schema+"."+name]
+	if obj == nil {
+		return nil, nil, fmt.Errorf("table %v.%v does not exist", schema, name)
+	}
+	if obj.Kind != kindTable {
+		return nil, nil, fmt.Errorf("%v.%v is a %v, not a table", schema, name, strings.ToLower(obj.Kind))
+	}
+	if err := c.open(obj); err != nil {
+		return nil, nil, err
+	}
+	columns, err := columnsOf(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	return obj.table, columns, nil
+}
+
+// CreateUser adds a user with a password. User names are not case sensitive.
+func (c *Catalog) CreateUser(name, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	name = strings.ToUpper(name)
+	if name == "" || password == "" {
+		return errors.New("a user needs a name and a password")
+	}
+	if _, found, err := c.findUser(name); err != nil {
+		return err
+	} else if found {
+		return fmt.Errorf("user %v already exists", name)
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	txn := c.db.Transactions().Begin(store.ReadCommitted)
+	if _, err = c.objects[userTable].table.Insert(txn, store.EncodeRow([][]byte{[]byte(name), []byte(hash)})); err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	if _, err = txn.Commit(); err != nil {
+		_ = txn.Rollback()
+		return err
+	}
+	return nil
+}
+
+// Authenticate reports whether a user exists and has the password given.
+func (c *Catalog) Authenticate(name, password string) (bool, error) {
+	c.mu.Lock()
+	hash, found, err := c.findUser(strings.ToUpper(name))
+	c.mu.Unlock()
+	if err != nil {
+		return false, err
+	}
+	if !found {
+		// check the password anyway, so that an unknown user takes as long as a wrong password
+		_, _ = CheckPassword(password, unknownUserHash())
+		return false, nil
+	}
+	return CheckPassword(password, hash)
+}
+
+// findUser returns the password hash of a user.
+func (c *Catalog) findUser(name string) (hash string, found bool, err error) {
+	users := c.objects[userTable]
+	if users == nil || users.Kind != kindTable {
+		return "", false, fmt.Errorf("the catalog has no %v table", userTable)
+	}
+	if err = c.open(users); err != nil {
+		return "", false, err
+	}
+
+	s := c.db.Transactions().Snapshot()
+	defer s.Release()
+	err = users.table.Scan(s, func(_ store.RowID, row []byte) error {
+		values, err := store.DecodeRow(row)
+		if err != nil {
+			return err
+		}
+		if len(values) > userPassword && string(values[userName]) == name {
+			hash, found = string(values[userPassword]), true
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,313 @@
+// Package database starts the database the server serves: it opens it from the init file, or, on
+// the first start, when there is no init file yet, makes it with CREATE DATABASE.
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/store"
+	"os"
+	"sync"
+)
+
+/*
+CREATE DATABASE makes the control files, the redo log, and the SYSTEM, default, temporary and undo
+tablespaces; builds the catalog with syscreate.sql; and adds the SYS user. The init file is written
+last, so that it is only there once the database is whole: a server that finds it opens the database,
+and one that does not waits for CREATE DATABASE. If making the database fails, whatever was made is
+deleted again, and CREATE DATABASE can be run once more.
+
+If every copy of the control file is lost, the server starts without a database, and CREATE
+CONTROLFILE makes the control file again, at the paths of the init file, and opens the database.
+*/
+
+// ErrNoControlFile is returned by Start when every copy of the control file is lost.
+var ErrNoControlFile = errors.New("every copy of the control file is lost: CREATE CONTROLFILE makes it again")
+
+// PoolPages is the size of the buffer pool.
+var PoolPages = 4096
+
+// DefaultLogSize is the size of a log file whose SIZE is not given.
+const DefaultLogSize = 100 << 20
+
+// Instance is the open database.
+type Instance struct {
+	Params   *InitParams
+	Database *store.Database
+	Catalog  *Catalog
+}
+
+var (
+	mu       sync.Mutex
+	initFile string
+	current  *Instance
+	lost     *InitParams // the parameters of a database whose control file is lost
+)
+
+// Start opens the database the init file describes, and returns its parameters. If there is no init
+// file, it returns nil parameters and no error: there is no database yet, and CREATE DATABASE will
+// make one and write the file. If every copy of the control file is lost, it returns the parameters
+// and ErrNoControlFile, and CREATE CONTROLFILE will open the database.
+func Start(path string) (*InitParams, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current != nil {
+		return nil, errors.New("the database is already open")
+	}
+	initFile, lost = path, nil
+
+	params, err := ReadInitFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if controlFileLost(params.ControlFiles) {
+		lost = params
+		return params, ErrNoControlFile
+	}
+	if current, err = open(params); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+// open opens the database and its catalog.
+func open(params *InitParams) (*Instance, error) {
+	db, err := store.OpenDatabase(params.ControlFiles, PoolPages)
+	if err != nil {
+		return nil, err
+	}
+	catalog, err := openCatalog(db)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return &Instance{Params: params, Database: db, Catalog: catalog}, nil
+}
+
+// controlFileLost reports whether no copy of the control file is there.
+func controlFileLost(paths []string) bool {
+	for _, path := range paths {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// Current is the open database, or nil if there is none yet.
+func Current() *Instance {
+	mu.Lock()
+	defer mu.Unlock()
+	return current
+}
+
+// Shutdown closes the open database.
+func Shutdown() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	lost = nil
+	if current == nil {
+		return nil
+	}
+	err := current.Database.Close()
+	current = nil
+	return err
+}
+
+// Create makes the database, as CREATE DATABASE says, and opens it. The server must have been
+// started without an init file.
+func Create(stmt *ddl.CreateDatabase) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch {
+	case current != nil, lost != nil:
+		return errors.New("the database already exists")
+	case initFile == "":
+		return errors.New("the server has not been started")
+	}
+	if _, err := os.Stat(initFile); err == nil {
+		return fmt.Errorf("init file %v already exists", initFile)
+	}
+
+	params, err := ParseInitParams(stmt.InitParams)
+	if err != nil {
+		return err
+	}
+	spec, err := databaseSpec(stmt, params)
+	if err != nil {
+		return err
+	}
+
+	db, err := store.CreateDatabase(spec)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := createCatalog(db, sysCreate)
+	if err == nil {
+		err = catalog.CreateUser("SYS", stmt.SysPassword)
+	}
+	if err == nil {
+		err = db.Checkpoint()
+	}
+	if err == nil {
+		err = WriteInitFile(initFile, params)
+	}
+	if err != nil {
+		_ = db.Close()
+		removeFiles(spec)
+		return err
+	}
+
+	current = &Instance{Params: params, Database: db, Catalog: catalog}
+	return nil
+}
+
+// databaseSpec turns CREATE DATABASE into what store.CreateDatabase needs.
+func databaseSpec(stmt *ddl.CreateDatabase, params *InitParams) (store.DatabaseSpec, error) {
+	spec := store.DatabaseSpec{
+		Name:         stmt.Name,
+		ControlFiles: params.ControlFiles,
+		System:       dataFileSpecs(stmt.DataFiles),
+		PoolPages:    PoolPages,
+	}
+
+	var err error
+	if spec.LogGroups, err = logGroups(stmt.LogFiles); err != nil {
+		return spec, err
+	}
+
+	// nothing is removed until every group has checked out
+	for _, group := range stmt.LogFiles {
+		if !group.Reuse {
+			continue
+		}
+		for _, member := range group.Members {
+			if err := os.Remove(member); err != nil && !os.IsNotExist(err) {
+				return spec, err
+			}
+		}
+	}
+
+	for _, clause := range []struct {
+		from *ddl.TTablespaceClause
+		to   *store.TablespaceSpec
+	}{
+		{stmt.DefaultTablespace, &spec.Default},
+		{stmt.TempTablespace, &spec.Temp},
+		{stmt.UndoTablespace, &spec.Undo},
+	} {
+		if clause.from != nil {
+			*clause.to = store.TablespaceSpec{Name: clause.from.Name, Files: dataFileSpecs(clause.from.DataFiles)}
+		}
+	}
+
+	return spec, nil
+}
+
+// RebuildControlFile makes the control file again, as CREATE CONTROLFILE says, and opens the
+// database. Start must have found every copy of it lost.
+func RebuildControlFile(stmt *ddl.CreateControlfile) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch {
+	case current != nil:
+		return errors.New("the database is open: its control file is not lost")
+	case lost == nil:
+		return errors.New("the server has not found the control file lost")
+	}
+
+	// a group without SIZE is as big as its members are
+	for _, group := range stmt.LogFiles {
+		for _, member := range group.Members {
+			if info, err := os.Stat(member); group.Size == 0 && err == nil {
+				group.Size = info.Size()
+			}
+		}
+	}
+	groups, err := logGroups(stmt.LogFiles)
+	if err != nil {
+		return err
+	}
+	var paths []string
+	for _, file := range stmt.DataFiles {
+		paths = append(paths, file.Path)
+	}
+
+	if _, err = store.RebuildControlFile(lost.ControlFiles, stmt.Database, groups, stmt.ResetLogs, paths, stmt.Reuse); err != nil {
+		return err
+	}
+	instance, err := open(lost)
+	if err != nil {
+		// a control file that does not open the database is no better than none
+		for _, path := range lost.ControlFiles {
+			_ = os.Remove(path)
+		}
+		return err
+	}
+
+	current, lost = instance, nil
+	return nil
+}
+
+// logGroups turns the LOGFILE clause into the log groups. A group without SIZE is DefaultLogSize.
+func logGroups(files []*ddl.TLogGroup) ([]store.LogGroup, error) {
+	var groups []store.LogGroup
+	for i, group := range files {
+		if group.Group != 0 && group.Group != i+1 {
+			return nil, fmt.Errorf("log group %v must be numbered %v: groups are numbered from 1 in order", group.Group, i+1)
+		}
+		size := group.Size
+		if size == 0 {
+			size = DefaultLogSize
+		}
+		groups = append(groups, store.LogGroup{Members: group.Members, Size: size})
+	}
+	return groups, nil
+}
+
+func dataFileSpecs(files []*ddl.TFileSpec) []store.DataFileSpec {
+	var specs []store.DataFileSpec
+	for _, file := range files {
+		spec := store.DataFileSpec{Path: file.Path, Size: file.Size, Reuse: file.Reuse}
+		spec.Next, spec.MaxSize = autoextend(file.Autoextend)
+		specs = append(specs, spec)
+	}
+	return specs
+}
+
+// autoextend returns how much a datafile grows by, 0 if it does not grow, and how big it may grow.
+func autoextend(a *ddl.TAutoextend) (next, maxSize int64) {
+	if a == nil || !a.On {
+		return 0, 0
+	}
+	if a.Next == 0 {
+		return store.DefaultPageSize, a.MaxSize
+	}
+	return a.Next, a.MaxSize
+}
+
+// removeFiles deletes the files of a database that could not be made whole.
+func removeFiles(spec store.DatabaseSpec) {
+	paths := append([]string(nil), spec.ControlFiles...)
+	for _, group := range spec.LogGroups {
+		paths = append(paths, group.Members...)
+	}
+	for _, ts := range [][]store.DataFileSpec{spec.System, spec.Default.Files, spec.Temp.Files, spec.Undo.Files} {
+		for _, file := range ts {
+			paths = append(paths, file.Path)
+		}
+	}
+	for _, path := range paths {
+		_ = os.Remove(path)
+	}
+}
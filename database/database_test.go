@@ -0,0 +1,173 @@
+package database
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/sql/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createDatabase(dir string) (*ddl.CreateDatabase, error) {
+	path := func(name string) string { return filepath.Join(dir, name) }
+	tokens, e := token.Tokenize(fmt.Sprintf(`create database orcl
+		initparams('ctrlFile=%v', 'ctrlFile=%v', 'serverPort=9443')
+		user sys identified by 'change me'
+		logfile group 1 ('%v', '%v') size 64k, group 2 ('%v', '%v') size 64k
+		datafile '%v' size 512k autoextend on
+		default tablespace users datafile '%v' autoextend on next 64k
+		default temp tablespace temp tempfile '%v'
+		undo tablespace undo datafile '%v' size 1m`,
+		path("control1.ctl"), path("control2.ctl"),
+		path("redo1a.log"), path("redo1b.log"), path("redo2a.log"), path("redo2b.log"),
+		path("system.dbf"), path("users.dbf"), path("temp.dbf"), path("undo.dbf")))
+	if e != nil {
+		return nil, e
+	}
+	return ddl.ProcessCreateDatabase(tokens)
+}
+
+func TestCreateDatabase(t *testing.T) {
+	dir := t.TempDir()
+	initPath := filepath.Join(dir, "godb.ini")
+	t.Cleanup(func() { _ = Shutdown() })
+
+	stmt, e := createDatabase(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = Create(stmt); e == nil {
+		t.Error("expected an error creating a database before the server starts")
+	}
+
+	// the first start finds no init file, and waits for CREATE DATABASE
+	if params, e := Start(initPath); params != nil || e != nil || Current() != nil {
+		t.Fatalf("expected no database, got %+v, %v", params, e)
+	}
+	if e = Create(stmt); e != nil {
+		t.Fatal(e)
+	}
+	check := func() {
+		db := Current()
+		if db == nil || db.Params.ServerPort != 9443 || len(db.Params.ControlFiles) != 2 {
+			t.Fatalf("unexpected database %+v", db)
+		}
+		if ts := db.Database.Tablespaces().Default(0); ts == nil || ts.Name() != "USERS" {
+			t.Error("expected USERS to be the default tablespace")
+		}
+		if obj := db.Catalog.Object("SYS", "_enumValue"); obj == nil || obj.Kind != kindTable {
+			t.Errorf("expected the catalog to have SYS._enumValue, got %+v", obj)
+		}
+		if obj := db.Catalog.Object("SYS", "TABLES"); obj == nil || obj.Kind != kindView {
+			t.Errorf("expected the catalog to have the view SYS.TABLES, got %+v", obj)
+		}
+		if ok, e := db.Catalog.Authenticate("sys", "change me"); !ok || e != nil {
+			t.Errorf("expected SYS to log in, got %v, %v", ok, e)
+		}
+		if ok, e := db.Catalog.Authenticate("SYS", "change it"); ok || e != nil {
+			t.Errorf("expected a wrong password to fail, got %v, %v", ok, e)
+		}
+	}
+	check()
+	if e = Create(stmt); e == nil {
+		t.Error("expected an error creating the database twice")
+	}
+	if e = Current().Catalog.CreateUser("Sys", "again"); e == nil {
+		t.Error("expected an error adding SYS twice")
+	}
+
+	// later starts open it from the init file
+	if e = Shutdown(); e != nil {
+		t.Fatal(e)
+	}
+	if params, e := Start(initPath); e != nil || params.ServerPort != 9443 {
+		t.Fatalf("unexpected start %+v, %v", params, e)
+	}
+	check()
+}
+
+func TestCreateDatabaseFails(t *testing.T) {
+	dir := t.TempDir()
+	initPath := filepath.Join(dir, "godb.ini")
+	t.Cleanup(func() { _ = Shutdown() })
+
+	stmt, e := createDatabase(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = Start(initPath); e != nil {
+		t.Fatal(e)
+	}
+
+	for _, change := range []func(){
+		func() { stmt.LogFiles = []*ddl.TLogGroup{stmt.LogFiles[0], {Group: 3, Members: []string{"redo3.log"}}} },
+		func() { stmt.InitParams = []string{"serverPort=9443"} },
+		// the catalog does not fit in SYSTEM
+		func() { stmt.DataFiles = []*ddl.TFileSpec{{Path: stmt.DataFiles[0].Path, Size: 4 * 8192}} },
+	} {
+		saved := *stmt
+		change()
+		if e = Create(stmt); e == nil {
+			t.Fatalf("expected an error creating %+v", stmt)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+			t.Errorf("expected every file made to be deleted, found %v", entries)
+		}
+		*stmt = saved
+	}
+
+	// a REUSE log member is kept when a later group is wrong
+	kept := stmt.LogFiles[0].Members[0]
+	if e = os.WriteFile(kept, []byte("in use"), 0600); e != nil {
+		t.Fatal(e)
+	}
+	reuse := *stmt.LogFiles[0]
+	reuse.Reuse = true
+	stmt.LogFiles = []*ddl.TLogGroup{&reuse, {Group: 3, Members: []string{"redo3.log"}}}
+	if e = Create(stmt); e == nil {
+		t.Fatal("expected an error for a misnumbered group")
+	}
+	if _, e = os.Stat(kept); e != nil {
+		t.Errorf("expected %v to be kept: %v", kept, e)
+	}
+
+	if Current() != nil {
+		t.Error("expected no database")
+	}
+}
+
+func TestCreateView(t *testing.T) {
+	for _, test := range []struct {
+		sql    string
+		schema string
+		name   string
+	}{
+		{"create view v as select a from t", "SYS", "V"},
+		{"/* x */ create view v as select a from t", "SYS", "V"},
+		{"-- the view\ncreate /* of */ view app . v as /*+ FULL(t) */ select a from t", "APP", "V"},
+	} {
+		statements, e := token.Split(test.sql)
+		if e != nil {
+			t.Fatal(e)
+		}
+		obj, e := createView(statements[0])
+		if e != nil || obj.Schema != test.schema || obj.Name != test.name || obj.Kind != kindView {
+			t.Errorf("%q: unexpected %+v, %v", test.sql, obj, e)
+		}
+	}
+
+	for _, sql := range []string{
+		"/* x */ create view v as select from t",
+		"create view v as select a from t where",
+		"create view v select a from t",
+	} {
+		statements, e := token.Split(sql)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, e = createView(statements[0]); e == nil {
+			t.Errorf("%q: expected an error", sql)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+The init file holds the parameters the server starts with, one name=value to a line. Blank lines
+and lines starting with # are skipped, and names are not case sensitive:
+
+  ctrlFile     a copy of the control file; give it once for every copy, at least once
+  maxSessions  the most sessions open at one time; 100 unless given
+  serverPort   the port HTTPS is served on; 9422 unless given
+  keyFile      the private key of the server's certificate
+  certFile     the server's certificate
+  authTokenLifetime
+               how long an AuthToken lasts after login, such as 8h or 90m, or 0 for ever; 10h unless
+               given
+
+CREATE DATABASE writes the file from its INITPARAMS.
+*/
+
+const (
+	DefaultMaxSessions       = 100
+	DefaultServerPort        = 9422
+	DefaultAuthTokenLifetime = 10 * time.Hour
+)
+
+// InitParams are the parameters of the init file.
+type InitParams struct {
+	ControlFiles []string
+	MaxSessions  int
+	ServerPort   int
+	KeyFile      string
+	CertFile     string
+
+	AuthTokenLifetime time.Duration // 0 if an AuthToken lasts for ever
+}
+
+// ParseInitParams reads name=value lines.
+func ParseInitParams(lines []string) (*InitParams, error) {
+	params := &InitParams{MaxSessions: DefaultMaxSessions, ServerPort: DefaultServerPort, AuthTokenLifetime: DefaultAuthTokenLifetime}
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return nil, fmt.Errorf("init parameter %q is not name=value", line)
+		}
+
+		var err error
+		switch strings.ToLower(name) {
+		case "ctrlfile":
+			params.ControlFiles = append(params.ControlFiles, value)
+		case "maxsessions":
+			params.MaxSessions, err = positive(name, value, 1<<20)
+		case "serverport":
+			params.ServerPort, err = positive(name, value, 65535)
+		case "keyfile":
+			params.KeyFile = value
+		case "certfile":
+			params.CertFile = value
+		case "authtokenlifetime":
+			if params.AuthTokenLifetime, err = time.ParseDuration(value); err != nil || params.AuthTokenLifetime < 0 {
+				err = fmt.Errorf("init parameter %v must be a duration such as 10h, or 0, not %v", name, value)
+			}
+		default:
+			err = fmt.Errorf("unknown init parameter %v", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(params.ControlFiles) == 0 {
+		return nil, errors.New("at least one ctrlFile init parameter is needed")
+	}
+	return params, nil
+}
+
+func positive(name, value string, limit int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > limit {
+		return 0, fmt.Errorf("init parameter %v must be a whole number from 1 to %v, not %v", name, limit, value)
+	}
+	return n, nil
+}
+
+// ReadInitFile reads the init file. If there is none, the error satisfies os.IsNotExist.
+func ReadInitFile(path string) (*InitParams, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	params, err := ParseInitParams(lines)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return params, nil
+}
+
+// Lines are the parameters as the init file holds them.
+func (params *InitParams) Lines() []string {
+	var lines []string
+	for _, path := range params.ControlFiles {
+		lines = append(lines, "ctrlFile="+path)
+	}
+	lines = append(lines, fmt.Sprintf("maxSessions=%v", params.MaxSessions), fmt.Sprintf("serverPort=%v", params.ServerPort))
+	if params.KeyFile != "" {
+		lines = append(lines, "keyFile="+params.KeyFile)
+	}
+	if params.CertFile != "" {
+		lines = append(lines, "certFile="+params.CertFile)
+	}
+	lines = append(lines, fmt.Sprintf("authTokenLifetime=%v", params.AuthTokenLifetime))
+	return lines
+}
+
+// WriteInitFile writes the init file, which must not exist yet.
+func WriteInitFile(path string, params *InitParams) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.WriteString(strings.Join(params.Lines(), "\n") + "\n")
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
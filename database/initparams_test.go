@@ -0,0 +1,71 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInitParams(t *testing.T) {
+	params, e := ParseInitParams([]string{
+		"# the control files",
+		"ctrlFile=/ctl/control1.ctl",
+		" CTRLFILE = /ctl/control2.ctl ",
+		"",
+		"serverPort=443",
+		"keyFile=/etc/godb/server.key",
+		"authTokenLifetime=90m",
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	expected := &InitParams{
+		ControlFiles: []string{"/ctl/control1.ctl", "/ctl/control2.ctl"},
+		MaxSessions:  DefaultMaxSessions,
+		ServerPort:   443,
+		KeyFile:      "/etc/godb/server.key",
+
+		AuthTokenLifetime: 90 * time.Minute,
+	}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %+v, got %+v", expected, params)
+	}
+
+	path := filepath.Join(t.TempDir(), "godb.ini")
+	if _, e = ReadInitFile(path); !os.IsNotExist(e) {
+		t.Errorf("expected a missing init file, got %v", e)
+	}
+	if e = WriteInitFile(path, params); e != nil {
+		t.Fatal(e)
+	}
+	if e = WriteInitFile(path, params); e == nil {
+		t.Error("expected an error writing an init file that exists")
+	}
+	if params, e = ReadInitFile(path); e != nil || !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %+v, got %+v, %v", expected, params, e)
+	}
+	if params, e = ParseInitParams([]string{"ctrlFile=a.ctl"}); e != nil || params.AuthTokenLifetime != DefaultAuthTokenLifetime {
+		t.Errorf("expected the default AuthToken lifetime, got %+v, %v", params, e)
+	}
+	if params, e = ParseInitParams([]string{"ctrlFile=a.ctl", "authTokenLifetime=0"}); e != nil || params.AuthTokenLifetime != 0 {
+		t.Errorf("expected AuthTokens to last for ever, got %+v, %v", params, e)
+	}
+
+	for _, lines := range [][]string{
+		{},
+		{"maxSessions=10"},
+		{"ctrlFile=a.ctl", "ctrlFile"},
+		{"ctrlFile=a.ctl", "ctrlFile="},
+		{"ctrlFile=a.ctl", "serverPort=70000"},
+		{"ctrlFile=a.ctl", "maxSessions=many"},
+		{"ctrlFile=a.ctl", "sessions=10"},
+		{"ctrlFile=a.ctl", "authTokenLifetime=10"},
+		{"ctrlFile=a.ctl", "authTokenLifetime=-1h"},
+	} {
+		if _, e = ParseInitParams(lines); e == nil {
+			t.Errorf("expected error: %v", lines)
+		}
+	}
+}
@@ -5,25 +5,30 @@ create table [SYS].[_obj] (
 
 create table [SYS].[_table] (
     [_id] uuid
-)
+);
 
 create table [SYS].[_enum] (
     [_id] uuid
-)
+);
 
 create table [SYS].[_enumValue] (
     [_id] uuid,
     [_enum_id] uuid,
     [_name] varchar(128),
     [_value] integer
-)
+);
 
 create table [SYS].[_constraint] (
+    [_id] uuid,
+    [_table_id] uuid,
+    [_name] varchar(128)
+);
 
-)
-
-
+create table [SYS].[_user] (
+    [_name] varchar(128) not null,
+    [_password] varchar(256) not null
+);
 
-create view tables as
+create view [SYS].tables as
 select *
-  from [SYS].[_table] t
+  from [SYS].[_table] t;
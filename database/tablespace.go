@@ -0,0 +1,146 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/store"
+	"os"
+)
+
+// CreateTablespace makes a tablespace, as CREATE TABLESPACE says.
+func CreateTablespace(stmt *ddl.CreateTablespace) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current == nil {
+		return errors.New("there is no database")
+	}
+	db := current.Database
+
+	kind := store.PermanentTablespace
+	switch stmt.Kind {
+	case ddl.TABLESPACE_TEMPORARY:
+		kind = store.TemporaryTablespace
+	case ddl.TABLESPACE_UNDO:
+		kind = store.UndoTablespace
+	}
+
+	ts, err := db.Tablespaces().Create(stmt.Name, kind, int(stmt.BlockSize), dataFileSpecs(stmt.DataFiles))
+	if err != nil {
+		return err
+	}
+	if stmt.Offline {
+		if err = ts.SetStatus(store.FileOffline); err != nil {
+			_ = db.Tablespaces().Drop(stmt.Name, false, true)
+			return err
+		}
+	}
+	return db.Checkpoint()
+}
+
+// AlterTablespace changes a tablespace, as ALTER TABLESPACE says.
+func AlterTablespace(stmt *ddl.AlterTablespace) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current == nil {
+		return errors.New("there is no database")
+	}
+	db := current.Database
+
+	if stmt.Action == ddl.TABLESPACE_RENAME {
+		if stmt.Name == store.SystemTablespace {
+			return fmt.Errorf("the %v tablespace cannot be renamed", store.SystemTablespace)
+		}
+		if err := db.Tablespaces().Rename(stmt.Name, stmt.NewName); err != nil {
+			return err
+		}
+		return db.Checkpoint()
+	}
+
+	ts, err := db.Tablespaces().Get(stmt.Name)
+	if err != nil {
+		return err
+	}
+
+	switch stmt.Action {
+	case ddl.TABLESPACE_ADD_FILE:
+		for _, spec := range dataFileSpecs(stmt.DataFiles) {
+			if _, err = ts.AddDataFile(spec); err != nil {
+				break
+			}
+		}
+	case ddl.TABLESPACE_DROP_FILE:
+		err = ts.DropDataFile(stmt.DataFile)
+	case ddl.TABLESPACE_RESIZE:
+		err = ts.Resize(stmt.DataFile, stmt.Size)
+	case ddl.TABLESPACE_AUTOEXTEND:
+		next, maxSize := autoextend(stmt.Autoextend)
+		err = ts.SetAutoextend(stmt.DataFile, next, maxSize)
+	case ddl.TABLESPACE_ONLINE, ddl.TABLESPACE_READ_WRITE:
+		err = ts.SetStatus(store.FileOnline)
+	case ddl.TABLESPACE_OFFLINE, ddl.TABLESPACE_READ_ONLY:
+		// the dictionary, and the catalog in it, must stay writable
+		if stmt.Name == store.SystemTablespace {
+			return fmt.Errorf("the %v tablespace must stay online and read write", store.SystemTablespace)
+		}
+		status := store.FileOffline
+		if stmt.Action == ddl.TABLESPACE_READ_ONLY {
+			status = store.FileReadOnly
+		}
+		err = ts.SetStatus(status)
+	}
+	if err != nil {
+		return err
+	}
+	return db.Checkpoint()
+}
+
+// DropTablespace removes a tablespace, as DROP TABLESPACE says. INCLUDING CONTENTS also drops the
+// tables and indexes in it, and the indexes on those tables, from the catalog.
+func DropTablespace(stmt *ddl.DropTablespace) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if current == nil {
+		return errors.New("there is no database")
+	}
+	db := current.Database
+
+	ts, err := db.Tablespaces().Get(stmt.Name)
+	if err != nil {
+		return err
+	}
+	if stmt.Name == store.SystemTablespace {
+		return fmt.Errorf("the %v tablespace cannot be dropped", store.SystemTablespace)
+	}
+
+	var paths []string
+	for _, df := range ts.DataFiles() {
+		paths = append(paths, df.Path())
+	}
+
+	drop := func() error {
+		// the datafiles are deleted once the control file no longer has them
+		return db.Tablespaces().Drop(stmt.Name, stmt.Contents, false)
+	}
+	if stmt.Contents {
+		err = current.Catalog.drop(ts.DataFiles(), drop)
+	} else {
+		err = drop()
+	}
+	if err == nil {
+		err = db.Checkpoint()
+	}
+	if err != nil || !stmt.DataFiles {
+		return err
+	}
+
+	for _, path := range paths {
+		if e := os.Remove(path); err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/sql/token"
+	"github.com/djbckr/godb/store"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// statement tokenizes one statement.
+func statement(t *testing.T, sql string) *token.Statement {
+	statements, e := token.Split(sql)
+	if e != nil || len(statements) != 1 {
+		t.Fatalf("%v: %v", sql, e)
+	}
+	return statements[0]
+}
+
+func TestTablespaceDDL(t *testing.T) {
+	dir := t.TempDir()
+	initPath := filepath.Join(dir, "godb.ini")
+	path := func(name string) string { return filepath.Join(dir, name) }
+	t.Cleanup(func() { _ = Shutdown() })
+
+	stmt, e := createDatabase(dir)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = Start(initPath); e != nil {
+		t.Fatal(e)
+	}
+	if e = Create(stmt); e != nil {
+		t.Fatal(e)
+	}
+
+	run := func(sql string) error {
+		tokens := statement(t, sql).Tokens
+		switch tokens[0].Value {
+		case "CREATE":
+			create, e := ddl.ProcessCreateTablespace(tokens)
+			if e != nil {
+				t.Fatal(e)
+			}
+			return CreateTablespace(create)
+		case "ALTER":
+			alter, e := ddl.ProcessAlterTablespace(tokens)
+			if e != nil {
+				t.Fatal(e)
+			}
+			return AlterTablespace(alter)
+		default:
+			drop, e := ddl.ProcessDropTablespace(tokens)
+			if e != nil {
+				t.Fatal(e)
+			}
+			return DropTablespace(drop)
+		}
+	}
+	must := func(sql string) {
+		if e := run(sql); e != nil {
+			t.Fatalf("%v: %v", sql, e)
+		}
+	}
+	restart := func() {
+		if e := Shutdown(); e != nil {
+			t.Fatal(e)
+		}
+		if _, e := Start(initPath); e != nil {
+			t.Fatal(e)
+		}
+	}
+	tablespace := func(name string) *store.Tablespace {
+		ts, e := Current().Database.Tablespaces().Get(name)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return ts
+	}
+
+	must(fmt.Sprintf("create tablespace app datafile '%v' size 256k", path("app1.dbf")))
+	must(fmt.Sprintf("alter tablespace app add datafile '%v' size 128k autoextend on next 64k maxsize 1m", path("app2.dbf")))
+	must(fmt.Sprintf("alter tablespace app datafile '%v' resize 512k", path("app1.dbf")))
+	must(fmt.Sprintf("alter tablespace app datafile '%v' autoextend off", path("app2.dbf")))
+	must("alter tablespace app rename to app_data")
+	if e = run("create tablespace app_data datafile 'other.dbf'"); e == nil {
+		t.Error("expected an error making a tablespace twice")
+	}
+	for _, sql := range []string{"alter tablespace system offline", "alter tablespace system rename to sys", "drop tablespace system"} {
+		if e = run(sql); e == nil {
+			t.Errorf("%v: expected an error", sql)
+		}
+	}
+
+	// a table goes where its statement says, and an index of it is built from its rows
+	catalog := Current().Catalog
+	for _, sql := range []string{
+		"create table app_rows (a varchar(10), b integer) tablespace app_data",
+		"create index app_rows_b on app_rows (b desc)",
+		"create unique index [SYS].[_user_name] on [SYS].[_user] ([_name]) tablespace app_data",
+	} {
+		if e = catalog.Create(statement(t, sql)); e != nil {
+			t.Fatalf("%v: %v", sql, e)
+		}
+	}
+	if e = catalog.Create(statement(t, "create index app_rows_c on app_rows (c)")); e == nil {
+		t.Error("expected an error indexing a column the table does not have")
+	}
+	if e = catalog.Create(statement(t, "create table misplaced (a integer) tablespace temp")); e == nil {
+		t.Error("expected an error making a table in a temporary tablespace")
+	}
+
+	check := func() {
+		catalog := Current().Catalog
+		appFiles := tablespace("APP_DATA").DataFiles()
+		if len(appFiles) != 2 || appFiles[0].PageCount() != 512<<10/store.DefaultPageSize {
+			t.Fatalf("unexpected datafiles %v", appFiles)
+		}
+		if next, _ := appFiles[1].Autoextend(); next != 0 {
+			t.Errorf("expected %v not to grow, got %v", appFiles[1].Path(), next)
+		}
+		users := tablespace("USERS").DataFiles()[0].No()
+		for name, file := range map[string]store.FileNo{"APP_ROWS": appFiles[0].No(), "APP_ROWS_B": users, "_user_name": appFiles[0].No()} {
+			if obj := catalog.Object("SYS", name); obj == nil || obj.file != file {
+				t.Errorf("expected %v in datafile %v, got %+v", name, file, obj)
+			}
+		}
+		obj := catalog.Object("SYS", "_user_name")
+		if e := catalog.open(obj); e != nil {
+			t.Fatal(e)
+		}
+		if ids, e := obj.index.Lookup([]interface{}{[]byte("SYS")}); len(ids) != 1 || e != nil {
+			t.Errorf("expected the index to have SYS, got %v, %v", ids, e)
+		}
+	}
+	check()
+	restart()
+	check()
+
+	// what is in an offline tablespace is opened once it is online again
+	must("alter tablespace app_data offline")
+	restart()
+	if ok, e := Current().Catalog.Authenticate("sys", "change me"); !ok || e != nil {
+		t.Errorf("expected SYS to log in, got %v, %v", ok, e)
+	}
+	must("alter tablespace app_data read write")
+	check()
+	must("alter tablespace app_data read only")
+	if e = Current().Catalog.Create(statement(t, "create table more (a integer) tablespace app_data")); e == nil {
+		t.Error("expected an error making a table in a read only tablespace")
+	}
+	must("alter tablespace app_data online")
+
+	if e = run("drop tablespace app_data"); e == nil {
+		t.Error("expected an error dropping a tablespace that is not empty")
+	}
+	must("drop tablespace app_data including contents and datafiles")
+	for _, name := range []string{"APP_ROWS", "APP_ROWS_B", "_user_name"} {
+		if obj := Current().Catalog.Object("SYS", name); obj != nil {
+			t.Errorf("expected %v to be dropped, got %+v", name, obj)
+		}
+	}
+	for _, name := range []string{"app1.dbf", "app2.dbf"} {
+		if _, e = os.Stat(path(name)); !os.IsNotExist(e) {
+			t.Errorf("expected %v to be deleted, got %v", name, e)
+		}
+	}
+	restart()
+	if _, e = Current().Database.Tablespaces().Get("APP_DATA"); e == nil {
+		t.Error("expected APP_DATA to stay dropped")
+	}
+	if ok, e := Current().Catalog.Authenticate("sys", "change me"); !ok || e != nil {
+		t.Errorf("expected SYS to log in, got %v, %v", ok, e)
+	}
+}
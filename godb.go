@@ -1,11 +1,37 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/djbckr/godb/database"
 	_ "github.com/djbckr/godb/http"
 	"log"
 	"net/http"
 )
 
 func main() {
-	log.Fatal(http.ListenAndServeTLS(":9422", "/Users/dbecker/.ssh/alchemy.crt", "/Users/dbecker/.ssh/alchemy.key", nil))
+	initFile := flag.String("init", "godb.ini", "the init file")
+	// until CREATE DATABASE writes the init file, the server listens as these say
+	port := flag.Int("port", database.DefaultServerPort, "the port to serve on when there is no init file")
+	certFile := flag.String("cert", "/Users/dbecker/.ssh/alchemy.crt", "the certificate to serve with when there is no init file")
+	keyFile := flag.String("key", "/Users/dbecker/.ssh/alchemy.key", "the key of the certificate when there is no init file")
+	flag.Parse()
+
+	params, err := database.Start(*initFile)
+	if errors.Is(err, database.ErrNoControlFile) {
+		log.Print(err)
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if params == nil {
+		log.Printf("%v not found: waiting for CREATE DATABASE", *initFile)
+	} else {
+		*port = params.ServerPort
+		if params.CertFile != "" {
+			*certFile, *keyFile = params.CertFile, params.KeyFile
+		}
+	}
+
+	log.Fatal(http.ListenAndServeTLS(fmt.Sprintf(":%v", *port), *certFile, *keyFile, nil))
 }
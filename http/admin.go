@@ -0,0 +1,64 @@
+package http
+
+import (
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/sql/types"
+	"net/http"
+	"strconv"
+)
+
+/*
+A GET of /admin answers with one row, in the format the request accepts, of what the buffer pool has
+done since the server started, and what it holds now:
+
+  hits        reads of a page that found it in the pool
+  misses      reads of a page that had to read it from its datafile
+  hitRatio    hits / (hits + misses); 0 until a page has been read
+  evictions   pages dropped to make room for others
+  writeBacks  changed pages written to their datafiles
+  capacity    how many pages the pool holds at most
+  resident    how many it holds now
+  pinned      how many of those are in use
+  dirty       how many of those are changed and not yet written
+*/
+
+// admin answers with the statistics of the buffer pool.
+func admin(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType := negotiate(req.Header.Get("Accept"))
+	if mediaType == "" {
+		newResponse(w, defaultMediaType).fail(http.StatusUnsupportedMediaType, codeMediaType,
+			"the response can be "+mediaTypes())
+		return
+	}
+	rsp := newResponse(w, mediaType)
+
+	db := database.Current()
+	if db == nil {
+		rsp.fail(http.StatusServiceUnavailable, codeInvalid, "there is no database")
+		return
+	}
+	stats := db.Database.Pool().Stats()
+	ratio, err := types.ParseNumber(strconv.FormatFloat(stats.HitRatio(), 'f', -1, 64))
+	if err != nil {
+		rsp.fail(http.StatusInternalServerError, codeInvalid, err.Error())
+		return
+	}
+
+	var fields []*Field
+	for _, name := range []string{"hits", "misses", "hitRatio", "evictions", "writeBacks", "capacity", "resident", "pinned", "dirty"} {
+		fields = append(fields, &Field{Name: name, Type: "number"})
+	}
+	row := []interface{}{
+		int64(stats.Hits), int64(stats.Misses), ratio, int64(stats.Evictions), int64(stats.WriteBacks),
+		int64(stats.Capacity), int64(stats.Resident), int64(stats.Pinned), int64(stats.Dirty),
+	}
+	if rsp.head(http.StatusOK, codeSuccess, "Success", &Meta{Fields: fields}) == nil && rsp.row(row) == nil {
+		_ = rsp.end()
+	}
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/djbckr/godb/database"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	get := func(method string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin", nil)
+		req.Header.Set("Accept", "application/json")
+		rsp := httptest.NewRecorder()
+		admin(rsp, req)
+		return rsp
+	}
+
+	if rsp := get(http.MethodGet); rsp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected no statistics before there is a database, got %v %v", rsp.Code, rsp.Body)
+	}
+	startDatabase(t)
+	if rsp := get(http.MethodPost); rsp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected only GET, got %v", rsp.Code)
+	}
+
+	rsp := get(http.MethodGet)
+	var body struct {
+		Code int
+		Data []map[string]float64
+	}
+	if e := json.Unmarshal(rsp.Body.Bytes(), &body); e != nil || rsp.Code != http.StatusOK || len(body.Data) != 1 {
+		t.Fatalf("unexpected %v %v: %v", rsp.Code, rsp.Body, e)
+	}
+	stats := body.Data[0]
+	if stats["capacity"] != float64(database.PoolPages) || stats["resident"] == 0 || stats["hits"]+stats["misses"] == 0 {
+		t.Errorf("unexpected statistics %v", stats)
+	}
+	if ratio := stats["hitRatio"]; ratio != stats["hits"]/(stats["hits"]+stats["misses"]) {
+		t.Errorf("expected the hit ratio of %v, got %v", stats, ratio)
+	}
+}
@@ -0,0 +1,84 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/session"
+	"net/http"
+	"strings"
+)
+
+/*
+Every request but a login must name its session with the header
+
+  Authorization: AuthToken xxx SessionID yyy
+
+A request without it, or whose session has closed or whose AuthToken has expired, gets a 401 and must
+log in again. A session runs one /sql request at a time: another that comes while one is running gets
+a 409. Until CREATE DATABASE has made the database, or CREATE CONTROLFILE has opened one whose
+control file was lost, no one can log in, so /sql then runs without a session, and runs nothing but
+those two.
+*/
+
+type contextKey int
+
+const sessionKey contextKey = 0
+
+// authorize runs a handler only for a request of an open session.
+func authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(rsp http.ResponseWriter, req *http.Request) {
+		s := authenticate(req.Header.Get("Authorization"))
+		if s == nil {
+			rsp.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(rsp, req.WithContext(context.WithValue(req.Context(), sessionKey, s)))
+	}
+}
+
+// exclusive runs a handler of an authorized request only if its session is not running another.
+func exclusive(next http.HandlerFunc) http.HandlerFunc {
+	return func(rsp http.ResponseWriter, req *http.Request) {
+		s := sessionOf(req)
+		if !s.Begin() {
+			http.Error(rsp, "the session is already running a request", http.StatusConflict)
+			return
+		}
+		defer s.End()
+		next(rsp, req)
+	}
+}
+
+// bootstrap runs noDatabase rather than next while there is no database.
+func bootstrap(next, noDatabase http.HandlerFunc) http.HandlerFunc {
+	return func(rsp http.ResponseWriter, req *http.Request) {
+		if database.Current() == nil {
+			noDatabase(rsp, req)
+			return
+		}
+		next(rsp, req)
+	}
+}
+
+// sessionOf is the session of a request authorize has let through.
+func sessionOf(req *http.Request) *session.Session {
+	s, _ := req.Context().Value(sessionKey).(*session.Session)
+	return s
+}
+
+// authenticate finds the session an Authorization header names, or returns nil if it names none that
+// is open.
+func authenticate(header string) *session.Session {
+	// the header is sometimes sent quoted, as the docs show it
+	fields := strings.Fields(strings.Trim(header, `'"`))
+	if len(fields) != 4 || !strings.EqualFold(fields[0], "AuthToken") || !strings.EqualFold(fields[2], "SessionID") {
+		return nil
+	}
+
+	s := session.SessionById(fields[3])
+	if s == nil || subtle.ConstantTimeCompare([]byte(s.AuthToken()), []byte(fields[1])) != 1 {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/session"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthorize(t *testing.T) {
+	sql := bootstrap(authorize(exclusive(execute)), execute)
+	run := func(authorization, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rsp := httptest.NewRecorder()
+		sql(rsp, req)
+		return rsp
+	}
+	query := `{"sql": "select 1 from dual"}`
+
+	// before there is a database, /sql runs CREATE DATABASE, and nothing else, without a session
+	dir := t.TempDir()
+	t.Cleanup(func() { _ = database.Shutdown() })
+	if _, e := database.Start(filepath.Join(dir, "godb.ini")); e != nil {
+		t.Fatal(e)
+	}
+	if rsp := run("", query); rsp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected only CREATE DATABASE or CREATE CONTROLFILE to run, got %v %v", rsp.Code, rsp.Body)
+	}
+	create, _ := json.Marshal(map[string]string{"sql": createDatabase(dir)})
+	if rsp := run("", string(create)); rsp.Code != http.StatusOK {
+		t.Fatalf("expected CREATE DATABASE to run, got %v %v", rsp.Code, rsp.Body)
+	}
+	if rsp := run("", string(create)); rsp.Code != http.StatusUnauthorized {
+		t.Errorf("expected a session to be needed once there is a database, got %v %v", rsp.Code, rsp.Body)
+	}
+
+	rsp := post(login, http.MethodPost, "application/json", `{"username": "sys", "password": "change me"}`)
+	header := rsp.Header().Get("Authorization")
+	var authToken, sessionID string
+	if _, e := fmt.Sscanf(header, "AuthToken %s SessionID %s", &authToken, &sessionID); e != nil {
+		t.Fatalf("unexpected login %v %v: %v", rsp.Code, rsp.Header(), e)
+	}
+
+	for _, test := range []struct {
+		authorization string
+		status        int
+	}{
+		{header, http.StatusOK},
+		{"'" + header + "'", http.StatusOK},
+		{strings.ToLower(header[:9]) + header[9:], http.StatusOK},
+		{"", http.StatusUnauthorized},
+		{"Bearer " + authToken, http.StatusUnauthorized},
+		{"AuthToken " + authToken, http.StatusUnauthorized},
+		{"AuthToken " + authToken + " SessionID 0123", http.StatusUnauthorized},
+		{"AuthToken 0123 SessionID " + sessionID, http.StatusUnauthorized},
+		{"SessionID " + sessionID + " AuthToken " + authToken, http.StatusUnauthorized},
+	} {
+		if rsp := run(test.authorization, query); rsp.Code != test.status {
+			t.Errorf("%q: expected %v, got %v", test.authorization, test.status, rsp.Code)
+		}
+	}
+
+	// one /sql at a time on a session, but other sessions of the same AuthToken may run
+	s := session.SessionById(sessionID)
+	if !s.Begin() {
+		t.Fatal("expected the session to be idle")
+	}
+	if rsp := run(header, query); rsp.Code != http.StatusConflict {
+		t.Errorf("expected a second request on the session to conflict, got %v", rsp.Code)
+	}
+	other := post(login, http.MethodPost, "application/json", fmt.Sprintf(`{"AuthToken": "%v"}`, authToken))
+	if rsp := run(other.Header().Get("Authorization"), query); rsp.Code != http.StatusOK {
+		t.Errorf("expected another session to run, got %v", rsp.Code)
+	}
+	s.End()
+	if rsp := run(header, query); rsp.Code != http.StatusOK {
+		t.Errorf("expected the session to run once the first request ended, got %v", rsp.Code)
+	}
+
+	// the other endpoints need a session too
+	for _, handler := range []http.HandlerFunc{authorize(root), authorize(admin)} {
+		for authorization, status := range map[string]int{header: http.StatusOK, "": http.StatusUnauthorized} {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", authorization)
+			rsp := httptest.NewRecorder()
+			handler(rsp, req)
+			if rsp.Code != status {
+				t.Errorf("%q: expected %v, got %v", authorization, status, rsp.Code)
+			}
+		}
+	}
+}
+
+func TestRebuildControlFile(t *testing.T) {
+	sql := bootstrap(authorize(exclusive(execute)), execute)
+	run := func(body string) *httptest.ResponseRecorder {
+		text, _ := json.Marshal(map[string]string{"sql": body})
+		req := httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(string(text)))
+		req.Header.Set("Content-Type", "application/json")
+		rsp := httptest.NewRecorder()
+		sql(rsp, req)
+		return rsp
+	}
+	dir := t.TempDir()
+	initPath := filepath.Join(dir, "godb.ini")
+	path := func(name string) string { return filepath.Join(dir, name) }
+	t.Cleanup(func() { _ = database.Shutdown() })
+	if _, e := database.Start(initPath); e != nil {
+		t.Fatal(e)
+	}
+	if rsp := run(createDatabase(dir)); rsp.Code != http.StatusOK {
+		t.Fatalf("expected CREATE DATABASE to run, got %v %v", rsp.Code, rsp.Body)
+	}
+	if e := database.Current().Catalog.CreateUser("scott", "tiger"); e != nil {
+		t.Fatal(e)
+	}
+	if e := database.Shutdown(); e != nil {
+		t.Fatal(e)
+	}
+
+	// with every copy of the control file gone, the server waits for CREATE CONTROLFILE
+	if e := os.Remove(path("control.ctl")); e != nil {
+		t.Fatal(e)
+	}
+	if params, e := database.Start(initPath); params == nil || e != database.ErrNoControlFile || database.Current() != nil {
+		t.Fatalf("expected the control file to be lost, got %+v, %v", params, e)
+	}
+	if rsp := run("select 1 from dual"); rsp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected only CREATE CONTROLFILE to run, got %v %v", rsp.Code, rsp.Body)
+	}
+	if rsp := run(createDatabase(dir)); rsp.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected CREATE DATABASE to fail, got %v %v", rsp.Code, rsp.Body)
+	}
+	control := fmt.Sprintf(`create controlfile database orcl
+		logfile group 1 ('%v'), group 2 ('%v')
+		noresetlogs
+		datafile '%v', '%v'`, path("redo1.log"), path("redo2.log"), path("system.dbf"), path("undo.dbf"))
+	if rsp := run(control); rsp.Code != http.StatusOK {
+		t.Fatalf("expected CREATE CONTROLFILE to run, got %v %v", rsp.Code, rsp.Body)
+	}
+	if _, e := os.Stat(path("control.ctl")); e != nil {
+		t.Errorf("expected the control file to be made again: %v", e)
+	}
+	if ok, e := database.Current().Catalog.Authenticate("scott", "tiger"); !ok || e != nil {
+		t.Errorf("expected SCOTT to log in, got %v, %v", ok, e)
+	}
+	if rsp := run(control); rsp.Code != http.StatusUnauthorized {
+		t.Errorf("expected a session to be needed once the database is open, got %v %v", rsp.Code, rsp.Body)
+	}
+
+	// and it opens as usual from then on
+	if e := database.Shutdown(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := database.Start(initPath); e != nil || database.Current() == nil {
+		t.Errorf("expected the database to open, got %v", e)
+	}
+}
@@ -0,0 +1,562 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/sql/types"
+	"io"
+	"mime"
+	"strings"
+)
+
+/*
+A /sql request is read as it arrives. Head reads everything before the data section - sql or sqlid,
+and meta - and then Next reads the data one set of bind values at a time, so the data can be as
+large as the client likes. The data must therefore come last.
+
+In JSON, data is one object, or an array of them:
+
+  {"sql": "...", "meta": {"binds": [{"name": "updated", "type": "timestamp", "format": "..."}]},
+   "data": [{"updated": "2020-01-01T00:00:00"}, {"updated": null}]}
+
+A value is a string, a number, true, false or null; a number keeps its text exactly as sent.
+
+In XML, the root is <godb>, each bind of meta is an element named after it, and there is a <data>
+element for each set of values:
+
+  <godb><sql>...</sql>
+    <meta><binds><updated type="timestamp" format="..."/></binds></meta>
+    <data><updated>2020-01-01T00:00:00</updated></data>
+    <data><updated null="true"/></data>
+  </godb>
+
+A positional bind is named by its number: "1" in JSON, <1> in XML.
+*/
+
+// Request is what a /sql request says before its data.
+type Request struct {
+	SQL   string
+	SQLID string
+	Binds []*Bind
+}
+
+// Bind is how a bind's values are given.
+type Bind struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Format string `json:"format"`
+}
+
+// Values are one set of values from the data section, by bind name. A nil value is NULL.
+type Values map[string]*string
+
+// typed gives each value the type and format its bind has in meta; a value whose bind is not in
+// meta stays a string.
+func (v Values) typed(binds []*Bind) (map[string]interface{}, error) {
+	typed := make(map[string]interface{}, len(v))
+	for name, text := range v {
+		if text == nil {
+			typed[name] = nil
+			continue
+		}
+		var value interface{} = *text
+		for _, bind := range binds {
+			if strings.EqualFold(bind.Name, name) {
+				var err error
+				if value, err = types.ParseValue(*text, bind.Type, bind.Format); err != nil {
+					return nil, unprocessablef("bind %v: %v", name, err)
+				}
+				break
+			}
+		}
+		typed[name] = value
+	}
+	return typed, nil
+}
+
+// Decoder reads a /sql request body.
+type Decoder interface {
+	// Head reads the request up to its data.
+	Head() (*Request, error)
+	// Next reads the next set of values, or returns io.EOF when there are no more.
+	Next() (Values, error)
+}
+
+// unprocessable is a request that is well formed, but does not say what /sql needs.
+type unprocessable string
+
+func (u unprocessable) Error() string {
+	return string(u)
+}
+
+func unprocessablef(format string, args ...interface{}) error {
+	return unprocessable(fmt.Sprintf(format, args...))
+}
+
+// errUnsupportedMedia is returned by NewDecoder for a content type other than JSON or XML.
+var errUnsupportedMedia = errors.New("unsupported media type")
+
+// NewDecoder makes a decoder for a request body of the given content type.
+func NewDecoder(contentType string, body io.Reader) (Decoder, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errUnsupportedMedia
+	}
+	switch mediaType {
+	case "application/json":
+		dec := json.NewDecoder(body)
+		dec.UseNumber()
+		return &jsonDecoder{dec: dec}, nil
+	case "application/xml", "text/xml":
+		return &xmlDecoder{dec: xml.NewDecoder(&numberedNames{r: bufio.NewReader(body)})}, nil
+	}
+	return nil, errUnsupportedMedia
+}
+
+type jsonDecoder struct {
+	dec   *json.Decoder
+	array bool // the data is an array of objects
+	first bool // the opening of the first object has been read
+	done  bool
+}
+
+func (d *jsonDecoder) Head() (*Request, error) {
+	req := &Request{}
+	if err := d.delim('{'); err != nil {
+		return nil, err
+	}
+
+	for d.dec.More() {
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case "sql":
+			err = d.string(&req.SQL)
+		case "sqlid":
+			err = d.string(&req.SQLID)
+		case "meta":
+			var meta struct {
+				Binds []*Bind `json:"binds"`
+			}
+			if err = d.dec.Decode(&meta); err == nil {
+				req.Binds = meta.Binds
+			}
+		case "data":
+			var tkn json.Token
+			if tkn, err = d.dec.Token(); err != nil {
+				return nil, err
+			}
+			switch tkn {
+			case json.Delim('{'):
+				d.first = true
+			case json.Delim('['):
+				d.array = true
+			default:
+				return nil, unprocessablef("data must be an object or an array of objects")
+			}
+			return req, nil
+		default:
+			err = unprocessablef("unexpected %q: a request has sql, sqlid, meta and data", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return req, d.noData()
+}
+
+func (d *jsonDecoder) Next() (Values, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+
+	if d.array {
+		if !d.dec.More() {
+			if err := d.delim(']'); err != nil {
+				return nil, err
+			}
+			if d.dec.More() {
+				return nil, unprocessablef("data must come last")
+			}
+			return nil, d.end()
+		}
+		if err := d.delim('{'); err != nil {
+			return nil, unprocessablef("data must be an object or an array of objects")
+		}
+	} else if !d.first {
+		if d.dec.More() {
+			return nil, unprocessablef("data must come last")
+		}
+		return nil, d.end()
+	}
+	d.first = false
+
+	values := make(Values)
+	for d.dec.More() {
+		key, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		tkn, err := d.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		var value string
+		switch v := tkn.(type) {
+		case nil:
+			values[key] = nil
+			continue
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = fmt.Sprint(v)
+		default:
+			return nil, unprocessablef("the value of %v must be a string, a number, true, false or null", key)
+		}
+		values[key] = &value
+	}
+
+	return values, d.delim('}')
+}
+
+// end reads the end of the request, and checks that nothing follows it.
+func (d *jsonDecoder) end() error {
+	if err := d.delim('}'); err != nil {
+		return err
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		return errors.New("unexpected text after the request")
+	}
+	d.done = true
+	return io.EOF
+}
+
+// noData reads the end of a request without data.
+func (d *jsonDecoder) noData() error {
+	if err := d.end(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+func (d *jsonDecoder) delim(delim json.Delim) error {
+	tkn, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	if tkn != delim {
+		return fmt.Errorf("'%v' expected", delim)
+	}
+	return nil
+}
+
+func (d *jsonDecoder) key() (string, error) {
+	tkn, err := d.dec.Token()
+	if err != nil {
+		return "", err
+	}
+	return tkn.(string), nil
+}
+
+func (d *jsonDecoder) string(s *string) error {
+	tkn, err := d.dec.Token()
+	if err != nil {
+		return err
+	}
+	var ok bool
+	if *s, ok = tkn.(string); !ok {
+		return unprocessablef("a string expected")
+	}
+	return nil
+}
+
+type xmlDecoder struct {
+	dec  *xml.Decoder
+	done bool
+	data bool // the opening of a data element has been read
+}
+
+func (d *xmlDecoder) Head() (*Request, error) {
+	req := &Request{}
+
+	root, err := d.start()
+	if err != nil {
+		return nil, err
+	}
+	if root == nil || root.Name.Local != "godb" {
+		return nil, unprocessablef("the root element must be <godb>")
+	}
+
+	for {
+		elem, err := d.start()
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			return req, d.noData()
+		}
+
+		switch elem.Name.Local {
+		case "sql":
+			req.SQL, err = d.text()
+		case "sqlid":
+			req.SQLID, err = d.text()
+		case "meta":
+			req.Binds, err = d.meta()
+		case "data":
+			d.data = true
+			return req, nil
+		default:
+			err = unprocessablef("unexpected <%v>: a request has sql, sqlid, meta and data", elem.Name.Local)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (d *xmlDecoder) Next() (Values, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+
+	if !d.data {
+		elem, err := d.start()
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			return nil, d.end()
+		}
+		if elem.Name.Local != "data" {
+			return nil, unprocessablef("data must come last")
+		}
+	}
+	d.data = false
+
+	values := make(Values)
+	for {
+		elem, err := d.start()
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			return values, nil
+		}
+
+		null := false
+		for _, attr := range elem.Attr {
+			if attr.Name.Local == "null" && attr.Value == "true" {
+				null = true
+			}
+		}
+		value, err := d.text()
+		if err != nil {
+			return nil, err
+		}
+		if null {
+			values[bindName(elem.Name)] = nil
+		} else {
+			values[bindName(elem.Name)] = &value
+		}
+	}
+}
+
+// meta reads the meta element.
+func (d *xmlDecoder) meta() ([]*Bind, error) {
+	var binds []*Bind
+	for {
+		elem, err := d.start()
+		if err != nil || elem == nil {
+			return binds, err
+		}
+		if elem.Name.Local != "binds" {
+			if err = d.dec.Skip(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		for {
+			bind, err := d.start()
+			if err != nil {
+				return nil, err
+			}
+			if bind == nil {
+				break
+			}
+			b := &Bind{Name: bindName(bind.Name)}
+			for _, attr := range bind.Attr {
+				switch attr.Name.Local {
+				case "type":
+					b.Type = attr.Value
+				case "format":
+					b.Format = attr.Value
+				}
+			}
+			binds = append(binds, b)
+			if err = d.dec.Skip(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// start reads up to the next element, and returns it, or nil if the element it is in ends first.
+func (d *xmlDecoder) start() (*xml.StartElement, error) {
+	for {
+		tkn, err := d.dec.Token()
+		if err == io.EOF {
+			return nil, errors.New("the request ends too soon")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tkn.(type) {
+		case xml.StartElement:
+			return &t, nil
+		case xml.EndElement:
+			return nil, nil
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" {
+				return nil, unprocessablef("unexpected text %q", strings.TrimSpace(string(t)))
+			}
+		}
+	}
+}
+
+// text reads the text of an element, up to and including its end.
+func (d *xmlDecoder) text() (string, error) {
+	var text strings.Builder
+	for {
+		tkn, err := d.dec.Token()
+		if err != nil {
+			return "", err
+		}
+
+		switch t := tkn.(type) {
+		case xml.CharData:
+			text.Write(t)
+		case xml.StartElement:
+			return "", unprocessablef("<%v> cannot hold other elements", t.Name.Local)
+		case xml.EndElement:
+			return text.String(), nil
+		}
+	}
+}
+
+// noData reads the end of a request without data.
+func (d *xmlDecoder) noData() error {
+	if err := d.end(); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// end checks that nothing but white space follows the root element.
+func (d *xmlDecoder) end() error {
+	for {
+		tkn, err := d.dec.Token()
+		if err == io.EOF {
+			d.done = true
+			return io.EOF
+		}
+		if err != nil {
+			return err
+		}
+		if data, ok := tkn.(xml.CharData); !ok || strings.TrimSpace(string(data)) != "" {
+			if _, ok = tkn.(xml.Comment); !ok {
+				return errors.New("unexpected text after the request")
+			}
+		}
+	}
+}
+
+// bindName is the name of the bind an element holds: numberedNames makes <1> into <_1>.
+func bindName(name xml.Name) string {
+	if len(name.Local) > 1 && name.Local[0] == '_' && isDigit(name.Local[1]) {
+		return name.Local[1:]
+	}
+	return name.Local
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+/*
+numberedNames lets a positional bind be an element named by its number, such as <1>, which XML does
+not allow: it puts an underscore before the number of such tags, leaving CDATA, comments and
+processing instructions alone.
+*/
+type numberedNames struct {
+	r       *bufio.Reader
+	end     string // the end of the CDATA, comment or processing instruction being read
+	pending []byte
+}
+
+func (x *numberedNames) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(x.pending) > 0 {
+			c := copy(p[n:], x.pending)
+			x.pending = x.pending[c:]
+			n += c
+			continue
+		}
+
+		// only block for more input if there is nothing to return yet
+		if n > 0 && x.r.Buffered() == 0 {
+			break
+		}
+		b, err := x.r.ReadByte()
+		if err != nil {
+			if n > 0 {
+				break
+			}
+			return 0, err
+		}
+		p[n] = b
+		n++
+
+		if x.end != "" {
+			if b == x.end[0] {
+				if next, _ := x.r.Peek(len(x.end) - 1); string(next) == x.end[1:] {
+					x.end = ""
+				}
+			}
+			continue
+		}
+		if b != '<' {
+			continue
+		}
+
+		next, _ := x.r.Peek(8)
+		switch {
+		case bytes.HasPrefix(next, []byte("![CDATA[")):
+			x.end = "]]>"
+		case bytes.HasPrefix(next, []byte("!--")):
+			x.end = "-->"
+		case bytes.HasPrefix(next, []byte("?")):
+			x.end = "?>"
+		case len(next) > 0 && isDigit(next[0]):
+			x.pending = []byte("_")
+		case len(next) > 1 && next[0] == '/' && isDigit(next[1]):
+			_, _ = x.r.Discard(1)
+			x.pending = []byte("/_")
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,236 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/djbckr/godb/database"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// decodeAll reads a whole request.
+func decodeAll(contentType, body string) (*Request, []Values, error) {
+	decoder, e := NewDecoder(contentType, strings.NewReader(body))
+	if e != nil {
+		return nil, nil, e
+	}
+	head, e := decoder.Head()
+	if e != nil {
+		return nil, nil, e
+	}
+	var data []Values
+	for {
+		values, e := decoder.Next()
+		if e == io.EOF {
+			return head, data, nil
+		}
+		if e != nil {
+			return nil, nil, e
+		}
+		data = append(data, values)
+	}
+}
+
+func value(s string) *string {
+	return &s
+}
+
+func TestDecode(t *testing.T) {
+	head := &Request{
+		SQL:   "select * from some_table where updated >= :updated",
+		Binds: []*Bind{{Name: "updated", Type: "timestamp", Format: "YYYY-MM-DDTHH:mm:SS"}},
+	}
+
+	for _, test := range []struct {
+		contentType string
+		body        string
+		data        []Values
+	}{
+		{"application/json", `{
+			"sql": "select * from some_table where updated >= :updated",
+			"meta": {"binds": [{"name": "updated", "type": "timestamp", "format": "YYYY-MM-DDTHH:mm:SS"}]},
+			"data": {"updated": "2020-01-01T00:00:00"}
+		}`, []Values{{"updated": value("2020-01-01T00:00:00")}}},
+		{"application/json; charset=utf-8", `{
+			"meta": {"binds": [{"name": "updated", "type": "timestamp", "format": "YYYY-MM-DDTHH:mm:SS"}]},
+			"sql": "select * from some_table where updated >= :updated",
+			"data": [{"updated": "2020-01-01T00:00:00", "n": 12345678901234567890.5, "b": true}, {"updated": null}, {}]
+		}`, []Values{
+			{"updated": value("2020-01-01T00:00:00"), "n": value("12345678901234567890.5"), "b": value("true")},
+			{"updated": nil},
+			{},
+		}},
+		{"application/xml", `<?xml version="1.0" encoding="UTF-8"?>
+			<godb>
+				<sql><![CDATA[select * from some_table where updated >= :updated]]></sql>
+				<meta>
+					<binds><updated type="timestamp" format="YYYY-MM-DDTHH:mm:SS" /></binds>
+					<other><a/></other>
+				</meta>
+				<data><updated>2020-01-01T00:00:00</updated></data>
+				<data><updated null="true"/></data>
+				<data></data>
+			</godb>`, []Values{{"updated": value("2020-01-01T00:00:00")}, {"updated": nil}, {}}},
+	} {
+		req, data, e := decodeAll(test.contentType, test.body)
+		if e != nil {
+			t.Errorf("%v: %v", test.body, e)
+			continue
+		}
+		if !reflect.DeepEqual(req, head) {
+			t.Errorf("expected %+v, got %+v", head, req)
+		}
+		if !reflect.DeepEqual(data, test.data) {
+			t.Errorf("expected %v, got %v", test.data, data)
+		}
+	}
+
+	// no data, and positional binds
+	req, data, e := decodeAll("text/xml", `<godb><sql>select ? from dual</sql><data><1>x</1></data></godb>`)
+	if e != nil || req.SQL != "select ? from dual" || len(data) != 1 || *data[0]["1"] != "x" {
+		t.Errorf("unexpected %+v, %v, %v", req, data, e)
+	}
+	req, _, e = decodeAll("application/xml", `<godb><!-- <2> --><sql><![CDATA[select ? from t where a <1]]></sql></godb>`)
+	if e != nil || req.SQL != "select ? from t where a <1" {
+		t.Errorf("unexpected %+v, %v", req, e)
+	}
+	req, data, e = decodeAll("application/json", `{"sql": "commit"}`)
+	if e != nil || req.SQL != "commit" || len(data) != 0 {
+		t.Errorf("unexpected %+v, %v, %v", req, data, e)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, e := NewDecoder("text/plain", strings.NewReader("")); e != errUnsupportedMedia {
+		t.Errorf("expected an unsupported media type, got %v", e)
+	}
+
+	for _, test := range []struct {
+		contentType   string
+		body          string
+		unprocessable bool
+	}{
+		{"application/json", `{"sql": "commit"`, false},
+		{"application/json", `{"sql": "commit"} {}`, false},
+		{"application/json", `["commit"]`, false},
+		{"application/json", `{"sql": 1}`, true},
+		{"application/json", `{"query": "commit"}`, true},
+		{"application/json", `{"data": "x"}`, true},
+		{"application/json", `{"data": [{"a": 1}, 2]}`, true},
+		{"application/json", `{"data": {"a": [1]}}`, true},
+		{"application/json", `{"data": {"a": 1}, "sql": "commit"}`, true},
+		{"application/json", `{"data": [{"a": 1}], "sql": "commit"}`, true},
+		{"application/xml", `<godb><sql>commit</sql>`, false},
+		{"application/xml", `<godb><sql>commit</sql></godb><godb/>`, false},
+		{"application/xml", `<request><sql>commit</sql></request>`, true},
+		{"application/xml", `<godb><query>commit</query></godb>`, true},
+		{"application/xml", `<godb><sql>commit<b/></sql></godb>`, true},
+		{"application/xml", `<godb>commit</godb>`, true},
+		{"application/xml", `<godb><data><a>1</a></data><sql>commit</sql></godb>`, true},
+	} {
+		_, _, e := decodeAll(test.contentType, test.body)
+		if e == nil {
+			t.Errorf("expected error: %v", test.body)
+			continue
+		}
+		if _, ok := e.(unprocessable); ok != test.unprocessable {
+			t.Errorf("%v: unexpected error %v", test.body, e)
+		}
+	}
+}
+
+func TestExecute(t *testing.T) {
+	startDatabase(t)
+
+	for _, test := range []struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{http.MethodGet, "application/json", `{"sql": "select 1 from dual"}`, http.StatusMethodNotAllowed},
+		{http.MethodPost, "text/plain", `select 1 from dual`, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "application/json", `{"sql": "select 1 from dual"`, http.StatusBadRequest},
+		{http.MethodPost, "application/json", `{}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sqlid": "84c8e7f9"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select from"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual"}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual where 1 = 0", "data": [{"A": 1}, {"b": 2}]}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual", "data": [{"A": 1}, {"a": null}]}`, http.StatusOK},
+		{http.MethodPut, "application/xml", `<godb><sql>select ? from dual</sql><data><1>x</1></data></godb>`, http.StatusOK},
+		{http.MethodPost, "application/json", `{"sql": "select 1 from dual; select :a from dual;", "data": {"a": 1}}`, http.StatusOK},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual", "meta": {"binds": [{"name": "a", "type": "number"}]}, "data": {"a": "x"}}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual", "meta": {"binds": [{"name": "a", "type": "number"}]}, "data": [{"a": 1.50}, {"a": null}]}`, http.StatusOK},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual", "meta": {"binds": [{"name": "a", "type": "integer"}]}, "data": {"a": 1}}`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select :a from dual", "meta": {"binds": [{"name": "a", "format": "YYYY"}]}, "data": {"a": "2020"}}`, http.StatusUnprocessableEntity},
+		{http.MethodPut, "application/xml", `<godb><sql>select :d from dual</sql><meta><binds><d type="timestamp" format="DD/MM/YYYY"/></binds></meta><data><d>31/12/1999</d></data></godb>`, http.StatusOK},
+		{http.MethodPut, "application/xml", `<godb><sql>select :d from dual</sql><meta><binds><d type="timestamp" format="DD/MM/YYYY"/></binds></meta><data><d>1999-12-31</d></data></godb>`, http.StatusUnprocessableEntity},
+		{http.MethodPost, "application/json", `{"sql": "select :b from dual", "meta": {"binds": [{"name": "B", "type": "boolean"}]}, "data": {"b": true}}`, http.StatusOK},
+	} {
+		req := httptest.NewRequest(test.method, "/sql", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		rsp := httptest.NewRecorder()
+		execute(rsp, req)
+		if rsp.Code != test.status {
+			t.Errorf("%v: expected %v, got %v %v", test.body, test.status, rsp.Code, rsp.Body)
+		}
+	}
+
+	// once rows have gone out, a set of values that fails leaves the response unfinished
+	req := httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(`{"sql": "select :a from dual", "data": [{"a": 1}, {"b": 2}]}`))
+	req.Header.Set("Content-Type", "application/json")
+	rsp := httptest.NewRecorder()
+	execute(rsp, req)
+	if rsp.Code != http.StatusOK || json.Valid(rsp.Body.Bytes()) || !strings.Contains(rsp.Body.String(), `"data":[{"COLUMN1":"1"}`) {
+		t.Errorf("expected one row and no end, got %v %v", rsp.Code, rsp.Body)
+	}
+
+	// a script stops at the statement that fails, and says which it was
+	req = httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(`{"sql": "select 1 from dual;\nselect from dual;\nselect 2 from dual"}`))
+	req.Header.Set("Content-Type", "application/json")
+	rsp = httptest.NewRecorder()
+	execute(rsp, req)
+	if rsp.Code != http.StatusUnprocessableEntity || !strings.Contains(rsp.Body.String(), "statement 2 at line 2, column 1:") {
+		t.Errorf("expected statement 2 to fail, got %v %v", rsp.Code, rsp.Body)
+	}
+}
+
+func TestExecuteDDL(t *testing.T) {
+	startDatabase(t)
+	path := filepath.Join(t.TempDir(), "app.dbf")
+
+	for _, test := range []struct {
+		sql    string
+		status int
+	}{
+		{fmt.Sprintf("create tablespace app datafile '%v' size 256k", path), http.StatusOK},
+		{"create table app_rows (a integer) tablespace app; create index app_rows_a on app_rows (a)", http.StatusOK},
+		{"create table app_rows (a integer)", http.StatusUnprocessableEntity},
+		{"create table elsewhere (a integer) tablespace nowhere", http.StatusUnprocessableEntity},
+		{"alter tablespace app read only", http.StatusOK},
+		{"drop tablespace app", http.StatusUnprocessableEntity},
+		{"drop tablespace app including contents and datafiles", http.StatusOK},
+	} {
+		body, _ := json.Marshal(map[string]string{"sql": test.sql})
+		req := httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		rsp := httptest.NewRecorder()
+		execute(rsp, req)
+		if rsp.Code != test.status {
+			t.Errorf("%v: expected %v, got %v %v", test.sql, test.status, rsp.Code, rsp.Body)
+		}
+	}
+
+	if obj := database.Current().Catalog.Object("SYS", "APP_ROWS"); obj != nil {
+		t.Errorf("expected APP_ROWS to be dropped with its tablespace, got %+v", obj)
+	}
+	if _, e := os.Stat(path); !os.IsNotExist(e) {
+		t.Errorf("expected %v to be deleted, got %v", path, e)
+	}
+}
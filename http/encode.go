@@ -0,0 +1,441 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/store"
+	"github.com/djbckr/godb/wire"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+A response is written as it is made: Head writes the code, message and meta, and then Row writes each
+row of the data as the statement produces it, so that a client can start on the rows before the last
+one is ready. The format is the one the Accept header asks for, whatever the format of the request.
+*/
+
+// Field is a column of a result.
+type Field struct {
+	Name   string `json:"name" xml:"name,attr"`
+	Type   string `json:"type" xml:"type,attr"`
+	Format string `json:"format,omitempty" xml:"format,attr,omitempty"`
+}
+
+// Meta describes the data of a response.
+type Meta struct {
+	Fields   []*Field `json:"fields,omitempty"`
+	SQLID    string   `json:"sqlid,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Enum is the value of an enum field: its name, and its number.
+type Enum = wire.Enum
+
+/*
+Encoder writes a response. A value of a row is nil for NULL, or a string, bool, int64,
+*types.Number, time.Time or Enum, in the order of the fields of the meta.
+*/
+type Encoder interface {
+	// Head writes the code, message and meta of the response. meta may be nil.
+	Head(code int, message string, meta *Meta) error
+	// Row writes the next row of the data.
+	Row(values []interface{}) error
+	// End finishes the response.
+	End() error
+}
+
+// encoders makes an encoder for each media type a response can be in.
+var encoders = map[string]func(w io.Writer) Encoder{
+	"application/json": newJSONEncoder,
+	"application/xml":  newXMLEncoder,
+	"text/xml":         newXMLEncoder,
+	wire.MediaType:     newWireEncoder,
+}
+
+// defaultMediaType is the format of a response when the client accepts anything.
+const defaultMediaType = "application/json"
+
+// RegisterEncoder adds a media type a response can be in.
+func RegisterEncoder(mediaType string, newEncoder func(w io.Writer) Encoder) {
+	encoders[mediaType] = newEncoder
+}
+
+// mediaTypes lists the media types a response can be in.
+func mediaTypes() string {
+	var types []string
+	for mediaType := range encoders {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return strings.Join(types, ", ")
+}
+
+// timestampFormat is how a timestamp is written as text, and timestampFieldFormat is the same in the
+// format elements of a timestamp field.
+const (
+	timestampFormat      = "2006-01-02T15:04:05.999999999"
+	timestampFieldFormat = "YYYY-MM-DDTHH:MI:SS.FF"
+)
+
+// negotiate chooses the media type of a response from an Accept header, or returns "" if there is
+// none the client accepts.
+func negotiate(accept string) string {
+	if strings.TrimSpace(accept) == "" {
+		return defaultMediaType
+	}
+
+	type choice struct {
+		mediaType string
+		q         float64
+	}
+	var choices []choice
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if text, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(text, 64); err != nil {
+				continue
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		switch {
+		case mediaType == "*/*" || mediaType == "application/*":
+			mediaType = defaultMediaType
+		case strings.HasSuffix(mediaType, "/*"):
+			prefix := strings.TrimSuffix(mediaType, "*")
+			mediaType = ""
+			for known := range encoders {
+				if strings.HasPrefix(known, prefix) && (mediaType == "" || known < mediaType) {
+					mediaType = known
+				}
+			}
+		}
+		if _, ok := encoders[mediaType]; ok {
+			choices = append(choices, choice{mediaType, q})
+		}
+	}
+
+	if len(choices) == 0 {
+		return ""
+	}
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].q > choices[j].q
+	})
+	return choices[0].mediaType
+}
+
+// emptyMeta is nil for a meta with nothing in it, so that it is left out of the response.
+func emptyMeta(meta *Meta) *Meta {
+	if meta == nil || len(meta.Fields) == 0 && meta.SQLID == "" && len(meta.Warnings) == 0 {
+		return nil
+	}
+	return meta
+}
+
+// valueText is how a value is written in JSON or XML.
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return v.Format(timestampFormat)
+	case Enum:
+		return v.Name
+	}
+	return fmt.Sprint(value)
+}
+
+type jsonEncoder struct {
+	w      io.Writer
+	fields []*Field
+	rows   int
+}
+
+func newJSONEncoder(w io.Writer) Encoder {
+	return &jsonEncoder{w: w}
+}
+
+func (e *jsonEncoder) Head(code int, message string, meta *Meta) error {
+	head := struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Meta    *Meta  `json:"meta,omitempty"`
+	}{Code: code, Message: message, Meta: emptyMeta(meta)}
+	if meta != nil {
+		e.fields = meta.Fields
+	}
+
+	data, err := marshalJSON(head)
+	if err != nil {
+		return err
+	}
+	// leave the object open for the data
+	_, err = e.w.Write(data[:len(data)-1])
+	return err
+}
+
+func (e *jsonEncoder) Row(values []interface{}) error {
+	var b strings.Builder
+	if e.rows == 0 {
+		b.WriteString(`,"data":[`)
+	} else {
+		b.WriteByte(',')
+	}
+	e.rows++
+
+	b.WriteByte('{')
+	for i, field := range e.fields {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := marshalJSON(field.Name)
+		b.Write(name)
+		b.WriteByte(':')
+
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		switch v := value.(type) {
+		case nil:
+			b.WriteString("null")
+		case string, time.Time, Enum:
+			data, _ := marshalJSON(valueText(v))
+			b.Write(data)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			b.Write(data)
+		}
+	}
+	b.WriteByte('}')
+
+	_, err := io.WriteString(e.w, b.String())
+	return err
+}
+
+// marshalJSON is json.Marshal, but leaves <, > and & as they are.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+func (e *jsonEncoder) End() error {
+	end := "}"
+	if e.rows > 0 {
+		end = "]}"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+type xmlEncoder struct {
+	w      io.Writer
+	enc    *xml.Encoder
+	fields []*Field
+}
+
+func newXMLEncoder(w io.Writer) Encoder {
+	return &xmlEncoder{w: w, enc: xml.NewEncoder(w)}
+}
+
+// xmlMeta is Meta in XML, where the lists it does not have are left out.
+type xmlMeta struct {
+	SQLID    string       `xml:"sqlid,attr,omitempty"`
+	Fields   *xmlFields   `xml:"fields"`
+	Warnings *xmlWarnings `xml:"warnings"`
+}
+
+type xmlFields struct {
+	Field []*Field `xml:"field"`
+}
+
+type xmlWarnings struct {
+	Warning []string `xml:"warning"`
+}
+
+func (e *xmlEncoder) Head(code int, message string, meta *Meta) error {
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+
+	head := struct {
+		XMLName xml.Name `xml:"godb"`
+		Code    int      `xml:"code"`
+		Message string   `xml:"message"`
+		Meta    *xmlMeta `xml:"meta"`
+	}{Code: code, Message: message}
+	if meta = emptyMeta(meta); meta != nil {
+		e.fields = meta.Fields
+		head.Meta = &xmlMeta{SQLID: meta.SQLID}
+		if len(meta.Fields) > 0 {
+			head.Meta.Fields = &xmlFields{meta.Fields}
+		}
+		if len(meta.Warnings) > 0 {
+			head.Meta.Warnings = &xmlWarnings{meta.Warnings}
+		}
+	}
+
+	// encode the head whole, and then take off the end of <godb> to leave it open for the data
+	var b strings.Builder
+	if err := xml.NewEncoder(&b).Encode(head); err != nil {
+		return err
+	}
+	_, err := io.WriteString(e.w, strings.TrimSuffix(b.String(), "</godb>"))
+	return err
+}
+
+func (e *xmlEncoder) Row(values []interface{}) error {
+	data := xml.StartElement{Name: xml.Name{Local: "data"}}
+	if err := e.enc.EncodeToken(data); err != nil {
+		return err
+	}
+
+	for i, field := range e.fields {
+		elem := xml.StartElement{Name: xml.Name{Local: field.Name}}
+		var value interface{}
+		if i < len(values) {
+			value = values[i]
+		}
+		switch v := value.(type) {
+		case nil:
+			elem.Attr = append(elem.Attr, xml.Attr{Name: xml.Name{Local: "null"}, Value: "true"})
+		case Enum:
+			elem.Attr = append(elem.Attr, xml.Attr{Name: xml.Name{Local: "enum"}, Value: strconv.Itoa(v.Value)})
+		}
+		if err := e.enc.EncodeToken(elem); err != nil {
+			return err
+		}
+		if value != nil {
+			if err := e.enc.EncodeToken(xml.CharData(valueText(value))); err != nil {
+				return err
+			}
+		}
+		if err := e.enc.EncodeToken(elem.End()); err != nil {
+			return err
+		}
+	}
+
+	if err := e.enc.EncodeToken(data.End()); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
+
+func (e *xmlEncoder) End() error {
+	_, err := io.WriteString(e.w, "</godb>")
+	return err
+}
+
+// wireEncoder writes the binary format of package wire, which keeps numbers and timestamps exact.
+type wireEncoder struct {
+	w *wire.Writer
+}
+
+func newWireEncoder(w io.Writer) Encoder {
+	return &wireEncoder{w: wire.NewWriter(w)}
+}
+
+func (e *wireEncoder) Head(code int, message string, meta *Meta) error {
+	head := &wire.Head{Code: code, Message: message}
+	if meta != nil {
+		head.SQLID, head.Warnings = meta.SQLID, meta.Warnings
+		for _, field := range meta.Fields {
+			head.Fields = append(head.Fields, &wire.Field{Name: field.Name, Type: field.Type, Format: field.Format})
+		}
+	}
+	return e.w.WriteHead(head)
+}
+
+func (e *wireEncoder) Row(values []interface{}) error {
+	return e.w.WriteRow(values)
+}
+
+func (e *wireEncoder) End() error {
+	return e.w.Close()
+}
+
+// The codes of a response, as doc/docs/err/index.md lists them.
+const (
+	codeSuccess   = 0
+	codeInvalid   = 7 // the request, or its statement, is not valid
+	codeMediaType = 8 // the request or the response is in a format the server does not have
+)
+
+// errorCode is the code of a response that failed with err.
+func errorCode(err error) int {
+	var storeErr *store.Error
+	if errors.As(err, &storeErr) {
+		return storeErr.Code
+	}
+	return codeInvalid
+}
+
+// response writes the response to a request as it is made, flushing it as it goes so that it is sent in
+// chunks.
+type response struct {
+	rsp http.ResponseWriter
+	enc Encoder
+}
+
+func newResponse(rsp http.ResponseWriter, mediaType string) *response {
+	rsp.Header().Set("Content-Type", mediaType)
+	return &response{rsp: rsp, enc: encoders[mediaType](rsp)}
+}
+
+func (r *response) head(status, code int, message string, meta *Meta) error {
+	r.rsp.WriteHeader(status)
+	if err := r.enc.Head(code, message, meta); err != nil {
+		return err
+	}
+	r.flush()
+	return nil
+}
+
+func (r *response) row(values []interface{}) error {
+	if err := r.enc.Row(values); err != nil {
+		return err
+	}
+	r.flush()
+	return nil
+}
+
+func (r *response) end() error {
+	return r.enc.End()
+}
+
+// fail writes a response with no data for a request that failed.
+func (r *response) fail(status, code int, message string) {
+	if r.head(status, code, message, nil) == nil {
+		_ = r.end()
+	}
+}
+
+func (r *response) flush() {
+	if flusher, ok := r.rsp.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
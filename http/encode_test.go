@@ -0,0 +1,204 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/djbckr/godb/sql/types"
+	"github.com/djbckr/godb/wire"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNegotiate(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                "application/json",
+		"*/*":             "application/json",
+		"application/xml": "application/xml",
+		"text/*":          "text/xml",
+		"text/html, application/xml;q=0.9, */*;q=0.1":       "application/xml",
+		"application/json;q=0.5, text/xml":                  "text/xml",
+		"application/json;q=0, application/xml":             "application/xml",
+		"application/vnd.godb.rows, application/json;q=0.5": "application/vnd.godb.rows",
+		"text/html":            "",
+		"application/json;q=0": "",
+		"application/json;q=x": "",
+	} {
+		if mediaType := negotiate(accept); mediaType != expected {
+			t.Errorf("%q: expected %q, got %q", accept, expected, mediaType)
+		}
+	}
+}
+
+// encode writes a whole response.
+func encode(mediaType string, meta *Meta, rows [][]interface{}) (string, error) {
+	var b strings.Builder
+	enc := encoders[mediaType](&b)
+	if e := enc.Head(codeSuccess, "Success", meta); e != nil {
+		return "", e
+	}
+	for _, row := range rows {
+		if e := enc.Row(row); e != nil {
+			return "", e
+		}
+	}
+	e := enc.End()
+	return b.String(), e
+}
+
+func TestEncode(t *testing.T) {
+	n, _ := types.ParseNumber("12345678901234567890.125")
+	updated := time.Date(2020, 2, 2, 11, 23, 33, 500000000, time.UTC)
+	meta := &Meta{
+		Fields: []*Field{
+			{Name: "field1", Type: "string"},
+			{Name: "field2", Type: "number"},
+			{Name: "updated", Type: "timestamp", Format: "YYYY-MM-DDTHH:mm:SS"},
+			{Name: "fieldX", Type: "enum"},
+		},
+		SQLID: "84c8e7f9",
+	}
+	rows := [][]interface{}{
+		{"this <is> a string", n, updated, Enum{Value: 2, Name: "png"}},
+		{nil, int64(3), nil, nil},
+	}
+
+	for _, test := range []struct {
+		mediaType string
+		meta      *Meta
+		rows      [][]interface{}
+		expected  string
+	}{
+		{"application/json", nil, nil, `{"code":0,"message":"Success"}`},
+		{"application/json", &Meta{}, nil, `{"code":0,"message":"Success"}`},
+		{"application/json", &Meta{Warnings: []string{"Hint ignored"}}, nil,
+			`{"code":0,"message":"Success","meta":{"warnings":["Hint ignored"]}}`},
+		{"application/json", meta, rows, `{"code":0,"message":"Success","meta":{"fields":[` +
+			`{"name":"field1","type":"string"},{"name":"field2","type":"number"},` +
+			`{"name":"updated","type":"timestamp","format":"YYYY-MM-DDTHH:mm:SS"},{"name":"fieldX","type":"enum"}],` +
+			`"sqlid":"84c8e7f9"},"data":[` +
+			`{"field1":"this <is> a string","field2":12345678901234567890.125,"updated":"2020-02-02T11:23:33.5","fieldX":"png"},` +
+			`{"field1":null,"field2":3,"updated":null,"fieldX":null}]}`},
+		{"application/xml", nil, nil, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+			`<godb><code>0</code><message>Success</message></godb>`},
+		{"application/xml", meta, rows, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+			`<godb><code>0</code><message>Success</message><meta sqlid="84c8e7f9"><fields>` +
+			`<field name="field1" type="string"></field><field name="field2" type="number"></field>` +
+			`<field name="updated" type="timestamp" format="YYYY-MM-DDTHH:mm:SS"></field><field name="fieldX" type="enum"></field>` +
+			`</fields></meta>` +
+			`<data><field1>this &lt;is&gt; a string</field1><field2>12345678901234567890.125</field2>` +
+			`<updated>2020-02-02T11:23:33.5</updated><fieldX enum="2">png</fieldX></data>` +
+			`<data><field1 null="true"></field1><field2>3</field2><updated null="true"></updated><fieldX null="true"></fieldX></data>` +
+			`</godb>`},
+	} {
+		body, e := encode(test.mediaType, test.meta, test.rows)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if body != test.expected {
+			t.Errorf("expected\n%v\ngot\n%v", test.expected, body)
+		}
+	}
+}
+
+func TestEncodeWire(t *testing.T) {
+	n, _ := types.ParseNumber("-12345678901234567890.125")
+	meta := &Meta{Fields: []*Field{{Name: "n", Type: "number"}, {Name: "x", Type: "enum"}}, Warnings: []string{"Hint ignored"}}
+	body, e := encode(wire.MediaType, meta, [][]interface{}{{n, Enum{Value: 3, Name: "jpeg"}}})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	r := wire.NewReader(strings.NewReader(body))
+	head, e := r.Head()
+	if e != nil || head.Message != "Success" || len(head.Fields) != 2 || head.Fields[1].Type != "enum" || head.Warnings[0] != "Hint ignored" {
+		t.Fatalf("unexpected %+v, %v", head, e)
+	}
+	row, e := r.Next()
+	if e != nil || row[0].(*types.Number).String() != n.String() || row[1] != (Enum{Value: 3, Name: "jpeg"}) {
+		t.Errorf("unexpected %v, %v", row, e)
+	}
+	if _, e = r.Next(); e != io.EOF {
+		t.Errorf("expected the end, got %v", e)
+	}
+}
+
+func TestExecuteAccept(t *testing.T) {
+	startDatabase(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(`{"sql": "select 1 from dual"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", wire.MediaType)
+	rsp := httptest.NewRecorder()
+	execute(rsp, req)
+	head, e := wire.NewReader(rsp.Body).Head()
+	if rsp.Code != http.StatusOK || rsp.Header().Get("Content-Type") != wire.MediaType || e != nil || head.Message != "Success" {
+		t.Errorf("unexpected %v %v: %+v, %v", rsp.Code, rsp.Header().Get("Content-Type"), head, e)
+	}
+
+	// the binary format carries the rows of a query exactly: all the digits of a number, and the
+	// nanoseconds of a timestamp
+	body := `{"sql": "select :n * 2 as n, :ts as ts from dual",
+		"meta": {"binds": [{"name": "n", "type": "number"}, {"name": "ts", "type": "timestamp"}]},
+		"data": [{"n": 12345678901234567890.125, "ts": "2020-02-02T11:23:33.123456789"}, {"n": null, "ts": null}]}`
+	req = httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", wire.MediaType)
+	rsp = httptest.NewRecorder()
+	execute(rsp, req)
+	r := wire.NewReader(rsp.Body)
+	if head, e = r.Head(); e != nil || len(head.Fields) != 2 || head.Fields[0].Type != "number" || head.Fields[1].Type != "timestamp" {
+		t.Fatalf("unexpected %v %+v, %v", rsp.Code, head, e)
+	}
+	row, e := r.Next()
+	if e != nil || row[0].(*types.Number).String() != "24691357802469135780.250" ||
+		!row[1].(time.Time).Equal(time.Date(2020, 2, 2, 11, 23, 33, 123456789, time.UTC)) {
+		t.Errorf("unexpected %v, %v", row, e)
+	}
+	if row, e = r.Next(); e != nil || row[0] != nil || row[1] != nil {
+		t.Errorf("expected NULLs, got %v, %v", row, e)
+	}
+	if _, e = r.Next(); e != io.EOF {
+		t.Errorf("expected the end, got %v", e)
+	}
+
+	for _, test := range []struct {
+		contentType string
+		accept      string
+		body        string
+		status      int
+		mediaType   string
+		expected    string
+	}{
+		{"application/xml", "application/json", `<godb><sql>select 1 from dual</sql></godb>`,
+			http.StatusOK, "application/json", `{"code":0,"message":"Success","meta":{"fields":[{"name":"COLUMN1","type":"number"}]},"data":[{"COLUMN1":1}]}`},
+		{"application/json", "application/xml", `{"sql": "select 1 from dual"}`,
+			http.StatusOK, "application/xml", `<message>Success</message><meta><fields><field name="COLUMN1" type="number"></field></fields></meta><data><COLUMN1>1</COLUMN1></data></godb>`},
+		{"application/json", "application/xml", `{"sql": "select from dual"}`,
+			http.StatusUnprocessableEntity, "application/xml", `<code>7</code>`},
+		{"text/plain", "", `select 1 from dual`,
+			http.StatusUnsupportedMediaType, "application/json", `"code":8`},
+		{"application/json", "text/html", `{"sql": "select 1 from dual"}`,
+			http.StatusUnsupportedMediaType, "application/json", `"code":8`},
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/sql", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", test.contentType)
+		req.Header.Set("Accept", test.accept)
+		rsp := httptest.NewRecorder()
+		execute(rsp, req)
+
+		body := rsp.Body.String()
+		if rsp.Code != test.status || rsp.Header().Get("Content-Type") != test.mediaType || !strings.Contains(body, test.expected) {
+			t.Errorf("%v: expected %v %v with %v, got %v %v %v", test.body, test.status, test.mediaType, test.expected,
+				rsp.Code, rsp.Header().Get("Content-Type"), body)
+		}
+		if test.mediaType == "application/json" && !json.Valid([]byte(body)) {
+			t.Errorf("expected JSON, got %v", body)
+		}
+		if !rsp.Flushed {
+			t.Error("expected the response to be flushed as it is written")
+		}
+	}
+}
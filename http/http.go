@@ -1,41 +1,156 @@
 package http
 
 import (
-	"encoding/json"
-	"encoding/xml"
+	"github.com/djbckr/godb/database"
+	godbsql "github.com/djbckr/godb/sql"
+	"github.com/djbckr/godb/sql/types"
 	"io"
 	"net/http"
+	"strings"
 )
 
 func root(rsp http.ResponseWriter, req *http.Request)  {
 
 }
 
-func admin(rsp http.ResponseWriter, req *http.Request)  {
+// execute runs the statement of a /sql request, once for each set of values in its data, or once if
+// it has none, and answers in the format the request accepts.
+func execute(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "use POST or PUT", http.StatusMethodNotAllowed)
+		return
+	}
+
+	mediaType := negotiate(req.Header.Get("Accept"))
+	if mediaType == "" {
+		newResponse(w, defaultMediaType).fail(http.StatusUnsupportedMediaType, codeMediaType,
+			"the response can be "+mediaTypes())
+		return
+	}
+	rsp := newResponse(w, mediaType)
+
+	decoder, err := NewDecoder(req.Header.Get("Content-Type"), req.Body)
+	if err != nil {
+		rsp.fail(http.StatusUnsupportedMediaType, codeMediaType, "the request must be application/json or application/xml")
+		return
+	}
+
+	head, err := decoder.Head()
+	if err == nil {
+		err = checkRequest(head)
+	}
+	if err == nil && database.Current() == nil && !godbsql.CreatesDatabase(head.SQL) && !godbsql.CreatesControlFile(head.SQL) {
+		rsp.fail(http.StatusServiceUnavailable, codeInvalid, "there is no database: only CREATE DATABASE or CREATE CONTROLFILE can be run")
+		return
+	}
 
+	// with no data, the statement runs once with no values; the rows of each run follow on from
+	// those of the one before, and the head goes out with the first of them, or at the end
+	var meta *Meta
+	written := false
+	writeHead := func() error {
+		written = true
+		return rsp.head(http.StatusOK, codeSuccess, "Success", meta)
+	}
+	for runs := 0; err == nil; runs++ {
+		var values Values
+		if values, err = decoder.Next(); err == io.EOF && runs == 0 {
+			values, err = nil, nil
+		}
+		if err != nil {
+			break
+		}
+
+		var typed map[string]interface{}
+		if typed, err = values.typed(head.Binds); err != nil {
+			break
+		}
+		command, runErr := godbsql.Run(head.SQL, typed)
+		if runErr == nil {
+			if runs == 0 {
+				meta = &Meta{Fields: fields(command.Fields), Warnings: command.Warnings}
+			}
+			runErr = command.Rows(func(values []interface{}) error {
+				if !written {
+					if err := writeHead(); err != nil {
+						return err
+					}
+				}
+				return rsp.row(values)
+			})
+		}
+		if runErr != nil {
+			// once rows have gone out the status cannot change, so the response is left unfinished
+			if !written {
+				rsp.fail(http.StatusUnprocessableEntity, errorCode(runErr), runErr.Error())
+			}
+			return
+		}
+		if values == nil {
+			err = io.EOF
+		}
+	}
+
+	switch _, ok := err.(unprocessable); {
+	case err == io.EOF:
+		if written || writeHead() == nil {
+			_ = rsp.end()
+		}
+	case written:
+		// as above, the response is left unfinished
+	case ok:
+		rsp.fail(http.StatusUnprocessableEntity, codeInvalid, err.Error())
+	default:
+		rsp.fail(http.StatusBadRequest, codeInvalid, err.Error())
+	}
 }
 
-func login(rsp http.ResponseWriter, req *http.Request)  {
-	rsp.Header().Add("Authorization", "whoopdedoo");
-	_, _ = io.WriteString(rsp, "done")
+// fields are the fields of a response for the columns of a query.
+func fields(columns []*godbsql.Field) []*Field {
+	var fields []*Field
+	for _, column := range columns {
+		field := &Field{Name: column.Name, Type: column.Type}
+		if field.Type == types.TypeTimestamp {
+			field.Format = timestampFieldFormat
+		}
+		fields = append(fields, field)
+	}
+	return fields
 }
 
-func sql(rsp http.ResponseWriter, req *http.Request) {
-	var decoder *Decoder
-	switch req.Header.Get("Content-Type") {
-	case "application/json":
-		decoder = json.NewDecoder(req.Body)
-	case "application/xml":
-		decoder = xml.NewDecoder(req.Body)
+// checkRequest checks what a request says before its data.
+func checkRequest(head *Request) error {
+	switch {
+	case head.SQL == "" && head.SQLID == "":
+		return unprocessablef("the request has neither sql nor sqlid")
+	case head.SQL != "" && head.SQLID != "":
+		return unprocessablef("the request has both sql and sqlid")
+	case head.SQLID != "":
+		return unprocessablef("unknown sqlid %v", head.SQLID)
+	}
 
+	names := make(map[string]bool)
+	for _, bind := range head.Binds {
+		name := strings.ToUpper(bind.Name)
+		if name == "" {
+			return unprocessablef("a bind in meta has no name")
+		}
+		if names[name] {
+			return unprocessablef("bind %v is in meta twice", bind.Name)
+		}
+		if err := types.CheckType(bind.Type, bind.Format); err != nil {
+			return unprocessablef("bind %v: %v", bind.Name, err)
+		}
+		names[name] = true
 	}
-	req.Header.Get("Content-Type") // body
-	// req.Header.Get("Accept")
+	return nil
 }
 
 func init() {
-	http.HandleFunc("/", root)
-	http.HandleFunc("/admin", admin)
+	http.HandleFunc("/", authorize(root))
+	http.HandleFunc("/admin", authorize(admin))
+	http.HandleFunc("/authenticate", login)
 	http.HandleFunc("/login", login)
-	http.HandleFunc("/sql", sql)
+	http.HandleFunc("/sql", bootstrap(authorize(exclusive(execute)), execute))
 }
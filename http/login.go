@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/session"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maxLoginBody is the largest login request read.
+const maxLoginBody = 64 << 10
+
+// credentials are what a login request holds: a user name and password, or an AuthToken.
+type credentials struct {
+	XMLName   xml.Name `json:"-" xml:"godb"`
+	Username  string   `json:"username" xml:"username"`
+	Password  string   `json:"password" xml:"password"`
+	AuthToken string   `json:"AuthToken" xml:"AuthToken"`
+}
+
+// login checks a user's password, or an AuthToken, and opens a session. The response has no body:
+// the Authorization header for later requests if the login worked, and 401 if it did not.
+func login(rsp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		rsp.Header().Set("Allow", "POST, PUT")
+		rsp.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	// no one can log in until CREATE DATABASE has made the database and its SYS user
+	db := database.Current()
+	if db == nil {
+		rsp.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	creds, err := readCredentials(req.Header.Get("Content-Type"), http.MaxBytesReader(rsp, req.Body, maxLoginBody))
+	switch {
+	case err == errUnsupportedMedia:
+		rsp.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	case err != nil:
+		http.Error(rsp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var s *session.Session
+	if creds.AuthToken != "" {
+		s, err = session.LoginWithToken(creds.AuthToken)
+	} else {
+		var ok bool
+		if ok, err = db.Catalog.Authenticate(creds.Username, creds.Password); ok {
+			s, err = session.Login(strings.ToUpper(creds.Username), db.Params.AuthTokenLifetime)
+		}
+	}
+
+	switch {
+	case err != nil:
+		// what went wrong is for the server's log, not for someone who has not logged in
+		log.Printf("login of %q: %v", creds.Username, err)
+		http.Error(rsp, "the login could not be checked", http.StatusInternalServerError)
+	case s == nil:
+		rsp.WriteHeader(http.StatusUnauthorized)
+	default:
+		rsp.Header().Set("Authorization", authorization(s))
+		rsp.WriteHeader(http.StatusOK)
+	}
+}
+
+// authorization is the Authorization header of the requests of a session.
+func authorization(s *session.Session) string {
+	return fmt.Sprintf("AuthToken %v SessionID %v", s.AuthToken(), s.ID())
+}
+
+// readCredentials reads the body of a login request.
+func readCredentials(contentType string, body io.Reader) (*credentials, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, errUnsupportedMedia
+	}
+
+	creds := &credentials{}
+	switch mediaType {
+	case "application/json":
+		err = json.NewDecoder(body).Decode(creds)
+	case "application/xml", "text/xml":
+		err = xml.NewDecoder(body).Decode(creds)
+	default:
+		return nil, errUnsupportedMedia
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if creds.AuthToken != "" && (creds.Username != "" || creds.Password != "") {
+		return nil, errors.New("a login has a username and password, or an AuthToken, but not both")
+	}
+	return creds, nil
+}
@@ -0,0 +1,107 @@
+package http
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/session"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/sql/token"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// createDatabase is a CREATE DATABASE of files in dir, whose SYS user has the password "change me".
+func createDatabase(dir string) string {
+	path := func(name string) string { return filepath.Join(dir, name) }
+	return fmt.Sprintf(`create database orcl
+		initparams('ctrlFile=%v')
+		user sys identified by 'change me'
+		logfile group 1 ('%v') size 64k, group 2 ('%v') size 64k
+		datafile '%v' size 512k autoextend on
+		undo tablespace undo datafile '%v' size 1m`,
+		path("control.ctl"), path("redo1.log"), path("redo2.log"), path("system.dbf"), path("undo.dbf"))
+}
+
+// startDatabase starts the server, and makes the database with createDatabase.
+func startDatabase(t *testing.T) {
+	dir := t.TempDir()
+	t.Cleanup(func() { _ = database.Shutdown() })
+
+	tokens, e := token.Tokenize(createDatabase(dir))
+	if e != nil {
+		t.Fatal(e)
+	}
+	stmt, e := ddl.ProcessCreateDatabase(tokens)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = database.Start(filepath.Join(dir, "godb.ini")); e != nil {
+		t.Fatal(e)
+	}
+	if e = database.Create(stmt); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func post(handler http.HandlerFunc, method, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/authenticate", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rsp := httptest.NewRecorder()
+	handler(rsp, req)
+	return rsp
+}
+
+func TestLogin(t *testing.T) {
+	sysLogin := `{"username": "sys", "password": "change me"}`
+	if rsp := post(login, http.MethodPost, "application/json", sysLogin); rsp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected no login before CREATE DATABASE, got %v", rsp.Code)
+	}
+
+	startDatabase(t)
+
+	// a user name and password
+	rsp := post(login, http.MethodPost, "application/json", sysLogin)
+	var authToken, sessionID string
+	if _, e := fmt.Sscanf(rsp.Header().Get("Authorization"), "AuthToken %s SessionID %s", &authToken, &sessionID); rsp.Code != http.StatusOK || e != nil || rsp.Body.Len() != 0 {
+		t.Fatalf("unexpected %v %v %q: %v", rsp.Code, rsp.Header(), rsp.Body, e)
+	}
+	s := session.SessionById(sessionID)
+	if s == nil || s.Username() != "SYS" || s.AuthToken() != authToken {
+		t.Fatalf("expected a session for SYS, got %+v", s)
+	}
+
+	// the AuthToken alone opens another session
+	rsp = post(login, http.MethodPut, "application/xml", fmt.Sprintf(`<godb><AuthToken>%v</AuthToken></godb>`, authToken))
+	var again string
+	if _, e := fmt.Sscanf(rsp.Header().Get("Authorization"), "AuthToken "+authToken+" SessionID %s", &again); rsp.Code != http.StatusOK || e != nil || again == sessionID {
+		t.Errorf("expected a new session with the same AuthToken, got %v %v: %v", rsp.Code, rsp.Header(), e)
+	}
+
+	for _, test := range []struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{http.MethodPost, "application/xml", `<godb><username>SYS</username><password>change me</password></godb>`, http.StatusOK},
+		{http.MethodPost, "application/json", `{"username": "sys", "password": "change it"}`, http.StatusUnauthorized},
+		{http.MethodPost, "application/json", `{"username": "nobody", "password": "change me"}`, http.StatusUnauthorized},
+		{http.MethodPost, "application/json", `{}`, http.StatusUnauthorized},
+		{http.MethodPost, "application/json", `{"AuthToken": "8d8d766c4fc4493bb82be83096b7e8d8"}`, http.StatusUnauthorized},
+		{http.MethodPost, "application/json", `{"username": "sys", "password": "change me", "AuthToken": "` + authToken + `"}`, http.StatusBadRequest},
+		{http.MethodPost, "application/json", `{"username": "sys"`, http.StatusBadRequest},
+		{http.MethodPost, "text/plain", `sys/change me`, http.StatusUnsupportedMediaType},
+		{http.MethodGet, "application/json", sysLogin, http.StatusMethodNotAllowed},
+	} {
+		rsp = post(login, test.method, test.contentType, test.body)
+		if rsp.Code != test.status {
+			t.Errorf("%v: expected %v, got %v", test.body, test.status, rsp.Code)
+		}
+		if rsp.Code == http.StatusUnauthorized && (rsp.Body.Len() != 0 || rsp.Header().Get("Authorization") != "") {
+			t.Errorf("expected a 401 with nothing else, got %v %q", rsp.Header(), rsp.Body)
+		}
+	}
+}
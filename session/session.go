@@ -1,30 +1,191 @@
 package session
 
-import "time"
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+/*
+A user logs in once, and gets an AuthToken: what the user may do. Each login, and each later login
+with the AuthToken alone, opens a session, with its own SessionID; a request names both. An
+AuthToken lasts for its lifetime, or forever if that is 0, and a session is closed once it has been
+idle for its maxIdleTime. Both are random, so that one cannot be guessed from another.
+*/
+
+// DefaultMaxIdleTime is how long a session may be idle before it is closed.
+const DefaultMaxIdleTime = 10 * time.Minute
+
+// tokenBytes is the size of the random part of AuthTokens and SessionIDs.
+const tokenBytes = 16
+
+type AuthToken struct {
+	token    string    // the token the client sends
+	username string    // the user who logged in
+	issued   time.Time // when the user logged in
+	expires  time.Time // when the token stops working; zero if it never does
+}
 
 type Session struct {
 	lastActive  time.Time     // the last time this object was doing something
 	initialized time.Time     // when this session was started
 	username    string        // the username for this session
-	authKey     int           // the authorization key for this session - there can be more than one session with the same authKey
-	sessionId   int           // the unique identifier for this session
+	authToken   *AuthToken    // the authorization for this session - there can be more than one session with the same authToken
+	sessionId   string        // the unique identifier for this session
 	maxIdleTime time.Duration // the allowed idle time for this session
+	running     bool          // whether a request is running on this session; only one may at a time
 }
 
-var sessionListId map[int]*Session
+var (
+	mu            sync.Mutex
+	sessionListId map[string]*Session
+	authTokens    map[string]*AuthToken
+)
 
 func init() {
-	sessionListId = make(map[int]*Session)
+	sessionListId = make(map[string]*Session)
+	authTokens = make(map[string]*AuthToken)
+}
+
+// Login opens a session for a user whose password has been checked, with a new AuthToken that lasts
+// for lifetime, or forever if lifetime is 0.
+func Login(username string, lifetime time.Duration) (*Session, error) {
+	token, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	auth := &AuthToken{token: token, username: username, issued: now}
+	if lifetime > 0 {
+		auth.expires = now.Add(lifetime)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	authTokens[token] = auth
+	return open(auth, now)
+}
+
+// LoginWithToken opens another session with an AuthToken, as if the user had logged in again. It
+// returns nil if the AuthToken is unknown or has expired.
+func LoginWithToken(token string) (*Session, error) {
+	now := time.Now()
+
+	mu.Lock()
+	defer mu.Unlock()
+	auth := authTokens[token]
+	if auth == nil || auth.expired(now) {
+		return nil, nil
+	}
+	return open(auth, now)
+}
+
+func open(auth *AuthToken, now time.Time) (*Session, error) {
+	id, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	prune(now)
+
+	session := &Session{
+		lastActive:  now,
+		initialized: now,
+		username:    auth.username,
+		authToken:   auth,
+		sessionId:   id,
+		maxIdleTime: DefaultMaxIdleTime,
+	}
+	sessionListId[id] = session
+	return session, nil
+}
+
+// prune closes the sessions that have been idle too long, and forgets the AuthTokens that have expired.
+func prune(now time.Time) {
+	for id, session := range sessionListId {
+		if session.expired(now) {
+			delete(sessionListId, id)
+		}
+	}
+	for token, auth := range authTokens {
+		if auth.expired(now) {
+			delete(authTokens, token)
+		}
+	}
 }
 
-func SessionById(id int) *Session {
+func SessionById(id string) *Session {
+	mu.Lock()
+	defer mu.Unlock()
+
 	session := sessionListId[id]
 
 	if session == nil {
 		return nil
 	}
 
-	session.lastActive = time.Now()
+	now := time.Now()
+	if session.expired(now) {
+		delete(sessionListId, id)
+		return nil
+	}
+
+	session.lastActive = now
 
 	return session
 }
+
+// ID is the SessionID of the session.
+func (s *Session) ID() string {
+	return s.sessionId
+}
+
+// AuthToken is the AuthToken the session was opened with.
+func (s *Session) AuthToken() string {
+	return s.authToken.token
+}
+
+// Username is the user who opened the session.
+func (s *Session) Username() string {
+	return s.username
+}
+
+// Begin marks the session as running a request, and reports false if it already is.
+func (s *Session) Begin() bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if s.running {
+		return false
+	}
+	s.running = true
+	return true
+}
+
+// End marks the request Begin started as done.
+func (s *Session) End() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	s.running = false
+	s.lastActive = time.Now()
+}
+
+// expired reports whether the session has been idle too long, or its AuthToken has expired. A
+// session running a request is not idle.
+func (s *Session) expired(now time.Time) bool {
+	return !s.running && now.Sub(s.lastActive) > s.maxIdleTime || s.authToken.expired(now)
+}
+
+func (a *AuthToken) expired(now time.Time) bool {
+	return !a.expires.IsZero() && !now.Before(a.expires)
+}
+
+func randomID() (string, error) {
+	b := make([]byte, tokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
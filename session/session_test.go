@@ -0,0 +1,62 @@
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSession(t *testing.T) {
+	s, e := Login("SCOTT", time.Hour)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if SessionById(s.ID()) != s || s.Username() != "SCOTT" || len(s.AuthToken()) != 2*tokenBytes {
+		t.Fatalf("unexpected session %+v", s)
+	}
+
+	other, e := LoginWithToken(s.AuthToken())
+	if e != nil || other == nil || other.ID() == s.ID() || other.AuthToken() != s.AuthToken() {
+		t.Fatalf("expected another session with the same AuthToken, got %+v, %v", other, e)
+	}
+	if unknown, e := LoginWithToken("0123"); unknown != nil || e != nil {
+		t.Errorf("expected no session for an unknown AuthToken, got %+v, %v", unknown, e)
+	}
+	if SessionById("0123") != nil {
+		t.Error("expected no session for an unknown SessionID")
+	}
+
+	// one request at a time, and a session running one is not idle
+	if !other.Begin() || other.Begin() {
+		t.Error("expected one request at a time")
+	}
+	other.lastActive = time.Now().Add(-DefaultMaxIdleTime - time.Second)
+	if SessionById(other.ID()) != other {
+		t.Error("expected a session running a request to stay open")
+	}
+	other.End()
+	if !other.Begin() {
+		t.Error("expected another request once the first has ended")
+	}
+	other.End()
+
+	// an idle session closes
+	other.lastActive = time.Now().Add(-DefaultMaxIdleTime - time.Second)
+	if SessionById(other.ID()) != nil {
+		t.Error("expected an idle session to be closed")
+	}
+
+	// an expired AuthToken closes its sessions, and opens no more
+	s.authToken.expires = time.Now()
+	if SessionById(s.ID()) != nil {
+		t.Error("expected the session of an expired AuthToken to be closed")
+	}
+	if expired, e := LoginWithToken(s.AuthToken()); expired != nil || e != nil {
+		t.Errorf("expected no session for an expired AuthToken, got %+v, %v", expired, e)
+	}
+
+	// a lifetime of 0 never expires
+	forever, e := Login("BATCH", 0)
+	if e != nil || !forever.authToken.expires.IsZero() {
+		t.Errorf("expected an AuthToken that never expires, got %+v, %v", forever, e)
+	}
+}
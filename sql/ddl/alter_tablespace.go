@@ -1 +1,140 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_3002.htm
+
+ALTER TABLESPACE tablespace
+  { ADD { DATAFILE | TEMPFILE } file_specification [, file_specification ]...
+  | DROP { DATAFILE | TEMPFILE } 'filename'
+  | [ { DATAFILE | TEMPFILE } 'filename' ] RESIZE size_clause
+  | [ { DATAFILE | TEMPFILE } 'filename' ] autoextend_clause
+  | ONLINE
+  | OFFLINE [ NORMAL ]
+  | READ { ONLY | WRITE }
+  | RENAME TO new_tablespace_name
+  } ;
+
+RESIZE and AUTOEXTEND change the file named, or the only file of the tablespace if none is named;
+Oracle does this with ALTER DATABASE DATAFILE, and for a bigfile tablespace with ALTER TABLESPACE.
+
+*/
+
+type TTablespaceAction = int
+
+const (
+	TABLESPACE_ADD_FILE TTablespaceAction = iota
+	TABLESPACE_DROP_FILE
+	TABLESPACE_RESIZE
+	TABLESPACE_AUTOEXTEND
+	TABLESPACE_ONLINE
+	TABLESPACE_OFFLINE
+	TABLESPACE_READ_ONLY
+	TABLESPACE_READ_WRITE
+	TABLESPACE_RENAME
+)
+
+type AlterTablespace struct {
+	Name       string
+	Action     TTablespaceAction
+	DataFiles  []*TFileSpec // TABLESPACE_ADD_FILE
+	DataFile   string       // the file to drop, resize or autoextend; "" for the only one
+	Size       int64        // TABLESPACE_RESIZE
+	Autoextend *TAutoextend // TABLESPACE_AUTOEXTEND
+	NewName    string       // TABLESPACE_RENAME
+}
+
+func ProcessAlterTablespace(sql token.Tokens) (*AlterTablespace, error) {
+	var err error
+	p := dml.NewParser(sql)
+	alter := &AlterTablespace{}
+
+	if err = p.ExpectKeyword("ALTER"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("TABLESPACE"); err != nil {
+		return nil, err
+	}
+
+	if alter.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.AcceptKeyword("ADD"):
+		alter.Action = TABLESPACE_ADD_FILE
+		if alter.DataFiles, err = parseFileSpecs(p, p.PeekKeyword("TEMPFILE")); err != nil {
+			return nil, err
+		}
+
+	case p.AcceptKeyword("DROP"):
+		alter.Action = TABLESPACE_DROP_FILE
+		if alter.DataFile, err = parseFileName(p); err != nil {
+			return nil, err
+		}
+
+	case p.AcceptKeyword("ONLINE"):
+		alter.Action = TABLESPACE_ONLINE
+
+	case p.AcceptKeyword("OFFLINE"):
+		alter.Action = TABLESPACE_OFFLINE
+		p.AcceptKeyword("NORMAL")
+
+	case p.AcceptKeyword("READ"):
+		if p.AcceptKeyword("ONLY") {
+			alter.Action = TABLESPACE_READ_ONLY
+		} else if p.AcceptKeyword("WRITE") {
+			alter.Action = TABLESPACE_READ_WRITE
+		} else {
+			return nil, p.Errorf("ONLY or WRITE expected")
+		}
+
+	case p.AcceptKeyword("RENAME"):
+		alter.Action = TABLESPACE_RENAME
+		if err = p.ExpectKeyword("TO"); err != nil {
+			return nil, err
+		}
+		if alter.NewName, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+
+	default:
+		if p.PeekKeyword("DATAFILE", "TEMPFILE") {
+			if alter.DataFile, err = parseFileName(p); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.AcceptKeyword("RESIZE") {
+			alter.Action = TABLESPACE_RESIZE
+			if alter.Size, err = parseSize(p); err != nil {
+				return nil, err
+			}
+		} else if p.PeekKeyword("AUTOEXTEND") {
+			alter.Action = TABLESPACE_AUTOEXTEND
+			if alter.Autoextend, err = parseAutoextend(p); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, p.Errorf("ADD, DROP, RESIZE, AUTOEXTEND, ONLINE, OFFLINE, READ or RENAME expected")
+		}
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return alter, nil
+}
+
+// parseFileName parses { DATAFILE | TEMPFILE } 'filename'.
+func parseFileName(p *dml.Parser) (string, error) {
+	if !p.AcceptKeyword("DATAFILE") && !p.AcceptKeyword("TEMPFILE") {
+		return "", p.Errorf("DATAFILE or TEMPFILE expected")
+	}
+	return parseString(p)
+}
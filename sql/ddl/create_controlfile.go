@@ -1 +1,156 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+	"github.com/djbckr/godb/sql/types"
+	"math"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_5003.htm
+
+CREATE CONTROLFILE [ REUSE ] [ SET ] DATABASE database
+  LOGFILE logfile_specification [, logfile_specification ]...
+  { RESETLOGS | NORESETLOGS }
+  DATAFILE file_specification [, file_specification ]... ;
+
+logfile_specification ::=
+  [ GROUP integer ] { 'filename' | ( 'filename' [, 'filename' ]... ) } [ SIZE size_clause ] [ REUSE ]
+
+This makes the control file again, from the datafiles, when every copy of it is lost. The datafiles
+must be all the datafiles of the database; only their names are used, the rest of each
+file_specification is read from the file. Oracle's MAXLOGFILES and the like are not supported.
+
+*/
+
+type CreateControlfile struct {
+	Reuse     bool
+	Set       bool
+	Database  string
+	LogFiles  []*TLogGroup
+	ResetLogs bool
+	DataFiles []*TFileSpec
+}
+
+// TLogGroup is a redo log group. Group is 0 if not given, and Size in bytes, 0 if not given.
+type TLogGroup struct {
+	Group   int
+	Members []string
+	Size    int64
+	Reuse   bool
+}
+
+func ProcessCreateControlfile(sql token.Tokens) (*CreateControlfile, error) {
+	var err error
+	p := dml.NewParser(sql)
+	control := &CreateControlfile{}
+
+	if err = p.ExpectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("CONTROLFILE"); err != nil {
+		return nil, err
+	}
+
+	control.Reuse = p.AcceptKeyword("REUSE")
+	control.Set = p.AcceptKeyword("SET")
+
+	if err = p.ExpectKeyword("DATABASE"); err != nil {
+		return nil, err
+	}
+	if control.Database, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+
+	if control.LogFiles, err = parseLogFiles(p); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("RESETLOGS") {
+		control.ResetLogs = true
+	} else if !p.AcceptKeyword("NORESETLOGS") {
+		return nil, p.Errorf("RESETLOGS or NORESETLOGS expected")
+	}
+
+	if control.DataFiles, err = parseFileSpecs(p, false); err != nil {
+		return nil, err
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return control, nil
+}
+
+// parseLogFiles parses LOGFILE logfile_specification [, logfile_specification ]...
+func parseLogFiles(p *dml.Parser) ([]*TLogGroup, error) {
+	if err := p.ExpectKeyword("LOGFILE"); err != nil {
+		return nil, err
+	}
+
+	var groups []*TLogGroup
+	for {
+		group, err := parseLogGroup(p)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+
+		if !p.AcceptPunct(",") {
+			return groups, nil
+		}
+	}
+}
+
+func parseLogGroup(p *dml.Parser) (*TLogGroup, error) {
+	var err error
+	group := &TLogGroup{}
+
+	if p.AcceptKeyword("GROUP") {
+		tkn := p.Peek()
+		if tkn == nil || tkn.TokenType != token.TypeNumber {
+			return nil, p.Errorf("group number expected")
+		}
+		n, ok := tkn.Value.(*types.Number).Int64()
+		if !ok || n < 1 || n > math.MaxInt32 {
+			return nil, p.Errorf("group number must be a whole number from 1")
+		}
+		p.Next()
+		group.Group = int(n)
+	}
+
+	if p.AcceptPunct("(") {
+		for {
+			member, err := parseString(p)
+			if err != nil {
+				return nil, err
+			}
+			group.Members = append(group.Members, member)
+
+			if !p.AcceptPunct(",") {
+				break
+			}
+		}
+		if err = p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+	} else {
+		member, err := parseString(p)
+		if err != nil {
+			return nil, err
+		}
+		group.Members = []string{member}
+	}
+
+	if p.AcceptKeyword("SIZE") {
+		if group.Size, err = parseSize(p); err != nil {
+			return nil, err
+		}
+	}
+
+	group.Reuse = p.AcceptKeyword("REUSE")
+
+	return group, nil
+}
@@ -0,0 +1,54 @@
+package ddl
+
+import (
+	"github.com/djbckr/godb/sql/token"
+	"reflect"
+	"testing"
+)
+
+func createControlfile(sql string) (*CreateControlfile, error) {
+	tokens, e := token.Tokenize(sql)
+	if e != nil {
+		return nil, e
+	}
+	return ProcessCreateControlfile(tokens)
+}
+
+func TestCreateControlfile(t *testing.T) {
+	cf, e := createControlfile(`create controlfile reuse database orcl
+		logfile group 1 ('/redo/1a.log', '/redo/1b.log') size 64m,
+		        group 2 ('/redo/2a.log', '/redo/2b.log') size 64m reuse
+		noresetlogs
+		datafile '/data/system.dbf', '/data/users01.dbf'`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if cf.Database != "ORCL" || !cf.Reuse || cf.Set || cf.ResetLogs || len(cf.DataFiles) != 2 || cf.DataFiles[1].Path != "/data/users01.dbf" {
+		t.Fatalf("unexpected control file: %+v", cf)
+	}
+	expected := []*TLogGroup{
+		{Group: 1, Members: []string{"/redo/1a.log", "/redo/1b.log"}, Size: 64 << 20},
+		{Group: 2, Members: []string{"/redo/2a.log", "/redo/2b.log"}, Size: 64 << 20, Reuse: true},
+	}
+	if !reflect.DeepEqual(cf.LogFiles, expected) {
+		t.Errorf("unexpected log groups: %+v %+v", cf.LogFiles[0], cf.LogFiles[1])
+	}
+
+	if cf, e = createControlfile(`create controlfile set database orcl logfile 'a.log', 'b.log' resetlogs datafile 'system.dbf';`); e != nil ||
+		!cf.Set || !cf.ResetLogs || len(cf.LogFiles) != 2 || cf.LogFiles[1].Group != 0 || cf.LogFiles[1].Members[0] != "b.log" {
+		t.Errorf("unexpected control file %+v, %v", cf, e)
+	}
+
+	for _, sql := range []string{
+		`create controlfile database orcl logfile 'a.log' datafile 'system.dbf'`,
+		`create controlfile database orcl logfile 'a.log' resetlogs`,
+		`create controlfile database orcl resetlogs datafile 'system.dbf'`,
+		`create controlfile database orcl logfile group 0 'a.log' resetlogs datafile 'system.dbf'`,
+		`create controlfile database orcl logfile group 1 ('a.log' 'b.log') resetlogs datafile 'system.dbf'`,
+		`create controlfile database orcl logfile group 1 () resetlogs datafile 'system.dbf'`,
+	} {
+		if _, e = createControlfile(sql); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
@@ -1 +1,190 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_5004.htm
+
+CREATE DATABASE database
+  INITPARAMS ( 'name=value' [, 'name=value' ]... [,] )
+  { USER SYS IDENTIFIED BY 'password'
+  | LOGFILE logfile_specification [, logfile_specification ]...
+  | DATAFILE file_specification [, file_specification ]...
+  | DEFAULT TABLESPACE tablespace DATAFILE file_specification [, file_specification ]...
+  | DEFAULT { TEMPORARY | TEMP } TABLESPACE tablespace TEMPFILE file_specification [, file_specification ]...
+  | UNDO TABLESPACE tablespace DATAFILE file_specification [, file_specification ]...
+  }... ;
+
+INITPARAMS is our own: the parameters are written to the init file, which Oracle keeps apart from
+the statement. The clauses after it may come in any order, each once. USER SYS, LOGFILE, DATAFILE
+(the files of the SYSTEM tablespace) and UNDO TABLESPACE must be given; without DEFAULT TABLESPACE,
+SYSTEM is the default.
+
+*/
+
+type CreateDatabase struct {
+	Name              string
+	InitParams        []string // name=value, as given
+	SysPassword       string
+	LogFiles          []*TLogGroup
+	DataFiles         []*TFileSpec
+	DefaultTablespace *TTablespaceClause // nil if not given
+	TempTablespace    *TTablespaceClause // nil if not given
+	UndoTablespace    *TTablespaceClause
+}
+
+// TTablespaceClause is a tablespace made along with the database.
+type TTablespaceClause struct {
+	Name      string
+	DataFiles []*TFileSpec
+}
+
+func ProcessCreateDatabase(sql token.Tokens) (*CreateDatabase, error) {
+	var err error
+	p := dml.NewParser(sql)
+	database := &CreateDatabase{}
+
+	if err = p.ExpectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("DATABASE"); err != nil {
+		return nil, err
+	}
+	if database.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+
+	if database.InitParams, err = parseInitParams(p); err != nil {
+		return nil, err
+	}
+
+	for !p.AtEnd() && !p.PeekPunct(";") {
+		switch {
+		case p.AcceptKeyword("USER"):
+			if database.SysPassword != "" {
+				return nil, p.Errorf("USER SYS is given twice")
+			}
+			if err = p.ExpectKeyword("SYS"); err != nil {
+				return nil, err
+			}
+			if err = p.ExpectKeyword("IDENTIFIED"); err != nil {
+				return nil, err
+			}
+			if err = p.ExpectKeyword("BY"); err != nil {
+				return nil, err
+			}
+			if database.SysPassword, err = parseString(p); err != nil {
+				return nil, err
+			}
+			if database.SysPassword == "" {
+				return nil, p.Errorf("the password of SYS cannot be empty")
+			}
+
+		case p.PeekKeyword("LOGFILE"):
+			if database.LogFiles != nil {
+				return nil, p.Errorf("LOGFILE is given twice")
+			}
+			if database.LogFiles, err = parseLogFiles(p); err != nil {
+				return nil, err
+			}
+
+		case p.PeekKeyword("DATAFILE"):
+			if database.DataFiles != nil {
+				return nil, p.Errorf("DATAFILE is given twice")
+			}
+			if database.DataFiles, err = parseFileSpecs(p, false); err != nil {
+				return nil, err
+			}
+
+		case p.AcceptKeyword("DEFAULT"):
+			temporary := p.AcceptKeyword("TEMPORARY") || p.AcceptKeyword("TEMP")
+			clause := &database.DefaultTablespace
+			if temporary {
+				clause = &database.TempTablespace
+			}
+			if *clause != nil {
+				return nil, p.Errorf("the default tablespace is given twice")
+			}
+			if *clause, err = parseTablespaceClause(p, temporary); err != nil {
+				return nil, err
+			}
+
+		case p.AcceptKeyword("UNDO"):
+			if database.UndoTablespace != nil {
+				return nil, p.Errorf("UNDO TABLESPACE is given twice")
+			}
+			if database.UndoTablespace, err = parseTablespaceClause(p, false); err != nil {
+				return nil, err
+			}
+
+		default:
+			return nil, p.Errorf("USER, LOGFILE, DATAFILE, DEFAULT or UNDO expected")
+		}
+	}
+
+	switch {
+	case database.SysPassword == "":
+		return nil, p.Errorf("USER SYS IDENTIFIED BY expected")
+	case database.LogFiles == nil:
+		return nil, p.Errorf("LOGFILE expected")
+	case database.DataFiles == nil:
+		return nil, p.Errorf("DATAFILE expected")
+	case database.UndoTablespace == nil:
+		return nil, p.Errorf("UNDO TABLESPACE expected")
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return database, nil
+}
+
+// parseInitParams parses INITPARAMS ( 'name=value' [, 'name=value' ]... [,] ).
+func parseInitParams(p *dml.Parser) ([]string, error) {
+	if err := p.ExpectKeyword("INITPARAMS"); err != nil {
+		return nil, err
+	}
+	if err := p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+
+	var params []string
+	for !p.AcceptPunct(")") {
+		param, err := parseString(p)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, param)
+
+		if !p.AcceptPunct(",") {
+			if err = p.ExpectPunct(")"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	return params, nil
+}
+
+// parseTablespaceClause parses TABLESPACE tablespace { DATAFILE | TEMPFILE } file_specification [, file_specification ]...
+func parseTablespaceClause(p *dml.Parser, temporary bool) (*TTablespaceClause, error) {
+	var err error
+	clause := &TTablespaceClause{}
+
+	if err = p.ExpectKeyword("TABLESPACE"); err != nil {
+		return nil, err
+	}
+	if clause.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+	if clause.DataFiles, err = parseFileSpecs(p, temporary); err != nil {
+		return nil, err
+	}
+
+	return clause, nil
+}
@@ -0,0 +1,78 @@
+package ddl
+
+import (
+	"github.com/djbckr/godb/sql/token"
+	"testing"
+)
+
+func createDatabase(sql string) (*CreateDatabase, error) {
+	tokens, e := token.Tokenize(sql)
+	if e != nil {
+		return nil, e
+	}
+	return ProcessCreateDatabase(tokens)
+}
+
+func TestCreateDatabase(t *testing.T) {
+	db, e := createDatabase(`create database orcl
+		initparams(
+			'ctrlFile=/ctl/control1.ctl',
+			'ctrlFile=/ctl/control2.ctl',
+			'serverPort=9422',
+		)
+		user sys identified by 'change me'
+		logfile group 1 ('/redo/1a.log', '/redo/1b.log') size 1g,
+		        group 2 ('/redo/2a.log', '/redo/2b.log') size 1g
+		datafile '/data/system.dbf' size 1g autoextend on next 1g maxsize 10g
+		default tablespace users datafile '/data/users01.dbf' size 1g
+		default temp tablespace temp tempfile '/data/temp.dbf'
+		undo tablespace undo datafile '/data/undo.dbf' size 512m;`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if db.Name != "ORCL" || db.SysPassword != "change me" || len(db.InitParams) != 3 || db.InitParams[2] != "serverPort=9422" {
+		t.Fatalf("unexpected database: %+v", db)
+	}
+	if len(db.LogFiles) != 2 || len(db.LogFiles[1].Members) != 2 || db.LogFiles[1].Size != 1<<30 {
+		t.Errorf("unexpected log files: %+v", db.LogFiles)
+	}
+	if len(db.DataFiles) != 1 || db.DataFiles[0].Path != "/data/system.dbf" || db.DataFiles[0].Autoextend.MaxSize != 10<<30 {
+		t.Errorf("unexpected datafiles: %+v", db.DataFiles)
+	}
+	if ts := db.DefaultTablespace; ts == nil || ts.Name != "USERS" || ts.DataFiles[0].Size != 1<<30 {
+		t.Errorf("unexpected default tablespace: %+v", ts)
+	}
+	if ts := db.TempTablespace; ts == nil || ts.Name != "TEMP" || ts.DataFiles[0].Path != "/data/temp.dbf" {
+		t.Errorf("unexpected temporary tablespace: %+v", ts)
+	}
+	if ts := db.UndoTablespace; ts == nil || ts.Name != "UNDO" || ts.DataFiles[0].Size != 512<<20 {
+		t.Errorf("unexpected undo tablespace: %+v", ts)
+	}
+
+	// the clauses may come in any order, and only USER, LOGFILE, DATAFILE and UNDO are needed
+	if db, e = createDatabase(`create database test initparams()
+		undo tablespace undo datafile 'undo.dbf'
+		datafile 'system.dbf'
+		logfile 'a.log', 'b.log'
+		user sys identified by 'x'`); e != nil || db.InitParams != nil || db.DefaultTablespace != nil || db.TempTablespace != nil {
+		t.Errorf("unexpected database %+v, %v", db, e)
+	}
+
+	for _, sql := range []string{
+		`create database test`,
+		`create database test initparams('a=b' 'c=d') user sys identified by 'x' logfile 'a.log' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() logfile 'a.log' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() user sys identified by 'x' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() user sys identified by 'x' logfile 'a.log' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() user sys identified by 'x' logfile 'a.log' datafile 'system.dbf'`,
+		`create database test initparams() user sys identified by '' logfile 'a.log' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() user system identified by 'x' logfile 'a.log' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() user sys identified by 'x' logfile 'a.log' datafile 'system.dbf' datafile 'more.dbf' undo tablespace undo datafile 'undo.dbf'`,
+		`create database test initparams() user sys identified by 'x' logfile 'a.log' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf' default temp tablespace temp datafile 'temp.dbf'`,
+		`create database test initparams() user sys identified by 'x' logfile 'a.log' datafile 'system.dbf' undo tablespace undo datafile 'undo.dbf' archivelog`,
+	} {
+		if _, e = createDatabase(sql); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
@@ -1 +1,107 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_5012.htm
+
+CREATE [ UNIQUE ] INDEX [ schema. ] index
+  ON [ schema. ] table ( column [ ASC | DESC ] [, column [ ASC | DESC ] ]... )
+  [ TABLESPACE tablespace ] ;
+
+A UNIQUE index rejects a second row with the same key, unless every column of the key is NULL.
+
+*/
+
+type CreateIndex struct {
+	Schema      string
+	Name        string
+	Unique      bool
+	TableSchema string
+	Table       string
+	Columns     []*TIndexColumn
+	Tablespace  string
+}
+
+type TIndexColumn struct {
+	Name string
+	Desc bool
+}
+
+func ProcessCreateIndex(sql token.Tokens) (*CreateIndex, error) {
+	var err error
+	p := dml.NewParser(sql)
+	index := &CreateIndex{}
+
+	if err = p.ExpectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	index.Unique = p.AcceptKeyword("UNIQUE")
+	if err = p.ExpectKeyword("INDEX"); err != nil {
+		return nil, err
+	}
+
+	if index.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+	if p.AcceptPunct(".") {
+		index.Schema = index.Name
+		if index.Name, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectKeyword("ON"); err != nil {
+		return nil, err
+	}
+
+	if index.Table, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+	if p.AcceptPunct(".") {
+		index.TableSchema = index.Table
+		if index.Table, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		column := &TIndexColumn{}
+		if column.Name, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+		if p.AcceptKeyword("DESC") {
+			column.Desc = true
+		} else {
+			p.AcceptKeyword("ASC")
+		}
+		index.Columns = append(index.Columns, column)
+
+		if !p.AcceptPunct(",") {
+			break
+		}
+	}
+
+	if err = p.ExpectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("TABLESPACE") {
+		if index.Tablespace, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
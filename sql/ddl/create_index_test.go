@@ -0,0 +1,46 @@
+package ddl
+
+import (
+	"github.com/djbckr/godb/sql/token"
+	"testing"
+)
+
+func createIndex(sql string) (*CreateIndex, error) {
+	tokens, e := token.Tokenize(sql)
+	if e != nil {
+		return nil, e
+	}
+	return ProcessCreateIndex(tokens)
+}
+
+func TestCreateIndex(t *testing.T) {
+	index, e := createIndex(`create unique index app.orders_ix on app.orders (customer, "DATE" desc, id asc) tablespace indexes`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if index.Schema != "APP" || index.Name != "ORDERS_IX" || !index.Unique || index.TableSchema != "APP" ||
+		index.Table != "ORDERS" || index.Tablespace != "INDEXES" || len(index.Columns) != 3 {
+		t.Fatalf("unexpected index: %+v", index)
+	}
+	for i, expected := range []TIndexColumn{{"CUSTOMER", false}, {"DATE", true}, {"ID", false}} {
+		if *index.Columns[i] != expected {
+			t.Errorf("column %v: expected %+v, got %+v", i, expected, *index.Columns[i])
+		}
+	}
+
+	if index, e = createIndex(`create index ix on t (c)`); e != nil || index.Unique || index.Schema != "" || len(index.Columns) != 1 {
+		t.Errorf("unexpected index %+v, %v", index, e)
+	}
+
+	for _, sql := range []string{
+		`create index ix on t ()`,
+		`create index ix t (c)`,
+		`create index on t (c)`,
+		`create index ix on t (c nulls)`,
+		`create index ix on t (c) tablespace`,
+	} {
+		if _, e = createIndex(sql); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
@@ -1 +1,120 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_7002.htm
+
+CREATE TABLE [ schema. ] table
+  ( column_definition [, column_definition ]... )
+  [ TABLESPACE tablespace ] ;
+
+column_definition ::=
+  column datatype [ DEFAULT expr ] [ [ NOT ] NULL ]
+
+Reserved words cannot be used as table or column names unless they are quoted:
+CREATE TABLE "SELECT" ( ... ) is fine, CREATE TABLE SELECT ( ... ) is not.
+
+*/
+
+type CreateTable struct {
+	Schema     string
+	Name       string
+	Columns    []*TColumn
+	Tablespace string
+}
+
+type TColumn struct {
+	Name     string
+	DataType *dml.TDataType
+	Default  dml.Expr
+	NotNull  bool
+}
+
+func ProcessCreateTable(sql token.Tokens) (*CreateTable, error) {
+	var err error
+	p := dml.NewParser(sql)
+	table := &CreateTable{}
+
+	if err = p.ExpectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("TABLE"); err != nil {
+		return nil, err
+	}
+
+	if table.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+	if p.AcceptPunct(".") {
+		table.Schema = table.Name
+		if table.Name, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		column, err := parseColumn(p)
+		if err != nil {
+			return nil, err
+		}
+		table.Columns = append(table.Columns, column)
+
+		if !p.AcceptPunct(",") {
+			break
+		}
+	}
+
+	if err = p.ExpectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("TABLESPACE") {
+		if table.Tablespace, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+func parseColumn(p *dml.Parser) (*TColumn, error) {
+	var err error
+	column := &TColumn{}
+
+	if column.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+
+	if column.DataType, err = p.ParseDataType(); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("DEFAULT") {
+		if column.Default, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.AcceptKeyword("NOT") {
+		if err = p.ExpectKeyword("NULL"); err != nil {
+			return nil, err
+		}
+		column.NotNull = true
+	} else {
+		p.AcceptKeyword("NULL")
+	}
+
+	return column, nil
+}
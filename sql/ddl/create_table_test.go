@@ -0,0 +1,62 @@
+package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+	"strings"
+	"testing"
+)
+
+func createTable(sql string) (*CreateTable, error) {
+	tokens, e := token.Tokenize(sql)
+	if e != nil {
+		return nil, e
+	}
+	return ProcessCreateTable(tokens)
+}
+
+func TestCreateTable(t *testing.T) {
+	table, e := createTable(`create table app.orders (
+		id number(10) not null,
+		"DATE" date default sysdate not null,
+		note varchar2(200) null,
+		[select] char(1) default 'N'
+	) tablespace users`)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+	if table.Schema != "APP" || table.Name != "ORDERS" || table.Tablespace != "USERS" || len(table.Columns) != 4 {
+		t.Fatalf("unexpected table: %+v", table)
+	}
+	if c := table.Columns[0]; c.Name != "ID" || c.DataType.Name != "NUMBER" || !c.NotNull {
+		t.Errorf("unexpected column: %+v", c)
+	}
+	if c := table.Columns[1]; c.Name != "DATE" || c.Default == nil || !c.NotNull {
+		t.Errorf("unexpected column: %+v", c)
+	}
+	if c := table.Columns[3]; c.Name != "select" || c.Default == nil || c.NotNull {
+		t.Errorf("unexpected column: %+v", c)
+	}
+}
+
+func TestCreateTableReservedWords(t *testing.T) {
+	for _, sql := range []string{
+		`create table select (x number)`,
+		`create table t (from number)`,
+		`create table s.where (x number)`,
+	} {
+		_, e := createTable(sql)
+		if e == nil {
+			t.Errorf("expected error: %v", sql)
+			continue
+		}
+		if _, ok := e.(*dml.ParseError); !ok || !strings.Contains(e.Error(), "is a reserved word") {
+			t.Errorf("%v: unexpected error: %v", sql, e)
+		}
+	}
+
+	if _, e := createTable(`create table "SELECT" (level number, "FROM" number)`); e != nil {
+		t.Error(e)
+	}
+}
@@ -1 +1,218 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+	"github.com/djbckr/godb/sql/types"
+	"math"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_7003.htm
+
+CREATE { [ TEMPORARY ] TABLESPACE | UNDO TABLESPACE } tablespace
+  { DATAFILE | TEMPFILE } file_specification [, file_specification ]...
+  [ BLOCKSIZE size_clause ]
+  [ ONLINE | OFFLINE ] ;
+
+file_specification ::=
+  'filename' [ SIZE size_clause ] [ REUSE ] [ autoextend_clause ]
+
+autoextend_clause ::=
+AUTOEXTEND { OFF | ON [ NEXT size_clause ] [ MAXSIZE { UNLIMITED | size_clause } ] }
+
+size_clause ::=
+integer [ K | M | G | T ]
+
+A temporary tablespace names its files with TEMPFILE, any other with DATAFILE.
+
+*/
+
+type TTablespaceKind = int
+
+const (
+	TABLESPACE_PERMANENT TTablespaceKind = iota
+	TABLESPACE_TEMPORARY
+	TABLESPACE_UNDO
+)
+
+type CreateTablespace struct {
+	Name      string
+	Kind      TTablespaceKind
+	DataFiles []*TFileSpec
+	BlockSize int64 // 0 if not given
+	Offline   bool
+}
+
+// TFileSpec is a file_specification. Sizes are in bytes, and 0 when not given.
+type TFileSpec struct {
+	Path       string
+	Size       int64
+	Reuse      bool
+	Autoextend *TAutoextend // nil if not given
+}
+
+type TAutoextend struct {
+	On      bool
+	Next    int64
+	MaxSize int64 // 0 for UNLIMITED
+}
+
+func ProcessCreateTablespace(sql token.Tokens) (*CreateTablespace, error) {
+	var err error
+	p := dml.NewParser(sql)
+	tablespace := &CreateTablespace{}
+
+	if err = p.ExpectKeyword("CREATE"); err != nil {
+		return nil, err
+	}
+	if p.AcceptKeyword("TEMPORARY") {
+		tablespace.Kind = TABLESPACE_TEMPORARY
+	} else if p.AcceptKeyword("UNDO") {
+		tablespace.Kind = TABLESPACE_UNDO
+	}
+	if err = p.ExpectKeyword("TABLESPACE"); err != nil {
+		return nil, err
+	}
+
+	if tablespace.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+
+	if tablespace.DataFiles, err = parseFileSpecs(p, tablespace.Kind == TABLESPACE_TEMPORARY); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("BLOCKSIZE") {
+		if tablespace.BlockSize, err = parseSize(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.AcceptKeyword("OFFLINE") {
+		tablespace.Offline = true
+	} else {
+		p.AcceptKeyword("ONLINE")
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return tablespace, nil
+}
+
+// parseFileSpecs parses { DATAFILE | TEMPFILE } file_specification [, file_specification ]...
+func parseFileSpecs(p *dml.Parser, temporary bool) ([]*TFileSpec, error) {
+	keyword := "DATAFILE"
+	if temporary {
+		keyword = "TEMPFILE"
+	}
+	if err := p.ExpectKeyword(keyword); err != nil {
+		return nil, err
+	}
+
+	var files []*TFileSpec
+	for {
+		file, err := parseFileSpec(p)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+
+		if !p.AcceptPunct(",") {
+			return files, nil
+		}
+	}
+}
+
+func parseFileSpec(p *dml.Parser) (*TFileSpec, error) {
+	var err error
+	file := &TFileSpec{}
+
+	if file.Path, err = parseString(p); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("SIZE") {
+		if file.Size, err = parseSize(p); err != nil {
+			return nil, err
+		}
+	}
+
+	file.Reuse = p.AcceptKeyword("REUSE")
+
+	if p.PeekKeyword("AUTOEXTEND") {
+		if file.Autoextend, err = parseAutoextend(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return file, nil
+}
+
+func parseAutoextend(p *dml.Parser) (*TAutoextend, error) {
+	var err error
+	autoextend := &TAutoextend{}
+
+	if err = p.ExpectKeyword("AUTOEXTEND"); err != nil {
+		return nil, err
+	}
+	if p.AcceptKeyword("OFF") {
+		return autoextend, nil
+	}
+	if err = p.ExpectKeyword("ON"); err != nil {
+		return nil, p.Errorf("ON or OFF expected")
+	}
+	autoextend.On = true
+
+	if p.AcceptKeyword("NEXT") {
+		if autoextend.Next, err = parseSize(p); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.AcceptKeyword("MAXSIZE") && !p.AcceptKeyword("UNLIMITED") {
+		if autoextend.MaxSize, err = parseSize(p); err != nil {
+			return nil, err
+		}
+	}
+
+	return autoextend, nil
+}
+
+// parseString consumes a string literal.
+func parseString(p *dml.Parser) (string, error) {
+	tkn := p.Peek()
+	if tkn == nil || tkn.TokenType != token.TypeString {
+		return "", p.Errorf("string expected")
+	}
+	p.Next()
+	return tkn.Value.(string), nil
+}
+
+// parseSize parses a size_clause into bytes: a whole number, and K, M, G or T for kilobytes and so on.
+func parseSize(p *dml.Parser) (int64, error) {
+	tkn := p.Peek()
+	if tkn == nil || tkn.TokenType != token.TypeNumber {
+		return 0, p.Errorf("size expected")
+	}
+	n, ok := tkn.Value.(*types.Number).Int64()
+	if !ok || n < 0 {
+		return 0, p.Errorf("size must be a whole number")
+	}
+	p.Next()
+
+	for i, unit := range []string{"K", "M", "G", "T"} {
+		if p.AcceptKeyword(unit) {
+			shift := uint(10 * (i + 1))
+			if n > math.MaxInt64>>shift {
+				return 0, p.Errorf("size is too large")
+			}
+			n <<= shift
+			break
+		}
+	}
+
+	return n, nil
+}
@@ -0,0 +1,137 @@
+package ddl
+
+import (
+	"github.com/djbckr/godb/sql/token"
+	"testing"
+)
+
+func createTablespace(sql string) (*CreateTablespace, error) {
+	tokens, e := token.Tokenize(sql)
+	if e != nil {
+		return nil, e
+	}
+	return ProcessCreateTablespace(tokens)
+}
+
+func TestCreateTablespace(t *testing.T) {
+	ts, e := createTablespace(`create tablespace users
+		datafile '/data/users01.dbf' size 1g autoextend on next 1g maxsize 10g,
+		         '/data/users02.dbf' size 512m reuse autoextend off
+		blocksize 16k offline`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ts.Name != "USERS" || ts.Kind != TABLESPACE_PERMANENT || ts.BlockSize != 16<<10 || !ts.Offline || len(ts.DataFiles) != 2 {
+		t.Fatalf("unexpected tablespace: %+v", ts)
+	}
+	if f := ts.DataFiles[0]; f.Path != "/data/users01.dbf" || f.Size != 1<<30 || f.Reuse ||
+		*f.Autoextend != (TAutoextend{On: true, Next: 1 << 30, MaxSize: 10 << 30}) {
+		t.Errorf("unexpected file: %+v %+v", f, f.Autoextend)
+	}
+	if f := ts.DataFiles[1]; f.Size != 512<<20 || !f.Reuse || f.Autoextend.On {
+		t.Errorf("unexpected file: %+v %+v", f, f.Autoextend)
+	}
+
+	if ts, e = createTablespace(`create temporary tablespace temp tempfile 'temp.dbf' autoextend on maxsize unlimited`); e != nil ||
+		ts.Kind != TABLESPACE_TEMPORARY || ts.DataFiles[0].Size != 0 || ts.DataFiles[0].Autoextend.MaxSize != 0 {
+		t.Errorf("unexpected tablespace %+v, %v", ts, e)
+	}
+	if ts, e = createTablespace(`create undo tablespace undo datafile 'undo.dbf' size 100 online;`); e != nil ||
+		ts.Kind != TABLESPACE_UNDO || ts.DataFiles[0].Size != 100 || ts.Offline {
+		t.Errorf("unexpected tablespace %+v, %v", ts, e)
+	}
+
+	for _, sql := range []string{
+		`create tablespace users`,
+		`create tablespace users datafile users01`,
+		`create temporary tablespace temp datafile 'temp.dbf'`,
+		`create tablespace users datafile 'a.dbf' size 1.5g`,
+		`create tablespace users datafile 'a.dbf' size 10000000000t`,
+		`create tablespace users datafile 'a.dbf' autoextend maybe`,
+		`create tablespace users datafile 'a.dbf',`,
+	} {
+		if _, e = createTablespace(sql); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
+
+func TestAlterTablespace(t *testing.T) {
+	alter := func(sql string) *AlterTablespace {
+		tokens, e := token.Tokenize(sql)
+		if e != nil {
+			t.Fatal(e)
+		}
+		a, e := ProcessAlterTablespace(tokens)
+		if e != nil {
+			t.Fatalf("%v: %v", sql, e)
+		}
+		return a
+	}
+
+	if a := alter(`alter tablespace users add datafile 'u3.dbf' size 10m, 'u4.dbf'`); a.Action != TABLESPACE_ADD_FILE || len(a.DataFiles) != 2 {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	if a := alter(`alter tablespace temp add tempfile 't2.dbf'`); a.Action != TABLESPACE_ADD_FILE || len(a.DataFiles) != 1 {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	if a := alter(`alter tablespace users drop datafile 'u3.dbf'`); a.Action != TABLESPACE_DROP_FILE || a.DataFile != "u3.dbf" {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	if a := alter(`alter tablespace users datafile 'u1.dbf' resize 2g`); a.Action != TABLESPACE_RESIZE || a.DataFile != "u1.dbf" || a.Size != 2<<30 {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	if a := alter(`alter tablespace users resize 100m`); a.Action != TABLESPACE_RESIZE || a.DataFile != "" || a.Size != 100<<20 {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	if a := alter(`alter tablespace users autoextend on next 10m`); a.Action != TABLESPACE_AUTOEXTEND || !a.Autoextend.On || a.Autoextend.Next != 10<<20 {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	if a := alter(`alter tablespace users rename to app_data`); a.Action != TABLESPACE_RENAME || a.NewName != "APP_DATA" {
+		t.Errorf("unexpected alter: %+v", a)
+	}
+	for sql, action := range map[string]TTablespaceAction{
+		`alter tablespace users online`:         TABLESPACE_ONLINE,
+		`alter tablespace users offline normal`: TABLESPACE_OFFLINE,
+		`alter tablespace users read only`:      TABLESPACE_READ_ONLY,
+		`alter tablespace users read write`:     TABLESPACE_READ_WRITE,
+	} {
+		if a := alter(sql); a.Action != action {
+			t.Errorf("%v: expected action %v, got %v", sql, action, a.Action)
+		}
+	}
+
+	for _, sql := range []string{
+		`alter tablespace users`,
+		`alter tablespace users read`,
+		`alter tablespace users drop 'u3.dbf'`,
+		`alter tablespace users datafile 'u1.dbf' online`,
+	} {
+		tokens, _ := token.Tokenize(sql)
+		if _, e := ProcessAlterTablespace(tokens); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
+
+func TestDropTablespace(t *testing.T) {
+	for sql, expected := range map[string]DropTablespace{
+		`drop tablespace users`:                                   {"USERS", false, false},
+		`drop tablespace users including contents`:                {"USERS", true, false},
+		`drop tablespace users including contents keep datafiles`: {"USERS", true, false},
+		`drop tablespace users including contents and datafiles;`: {"USERS", true, true},
+	} {
+		tokens, _ := token.Tokenize(sql)
+		drop, e := ProcessDropTablespace(tokens)
+		if e != nil || *drop != expected {
+			t.Errorf("%v: expected %+v, got %+v, %v", sql, expected, drop, e)
+		}
+	}
+
+	for _, sql := range []string{`drop tablespace users including`, `drop tablespace users including contents and`} {
+		tokens, _ := token.Tokenize(sql)
+		if _, e := ProcessDropTablespace(tokens); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
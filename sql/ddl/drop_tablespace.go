@@ -1 +1,63 @@
 package ddl
+
+import (
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/token"
+)
+
+/*
+https://docs.oracle.com/cd/E11882_01/server.112/e41084/statements_9004.htm
+
+DROP TABLESPACE tablespace
+  [ INCLUDING CONTENTS [ { AND | KEEP } DATAFILES ] ] ;
+
+Without INCLUDING CONTENTS the tablespace must be empty. Its datafiles are only deleted with
+AND DATAFILES.
+
+*/
+
+type DropTablespace struct {
+	Name      string
+	Contents  bool
+	DataFiles bool
+}
+
+func ProcessDropTablespace(sql token.Tokens) (*DropTablespace, error) {
+	var err error
+	p := dml.NewParser(sql)
+	drop := &DropTablespace{}
+
+	if err = p.ExpectKeyword("DROP"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("TABLESPACE"); err != nil {
+		return nil, err
+	}
+
+	if drop.Name, err = p.Identifier(); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("INCLUDING") {
+		if err = p.ExpectKeyword("CONTENTS"); err != nil {
+			return nil, err
+		}
+		drop.Contents = true
+
+		if p.AcceptKeyword("AND") {
+			drop.DataFiles = true
+			err = p.ExpectKeyword("DATAFILES")
+		} else if p.AcceptKeyword("KEEP") {
+			err = p.ExpectKeyword("DATAFILES")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	return drop, nil
+}
@@ -1,4 +1,11 @@
 package dml
+
+import (
+	"github.com/djbckr/godb/sql/token"
+	"github.com/djbckr/godb/sql/types"
+	"math"
+)
+
 /*
 
 expr ::=
@@ -105,3 +112,927 @@ variable_expression ::=
 
 
 */
+
+// Expr is a node in an expression tree. Conditions are expressions too; they evaluate to
+// true, false or unknown.
+type Expr interface {
+	exprNode()
+}
+
+// TIdentifier is a possibly qualified name: column, [schema.]table.column, ROWID, ROWNUM,
+// sequence.NEXTVAL and so on. Names are resolved when the statement is planned.
+type TIdentifier struct {
+	Names []string
+}
+
+// TLiteral is a string or *types.Number constant, or NULL when Value is nil.
+type TLiteral struct {
+	Value interface{}
+}
+
+// TBind is a bind variable: :name, or ? numbered by its position in the statement starting at 1.
+type TBind struct {
+	Name     string // set for a named bind
+	Position int    // set for a positional bind
+}
+
+// TTypedLiteral is DATE 'text' or TIMESTAMP 'text'.
+type TTypedLiteral struct {
+	Type  string
+	Value string
+}
+
+// TIntervalLiteral is INTERVAL 'text' followed by an interval qualifier.
+type TIntervalLiteral struct {
+	Value     string
+	Qualifier *TIntervalQualifier
+}
+
+// TIntervalQualifier is DAY [(precision)] TO SECOND [(precision)], YEAR TO MONTH and the like.
+type TIntervalQualifier struct {
+	From          string
+	FromPrecision int // 0 when not given
+	To            string
+	ToPrecision   int
+}
+
+type TUnary struct {
+	Operator string // + - PRIOR NOT
+	Operand  Expr
+}
+
+type TBinary struct {
+	Operator string // * / + - || = <> != ^= < > <= >= AND OR
+	Left     Expr
+	Right    Expr
+}
+
+// TQuantified is the right side of a comparison with ANY, SOME or ALL.
+type TQuantified struct {
+	Quantifier string
+	Operand    Expr // TList or TSubquery
+}
+
+type TIsNull struct {
+	Operand Expr
+	Not     bool
+}
+
+type TLike struct {
+	Operand Expr
+	Pattern Expr
+	Escape  Expr
+	Not     bool
+}
+
+type TBetween struct {
+	Operand Expr
+	Low     Expr
+	High    Expr
+	Not     bool
+}
+
+type TIn struct {
+	Operand Expr
+	List    Expr // TList, TSubquery or a single expression
+	Not     bool
+}
+
+type TExists struct {
+	Query *Query
+}
+
+// TList is a parenthesized list of two or more expressions.
+type TList struct {
+	Items []Expr
+}
+
+// TSubquery is a scalar subquery, or the query of an IN or quantified comparison.
+type TSubquery struct {
+	Query *Query
+}
+
+type TCursor struct {
+	Query *Query
+}
+
+type TCase struct {
+	Operand Expr // set for simple_case_expression
+	Whens   []*TWhen
+	Else    Expr
+}
+
+type TWhen struct {
+	Condition Expr // comparison_expr for a simple case
+	Result    Expr
+}
+
+type TFunction struct {
+	Names    []string // [schema.][package.]function
+	Distinct bool
+	Star     bool // COUNT(*)
+	Args     []Expr
+	Over     *TOver
+}
+
+// TOver is the analytic clause of a function.
+type TOver struct {
+	PartitionBy []Expr
+	OrderBy     *TOrderBy
+	Window      *TWindow
+}
+
+type TWindow struct {
+	Unit  string // ROWS or RANGE
+	Start *TFrameBound
+	End   *TFrameBound // nil unless BETWEEN was used
+}
+
+type TFrameBound struct {
+	Bound  string // UNBOUNDED PRECEDING, UNBOUNDED FOLLOWING, CURRENT ROW, PRECEDING, FOLLOWING
+	Offset Expr   // for PRECEDING and FOLLOWING
+}
+
+type TCast struct {
+	Operand Expr
+	Type    *TDataType
+}
+
+// TConstructor is NEW [schema.]type_name ( args ). Without NEW, a constructor looks like a function call.
+type TConstructor struct {
+	Names []string
+	Args  []Expr
+}
+
+type TAtTimeZone struct {
+	Operand Expr
+	Local   bool
+	Zone    Expr
+}
+
+// TInterval is ( expr1 - expr2 ) followed by an interval qualifier.
+type TInterval struct {
+	Operand   Expr
+	Qualifier *TIntervalQualifier
+}
+
+// TDataType is a type name as used by CAST and column definitions.
+type TDataType struct {
+	Name string // may be several words: DOUBLE PRECISION, TIMESTAMP WITH TIME ZONE
+	Args []int  // length, or precision and scale
+}
+
+func (*TIdentifier) exprNode()      {}
+func (*TLiteral) exprNode()         {}
+func (*TBind) exprNode()            {}
+func (*TTypedLiteral) exprNode()    {}
+func (*TIntervalLiteral) exprNode() {}
+func (*TUnary) exprNode()           {}
+func (*TBinary) exprNode()          {}
+func (*TQuantified) exprNode()      {}
+func (*TIsNull) exprNode()          {}
+func (*TLike) exprNode()            {}
+func (*TBetween) exprNode()         {}
+func (*TIn) exprNode()              {}
+func (*TExists) exprNode()          {}
+func (*TList) exprNode()            {}
+func (*TSubquery) exprNode()        {}
+func (*TCursor) exprNode()          {}
+func (*TCase) exprNode()            {}
+func (*TFunction) exprNode()        {}
+func (*TCast) exprNode()            {}
+func (*TConstructor) exprNode()     {}
+func (*TAtTimeZone) exprNode()      {}
+func (*TInterval) exprNode()        {}
+
+var intervalUnits = []string{"YEAR", "MONTH", "DAY", "HOUR", "MINUTE", "SECOND"}
+
+// ParseExpr parses one expression or condition, leaving the parser on the first token after it.
+//
+// Precedence, from loosest to tightest:
+//
+//	OR
+//	AND
+//	NOT
+//	comparison, IS [NOT] NULL, [NOT] LIKE, [NOT] BETWEEN, [NOT] IN
+//	+ - ||
+//	* /
+//	unary + - PRIOR
+//	AT LOCAL, AT TIME ZONE
+func (p *Parser) ParseExpr() (Expr, error) {
+	return p.parseOr()
+}
+
+func (p *Parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.AcceptKeyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &TBinary{Operator: "OR", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseAnd() (Expr, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.AcceptKeyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &TBinary{Operator: "AND", Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseNot() (Expr, error) {
+	if p.AcceptKeyword("NOT") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &TUnary{Operator: "NOT", Operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *Parser) parseComparison() (Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	if op := p.acceptComparisonOperator(); op != "" {
+		if p.PeekKeyword("ANY", "SOME", "ALL") {
+			quantified := &TQuantified{Quantifier: p.Next().Value.(string)}
+			if quantified.Operand, err = p.parseParenthesized(); err != nil {
+				return nil, err
+			}
+			return &TBinary{Operator: op, Left: left, Right: quantified}, nil
+		}
+
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &TBinary{Operator: op, Left: left, Right: right}, nil
+	}
+
+	if p.AcceptKeyword("IS") {
+		isNull := &TIsNull{Operand: left, Not: p.AcceptKeyword("NOT")}
+		return isNull, p.ExpectKeyword("NULL")
+	}
+
+	not := false
+	if p.PeekKeyword("NOT") && isKeyword(p.PeekAt(1), "LIKE", "BETWEEN", "IN") {
+		p.Next()
+		not = true
+	}
+
+	switch {
+	case p.AcceptKeyword("LIKE"):
+		like := &TLike{Operand: left, Not: not}
+		if like.Pattern, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+		if p.AcceptKeyword("ESCAPE") {
+			if like.Escape, err = p.parseAdditive(); err != nil {
+				return nil, err
+			}
+		}
+		return like, nil
+
+	case p.AcceptKeyword("BETWEEN"):
+		between := &TBetween{Operand: left, Not: not}
+		if between.Low, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectKeyword("AND"); err != nil {
+			return nil, err
+		}
+		if between.High, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+		return between, nil
+
+	case p.AcceptKeyword("IN"):
+		in := &TIn{Operand: left, Not: not}
+		if in.List, err = p.parseParenthesized(); err != nil {
+			return nil, err
+		}
+		return in, nil
+	}
+
+	return left, nil
+}
+
+// acceptComparisonOperator consumes a comparison operator, returning "" if there is none.
+func (p *Parser) acceptComparisonOperator() string {
+	tkn := p.Peek()
+
+	switch {
+	case isPunct(tkn, "="), isPunct(tkn, "<"), isPunct(tkn, ">"),
+		isOperator(tkn, "<="), isOperator(tkn, ">="), isOperator(tkn, "<>"), isOperator(tkn, "!="), isOperator(tkn, "^="):
+		p.Next()
+		return tkn.Value.(string)
+	}
+
+	return ""
+}
+
+func (p *Parser) parseAdditive() (Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var op string
+		switch {
+		case p.PeekPunct("+"), p.PeekPunct("-"), isOperator(p.Peek(), "||"):
+			op = p.Next().Value.(string)
+		default:
+			return left, nil
+		}
+
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &TBinary{Operator: op, Left: left, Right: right}
+	}
+}
+
+func (p *Parser) parseMultiplicative() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.PeekPunct("*") || p.PeekPunct("/") {
+		op := p.Next().Value.(string)
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &TBinary{Operator: op, Left: left, Right: right}
+	}
+
+	return left, nil
+}
+
+func (p *Parser) parseUnary() (Expr, error) {
+	if p.PeekPunct("+") || p.PeekPunct("-") || p.PeekKeyword("PRIOR") {
+		op := p.Next().Value.(string)
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &TUnary{Operator: op, Operand: operand}, nil
+	}
+
+	operand, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	// datetime_expression
+	for p.AcceptKeyword("AT") {
+		atz := &TAtTimeZone{Operand: operand}
+		if p.AcceptKeyword("LOCAL") {
+			atz.Local = true
+		} else {
+			if err = p.ExpectKeyword("TIME"); err != nil {
+				return nil, err
+			}
+			if err = p.ExpectKeyword("ZONE"); err != nil {
+				return nil, err
+			}
+			if atz.Zone, err = p.parsePrimary(); err != nil {
+				return nil, err
+			}
+		}
+		operand = atz
+	}
+
+	return operand, nil
+}
+
+func (p *Parser) parsePrimary() (Expr, error) {
+	var err error
+	tkn := p.Peek()
+
+	switch {
+	case tkn == nil:
+		return nil, p.Errorf("expression expected")
+
+	case isPunct(tkn, "("):
+		expr, err := p.parseParenthesized()
+		if err != nil {
+			return nil, err
+		}
+		if p.PeekKeyword(intervalUnits...) {
+			interval := &TInterval{Operand: expr}
+			if interval.Qualifier, err = p.parseIntervalQualifier(); err != nil {
+				return nil, err
+			}
+			return interval, nil
+		}
+		return expr, nil
+
+	case tkn.TokenType == token.TypeString, tkn.TokenType == token.TypeNumber:
+		p.Next()
+		return &TLiteral{Value: tkn.Value}, nil
+
+	case tkn.TokenType == token.TypeBind:
+		return p.parseBind()
+
+	case isKeyword(tkn, "NULL"):
+		p.Next()
+		return &TLiteral{}, nil
+
+	case isKeyword(tkn, "DATE", "TIMESTAMP") && p.PeekAt(1) != nil && p.PeekAt(1).TokenType == token.TypeString:
+		p.Next()
+		return &TTypedLiteral{Type: tkn.Value.(string), Value: p.Next().Value.(string)}, nil
+
+	case isKeyword(tkn, "INTERVAL") && p.PeekAt(1) != nil && p.PeekAt(1).TokenType == token.TypeString:
+		p.Next()
+		literal := &TIntervalLiteral{Value: p.Next().Value.(string)}
+		if literal.Qualifier, err = p.parseIntervalQualifier(); err != nil {
+			return nil, err
+		}
+		return literal, nil
+
+	case isKeyword(tkn, "CASE"):
+		p.Next()
+		return p.parseCase()
+
+	case isKeyword(tkn, "EXISTS"):
+		p.Next()
+		exists := &TExists{}
+		exists.Query, err = p.parseSubquery()
+		return exists, err
+
+	case isKeyword(tkn, "CURSOR"):
+		p.Next()
+		cursor := &TCursor{}
+		cursor.Query, err = p.parseSubquery()
+		return cursor, err
+
+	case isKeyword(tkn, "CAST") && isPunct(p.PeekAt(1), "("):
+		p.Next()
+		return p.parseCast()
+
+	case isKeyword(tkn, "NEW"):
+		p.Next()
+		constructor := &TConstructor{}
+		if constructor.Names, err = p.parseQualifiedName(); err != nil {
+			return nil, err
+		}
+		constructor.Args, _, _, err = p.parseArguments()
+		return constructor, err
+
+	case isName(tkn):
+		names, err := p.parseQualifiedName()
+		if err != nil {
+			return nil, err
+		}
+		if !p.PeekPunct("(") {
+			return &TIdentifier{Names: names}, nil
+		}
+		return p.parseFunction(names)
+	}
+
+	return nil, p.Errorf("expression expected")
+}
+
+// parseBind consumes a bind variable. A statement uses either named or positional binds, never both.
+func (p *Parser) parseBind() (Expr, error) {
+	bind := &TBind{}
+
+	switch v := p.Peek().Value.(type) {
+	case string:
+		bind.Name = v
+	case int:
+		bind.Position = v
+	}
+
+	if len(p.binds) > 0 && (p.binds[0].Name == "") != (bind.Name == "") {
+		return nil, p.Errorf("named and positional bind variables cannot be mixed in one statement")
+	}
+
+	p.Next()
+	p.binds = append(p.binds, bind)
+
+	return bind, nil
+}
+
+func (p *Parser) parseQualifiedName() ([]string, error) {
+	var names []string
+	for {
+		name, err := p.Identifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+
+		if !p.AcceptPunct(".") {
+			return names, nil
+		}
+	}
+}
+
+// parseParenthesized parses ( subquery ), ( expr ) or ( expr, expr... ).
+func (p *Parser) parseParenthesized() (Expr, error) {
+	if p.PeekPunct("(") && isKeyword(p.PeekAt(1), "SELECT", "WITH", "FROM") {
+		query, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+		return &TSubquery{Query: query}, nil
+	}
+
+	if err := p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+
+	var items []Expr
+	for {
+		item, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		if !p.AcceptPunct(",") {
+			break
+		}
+	}
+
+	if err := p.ExpectPunct(")"); err != nil {
+		return nil, err
+	}
+
+	if len(items) == 1 {
+		return items[0], nil
+	}
+	return &TList{Items: items}, nil
+}
+
+// parseSubquery parses ( subquery )
+func (p *Parser) parseSubquery() (*Query, error) {
+	if err := p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	return query, p.ExpectPunct(")")
+}
+
+func (p *Parser) parseCase() (Expr, error) {
+	var err error
+	expr := &TCase{}
+
+	if !p.PeekKeyword("WHEN") {
+		if expr.Operand, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	for p.AcceptKeyword("WHEN") {
+		when := &TWhen{}
+		if when.Condition, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectKeyword("THEN"); err != nil {
+			return nil, err
+		}
+		if when.Result, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+		expr.Whens = append(expr.Whens, when)
+	}
+
+	if len(expr.Whens) == 0 {
+		return nil, p.Errorf("WHEN expected")
+	}
+
+	if p.AcceptKeyword("ELSE") {
+		if expr.Else, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return expr, p.ExpectKeyword("END")
+}
+
+func (p *Parser) parseCast() (Expr, error) {
+	var err error
+	cast := &TCast{}
+
+	if err = p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+	if cast.Operand, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("AS"); err != nil {
+		return nil, err
+	}
+	if cast.Type, err = p.ParseDataType(); err != nil {
+		return nil, err
+	}
+
+	return cast, p.ExpectPunct(")")
+}
+
+func (p *Parser) parseFunction(names []string) (Expr, error) {
+	var err error
+	fn := &TFunction{Names: names}
+
+	if fn.Args, fn.Distinct, fn.Star, err = p.parseArguments(); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("OVER") {
+		if fn.Over, err = p.parseOver(); err != nil {
+			return nil, err
+		}
+	}
+
+	return fn, nil
+}
+
+// parseArguments parses ( [ DISTINCT | ALL ] [ expr [, expr]... ] ) or ( * )
+func (p *Parser) parseArguments() (args []Expr, distinct bool, star bool, err error) {
+	if err = p.ExpectPunct("("); err != nil {
+		return
+	}
+
+	if p.AcceptPunct(")") {
+		return
+	}
+
+	if p.AcceptPunct("*") {
+		star = true
+		err = p.ExpectPunct(")")
+		return
+	}
+
+	if p.AcceptKeyword("DISTINCT") || p.AcceptKeyword("UNIQUE") {
+		distinct = true
+	} else {
+		p.AcceptKeyword("ALL")
+	}
+
+	for {
+		var arg Expr
+		if arg, err = p.ParseExpr(); err != nil {
+			return
+		}
+		args = append(args, arg)
+
+		if !p.AcceptPunct(",") {
+			break
+		}
+	}
+
+	err = p.ExpectPunct(")")
+	return
+}
+
+// parseOver parses ( [ PARTITION BY expr, ... ] [ order_by_clause [ windowing_clause ] ] )
+func (p *Parser) parseOver() (*TOver, error) {
+	var err error
+	over := &TOver{}
+
+	if err = p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("PARTITION") {
+		if err = p.ExpectKeyword("BY"); err != nil {
+			return nil, err
+		}
+		for {
+			expr, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			over.PartitionBy = append(over.PartitionBy, expr)
+
+			if !p.AcceptPunct(",") {
+				break
+			}
+		}
+	}
+
+	if p.AcceptKeyword("ORDER") {
+		if over.OrderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+
+		if p.PeekKeyword("ROWS", "RANGE") {
+			if over.Window, err = p.parseWindow(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return over, p.ExpectPunct(")")
+}
+
+func (p *Parser) parseWindow() (*TWindow, error) {
+	var err error
+	window := &TWindow{Unit: p.Next().Value.(string)}
+
+	if !p.AcceptKeyword("BETWEEN") {
+		window.Start, err = p.parseFrameBound()
+		return window, err
+	}
+
+	if window.Start, err = p.parseFrameBound(); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("AND"); err != nil {
+		return nil, err
+	}
+	window.End, err = p.parseFrameBound()
+
+	return window, err
+}
+
+func (p *Parser) parseFrameBound() (*TFrameBound, error) {
+	var err error
+	bound := &TFrameBound{}
+
+	switch {
+	case p.AcceptKeyword("UNBOUNDED"):
+		switch {
+		case p.AcceptKeyword("PRECEDING"):
+			bound.Bound = "UNBOUNDED PRECEDING"
+		case p.AcceptKeyword("FOLLOWING"):
+			bound.Bound = "UNBOUNDED FOLLOWING"
+		default:
+			return nil, p.Errorf("PRECEDING or FOLLOWING expected")
+		}
+	case p.AcceptKeyword("CURRENT"):
+		bound.Bound = "CURRENT ROW"
+		err = p.ExpectKeyword("ROW")
+	default:
+		if bound.Offset, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+		switch {
+		case p.AcceptKeyword("PRECEDING"):
+			bound.Bound = "PRECEDING"
+		case p.AcceptKeyword("FOLLOWING"):
+			bound.Bound = "FOLLOWING"
+		default:
+			return nil, p.Errorf("PRECEDING or FOLLOWING expected")
+		}
+	}
+
+	return bound, err
+}
+
+// parseIntervalQualifier parses unit [ (precision) ] [ TO unit [ (precision) ] ]
+func (p *Parser) parseIntervalQualifier() (*TIntervalQualifier, error) {
+	var err error
+	qualifier := &TIntervalQualifier{}
+
+	if !p.PeekKeyword(intervalUnits...) {
+		return nil, p.Errorf("interval unit expected")
+	}
+	qualifier.From = p.Next().Value.(string)
+
+	if p.AcceptPunct("(") {
+		if qualifier.FromPrecision, err = p.parseInteger(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.AcceptKeyword("TO") {
+		return qualifier, nil
+	}
+
+	if !p.PeekKeyword(intervalUnits...) {
+		return nil, p.Errorf("interval unit expected")
+	}
+	qualifier.To = p.Next().Value.(string)
+
+	if p.AcceptPunct("(") {
+		if qualifier.ToPrecision, err = p.parseInteger(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	return qualifier, nil
+}
+
+// multiWordTypes lists the type names that continue past their first word.
+var multiWordTypes = map[string][][]string{
+	"DOUBLE":    {{"PRECISION"}},
+	"CHARACTER": {{"VARYING"}},
+	"CHAR":      {{"VARYING"}},
+	"NATIONAL":  {{"CHARACTER", "VARYING"}, {"CHARACTER"}, {"CHAR", "VARYING"}, {"CHAR"}},
+	"TIMESTAMP": {{"WITH", "LOCAL", "TIME", "ZONE"}, {"WITH", "TIME", "ZONE"}, {"WITHOUT", "TIME", "ZONE"}},
+	"LONG":      {{"RAW"}},
+}
+
+// ParseDataType parses a type name with its optional length or precision and scale.
+func (p *Parser) ParseDataType() (*TDataType, error) {
+	tkn := p.Peek()
+	if tkn == nil || tkn.TokenType != token.TypeToken {
+		return nil, p.Errorf("data type expected")
+	}
+	p.Next()
+
+	dataType := &TDataType{Name: tkn.Value.(string)}
+
+	if dataType.Name == "INTERVAL" {
+		qualifier, err := p.parseIntervalQualifier()
+		if err != nil {
+			return nil, err
+		}
+		dataType.Name += " " + qualifier.From
+		if qualifier.To != "" {
+			dataType.Name += " TO " + qualifier.To
+		}
+		return dataType, nil
+	}
+
+	for _, words := range multiWordTypes[dataType.Name] {
+		if p.peekWords(words) {
+			for _, word := range words {
+				dataType.Name += " " + word
+			}
+			p.idx += len(words)
+			break
+		}
+	}
+
+	if p.AcceptPunct("(") {
+		for {
+			n, err := p.parseInteger()
+			if err != nil {
+				return nil, err
+			}
+			dataType.Args = append(dataType.Args, n)
+
+			if !p.AcceptPunct(",") {
+				break
+			}
+		}
+		if err := p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+	}
+
+	return dataType, nil
+}
+
+func (p *Parser) peekWords(words []string) bool {
+	for i, word := range words {
+		if !isKeyword(p.PeekAt(i), word) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseInteger consumes a number token holding a whole number.
+func (p *Parser) parseInteger() (int, error) {
+	tkn := p.Peek()
+	if tkn == nil || tkn.TokenType != token.TypeNumber {
+		return 0, p.Errorf("integer expected")
+	}
+
+	n, ok := tkn.Value.(*types.Number).Int64()
+	if !ok || n < 0 || n > math.MaxInt32 {
+		return 0, p.Errorf("integer expected")
+	}
+
+	p.Next()
+	return int(n), nil
+}
@@ -0,0 +1,195 @@
+package dml
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/sql/token"
+	"strings"
+	"testing"
+)
+
+// show renders an expression fully parenthesized so the tree shape can be compared as text.
+func show(e Expr) string {
+	switch v := e.(type) {
+	case *TIdentifier:
+		return strings.Join(v.Names, ".")
+	case *TLiteral:
+		if v.Value == nil {
+			return "NULL"
+		}
+		if s, ok := v.Value.(string); ok {
+			return "'" + s + "'"
+		}
+		return fmt.Sprint(v.Value)
+	case *TTypedLiteral:
+		return v.Type + " '" + v.Value + "'"
+	case *TIntervalLiteral:
+		return "INTERVAL '" + v.Value + "' " + v.Qualifier.From + " TO " + v.Qualifier.To
+	case *TUnary:
+		return "(" + v.Operator + " " + show(v.Operand) + ")"
+	case *TBinary:
+		return "(" + show(v.Left) + " " + v.Operator + " " + show(v.Right) + ")"
+	case *TQuantified:
+		return v.Quantifier + show(v.Operand)
+	case *TIsNull:
+		if v.Not {
+			return "(" + show(v.Operand) + " IS NOT NULL)"
+		}
+		return "(" + show(v.Operand) + " IS NULL)"
+	case *TLike:
+		return "(" + show(v.Operand) + " LIKE " + show(v.Pattern) + ")"
+	case *TBetween:
+		return "(" + show(v.Operand) + " BETWEEN " + show(v.Low) + " AND " + show(v.High) + ")"
+	case *TIn:
+		if v.Not {
+			return "(" + show(v.Operand) + " NOT IN " + show(v.List) + ")"
+		}
+		return "(" + show(v.Operand) + " IN " + show(v.List) + ")"
+	case *TExists:
+		return "EXISTS(...)"
+	case *TList:
+		var items []string
+		for _, item := range v.Items {
+			items = append(items, show(item))
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case *TSubquery:
+		return "(subquery)"
+	case *TCase:
+		s := "CASE"
+		if v.Operand != nil {
+			s += " " + show(v.Operand)
+		}
+		for _, when := range v.Whens {
+			s += " WHEN " + show(when.Condition) + " THEN " + show(when.Result)
+		}
+		if v.Else != nil {
+			s += " ELSE " + show(v.Else)
+		}
+		return s + " END"
+	case *TFunction:
+		var args []string
+		for _, arg := range v.Args {
+			args = append(args, show(arg))
+		}
+		if v.Star {
+			args = append(args, "*")
+		}
+		return strings.Join(v.Names, ".") + "(" + strings.Join(args, ", ") + ")"
+	case *TCast:
+		return "CAST(" + show(v.Operand) + " AS " + v.Type.Name + fmt.Sprint(v.Type.Args) + ")"
+	case *TConstructor:
+		return "NEW " + strings.Join(v.Names, ".") + fmt.Sprintf("(%v)", len(v.Args))
+	case *TAtTimeZone:
+		if v.Local {
+			return "(" + show(v.Operand) + " AT LOCAL)"
+		}
+		return "(" + show(v.Operand) + " AT TIME ZONE " + show(v.Zone) + ")"
+	case *TInterval:
+		return "(" + show(v.Operand) + " " + v.Qualifier.From + " TO " + v.Qualifier.To + ")"
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+func parseExpression(t *testing.T, sql string) Expr {
+	tokens, e := token.Tokenize(sql)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	p := NewParser(tokens)
+
+	expr, e := p.ParseExpr()
+
+	if e != nil {
+		t.Fatalf("%v: %v", sql, e)
+	}
+
+	if !p.AtEnd() {
+		t.Fatalf("%v: not fully consumed, stopped near %v", sql, p.Peek().Value)
+	}
+
+	return expr
+}
+
+func TestExpressions(t *testing.T) {
+
+	tests := []struct {
+		sql      string
+		expected string
+	}{
+		{`a + b * c`, `(A + (B * C))`},
+		{`(a + b) * c`, `((A + B) * C)`},
+		{`a - b - c`, `((A - B) - C)`},
+		{`a || b || 'x'`, `((A || B) || 'x')`},
+		{`a + b || c`, `((A + B) || C)`},
+		{`-a * b`, `((- A) * B)`},
+		{`prior id = mgr`, `((PRIOR ID) = MGR)`},
+		{`a = 1 or b = 2 and c = 3`, `((A = 1) OR ((B = 2) AND (C = 3)))`},
+		{`not a = 1 and b <> 2`, `((NOT (A = 1)) AND (B <> 2))`},
+		{`a <= 1 and b >= 2 and c != 3 and d ^= 4`, `((((A <= 1) AND (B >= 2)) AND (C != 3)) AND (D ^= 4))`},
+		{`x is not null`, `(X IS NOT NULL)`},
+		{`x not in (1, 2, 3)`, `(X NOT IN [1, 2, 3])`},
+		{`x in (select 1 from dual)`, `(X IN (subquery))`},
+		{`x between a + 1 and b`, `(X BETWEEN (A + 1) AND B)`},
+		{`name like 'A%'`, `(NAME LIKE 'A%')`},
+		{`x = any (1, 2)`, `(X = ANY[1, 2])`},
+		{`exists (select * from t)`, `EXISTS(...)`},
+		{`s.t.c + t.rowid`, `(S.T.C + T.ROWID)`},
+		{`seq.nextval`, `SEQ.NEXTVAL`},
+		{`count(*)`, `COUNT(*)`},
+		{`nvl(a, 'x')`, `NVL(A, 'x')`},
+		{`case x when 1 then 'a' else 'b' end`, `CASE X WHEN 1 THEN 'a' ELSE 'b' END`},
+		{`case when x > 1 then 'a' end`, `CASE WHEN (X > 1) THEN 'a' END`},
+		{`cast(x as number(10, 2))`, `CAST(X AS NUMBER[10 2])`},
+		{`cast(x as double precision)`, `CAST(X AS DOUBLE PRECISION[])`},
+		{`ts at time zone 'UTC'`, `(TS AT TIME ZONE 'UTC')`},
+		{`ts at local`, `(TS AT LOCAL)`},
+		{`(a - b) day to second`, `((A - B) DAY TO SECOND)`},
+		{`interval '1-2' year to month`, `INTERVAL '1-2' YEAR TO MONTH`},
+		{`date '2020-01-01'`, `DATE '2020-01-01'`},
+		{`new point(1, 2)`, `NEW POINT(2)`},
+		{`(1, 2)`, `[1, 2]`},
+		{`null`, `NULL`},
+		{`(select max(x) from t) + 1`, `((subquery) + 1)`},
+	}
+
+	for _, test := range tests {
+		if actual := show(parseExpression(t, test.sql)); actual != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.sql, test.expected, actual)
+		}
+	}
+}
+
+func TestAnalyticFunction(t *testing.T) {
+	expr := parseExpression(t, `sum(x) over (partition by a, b order by c desc rows between unbounded preceding and current row)`)
+
+	fn, ok := expr.(*TFunction)
+	if !ok || fn.Over == nil {
+		t.Fatal("expected analytic function")
+	}
+	if len(fn.Over.PartitionBy) != 2 || fn.Over.OrderBy == nil || !fn.Over.OrderBy.Items[0].Desc {
+		t.Error("analytic clause not parsed")
+	}
+	if fn.Over.Window == nil || fn.Over.Window.Start.Bound != "UNBOUNDED PRECEDING" || fn.Over.Window.End.Bound != "CURRENT ROW" {
+		t.Error("window not parsed")
+	}
+
+	expr = parseExpression(t, `count(distinct x)`)
+
+	if fn, ok = expr.(*TFunction); !ok || !fn.Distinct {
+		t.Error("expected count(distinct x)")
+	}
+}
+
+func TestExpressionErrors(t *testing.T) {
+	for _, sql := range []string{`a +`, `case end`, `(a, b`, `x between 1`, `x is 1`, `cast(x number)`, `f(a,)`, `a < = b`} {
+		tokens, e := token.Tokenize(sql)
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, e = NewParser(tokens).ParseExpr(); e == nil {
+			t.Errorf("expected error: %v", sql)
+		}
+	}
+}
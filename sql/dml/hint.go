@@ -0,0 +1,231 @@
+package dml
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/sql/token"
+)
+
+/*
+
+hint:
+  { /*+ hint_item [ hint_item ]... *\/
+  | --+ hint_item [ hint_item ]...
+  }
+
+hint_item:
+  { INDEX ( table [ index ]... )
+  | NO_INDEX ( table [ index ]... )
+  | FULL ( table )
+  | LEADING ( table [ table ]... )
+  | ORDERED
+  | USE_NL ( table [ table ]... )
+  | USE_HASH ( table [ table ]... )
+  | USE_MERGE ( table [ table ]... )
+  | PARALLEL [ ( [ table ] [ degree ] ) ]
+  | NO_PARALLEL [ ( table ) ]
+  | CACHE ( table )
+  | NOCACHE ( table )
+  }
+
+Arguments may be separated by spaces or commas. A hint that is not understood is ignored with a
+warning; it never makes the statement fail.
+
+*/
+
+type THintKind = int
+
+const (
+	HINT_INDEX THintKind = iota
+	HINT_NO_INDEX
+	HINT_FULL
+	HINT_LEADING
+	HINT_ORDERED
+	HINT_USE_NL
+	HINT_USE_HASH
+	HINT_USE_MERGE
+	HINT_PARALLEL
+	HINT_NO_PARALLEL
+	HINT_CACHE
+	HINT_NOCACHE
+)
+
+var hintKinds = map[string]THintKind{
+	"INDEX":       HINT_INDEX,
+	"NO_INDEX":    HINT_NO_INDEX,
+	"FULL":        HINT_FULL,
+	"LEADING":     HINT_LEADING,
+	"ORDERED":     HINT_ORDERED,
+	"USE_NL":      HINT_USE_NL,
+	"USE_HASH":    HINT_USE_HASH,
+	"USE_MERGE":   HINT_USE_MERGE,
+	"PARALLEL":    HINT_PARALLEL,
+	"NO_PARALLEL": HINT_NO_PARALLEL,
+	"CACHE":       HINT_CACHE,
+	"NOCACHE":     HINT_NOCACHE,
+}
+
+// THint is one optimizer directive.
+type THint struct {
+	Kind    THintKind
+	Name    string   // the hint as written, in upper case
+	Tables  []string // the tables or aliases it applies to; for LEADING, in join order
+	Indexes []string // INDEX and NO_INDEX: the indexes to choose from or avoid; none means any index
+	Degree  int      // PARALLEL: the degree asked for; 0 leaves it to the server
+}
+
+// parseHints reads the hints written just before the current token, which for SELECT /*+ ... */
+// is the keyword that owns them. Hints anywhere else are just comments.
+func (p *Parser) parseHints() []*THint {
+	var hints []*THint
+	for _, tkn := range p.hints[p.idx] {
+		hints = append(hints, p.parseHint(tkn)...)
+	}
+	return hints
+}
+
+// parseHint reads the directives of one hint token. Anything it cannot use becomes a warning.
+func (p *Parser) parseHint(hint *token.Token) []*THint {
+
+	tokens, err := token.Tokenize(hint.Value.(string))
+	if err != nil {
+		p.warnings = append(p.warnings, fmt.Sprintf("Hint ignored at %v: %v", hint.Start, err))
+		return nil
+	}
+
+	hp := NewParser(tokens)
+
+	var hints []*THint
+
+	for !hp.AtEnd() {
+		start := hp.idx
+		item, err := hp.parseHintItem()
+		if err == nil {
+			hints = append(hints, item)
+			continue
+		}
+
+		// report where the problem is in the statement, then carry on with the next hint
+		err.Position = hintPosition(hint, err.Position)
+		p.warnings = append(p.warnings, "Hint ignored: "+err.Error())
+
+		hp.idx = start + 1
+		for !hp.AtEnd() && !hp.atHintItem() {
+			hp.idx++
+		}
+	}
+
+	return hints
+}
+
+// atHintItem reports whether the current token could start the next hint item: a word that is not
+// inside parentheses.
+func (p *Parser) atHintItem() bool {
+	depth := 0
+	for _, tkn := range p.tokens[:p.idx] {
+		if isPunct(tkn, "(") {
+			depth++
+		} else if isPunct(tkn, ")") && depth > 0 {
+			depth--
+		}
+	}
+	tkn := p.Peek()
+	return depth == 0 && tkn.TokenType == token.TypeToken
+}
+
+func (p *Parser) parseHintItem() (*THint, *ParseError) {
+
+	tkn := p.Peek()
+	if tkn.TokenType != token.TypeToken {
+		return nil, p.Errorf("hint expected")
+	}
+
+	kind, ok := hintKinds[tkn.Value.(string)]
+	if !ok {
+		return nil, p.Errorf("unknown hint")
+	}
+
+	p.Next()
+	hint := &THint{Kind: kind, Name: tkn.Value.(string)}
+
+	if !p.AcceptPunct("(") {
+		switch kind {
+		case HINT_ORDERED, HINT_PARALLEL, HINT_NO_PARALLEL:
+			return hint, nil
+		}
+		return nil, p.Errorf("'(' expected")
+	}
+
+	if kind == HINT_ORDERED {
+		return nil, p.Errorf("ORDERED takes no arguments")
+	}
+
+	// arguments are names and numbers, optionally separated by commas
+	var names []string
+	var numbers []int
+
+	for !p.AcceptPunct(")") {
+		tkn = p.Peek()
+		switch {
+		case tkn == nil:
+			return nil, p.Errorf("')' expected")
+		case p.AcceptPunct(","):
+		case tkn.TokenType == token.TypeToken:
+			if len(numbers) > 0 {
+				return nil, p.Errorf("unexpected name")
+			}
+			names = append(names, p.Next().Value.(string))
+		case tkn.TokenType == token.TypeNumber:
+			n, err := p.parseInteger()
+			if err != nil {
+				return nil, err.(*ParseError)
+			}
+			numbers = append(numbers, n)
+		default:
+			return nil, p.Errorf("name expected")
+		}
+	}
+
+	switch kind {
+	case HINT_PARALLEL:
+		if len(names) > 1 || len(numbers) > 1 {
+			return nil, p.Errorf("PARALLEL takes a table and a degree")
+		}
+		if len(numbers) == 1 {
+			hint.Degree = numbers[0]
+		}
+	case HINT_INDEX, HINT_NO_INDEX:
+		if len(names) == 0 || len(numbers) > 0 {
+			return nil, p.Errorf("%v takes a table and index names", hint.Name)
+		}
+		hint.Indexes = names[1:]
+		names = names[:1]
+	case HINT_FULL, HINT_CACHE, HINT_NOCACHE, HINT_NO_PARALLEL:
+		if len(names) != 1 || len(numbers) > 0 {
+			return nil, p.Errorf("%v takes one table", hint.Name)
+		}
+	default:
+		if len(names) == 0 || len(numbers) > 0 {
+			return nil, p.Errorf("%v takes a list of tables", hint.Name)
+		}
+	}
+
+	hint.Tables = names
+
+	return hint, nil
+}
+
+// hintPosition converts a position within the text of a hint into a position in the statement.
+// The text starts just after the /*+ or --+ that opens the hint.
+func hintPosition(hint *token.Token, inner token.Position) token.Position {
+	const opener = 3
+
+	pos := token.Position{
+		Offset: hint.Start.Offset + opener + inner.Offset,
+		Line:   hint.Start.Line + inner.Line - 1,
+		Column: inner.Column,
+	}
+	if inner.Line == 1 {
+		pos.Column = hint.Start.Column + opener + inner.Column - 1
+	}
+	return pos
+}
@@ -0,0 +1,63 @@
+package dml
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHints(t *testing.T) {
+	q := parse(t, `select /*+ leading(o, c) use_hash(c) index(o orders_ix dates_ix) full(x) ordered parallel(o 4) nocache(c) */ *
+		from orders o join customers c on c.id = o.cid`)
+
+	hints := q.QueryBlock[0].Hints
+	if len(hints) != 7 {
+		t.Fatalf("expected 7 hints, got %v", len(hints))
+	}
+	if hints[0].Kind != HINT_LEADING || strings.Join(hints[0].Tables, " ") != "O C" {
+		t.Errorf("LEADING not parsed: %+v", hints[0])
+	}
+	if hints[2].Kind != HINT_INDEX || hints[2].Tables[0] != "O" || strings.Join(hints[2].Indexes, " ") != "ORDERS_IX DATES_IX" {
+		t.Errorf("INDEX not parsed: %+v", hints[2])
+	}
+	if hints[4].Kind != HINT_ORDERED || hints[5].Kind != HINT_PARALLEL || hints[5].Degree != 4 || hints[5].Tables[0] != "O" {
+		t.Errorf("ORDERED PARALLEL not parsed: %+v %+v", hints[4], hints[5])
+	}
+	if len(q.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", q.Warnings)
+	}
+
+	q = parse(t, "from t select --+ parallel\n x")
+	if hints = q.QueryBlock[0].Hints; len(hints) != 1 || hints[0].Kind != HINT_PARALLEL || hints[0].Degree != 0 {
+		t.Errorf("line hint not parsed: %v", hints)
+	}
+
+	// a hint anywhere but after SELECT is only a comment
+	q = parse(t, `select * from t /*+ full(t) */ where x = 1`)
+	if len(q.QueryBlock[0].Hints) != 0 || len(q.Warnings) != 0 {
+		t.Error("misplaced hint should be ignored")
+	}
+}
+
+func TestHintWarnings(t *testing.T) {
+	q := parse(t, `select /*+ fulll(t) full(t) index() parallel(1 2) use_nl(a b) */ * from t, (select /*+ bogus */ 1 from dual)`)
+
+	hints := q.QueryBlock[0].Hints
+	if len(hints) != 2 || hints[0].Kind != HINT_FULL || hints[1].Kind != HINT_USE_NL {
+		t.Errorf("valid hints should survive: %v", hints)
+	}
+
+	if len(q.Warnings) != 4 {
+		t.Fatalf("expected 4 warnings, got %v", q.Warnings)
+	}
+	if q.Warnings[0] != "Hint ignored: unknown hint at line 1, column 12 near: FULLL" {
+		t.Error(q.Warnings[0])
+	}
+	if !strings.Contains(q.Warnings[3], "BOGUS") {
+		t.Error(q.Warnings[3])
+	}
+
+	q = parse(t, "select\n  /*+ full(t)\n    nope */ 1 from t")
+	if len(q.Warnings) != 1 || !strings.Contains(q.Warnings[0], "line 3, column 5") {
+		t.Errorf("warning position: %v", q.Warnings)
+	}
+}
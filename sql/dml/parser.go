@@ -0,0 +1,203 @@
+package dml
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/sql/token"
+)
+
+// ParseError is returned by the parser when the token stream does not match the grammar.
+type ParseError struct {
+	Message  string         // what went wrong
+	Token    *token.Token   // the token where the problem was found; nil at end of statement
+	Position token.Position // where the token starts, or where the statement ends
+}
+
+func (e *ParseError) Error() string {
+	if e.Token == nil {
+		return fmt.Sprintf("%v at end of statement (%v)", e.Message, e.Position)
+	}
+	return fmt.Sprintf("%v at %v near: %v", e.Message, e.Position, sourceText(e.Token))
+}
+
+// sourceText is a token as it was written, as far as an error needs it: a bind keeps its ? or colon.
+func sourceText(tkn *token.Token) interface{} {
+	if tkn.TokenType != token.TypeBind {
+		return tkn.Value
+	}
+	if name, ok := tkn.Value.(string); ok {
+		return ":" + name
+	}
+	return "?"
+}
+
+// Parser is a recursive-descent parser over a token stream. Comments and hints are not
+// part of the grammar, so they are removed when the parser is created; hints are kept aside
+// for the keyword they follow.
+type Parser struct {
+	tokens   token.Tokens
+	idx      int
+	binds    []*TBind             // bind variables in the order they appear
+	hints    map[int]token.Tokens // hint tokens, by the index of the token that follows them
+	warnings []string             // problems that do not stop the statement, such as unknown hints
+}
+
+func NewParser(tokens token.Tokens) *Parser {
+	p := &Parser{}
+	for _, tkn := range tokens {
+		switch tkn.TokenType {
+		case token.TypeComment:
+		case token.TypeHint:
+			if p.hints == nil {
+				p.hints = make(map[int]token.Tokens)
+			}
+			p.hints[len(p.tokens)] = append(p.hints[len(p.tokens)], tkn)
+		default:
+			p.tokens = append(p.tokens, tkn)
+		}
+	}
+	return p
+}
+
+// Peek returns the current token without consuming it, or nil at the end of the stream.
+func (p *Parser) Peek() *token.Token {
+	return p.PeekAt(0)
+}
+
+// PeekAt returns the token n positions past the current one without consuming anything.
+func (p *Parser) PeekAt(n int) *token.Token {
+	if p.idx+n < len(p.tokens) {
+		return p.tokens[p.idx+n]
+	}
+	return nil
+}
+
+// Next consumes and returns the current token, or nil at the end of the stream.
+func (p *Parser) Next() *token.Token {
+	tkn := p.Peek()
+	if tkn != nil {
+		p.idx++
+	}
+	return tkn
+}
+
+// Binds returns the bind variables parsed so far, in the order they appear in the statement.
+func (p *Parser) Binds() []*TBind {
+	return p.binds
+}
+
+// Warnings returns the problems found so far that do not stop the statement from running.
+func (p *Parser) Warnings() []string {
+	return p.warnings
+}
+
+func (p *Parser) AtEnd() bool {
+	return p.idx >= len(p.tokens)
+}
+
+// PeekKeyword reports whether the current token is one of the given keywords.
+func (p *Parser) PeekKeyword(words ...string) bool {
+	return isKeyword(p.Peek(), words...)
+}
+
+// AcceptKeyword consumes the current token if it is the given keyword.
+func (p *Parser) AcceptKeyword(word string) bool {
+	if p.PeekKeyword(word) {
+		p.idx++
+		return true
+	}
+	return false
+}
+
+// ExpectKeyword consumes the given keyword, or returns an error if it is not the current token.
+func (p *Parser) ExpectKeyword(word string) error {
+	if !p.AcceptKeyword(word) {
+		return p.Errorf("%v expected", word)
+	}
+	return nil
+}
+
+// PeekPunct reports whether the current token is the given punctuation.
+func (p *Parser) PeekPunct(punct string) bool {
+	return isPunct(p.Peek(), punct)
+}
+
+// AcceptPunct consumes the current token if it is the given punctuation.
+func (p *Parser) AcceptPunct(punct string) bool {
+	if p.PeekPunct(punct) {
+		p.idx++
+		return true
+	}
+	return false
+}
+
+// ExpectPunct consumes the given punctuation, or returns an error if it is not the current token.
+func (p *Parser) ExpectPunct(punct string) error {
+	if !p.AcceptPunct(punct) {
+		return p.Errorf("'%v' expected", punct)
+	}
+	return nil
+}
+
+// Identifier consumes a name. Reserved words can only be used as names when they are quoted.
+func (p *Parser) Identifier() (string, error) {
+	tkn := p.Peek()
+	if !isName(tkn) {
+		if tkn != nil && tkn.TokenType == token.TypeToken {
+			return "", p.Errorf("%v is a reserved word and cannot be used as a name unless it is quoted", tkn.Value)
+		}
+		return "", p.Errorf("identifier expected")
+	}
+	p.idx++
+	return tkn.Value.(string), nil
+}
+
+// ExpectEnd checks that the whole statement was consumed; a trailing semicolon is allowed.
+func (p *Parser) ExpectEnd() error {
+	p.AcceptPunct(";")
+	if !p.AtEnd() {
+		return p.Errorf("unexpected text at end of statement")
+	}
+	return nil
+}
+
+// Errorf builds a ParseError located at the current token.
+func (p *Parser) Errorf(format string, args ...interface{}) *ParseError {
+	err := &ParseError{
+		Message: fmt.Sprintf(format, args...),
+		Token:   p.Peek(),
+	}
+	if err.Token != nil {
+		err.Position = err.Token.Start
+	} else if len(p.tokens) > 0 {
+		err.Position = p.tokens[len(p.tokens)-1].End
+	} else {
+		err.Position = token.Position{Line: 1, Column: 1}
+	}
+	return err
+}
+
+func isKeyword(tkn *token.Token, words ...string) bool {
+	if tkn == nil || tkn.TokenType != token.TypeToken || tkn.Quoted {
+		return false
+	}
+	for _, word := range words {
+		if tkn.Value == word {
+			return true
+		}
+	}
+	return false
+}
+
+func isPunct(tkn *token.Token, punct string) bool {
+	return tkn != nil && tkn.TokenType == token.TypePunctuation && tkn.Value == punct
+}
+
+func isOperator(tkn *token.Token, operator string) bool {
+	return tkn != nil && tkn.TokenType == token.TypeOperator && tkn.Value == operator
+}
+
+// isName reports whether the token can be used as a name. Reserved words end an expression and
+// cannot be used as an alias without quoting.
+func isName(tkn *token.Token) bool {
+	return tkn != nil && tkn.TokenType == token.TypeToken && (tkn.Quoted || !token.IsReserved(tkn.Value.(string)))
+}
@@ -1,7 +1,6 @@
 package dml
 
 import (
-	"fmt"
 	"github.com/djbckr/godb/sql/token"
 )
 
@@ -168,7 +167,12 @@ FOR UPDATE
 */
 
 type Query struct {
-	QueryBlock []*TQueryBlock
+	With       []*TWith       // subquery_factoring_clause
+	QueryBlock []*TQueryBlock // query blocks joined by set operators
+	OrderBy    *TOrderBy
+	ForUpdate  *TForUpdate
+	Binds      []*TBind // every bind variable in the statement, in order of appearance
+	Warnings   []string // problems that do not stop the statement, such as hints that were ignored
 }
 
 type TSetOperator = int
@@ -192,41 +196,757 @@ const (
 	JOIN_OUTER_FULL
 )
 
+type TNullsOrder = int
+
+const (
+	NULLS_DEFAULT TNullsOrder = iota
+	NULLS_FIRST
+	NULLS_LAST
+)
+
+type TWith struct {
+	Name    string
+	Columns []string
+	Query   *Query
+}
+
 type TQueryBlock struct {
-	SetOperator TSetOperator
-	From        []*TFrom
+	SetOperator TSetOperator // how this block combines with the one before it
+	Subquery    *Query       // set instead of the clauses below for ( subquery )
+	Hints       []*THint     // from the hint that follows SELECT
+	Distinct    bool
 	Select      []*TSelect
-	Where       []*TWhere
-	GroupBy     []*TGroupBy
+	From        []*TFrom
+	Where       *TWhere
+	ConnectBy   *TConnectBy
+	GroupBy     *TGroupBy
 }
 
+// TFrom is one comma-separated entry of the FROM clause: a table reference and the tables joined to it.
 type TFrom struct {
+	TableRef *TTableRef
+	Joins    []*TJoin
+}
+
+type TJoin struct {
 	JoinType TJoinType
+	Natural  bool // NATURAL outer join; a natural inner join is JOIN_INNER_NATURAL
 	TableRef *TTableRef
-	On       []*TCondition
+	On       Expr
+	Using    []string
 }
 
 type TSelect struct {
+	Star      bool     // * or qualifier.*
+	Qualifier []string // the t_alias, query_name or [schema.]table before .*
+	Expr      Expr
+	Alias     string
 }
 
-type TCondition struct {
+type TTableRef struct {
+	Only      bool
+	Schema    string
+	Name      string
+	DbLink    string
+	Subquery  *Query // ( subquery )
+	Join      *TFrom // ( join_clause )
+	Flashback *TFlashback
+	Alias     string
 }
 
-type TTableRef struct {
+// TFlashback reads a table as it was at an earlier point, or lists the versions of its rows over a
+// span of time. The point is an SCN, or a TIMESTAMP that is mapped to the SCN current at that moment.
+type TFlashback struct {
+	Versions  bool // VERSIONS BETWEEN rather than AS OF
+	Timestamp bool // TIMESTAMP rather than SCN
+	AsOf      Expr
+	From      Expr // nil for MINVALUE
+	To        Expr // nil for MAXVALUE
 }
 
 type TWhere struct {
+	Condition Expr
+}
 
+type TConnectBy struct {
+	StartWith Expr
+	NoCycle   bool
+	Condition Expr
 }
 
 type TGroupBy struct {
+	Items  []Expr
+	Having Expr
+}
+
+type TOrderBy struct {
+	Siblings bool
+	Items    []*TOrderByItem
+}
+
+type TOrderByItem struct {
+	Expr  Expr // expr, position or c_alias
+	Desc  bool
+	Nulls TNullsOrder
+}
+
+type TForUpdate struct {
+	Of         [][]string // [[schema.]table.]column
+	NoWait     bool
+	Wait       Expr
+	SkipLocked bool
+}
+
+// ProcessSelect parses a complete query statement.
+func ProcessSelect(sql token.Tokens) (*Query, error) {
+	return NewParser(sql).ParseSelect()
+}
+
+// ParseSelect parses a query from the current token to the end of the statement, such as the query
+// of CREATE VIEW ... AS.
+func (p *Parser) ParseSelect() (*Query, error) {
+	query, err := p.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("FOR") {
+		if query.ForUpdate, err = p.parseForUpdate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectEnd(); err != nil {
+		return nil, err
+	}
+
+	query.Binds = p.Binds()
+	query.Warnings = p.Warnings()
+
+	return query, nil
+}
+
+// parseQuery parses a subquery: query blocks joined by set operators, with an optional ORDER BY.
+func (p *Parser) parseQuery() (*Query, error) {
+	var err error
+	query := &Query{}
+
+	if p.AcceptKeyword("WITH") {
+		if query.With, err = p.parseWith(); err != nil {
+			return nil, err
+		}
+	}
+
+	setOperator := NONE
+	for {
+		block, err := p.parseQueryBlock()
+		if err != nil {
+			return nil, err
+		}
+		block.SetOperator = setOperator
+		query.QueryBlock = append(query.QueryBlock, block)
+
+		if setOperator = p.parseSetOperator(); setOperator == NONE {
+			break
+		}
+	}
+
+	if p.AcceptKeyword("ORDER") {
+		if query.OrderBy, err = p.parseOrderBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	return query, nil
+}
+
+func (p *Parser) parseWith() ([]*TWith, error) {
+	var list []*TWith
+
+	for {
+		var err error
+		with := &TWith{}
+
+		if with.Name, err = p.Identifier(); err != nil {
+			return nil, err
+		}
+
+		if p.PeekPunct("(") {
+			if with.Columns, err = p.parseNameList(); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = p.ExpectKeyword("AS"); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectPunct("("); err != nil {
+			return nil, err
+		}
+		if with.Query, err = p.parseQuery(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+
+		if p.PeekKeyword("SEARCH", "CYCLE") {
+			return nil, p.Errorf("%v clause is not supported", p.Peek().Value)
+		}
+
+		list = append(list, with)
+
+		if !p.AcceptPunct(",") {
+			return list, nil
+		}
+	}
 }
 
-func ProcessSelect(sql token.Tokens) {
-	var vv interface{}
+func (p *Parser) parseSetOperator() TSetOperator {
+	switch {
+	case p.AcceptKeyword("UNION"):
+		if p.AcceptKeyword("ALL") {
+			return UNION_ALL
+		}
+		return UNION
+	case p.AcceptKeyword("INTERSECT"):
+		return INTERSECT
+	case p.AcceptKeyword("MINUS"), p.AcceptKeyword("EXCEPT"):
+		return MINUS
+	}
+	return NONE
+}
+
+// parseQueryBlock parses SELECT ... FROM ... or FROM ... SELECT ..., followed by the remaining clauses.
+func (p *Parser) parseQueryBlock() (*TQueryBlock, error) {
+	var err error
+	block := &TQueryBlock{}
+
+	if p.AcceptPunct("(") {
+		if block.Subquery, err = p.parseQuery(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+		return block, nil
+	}
+
+	switch {
+	case p.AcceptKeyword("SELECT"):
+		block.Hints = p.parseHints()
+		if err = p.parseSelectList(block); err != nil {
+			return nil, err
+		}
+		if p.AcceptKeyword("FROM") {
+			if block.From, err = p.parseFromList(); err != nil {
+				return nil, err
+			}
+		}
+
+	case p.AcceptKeyword("FROM"):
+		if block.From, err = p.parseFromList(); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectKeyword("SELECT"); err != nil {
+			return nil, err
+		}
+		block.Hints = p.parseHints()
+		if err = p.parseSelectList(block); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, p.Errorf("SELECT or FROM expected")
+	}
+
+	if p.AcceptKeyword("WHERE") {
+		block.Where = &TWhere{}
+		if block.Where.Condition, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.PeekKeyword("START", "CONNECT") {
+		if block.ConnectBy, err = p.parseConnectBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.AcceptKeyword("GROUP") {
+		if block.GroupBy, err = p.parseGroupBy(); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.PeekKeyword("MODEL") {
+		return nil, p.Errorf("MODEL clause is not supported")
+	}
+
+	return block, nil
+}
+
+func (p *Parser) parseSelectList(block *TQueryBlock) error {
 
-	for idx, tkn := range sql {
-		vv = tkn.Value
-		fmt.Printf("%v, %t   ::   %v    ::    %v\n", idx, vv, vv, tkn.TokenType)
+	if p.AcceptKeyword("DISTINCT") || p.AcceptKeyword("UNIQUE") {
+		block.Distinct = true
+	} else {
+		p.AcceptKeyword("ALL")
 	}
+
+	for {
+		item, err := p.parseSelectItem()
+		if err != nil {
+			return err
+		}
+		block.Select = append(block.Select, item)
+
+		if !p.AcceptPunct(",") {
+			return nil
+		}
+	}
+}
+
+func (p *Parser) parseSelectItem() (*TSelect, error) {
+	var err error
+
+	if p.AcceptPunct("*") {
+		return &TSelect{Star: true}, nil
+	}
+
+	// look for qualifier.* before falling back to an expression
+	start := p.idx
+	var qualifier []string
+	for isName(p.Peek()) && isPunct(p.PeekAt(1), ".") {
+		qualifier = append(qualifier, p.Next().Value.(string))
+		p.Next()
+		if p.AcceptPunct("*") {
+			return &TSelect{Star: true, Qualifier: qualifier}, nil
+		}
+	}
+	p.idx = start
+
+	item := &TSelect{}
+	if item.Expr, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+
+	if item.Alias, err = p.parseAlias(); err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// parseAlias parses [ AS ] alias, returning an empty string when there is no alias.
+func (p *Parser) parseAlias() (string, error) {
+	if p.AcceptKeyword("AS") {
+		return p.Identifier()
+	}
+	if isName(p.Peek()) {
+		return p.Identifier()
+	}
+	return "", nil
+}
+
+func (p *Parser) parseFromList() ([]*TFrom, error) {
+	var list []*TFrom
+
+	for {
+		from, err := p.parseJoinClause()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, from)
+
+		if !p.AcceptPunct(",") {
+			return list, nil
+		}
+	}
+}
+
+// parseJoinClause parses a table reference followed by any number of joins.
+func (p *Parser) parseJoinClause() (*TFrom, error) {
+	var err error
+	from := &TFrom{}
+
+	if from.TableRef, err = p.parseTableRef(); err != nil {
+		return nil, err
+	}
+
+	for {
+		join := &TJoin{}
+		needsCondition := true
+
+		switch {
+		case p.AcceptKeyword("JOIN"):
+			join.JoinType = JOIN_INNER
+		case p.AcceptKeyword("INNER"):
+			join.JoinType = JOIN_INNER
+			if err = p.ExpectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		case p.AcceptKeyword("CROSS"):
+			join.JoinType = JOIN_INNER_CROSS
+			needsCondition = false
+			if err = p.ExpectKeyword("JOIN"); err != nil {
+				return nil, err
+			}
+		case p.AcceptKeyword("NATURAL"):
+			needsCondition = false
+			if p.PeekKeyword("LEFT", "RIGHT", "FULL") {
+				join.Natural = true
+				if join.JoinType, err = p.parseOuterJoin(); err != nil {
+					return nil, err
+				}
+			} else {
+				join.JoinType = JOIN_INNER_NATURAL
+				p.AcceptKeyword("INNER")
+				if err = p.ExpectKeyword("JOIN"); err != nil {
+					return nil, err
+				}
+			}
+		case p.PeekKeyword("LEFT", "RIGHT", "FULL"):
+			if join.JoinType, err = p.parseOuterJoin(); err != nil {
+				return nil, err
+			}
+		default:
+			return from, nil
+		}
+
+		if join.TableRef, err = p.parseTableRef(); err != nil {
+			return nil, err
+		}
+
+		if needsCondition {
+			switch {
+			case p.AcceptKeyword("ON"):
+				if join.On, err = p.ParseExpr(); err != nil {
+					return nil, err
+				}
+			case p.PeekKeyword("USING"):
+				p.Next()
+				if join.Using, err = p.parseNameList(); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, p.Errorf("ON or USING expected")
+			}
+		}
+
+		from.Joins = append(from.Joins, join)
+	}
+}
+
+// parseOuterJoin parses { LEFT | RIGHT | FULL } [ OUTER ] JOIN
+func (p *Parser) parseOuterJoin() (TJoinType, error) {
+	var joinType TJoinType
+
+	switch p.Next().Value {
+	case "LEFT":
+		joinType = JOIN_OUTER_LEFT
+	case "RIGHT":
+		joinType = JOIN_OUTER_RIGHT
+	default:
+		joinType = JOIN_OUTER_FULL
+	}
+
+	p.AcceptKeyword("OUTER")
+
+	return joinType, p.ExpectKeyword("JOIN")
+}
+
+func (p *Parser) parseTableRef() (*TTableRef, error) {
+	var err error
+	ref := &TTableRef{}
+
+	if p.AcceptKeyword("ONLY") {
+		ref.Only = true
+		if err = p.ExpectPunct("("); err != nil {
+			return nil, err
+		}
+		if err = p.parseQueryTableExpression(ref); err != nil {
+			return nil, err
+		}
+		if err = p.ExpectPunct(")"); err != nil {
+			return nil, err
+		}
+	} else if err = p.parseQueryTableExpression(ref); err != nil {
+		return nil, err
+	}
+
+	// VERSIONS is not reserved, so it is only the clause when BETWEEN follows; otherwise it is an alias
+	if p.peekWords([]string{"VERSIONS", "BETWEEN"}) || p.peekWords([]string{"AS", "OF"}) {
+		if ref.Flashback, err = p.parseFlashback(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ref.Alias, err = p.parseAlias(); err != nil {
+		return nil, err
+	}
+
+	return ref, nil
+}
+
+// parseFlashback parses VERSIONS BETWEEN { SCN | TIMESTAMP } { expr | MINVALUE } AND { expr | MAXVALUE }
+// or AS OF { SCN | TIMESTAMP } expr.
+func (p *Parser) parseFlashback() (*TFlashback, error) {
+	var err error
+	flashback := &TFlashback{}
+
+	if p.AcceptKeyword("VERSIONS") {
+		flashback.Versions = true
+		if err = p.ExpectKeyword("BETWEEN"); err != nil {
+			return nil, err
+		}
+	} else if err = p.ExpectKeyword("AS"); err == nil {
+		err = p.ExpectKeyword("OF")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("TIMESTAMP") {
+		flashback.Timestamp = true
+	} else if err = p.ExpectKeyword("SCN"); err != nil {
+		return nil, p.Errorf("SCN or TIMESTAMP expected")
+	}
+
+	if !flashback.Versions {
+		if flashback.AsOf, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+		return flashback, nil
+	}
+
+	if !p.AcceptKeyword("MINVALUE") {
+		if flashback.From, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.ExpectKeyword("AND"); err != nil {
+		return nil, err
+	}
+	if !p.AcceptKeyword("MAXVALUE") {
+		if flashback.To, err = p.parseAdditive(); err != nil {
+			return nil, err
+		}
+	}
+
+	return flashback, nil
+}
+
+// parseQueryTableExpression parses [schema.]table[@dblink], ( subquery ) or ( join_clause ).
+func (p *Parser) parseQueryTableExpression(ref *TTableRef) error {
+	var err error
+
+	if p.AcceptPunct("(") {
+		// a parenthesis may hold a subquery or a join clause; try the subquery first
+		start, binds, warnings := p.idx, len(p.binds), len(p.warnings)
+		if ref.Subquery, err = p.parseQuery(); err != nil {
+			p.idx, p.binds, p.warnings = start, p.binds[:binds], p.warnings[:warnings]
+			if ref.Join, err = p.parseJoinClause(); err != nil {
+				return err
+			}
+		}
+		return p.ExpectPunct(")")
+	}
+
+	if ref.Name, err = p.Identifier(); err != nil {
+		return err
+	}
+
+	if p.AcceptPunct(".") {
+		ref.Schema = ref.Name
+		if ref.Name, err = p.Identifier(); err != nil {
+			return err
+		}
+	}
+
+	if p.AcceptPunct("@") {
+		if ref.DbLink, err = p.Identifier(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *Parser) parseConnectBy() (*TConnectBy, error) {
+	var err error
+	connectBy := &TConnectBy{}
+
+	if p.AcceptKeyword("START") {
+		if err = p.ExpectKeyword("WITH"); err != nil {
+			return nil, err
+		}
+		if connectBy.StartWith, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = p.ExpectKeyword("CONNECT"); err != nil {
+		return nil, err
+	}
+	if err = p.ExpectKeyword("BY"); err != nil {
+		return nil, err
+	}
+
+	connectBy.NoCycle = p.AcceptKeyword("NOCYCLE")
+
+	if connectBy.Condition, err = p.ParseExpr(); err != nil {
+		return nil, err
+	}
+
+	if connectBy.StartWith == nil && p.AcceptKeyword("START") {
+		if err = p.ExpectKeyword("WITH"); err != nil {
+			return nil, err
+		}
+		if connectBy.StartWith, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return connectBy, nil
+}
+
+func (p *Parser) parseGroupBy() (*TGroupBy, error) {
+	var err error
+	groupBy := &TGroupBy{}
+
+	if err = p.ExpectKeyword("BY"); err != nil {
+		return nil, err
+	}
+
+	for {
+		item, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		groupBy.Items = append(groupBy.Items, item)
+
+		if !p.AcceptPunct(",") {
+			break
+		}
+	}
+
+	if p.AcceptKeyword("HAVING") {
+		if groupBy.Having, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	}
+
+	return groupBy, nil
+}
+
+func (p *Parser) parseOrderBy() (*TOrderBy, error) {
+	orderBy := &TOrderBy{}
+
+	orderBy.Siblings = p.AcceptKeyword("SIBLINGS")
+
+	if err := p.ExpectKeyword("BY"); err != nil {
+		return nil, err
+	}
+
+	for {
+		var err error
+		item := &TOrderByItem{}
+
+		if item.Expr, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+
+		if p.AcceptKeyword("DESC") {
+			item.Desc = true
+		} else {
+			p.AcceptKeyword("ASC")
+		}
+
+		if p.AcceptKeyword("NULLS") {
+			switch {
+			case p.AcceptKeyword("FIRST"):
+				item.Nulls = NULLS_FIRST
+			case p.AcceptKeyword("LAST"):
+				item.Nulls = NULLS_LAST
+			default:
+				return nil, p.Errorf("FIRST or LAST expected")
+			}
+		}
+
+		orderBy.Items = append(orderBy.Items, item)
+
+		if !p.AcceptPunct(",") {
+			return orderBy, nil
+		}
+	}
+}
+
+func (p *Parser) parseForUpdate() (*TForUpdate, error) {
+	var err error
+	forUpdate := &TForUpdate{}
+
+	if err = p.ExpectKeyword("UPDATE"); err != nil {
+		return nil, err
+	}
+
+	if p.AcceptKeyword("OF") {
+		for {
+			var column []string
+			for {
+				name, err := p.Identifier()
+				if err != nil {
+					return nil, err
+				}
+				column = append(column, name)
+				if !p.AcceptPunct(".") {
+					break
+				}
+			}
+			forUpdate.Of = append(forUpdate.Of, column)
+
+			if !p.AcceptPunct(",") {
+				break
+			}
+		}
+	}
+
+	switch {
+	case p.AcceptKeyword("NOWAIT"):
+		forUpdate.NoWait = true
+	case p.AcceptKeyword("WAIT"):
+		if forUpdate.Wait, err = p.ParseExpr(); err != nil {
+			return nil, err
+		}
+	case p.AcceptKeyword("SKIP"):
+		if err = p.ExpectKeyword("LOCKED"); err != nil {
+			return nil, err
+		}
+		forUpdate.SkipLocked = true
+	}
+
+	return forUpdate, nil
+}
+
+// parseNameList parses ( name [, name]... )
+func (p *Parser) parseNameList() ([]string, error) {
+	var names []string
+
+	if err := p.ExpectPunct("("); err != nil {
+		return nil, err
+	}
+
+	for {
+		name, err := p.Identifier()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+
+		if !p.AcceptPunct(",") {
+			break
+		}
+	}
+
+	return names, p.ExpectPunct(")")
 }
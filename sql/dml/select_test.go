@@ -1,31 +1,307 @@
 package dml
 
 import (
-	"fmt"
 	"github.com/djbckr/godb/sql/token"
+	"strings"
 	"testing"
 )
 
+func parse(t *testing.T, sql string) *Query {
+	tokens, e := token.Tokenize(sql)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	q, e := ProcessSelect(tokens)
+
+	if e != nil {
+		t.Fatalf("%v: %v", sql, e)
+	}
+
+	return q
+}
+
+func parseFails(t *testing.T, sql string) {
+	tokens, e := token.Tokenize(sql)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e = ProcessSelect(tokens); e == nil {
+		t.Errorf("expected parse error: %v", sql)
+	} else if _, ok := e.(*ParseError); !ok {
+		t.Errorf("expected *ParseError, got %T", e)
+	}
+}
+
 func TestProcessSelect(t *testing.T) {
-	tokens, e := token.Tokenize(`select * from dual where 1 = 1.0`)
+	q := parse(t, `select * from dual where 1 = 1.0`)
+
+	if len(q.QueryBlock) != 1 {
+		t.Fatal("expected one query block")
+	}
+
+	qb := q.QueryBlock[0]
+	if len(qb.Select) != 1 || !qb.Select[0].Star {
+		t.Error("expected select *")
+	}
+	if len(qb.From) != 1 || qb.From[0].TableRef.Name != "DUAL" {
+		t.Error("expected from dual")
+	}
+	if qb.Where == nil {
+		t.Fatal("expected where clause")
+	}
+	if cond, ok := qb.Where.Condition.(*TBinary); !ok || cond.Operator != "=" {
+		t.Error("expected where 1 = 1.0")
+	}
+
+	q = parse(t, `select * from dual where x = q'[bob]'`)
+
+	if q.QueryBlock[0].Where == nil {
+		t.Error("expected where clause")
+	}
+
+	q = parse(t, `select a-1 from dual where x = 1`)
+
+	if expr, ok := q.QueryBlock[0].Select[0].Expr.(*TBinary); !ok || expr.Operator != "-" {
+		t.Error("expected a - 1")
+	}
+	if cond, ok := q.QueryBlock[0].Where.Condition.(*TBinary); !ok || cond.Operator != "=" {
+		t.Error("expected x = 1")
+	}
+}
+
+func TestSelectList(t *testing.T) {
+	q := parse(t, `select distinct a, b as bee, c cee, t.*, s.t.*, count(*) over (partition by a) n, x+1 from t`)
+
+	qb := q.QueryBlock[0]
+	if !qb.Distinct {
+		t.Error("expected distinct")
+	}
+	if len(qb.Select) != 7 {
+		t.Fatalf("expected 7 select items, got %v", len(qb.Select))
+	}
+	if qb.Select[1].Alias != "BEE" || qb.Select[2].Alias != "CEE" || qb.Select[5].Alias != "N" {
+		t.Error("aliases not parsed")
+	}
+	if !qb.Select[3].Star || len(qb.Select[3].Qualifier) != 1 || qb.Select[3].Qualifier[0] != "T" {
+		t.Error("expected t.*")
+	}
+	if !qb.Select[4].Star || len(qb.Select[4].Qualifier) != 2 {
+		t.Error("expected s.t.*")
+	}
+	if fn, ok := qb.Select[5].Expr.(*TFunction); !ok || !fn.Star || fn.Over == nil || len(fn.Over.PartitionBy) != 1 {
+		t.Error("expected count(*) over (partition by a)")
+	}
+	if expr, ok := qb.Select[6].Expr.(*TBinary); !ok || expr.Operator != "+" {
+		t.Error("expected x+1")
+	}
+}
+
+func TestJoins(t *testing.T) {
+	q := parse(t, `select * from a
+		join b on a.id = b.id
+		inner join c using (id, x)
+		cross join d
+		natural join e
+		left outer join f on f.id = a.id
+		right join g on g.id = a.id
+		full join h on h.id = a.id
+		natural left join i,
+		(j join k on j.id = k.id) jk,
+		(select * from l) ll`)
+
+	from := q.QueryBlock[0].From
+	if len(from) != 3 {
+		t.Fatalf("expected 3 from entries, got %v", len(from))
+	}
+
+	joins := from[0].Joins
+	expected := []TJoinType{JOIN_INNER, JOIN_INNER, JOIN_INNER_CROSS, JOIN_INNER_NATURAL,
+		JOIN_OUTER_LEFT, JOIN_OUTER_RIGHT, JOIN_OUTER_FULL, JOIN_OUTER_LEFT}
+	if len(joins) != len(expected) {
+		t.Fatalf("expected %v joins, got %v", len(expected), len(joins))
+	}
+	for i, typ := range expected {
+		if joins[i].JoinType != typ {
+			t.Errorf("join %v: expected type %v, got %v", i, typ, joins[i].JoinType)
+		}
+	}
+	if joins[0].On == nil || len(joins[1].Using) != 2 || !joins[7].Natural {
+		t.Error("join conditions not parsed")
+	}
+
+	if from[1].TableRef.Join == nil || from[1].TableRef.Alias != "JK" {
+		t.Error("expected parenthesized join")
+	}
+	if from[2].TableRef.Subquery == nil || from[2].TableRef.Alias != "LL" {
+		t.Error("expected subquery")
+	}
+
+	parseFails(t, `select * from a join b`)
+}
+
+func TestFlashback(t *testing.T) {
+	q := parse(t, `select * from a as of scn :s x, b as of timestamp systimestamp - interval '1' hour`)
+
+	from := q.QueryBlock[0].From
+	if fb := from[0].TableRef.Flashback; fb == nil || fb.Versions || fb.Timestamp || fb.AsOf == nil || from[0].TableRef.Alias != "X" {
+		t.Error("expected as of scn")
+	}
+	if fb := from[1].TableRef.Flashback; fb == nil || !fb.Timestamp || fb.AsOf == nil {
+		t.Error("expected as of timestamp")
+	}
+
+	q = parse(t, `select versions_startscn, versions_endscn, id from a versions between scn minvalue and maxvalue where id = 1`)
+
+	ref := q.QueryBlock[0].From[0].TableRef
+	if fb := ref.Flashback; fb == nil || !fb.Versions || fb.From != nil || fb.To != nil || ref.Alias != "" {
+		t.Error("expected versions between minvalue and maxvalue")
+	}
+
+	q = parse(t, `select * from a versions between timestamp :t1 and :t2 v`)
+
+	ref = q.QueryBlock[0].From[0].TableRef
+	if fb := ref.Flashback; fb == nil || !fb.Timestamp || fb.From == nil || fb.To == nil || ref.Alias != "V" {
+		t.Error("expected versions between timestamps")
+	}
+
+	// without BETWEEN, VERSIONS is just an alias
+	if ref = parse(t, `select * from a versions`).QueryBlock[0].From[0].TableRef; ref.Flashback != nil || ref.Alias != "VERSIONS" {
+		t.Error("expected alias VERSIONS")
+	}
+
+	parseFails(t, `select * from a as of 5`)
+	parseFails(t, `select * from a versions between scn 1`)
+}
+
+func TestClauses(t *testing.T) {
+	q := parse(t, `with x (c1, c2) as (select 1, 2 from dual)
+		select c1, count(*) from x
+		where c1 > 0 and c2 is not null
+		group by c1 having count(*) > 1
+		union all
+		from y select a, b
+		minus
+		(select a, b from z)
+		order by 1 desc nulls last, 2 nulls first`)
+
+	if len(q.With) != 1 || q.With[0].Name != "X" || len(q.With[0].Columns) != 2 {
+		t.Error("expected with clause")
+	}
+
+	if len(q.QueryBlock) != 3 {
+		t.Fatalf("expected 3 query blocks, got %v", len(q.QueryBlock))
+	}
+
+	qb := q.QueryBlock[0]
+	if qb.GroupBy == nil || len(qb.GroupBy.Items) != 1 || qb.GroupBy.Having == nil {
+		t.Error("expected group by / having")
+	}
+
+	if q.QueryBlock[1].SetOperator != UNION_ALL || q.QueryBlock[1].From[0].TableRef.Name != "Y" {
+		t.Error("expected union all from y")
+	}
+
+	if q.QueryBlock[2].SetOperator != MINUS || q.QueryBlock[2].Subquery == nil {
+		t.Error("expected minus (subquery)")
+	}
+
+	if q.OrderBy == nil || len(q.OrderBy.Items) != 2 {
+		t.Fatal("expected order by")
+	}
+	if !q.OrderBy.Items[0].Desc || q.OrderBy.Items[0].Nulls != NULLS_LAST || q.OrderBy.Items[1].Nulls != NULLS_FIRST {
+		t.Error("order by options not parsed")
+	}
+
+	q = parse(t, `select 'Hello World'`)
+
+	if q.QueryBlock[0].From != nil {
+		t.Error("expected no from clause")
+	}
+
+	q = parse(t, `select * from emp start with mgr is null connect by prior id = mgr for update of emp.sal nowait;`)
+
+	if q.QueryBlock[0].ConnectBy == nil || q.QueryBlock[0].ConnectBy.StartWith == nil {
+		t.Error("expected connect by")
+	}
+	if q.ForUpdate == nil || !q.ForUpdate.NoWait || len(q.ForUpdate.Of) != 1 {
+		t.Error("expected for update")
+	}
+
+	parseFails(t, `select from t`)
+	parseFails(t, `select a from t order by`)
+	parseFails(t, `select a from t where`)
+	parseFails(t, `select a from t t2 t3`)
+	parseFails(t, `from t where x = 1`)
+}
 
-	fmt.Println("-------------")
+func TestParseErrorPosition(t *testing.T) {
+	tokens, e := token.Tokenize("select a,\n       b\n  from t\n where x = = 1")
 
 	if e != nil {
-		t.Error(e)
+		t.Fatal(e)
 	}
 
-	ProcessSelect(tokens)
+	_, e = ProcessSelect(tokens)
 
-	tokens, e = token.Tokenize(`select * from dual where x = q'[bob]'`)
+	if e == nil || e.Error() != "expression expected at line 4, column 12 near: =" {
+		t.Error(e)
+	}
 
-	fmt.Println("-------------")
+	tokens, e = token.Tokenize("select a\n  from t\n where")
 
 	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = ProcessSelect(tokens)
+
+	if e == nil || e.Error() != "expression expected at end of statement (line 3, column 7)" {
+		t.Error(e)
+	}
+
+	if _, e = ProcessSelect(nil); e == nil || !strings.HasSuffix(e.Error(), "at end of statement (line 1, column 1)") {
 		t.Error(e)
 	}
 
-	ProcessSelect(tokens)
+	for sql, expected := range map[string]string{
+		"select :a, ? from t": "near: ?",
+		"select ?, :a from t": "near: :a",
+	} {
+		tokens, _ = token.Tokenize(sql)
+		if _, e = ProcessSelect(tokens); e == nil || !strings.HasSuffix(e.Error(), expected) {
+			t.Errorf("%v: expected an error %v, got %v", sql, expected, e)
+		}
+	}
+}
+
+func TestBinds(t *testing.T) {
+	q := parse(t, `select * from t where updated >= :updated and id in (:a, :b)`)
+
+	if len(q.Binds) != 3 || q.Binds[0].Name != "updated" || q.Binds[2].Name != "b" {
+		t.Error("expected three named binds")
+	}
+
+	q = parse(t, `select ? from t where a = ? and b = ?`)
+
+	if len(q.Binds) != 3 || q.Binds[0].Position != 1 || q.Binds[2].Position != 3 {
+		t.Error("expected three positional binds")
+	}
 
+	parseFails(t, `select * from t where a = :a and b = ?`)
+	parseFails(t, `select * from t where a = ? and b = :b`)
+}
+
+func TestReservedWords(t *testing.T) {
+	q := parse(t, `select level, "SELECT".x "FROM" from "SELECT" where "NULL" is null`)
+
+	if q.QueryBlock[0].Select[1].Alias != "FROM" || q.QueryBlock[0].From[0].TableRef.Name != "SELECT" {
+		t.Error("quoted reserved words should be names")
+	}
 
+	parseFails(t, `select x from select`)
+	parseFails(t, `select t.from from t`)
 }
@@ -0,0 +1,1023 @@
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/sql/dml"
+	"github.com/djbckr/godb/sql/types"
+	"github.com/djbckr/godb/store"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+A query is planned for the bind values of one run, and then read a row at a time: Rows hands each
+row to its caller as soon as it is made, so a result of any size is never held whole.
+
+So far a query reads one table, DUAL, § or nothing, with an optional WHERE clause; one with
+ROWID = value fetches the row it names rather than reading the whole table. Joins, views, subqueries,
+WITH, UNION, INTERSECT and MINUS, DISTINCT, GROUP BY, ORDER BY and CONNECT BY are refused with an
+error rather than answered wrongly, as are functions and CASE.
+
+A table read AS OF an SCN or a timestamp is read through a snapshot of that point. One read VERSIONS
+BETWEEN two points gives a row for each version of each of its rows in that span, which the
+pseudo-columns VERSIONS_STARTSCN, VERSIONS_ENDSCN, VERSIONS_OPERATION and VERSIONS_XID describe.
+*/
+
+// Field is a column of the rows of a query.
+type Field struct {
+	Name string
+	Type string // string, number, timestamp or boolean, as package types names them
+}
+
+// plan is a query made ready to run.
+type plan struct {
+	fields  []*Field
+	source  *source
+	where   *expr
+	rowid   *expr // set when the WHERE clause names the one row it can be by ROWID = value
+	columns []*expr
+}
+
+// source is where the rows of a query come from: a table, or the one row of DUAL, § or no table.
+type source struct {
+	schema  string
+	name    string
+	alias   string
+	table   *store.Table // nil for the one row
+	txns    *store.Transactions
+	columns []string
+	types   []string
+	values  []interface{} // the one row
+
+	// the flashback clause: the SCN or timestamp of AS OF, or those VERSIONS BETWEEN, where nil is
+	// MINVALUE or MAXVALUE
+	asOf      *expr
+	versions  bool
+	from, to  *expr
+	timestamp bool
+}
+
+// row is a row of the source, as the expressions of a query see it.
+type row struct {
+	id      store.RowID
+	values  []interface{}
+	version *store.RowVersion // set for a row of VERSIONS BETWEEN
+}
+
+// expr is an expression compiled for the rows of a source. typ is "" for NULL, whose type is not
+// known, and otherwise one of the types of package types.
+type expr struct {
+	typ  string
+	eval func(r *row) (interface{}, error)
+}
+
+// divisionScale is how many digits after the point a division keeps, before the zeros at the end
+// are dropped.
+const divisionScale = 38
+
+// planQuery makes a query ready to run with the bind values given.
+func planQuery(query *dml.Query, values map[string]interface{}) (*plan, error) {
+	switch {
+	case len(query.With) > 0:
+		return nil, errors.New("WITH clause is not supported")
+	case len(query.QueryBlock) > 1:
+		return nil, errors.New("UNION, INTERSECT and MINUS are not supported")
+	case query.OrderBy != nil:
+		return nil, errors.New("ORDER BY clause is not supported")
+	case query.ForUpdate != nil:
+		return nil, errors.New("FOR UPDATE clause is not supported")
+	}
+
+	block := query.QueryBlock[0]
+	switch {
+	case block.Subquery != nil:
+		return nil, errors.New("a query in parentheses is not supported")
+	case block.Distinct:
+		return nil, errors.New("DISTINCT is not supported")
+	case block.ConnectBy != nil:
+		return nil, errors.New("CONNECT BY clause is not supported")
+	case block.GroupBy != nil:
+		return nil, errors.New("GROUP BY clause is not supported")
+	case len(block.From) > 1 || len(block.From) == 1 && len(block.From[0].Joins) > 0:
+		return nil, errors.New("joins are not supported")
+	}
+
+	src := &source{}
+	if len(block.From) == 1 {
+		var err error
+		if src, err = openSource(block.From[0].TableRef, values); err != nil {
+			return nil, err
+		}
+	}
+
+	c := &compiler{source: src, values: values}
+	p := &plan{source: src}
+	for _, item := range block.Select {
+		if item.Star {
+			if len(block.From) == 0 {
+				return nil, errors.New("* needs a FROM clause")
+			}
+			if len(item.Qualifier) > 0 && !src.named(item.Qualifier) {
+				return nil, fmt.Errorf("unknown table %v", strings.Join(item.Qualifier, "."))
+			}
+			for i, name := range src.columns {
+				p.columns = append(p.columns, src.column(i))
+				p.fields = append(p.fields, &Field{Name: name, Type: src.types[i]})
+			}
+			continue
+		}
+
+		column, err := c.compile(item.Expr)
+		if err != nil {
+			return nil, err
+		}
+		field := &Field{Name: item.Alias, Type: column.typ}
+		if identifier, ok := item.Expr.(*dml.TIdentifier); ok && field.Name == "" {
+			field.Name = identifier.Names[len(identifier.Names)-1]
+		}
+		if field.Name == "" {
+			field.Name = "COLUMN" + strconv.Itoa(len(p.fields)+1)
+		}
+		if field.Type == "" {
+			field.Type = types.TypeString
+		}
+		p.columns = append(p.columns, column)
+		p.fields = append(p.fields, field)
+	}
+	if len(p.fields) == 0 {
+		return nil, errors.New("the query has no columns")
+	}
+
+	if block.Where != nil {
+		where, err := c.condition(block.Where.Condition, "WHERE")
+		if err != nil {
+			return nil, err
+		}
+		p.where = where
+		if value := rowidValue(block.Where.Condition); value != nil && src.table != nil && !src.versions {
+			if p.rowid, err = c.compile(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// rowidValue finds ROWID = value among the conditions a WHERE clause ANDs together, where the value
+// is a literal or a bind, and returns the value.
+func rowidValue(cond dml.Expr) dml.Expr {
+	e, ok := cond.(*dml.TBinary)
+	switch {
+	case !ok:
+		return nil
+	case e.Operator == "AND":
+		if value := rowidValue(e.Left); value != nil {
+			return value
+		}
+		return rowidValue(e.Right)
+	case e.Operator != "=":
+		return nil
+	}
+
+	for _, sides := range [][2]dml.Expr{{e.Left, e.Right}, {e.Right, e.Left}} {
+		if id, ok := sides[0].(*dml.TIdentifier); ok && len(id.Names) == 1 && id.Names[0] == "ROWID" {
+			switch sides[1].(type) {
+			case *dml.TLiteral, *dml.TBind:
+				return sides[1]
+			}
+		}
+	}
+	return nil
+}
+
+// rows gives each row of the query to fn in turn, and stops at the first error fn returns.
+func (p *plan) rows(fn func(values []interface{}) error) error {
+	read := p.source.scan
+	if p.rowid != nil {
+		read = func(fn func(r *row) error) error {
+			id, err := p.rowid.eval(nil)
+			if err != nil || id == nil {
+				return err
+			}
+			return p.source.fetch(text(id), fn)
+		}
+	}
+
+	return read(func(r *row) error {
+		if p.where != nil {
+			ok, err := p.where.eval(r)
+			if err != nil || ok != true {
+				return err
+			}
+		}
+
+		values := make([]interface{}, len(p.columns))
+		for i, column := range p.columns {
+			var err error
+			if values[i], err = column.eval(r); err != nil {
+				return err
+			}
+		}
+		return fn(values)
+	})
+}
+
+// openSource finds the table a query reads, as of the point its flashback clause names.
+func openSource(ref *dml.TTableRef, values map[string]interface{}) (*source, error) {
+	switch {
+	case ref.Subquery != nil, ref.Join != nil:
+		return nil, errors.New("a query in the FROM clause is not supported")
+	case ref.DbLink != "":
+		return nil, errors.New("database links are not supported")
+	}
+
+	src := &source{schema: ref.Schema, name: ref.Name, alias: ref.Alias}
+	if src.schema == "" {
+		src.schema = "SYS"
+	}
+	if src.schema == "SYS" && (ref.Name == "DUAL" || ref.Name == "§") && ref.Flashback != nil {
+		return nil, fmt.Errorf("%v has no history for a flashback query", ref.Name)
+	}
+	if src.schema == "SYS" {
+		switch ref.Name {
+		case "DUAL":
+			src.columns, src.types, src.values = []string{"DUMMY"}, []string{types.TypeString}, []interface{}{"X"}
+			return src, nil
+		case "§":
+			return src, nil
+		}
+	}
+
+	db := database.Current()
+	if db == nil {
+		return nil, errors.New("there is no database")
+	}
+	table, columns, err := db.Catalog.Table(src.schema, src.name)
+	if err != nil {
+		return nil, err
+	}
+	src.table, src.txns = table, db.Database.Transactions()
+	if err = src.describe(columns); err != nil {
+		return nil, err
+	}
+	if ref.Flashback != nil {
+		if err = src.flashback(ref.Flashback, &compiler{source: &source{}, values: values}); err != nil {
+			return nil, err
+		}
+	}
+	return src, nil
+}
+
+// flashback compiles the points of a flashback clause, which cannot use the columns of the table.
+func (s *source) flashback(f *dml.TFlashback, c *compiler) error {
+	s.versions, s.timestamp = f.Versions, f.Timestamp
+	clause, typ := "AS OF", types.TypeNumber
+	if f.Versions {
+		clause = "VERSIONS BETWEEN"
+	}
+	if f.Timestamp {
+		typ = types.TypeTimestamp
+	}
+
+	compile := func(e dml.Expr) (*expr, error) {
+		if e == nil {
+			return nil, nil
+		}
+		point, err := c.compile(e)
+		if err != nil {
+			return nil, err
+		}
+		return coerce(point, typ, clause)
+	}
+
+	var err error
+	if s.asOf, err = compile(f.AsOf); err != nil {
+		return err
+	}
+	if s.from, err = compile(f.From); err != nil {
+		return err
+	}
+	s.to, err = compile(f.To)
+	return err
+}
+
+// point is the SCN a point of the flashback clause names: the SCN it is, or the one current at the
+// timestamp it is.
+func (s *source) point(e *expr) (store.SCN, error) {
+	v, err := e.eval(nil)
+	if err != nil {
+		return 0, err
+	}
+	if v == nil {
+		return 0, errors.New("a flashback query needs an SCN or a timestamp, not NULL")
+	}
+	if s.timestamp {
+		return s.txns.SCNAt(v.(time.Time))
+	}
+	scn, ok := v.(*types.Number).Int64()
+	if !ok || scn < 0 {
+		return 0, fmt.Errorf("invalid SCN %v", v)
+	}
+	return store.SCN(scn), nil
+}
+
+// snapshot is what the query sees of the table: what is committed now, or as of the point AS OF names.
+func (s *source) snapshot() (*store.Snapshot, error) {
+	if s.asOf == nil {
+		return s.txns.Snapshot(), nil
+	}
+	scn, err := s.point(s.asOf)
+	if err != nil {
+		return nil, err
+	}
+	return s.txns.SnapshotAt(scn)
+}
+
+// describe gives the source the columns of its table.
+func (s *source) describe(columns []*ddl.TColumn) error {
+	for _, column := range columns {
+		typ, err := types.TypeOf(column.DataType.Name)
+		if err != nil {
+			return fmt.Errorf("%v.%v: %w", s.name, column.Name, err)
+		}
+		s.columns = append(s.columns, column.Name)
+		s.types = append(s.types, typ)
+	}
+	return nil
+}
+
+// named reports whether a qualifier - alias, table or schema.table - names the source.
+func (s *source) named(qualifier []string) bool {
+	switch {
+	case len(qualifier) == 1 && s.alias != "":
+		return qualifier[0] == s.alias
+	case len(qualifier) == 1:
+		return qualifier[0] == s.name
+	case len(qualifier) == 2:
+		return s.alias == "" && qualifier[0] == s.schema && qualifier[1] == s.name
+	}
+	return false
+}
+
+// column is the expression of a column of the source.
+func (s *source) column(i int) *expr {
+	return &expr{typ: s.types[i], eval: func(r *row) (interface{}, error) {
+		return r.values[i], nil
+	}}
+}
+
+// scan gives each row of the source to fn.
+func (s *source) scan(fn func(r *row) error) error {
+	if s.table == nil {
+		return fn(&row{values: s.values})
+	}
+	if s.versions {
+		return s.scanVersions(fn)
+	}
+
+	snapshot, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+	return s.table.Scan(snapshot, func(id store.RowID, data []byte) error {
+		r, err := s.decode(id, data)
+		if err != nil {
+			return err
+		}
+		return fn(r)
+	})
+}
+
+// scanVersions gives each version of each row VERSIONS BETWEEN lists to fn. MINVALUE is the oldest
+// SCN remembered, and MAXVALUE the current one.
+func (s *source) scanVersions(fn func(r *row) error) error {
+	var err error
+	from, to := s.txns.OldestSCN(), s.txns.SCN()
+	if s.from != nil {
+		if from, err = s.point(s.from); err != nil {
+			return err
+		}
+	}
+	if s.to != nil {
+		if to, err = s.point(s.to); err != nil {
+			return err
+		}
+	}
+
+	return s.table.Versions(from, to, func(id store.RowID, version *store.RowVersion) error {
+		r := &row{id: id, values: make([]interface{}, len(s.columns))}
+		if version.Row != nil {
+			if r, err = s.decode(id, version.Row); err != nil {
+				return err
+			}
+		}
+		r.version = version
+		return fn(r)
+	})
+}
+
+// fetch gives the row a ROWID names to fn, if the table has it.
+func (s *source) fetch(rowid string, fn func(r *row) error) error {
+	id, err := store.ParseRowID(rowid)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	defer snapshot.Release()
+	data, err := s.table.Fetch(snapshot, id)
+	if err == store.ErrRowNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	r, err := s.decode(id, data)
+	if err != nil {
+		return err
+	}
+	return fn(r)
+}
+
+// decode reads the values of a row of the table.
+func (s *source) decode(id store.RowID, data []byte) (*row, error) {
+	raw, err := store.DecodeRow(data)
+	if err != nil {
+		return nil, err
+	}
+	r := &row{id: id, values: make([]interface{}, len(s.columns))}
+	for i := range s.columns {
+		if i < len(raw) && raw[i] != nil {
+			if r.values[i], err = types.DecodeValue(raw[i], s.types[i]); err != nil {
+				return nil, fmt.Errorf("column %v of row %v: %w", s.columns[i], id, err)
+			}
+		}
+	}
+	return r, nil
+}
+
+// compiler turns the expressions of a query into exprs.
+type compiler struct {
+	source *source
+	values map[string]interface{}
+}
+
+// condition compiles an expression that must be true, false or unknown, such as that of a WHERE
+// clause.
+func (c *compiler) condition(e dml.Expr, clause string) (*expr, error) {
+	cond, err := c.compile(e)
+	if err != nil {
+		return nil, err
+	}
+	if cond.typ != types.TypeBoolean && cond.typ != "" {
+		return nil, fmt.Errorf("%v needs a condition, not a %v", clause, cond.typ)
+	}
+	return cond, nil
+}
+
+func (c *compiler) compile(e dml.Expr) (*expr, error) {
+	switch e := e.(type) {
+	case *dml.TLiteral:
+		return constant(e.Value), nil
+
+	case *dml.TBind:
+		name := e.Name
+		if name == "" {
+			name = strconv.Itoa(e.Position)
+		}
+		for key, value := range c.values {
+			if strings.EqualFold(key, name) {
+				return constant(value), nil
+			}
+		}
+		return nil, fmt.Errorf("no value for bind variable %v", name)
+
+	case *dml.TTypedLiteral:
+		t, err := types.ParseTimestamp(e.Value, "")
+		if err != nil {
+			return nil, fmt.Errorf("%v literal: %w", e.Type, err)
+		}
+		return constant(t), nil
+
+	case *dml.TIdentifier:
+		return c.identifier(e.Names)
+
+	case *dml.TUnary:
+		return c.unary(e)
+
+	case *dml.TBinary:
+		return c.binary(e.Operator, e.Left, e.Right)
+
+	case *dml.TIsNull:
+		operand, err := c.compile(e.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return &expr{typ: types.TypeBoolean, eval: func(r *row) (interface{}, error) {
+			v, err := operand.eval(r)
+			if err != nil {
+				return nil, err
+			}
+			return (v == nil) != e.Not, nil
+		}}, nil
+
+	case *dml.TBetween:
+		// x BETWEEN a AND b is x >= a AND x <= b
+		var between dml.Expr = &dml.TBinary{Operator: "AND",
+			Left:  &dml.TBinary{Operator: ">=", Left: e.Operand, Right: e.Low},
+			Right: &dml.TBinary{Operator: "<=", Left: e.Operand, Right: e.High}}
+		if e.Not {
+			between = &dml.TUnary{Operator: "NOT", Operand: between}
+		}
+		return c.compile(between)
+
+	case *dml.TIn:
+		// x IN (a, b) is x = a OR x = b, which is unknown rather than false when one of them is NULL
+		items := []dml.Expr{e.List}
+		switch list := e.List.(type) {
+		case *dml.TList:
+			items = list.Items
+		case *dml.TSubquery:
+			return nil, errors.New("IN with a subquery is not supported")
+		}
+		var in dml.Expr
+		for _, item := range items {
+			var equal dml.Expr = &dml.TBinary{Operator: "=", Left: e.Operand, Right: item}
+			if in != nil {
+				equal = &dml.TBinary{Operator: "OR", Left: in, Right: equal}
+			}
+			in = equal
+		}
+		if e.Not {
+			in = &dml.TUnary{Operator: "NOT", Operand: in}
+		}
+		return c.compile(in)
+
+	case *dml.TLike:
+		return c.like(e)
+	}
+
+	return nil, unsupported(e)
+}
+
+// unsupported is the error for an expression that a query cannot have yet.
+func unsupported(e dml.Expr) error {
+	switch e := e.(type) {
+	case *dml.TFunction:
+		return fmt.Errorf("function %v is not supported", strings.Join(e.Names, "."))
+	case *dml.TCase:
+		return errors.New("CASE is not supported")
+	case *dml.TSubquery, *dml.TExists, *dml.TCursor:
+		return errors.New("subqueries are not supported")
+	case *dml.TCast:
+		return errors.New("CAST is not supported")
+	case *dml.TIntervalLiteral, *dml.TInterval:
+		return errors.New("intervals are not supported")
+	case *dml.TAtTimeZone:
+		return errors.New("AT TIME ZONE is not supported")
+	case *dml.TList:
+		return errors.New("a list of values is only allowed after IN")
+	case *dml.TQuantified:
+		return fmt.Errorf("%v is not supported", e.Quantifier)
+	}
+	return fmt.Errorf("%T is not supported", e)
+}
+
+// constant is an expression whose value is known when the query is planned.
+func constant(value interface{}) *expr {
+	return &expr{typ: typeOf(value), eval: func(*row) (interface{}, error) {
+		return value, nil
+	}}
+}
+
+// typeOf is the type of a value, or "" for NULL.
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return types.TypeString
+	case *types.Number:
+		return types.TypeNumber
+	case time.Time:
+		return types.TypeTimestamp
+	case bool:
+		return types.TypeBoolean
+	}
+	return ""
+}
+
+// identifier finds the column a name is, as column, qualifier.column or schema.table.column.
+func (c *compiler) identifier(names []string) (*expr, error) {
+	name, qualifier := names[len(names)-1], names[:len(names)-1]
+
+	if len(qualifier) == 0 || c.source.named(qualifier) {
+		for i, column := range c.source.columns {
+			if column == name {
+				return c.source.column(i), nil
+			}
+		}
+	}
+
+	if name == "ROWID" && c.source.table != nil && (len(qualifier) == 0 || c.source.named(qualifier)) {
+		return &expr{typ: types.TypeString, eval: func(r *row) (interface{}, error) {
+			return r.id.String(), nil
+		}}, nil
+	}
+	if strings.HasPrefix(name, "VERSIONS_") && (len(qualifier) == 0 || c.source.named(qualifier)) {
+		return c.source.versionColumn(name)
+	}
+	if len(qualifier) == 0 {
+		switch name {
+		case "SYSTIMESTAMP":
+			return constant(time.Now().UTC()), nil
+		case "SYSDATE":
+			return constant(time.Now().UTC().Truncate(time.Second)), nil
+		case "TRUE", "FALSE":
+			return constant(name == "TRUE"), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown column %v", strings.Join(names, "."))
+}
+
+// versionColumn is a pseudo-column that describes a version of a row VERSIONS BETWEEN lists.
+func (s *source) versionColumn(name string) (*expr, error) {
+	var typ string
+	var value func(v *store.RowVersion) interface{}
+	scn := func(scn store.SCN) interface{} {
+		if scn == 0 {
+			return nil
+		}
+		return types.NewNumber(int64(scn))
+	}
+
+	switch name {
+	case "VERSIONS_STARTSCN":
+		typ, value = types.TypeNumber, func(v *store.RowVersion) interface{} { return scn(v.StartSCN) }
+	case "VERSIONS_ENDSCN":
+		typ, value = types.TypeNumber, func(v *store.RowVersion) interface{} { return scn(v.EndSCN) }
+	case "VERSIONS_OPERATION":
+		typ, value = types.TypeString, func(v *store.RowVersion) interface{} { return string(v.Operation) }
+	case "VERSIONS_XID":
+		typ, value = types.TypeNumber, func(v *store.RowVersion) interface{} { return types.NewNumber(int64(v.Txn)) }
+	default:
+		return nil, fmt.Errorf("unknown column %v", name)
+	}
+	if !s.versions {
+		return nil, fmt.Errorf("%v needs VERSIONS BETWEEN", name)
+	}
+
+	return &expr{typ: typ, eval: func(r *row) (interface{}, error) {
+		return value(r.version), nil
+	}}, nil
+}
+
+func (c *compiler) unary(e *dml.TUnary) (*expr, error) {
+	operand, err := c.compile(e.Operand)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Operator {
+	case "NOT":
+		if operand, err = coerce(operand, types.TypeBoolean, "NOT"); err != nil {
+			return nil, err
+		}
+		return &expr{typ: types.TypeBoolean, eval: func(r *row) (interface{}, error) {
+			v, err := operand.eval(r)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return !v.(bool), nil
+		}}, nil
+
+	case "+", "-":
+		if operand, err = coerce(operand, types.TypeNumber, e.Operator); err != nil {
+			return nil, err
+		}
+		if e.Operator == "+" {
+			return operand, nil
+		}
+		return &expr{typ: types.TypeNumber, eval: func(r *row) (interface{}, error) {
+			v, err := operand.eval(r)
+			if err != nil || v == nil {
+				return nil, err
+			}
+			return v.(*types.Number).Neg(), nil
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("%v is not supported", e.Operator)
+}
+
+func (c *compiler) binary(op string, l, r dml.Expr) (*expr, error) {
+	if _, ok := r.(*dml.TQuantified); ok {
+		return nil, unsupported(r)
+	}
+	left, err := c.compile(l)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "AND", "OR":
+		return logical(op, left, right)
+	case "+", "-", "*", "/":
+		return arithmetic(op, left, right)
+	case "||":
+		return concatenate(left, right), nil
+	}
+	return comparison(op, left, right)
+}
+
+// logical is AND or OR, which are true, false or unknown.
+func logical(op string, left, right *expr) (*expr, error) {
+	var err error
+	if left, err = coerce(left, types.TypeBoolean, op); err != nil {
+		return nil, err
+	}
+	if right, err = coerce(right, types.TypeBoolean, op); err != nil {
+		return nil, err
+	}
+
+	// AND is false if either side is false, and OR is true if either side is true, whatever the other is
+	decides := op == "OR"
+	return &expr{typ: types.TypeBoolean, eval: func(r *row) (interface{}, error) {
+		a, err := left.eval(r)
+		if err != nil || a == decides {
+			return a, err
+		}
+		b, err := right.eval(r)
+		if err != nil || b == decides {
+			return b, err
+		}
+		if a == nil || b == nil {
+			return nil, nil
+		}
+		return !decides, nil
+	}}, nil
+}
+
+func arithmetic(op string, left, right *expr) (*expr, error) {
+	var err error
+	if left, err = coerce(left, types.TypeNumber, op); err != nil {
+		return nil, err
+	}
+	if right, err = coerce(right, types.TypeNumber, op); err != nil {
+		return nil, err
+	}
+
+	return &expr{typ: types.TypeNumber, eval: func(r *row) (interface{}, error) {
+		a, err := left.eval(r)
+		if err != nil || a == nil {
+			return nil, err
+		}
+		b, err := right.eval(r)
+		if err != nil || b == nil {
+			return nil, err
+		}
+
+		x, y := a.(*types.Number), b.(*types.Number)
+		switch op {
+		case "+":
+			return x.Add(y)
+		case "-":
+			return x.Sub(y)
+		case "*":
+			return x.Mul(y)
+		}
+		return quo(x, y)
+	}}, nil
+}
+
+// quo divides x by y, dropping the zeros the division leaves at the end, so that 1/4 is 0.25 and
+// 10/2 is 5.
+func quo(x, y *types.Number) (*types.Number, error) {
+	z, err := x.Quo(y, divisionScale, types.HalfUp)
+	if err != nil {
+		return nil, err
+	}
+
+	unscaled, scale := z.Unscaled(), z.Scale()
+	ten, q, m := big.NewInt(10), new(big.Int), new(big.Int)
+	for scale > 0 {
+		if q.QuoRem(unscaled, ten, m); m.Sign() != 0 {
+			break
+		}
+		unscaled.Set(q)
+		scale--
+	}
+	return types.NewScaledNumber(unscaled, scale)
+}
+
+// concatenate is ||, which takes NULL for an empty string.
+func concatenate(left, right *expr) *expr {
+	return &expr{typ: types.TypeString, eval: func(r *row) (interface{}, error) {
+		a, err := left.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		if a == nil && b == nil {
+			return nil, nil
+		}
+		return text(a) + text(b), nil
+	}}
+}
+
+// text is a value as a string, as || and LIKE see it.
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case time.Time:
+		return v.Format("2006-01-02T15:04:05.999999999")
+	case bool:
+		return strings.ToUpper(strconv.FormatBool(v))
+	}
+	return fmt.Sprint(value)
+}
+
+// comparison is =, <>, !=, ^=, <, >, <= or >=. A string compared with a number or a timestamp is
+// read as one.
+func comparison(op string, left, right *expr) (*expr, error) {
+	typ := left.typ
+	switch {
+	case typ == "" || typ == types.TypeString && right.typ != "":
+		typ = right.typ
+	}
+
+	var err error
+	if left, err = coerce(left, typ, op); err != nil {
+		return nil, err
+	}
+	if right, err = coerce(right, typ, op); err != nil {
+		return nil, err
+	}
+	if typ == types.TypeBoolean && op != "=" && op != "<>" && op != "!=" && op != "^=" {
+		return nil, fmt.Errorf("booleans cannot be compared with %v", op)
+	}
+
+	return &expr{typ: types.TypeBoolean, eval: func(r *row) (interface{}, error) {
+		a, err := left.eval(r)
+		if err != nil || a == nil {
+			return nil, err
+		}
+		b, err := right.eval(r)
+		if err != nil || b == nil {
+			return nil, err
+		}
+
+		cmp := compare(a, b)
+		switch op {
+		case "=":
+			return cmp == 0, nil
+		case "<":
+			return cmp < 0, nil
+		case ">":
+			return cmp > 0, nil
+		case "<=":
+			return cmp <= 0, nil
+		case ">=":
+			return cmp >= 0, nil
+		}
+		return cmp != 0, nil
+	}}, nil
+}
+
+// compare orders two values of the same type.
+func compare(a, b interface{}) int {
+	switch x := a.(type) {
+	case string:
+		return strings.Compare(x, b.(string))
+	case *types.Number:
+		return x.Cmp(b.(*types.Number))
+	case time.Time:
+		return x.Compare(b.(time.Time))
+	case bool:
+		switch y := b.(bool); {
+		case x == y:
+			return 0
+		case y:
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+// coerce makes an expression one of a type, reading a string as a number or timestamp when it has to.
+func coerce(e *expr, typ, op string) (*expr, error) {
+	switch {
+	case e.typ == typ || e.typ == "" || typ == "":
+		return e, nil
+	case e.typ != types.TypeString || typ == types.TypeBoolean:
+		return nil, fmt.Errorf("%v needs a %v, not a %v", op, typ, e.typ)
+	}
+
+	return &expr{typ: typ, eval: func(r *row) (interface{}, error) {
+		v, err := e.eval(r)
+		if err != nil || v == nil {
+			return nil, err
+		}
+		if typ == types.TypeNumber {
+			return types.ParseNumber(v.(string))
+		}
+		return types.ParseTimestamp(v.(string), "")
+	}}, nil
+}
+
+func (c *compiler) like(e *dml.TLike) (*expr, error) {
+	operand, err := c.compile(e.Operand)
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := c.compile(e.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	escape := constant(nil)
+	if e.Escape != nil {
+		if escape, err = c.compile(e.Escape); err != nil {
+			return nil, err
+		}
+	}
+
+	return &expr{typ: types.TypeBoolean, eval: func(r *row) (interface{}, error) {
+		s, err := operand.eval(r)
+		if err != nil || s == nil {
+			return nil, err
+		}
+		p, err := pattern.eval(r)
+		if err != nil || p == nil {
+			return nil, err
+		}
+		esc, err := escape.eval(r)
+		if err != nil {
+			return nil, err
+		}
+		var escapeRune rune = -1
+		if esc != nil {
+			runes := []rune(text(esc))
+			if len(runes) != 1 {
+				return nil, errors.New("the ESCAPE of LIKE must be one character")
+			}
+			escapeRune = runes[0]
+		}
+		return like([]rune(text(s)), []rune(text(p)), escapeRune) != e.Not, nil
+	}}, nil
+}
+
+// like reports whether s matches a LIKE pattern, where _ is any one character and % any number of
+// them, unless the escape character comes before it.
+func like(s, pattern []rune, escape rune) bool {
+	// on a mismatch, go back to the last % and let it take one more character
+	star, starS := -1, 0
+	i, j := 0, 0
+	for i < len(s) {
+		if j < len(pattern) {
+			c := pattern[j]
+			switch {
+			case c == escape && j+1 < len(pattern):
+				if pattern[j+1] == s[i] {
+					i, j = i+1, j+2
+					continue
+				}
+			case c == '%':
+				star, starS = j, i
+				j++
+				continue
+			case c == '_' || c == s[i]:
+				i, j = i+1, j+1
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		starS++
+		i, j = starS, star+1
+	}
+
+	for j < len(pattern) && pattern[j] == '%' {
+		j++
+	}
+	return j == len(pattern)
+}
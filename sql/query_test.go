@@ -0,0 +1,302 @@
+package sql
+
+import (
+	"fmt"
+	"github.com/djbckr/godb/database"
+	"github.com/djbckr/godb/sql/ddl"
+	"github.com/djbckr/godb/sql/token"
+	"github.com/djbckr/godb/sql/types"
+	"github.com/djbckr/godb/store"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startDatabase starts the server and makes a database in a directory of its own.
+func startDatabase(t *testing.T) *database.Instance {
+	dir := t.TempDir()
+	t.Cleanup(func() { _ = database.Shutdown() })
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	tokens, e := token.Tokenize(fmt.Sprintf(`create database orcl
+		initparams('ctrlFile=%v')
+		user sys identified by 'change me'
+		logfile group 1 ('%v') size 64k, group 2 ('%v') size 64k
+		datafile '%v' size 512k autoextend on
+		undo tablespace undo datafile '%v' size 1m`,
+		path("control.ctl"), path("redo1.log"), path("redo2.log"), path("system.dbf"), path("undo.dbf")))
+	if e != nil {
+		t.Fatal(e)
+	}
+	stmt, e := ddl.ProcessCreateDatabase(tokens)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = database.Start(filepath.Join(dir, "godb.ini")); e != nil {
+		t.Fatal(e)
+	}
+	if e = database.Create(stmt); e != nil {
+		t.Fatal(e)
+	}
+	return database.Current()
+}
+
+// insert adds rows to a table, in one transaction, and returns their row ids.
+func insert(t *testing.T, db *database.Instance, name string, rows ...[]interface{}) []store.RowID {
+	table, _, e := db.Catalog.Table("SYS", name)
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var ids []store.RowID
+	txn := db.Database.Transactions().Begin(store.ReadCommitted)
+	for _, values := range rows {
+		row := make([][]byte, len(values))
+		for i, value := range values {
+			if value != nil {
+				if row[i], e = types.EncodeValue(value); e != nil {
+					t.Fatal(e)
+				}
+			}
+		}
+		id, e := table.Insert(txn, store.EncodeRow(row))
+		if e != nil {
+			t.Fatal(e)
+		}
+		ids = append(ids, id)
+	}
+	if _, e = txn.Commit(); e != nil {
+		t.Fatal(e)
+	}
+	return ids
+}
+
+// query runs a query and returns its fields and rows, with each value as text.
+func query(sql string, values map[string]interface{}) ([]*Field, [][]string, error) {
+	command, e := Run(sql, values)
+	if e != nil {
+		return nil, nil, e
+	}
+	var rows [][]string
+	e = command.Rows(func(values []interface{}) error {
+		row := make([]string, len(values))
+		for i, value := range values {
+			row[i] = fmt.Sprint(value)
+			if ts, ok := value.(time.Time); ok {
+				row[i] = ts.Format(time.RFC3339Nano)
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return command.Fields, rows, e
+}
+
+func number(text string) *types.Number {
+	n, _ := types.ParseNumber(text)
+	return n
+}
+
+func TestQuery(t *testing.T) {
+	db := startDatabase(t)
+
+	if _, e := Run("create table items (id integer, name varchar(20), price number(8,2), added timestamp, active boolean)", nil); e != nil {
+		t.Fatal(e)
+	}
+	added := time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	insert(t, db, "ITEMS",
+		[]interface{}{number("1"), "apple", number("0.50"), added, true},
+		[]interface{}{number("2"), "banana", number("12.25"), nil, false},
+		[]interface{}{number("3"), "blueberry", nil, added, nil},
+	)
+
+	_, rows, e := query("select * from items where id = 1", nil)
+	if e != nil || fmt.Sprint(rows) != "[[1 apple 0.50 2020-01-02T03:04:05.6Z true]]" {
+		t.Errorf("unexpected %v, %v", rows, e)
+	}
+	fields, _, _ := query("select * from items", nil)
+	if fmt.Sprint(fields[0], fields[3], fields[4]) != "&{ID number} &{ADDED timestamp} &{ACTIVE boolean}" {
+		t.Errorf("unexpected fields %v %v %v", fields[0], fields[3], fields[4])
+	}
+
+	for _, test := range []struct {
+		sql      string
+		values   map[string]interface{}
+		expected string
+	}{
+		{"select name from items", nil, "[[apple] [banana] [blueberry]]"},
+		{"from items i select i.id, price * 2 as double where name like 'b%'", nil, "[[2 24.50] [3 <nil>]]"},
+		{"select id from items where price > 1 or active", nil, "[[1] [2]]"},
+		{"select id from items where not active", nil, "[[2]]"},
+		{"select id from items where price between 0.5 and 12 and added is not null", nil, "[[1]]"},
+		{"select id from items where id in (1, 3) and name not like '%na%'", nil, "[[1] [3]]"},
+		{"select id from items where id not in (1, null)", nil, "[]"},
+		{"select id from items where added < timestamp '2020-01-03'", nil, "[[1] [3]]"},
+		{"select id from items where added = '2020-01-02T03:04:05.6'", nil, "[[1] [3]]"},
+		{"select 'x' || name || null from sys.items where id = :id", map[string]interface{}{"ID": number("2")}, "[[xbanana]]"},
+		{"select id from items where name = ?", map[string]interface{}{"1": "apple"}, "[[1]]"},
+		{"select id from items where active = :a", map[string]interface{}{"a": false}, "[[2]]"},
+		{"select id from items where id = '3'", nil, "[[3]]"},
+		{"select items.* from items where id = 2", nil, "[[2 banana 12.25 <nil> false]]"},
+		{"select 1/4, 10/2, 2/3, -(1 + 2) * 3 from dual", nil, "[[0.25 5 0.66666666666666666666666666666666666667 -9]]"},
+		{"select * from dual", nil, "[[X]]"},
+		{"from § select 'Hello World'", nil, "[[Hello World]]"},
+		{"select 'Hello World'", nil, "[[Hello World]]"},
+		{"select 1 from dual where 1 = 0", nil, "[]"},
+		{"select [_name] from sys.[_user]", nil, "[[SYS]]"},
+		{"select 'a_c' like 'a!_%' escape '!', 'abc' like 'a!_%' escape '!', 'abc' like '_b_' from dual", nil, "[[true false true]]"},
+	} {
+		if _, rows, e := query(test.sql, test.values); e != nil || fmt.Sprint(rows) != test.expected {
+			t.Errorf("%v: expected %v, got %v, %v", test.sql, test.expected, rows, e)
+		}
+	}
+
+	fields, _, _ = query("select id, name n, price + 1, added from items", nil)
+	if fmt.Sprint(fields[0], fields[1], fields[2], fields[3]) != "&{ID number} &{N string} &{COLUMN3 number} &{ADDED timestamp}" {
+		t.Errorf("unexpected fields %v %v %v %v", fields[0], fields[1], fields[2], fields[3])
+	}
+
+	for _, sql := range []string{
+		"select nothing from items",
+		"select * from nowhere",
+		"select * from sys.tables",
+		"select i.id from items",
+		"select * from items, dual",
+		"select * from items join dual on 1 = 1",
+		"select * from items order by id",
+		"select distinct name from items",
+		"select count(*) from items",
+		"select id from items where name",
+		"select name + 1 from items",
+		"select *",
+		"select 1 from dual union select 2 from dual",
+		"select id from items where active < true",
+	} {
+		if _, rows, e := query(sql, nil); e == nil {
+			t.Errorf("%v: expected an error, got %v", sql, rows)
+		}
+	}
+
+	// a string that is not a number fails when the row is read
+	if _, _, e := query("select id from items where id = 'one'", nil); e == nil {
+		t.Error("expected 'one' not to be read as a number")
+	}
+}
+
+func TestRowID(t *testing.T) {
+	db := startDatabase(t)
+
+	if _, e := Run("create table items (id integer, name varchar(20))", nil); e != nil {
+		t.Fatal(e)
+	}
+	ids := insert(t, db, "ITEMS", []interface{}{number("1"), "apple"}, []interface{}{number("2"), "banana"})
+
+	_, rows, e := query("select rowid, i.rowid, name from items i", nil)
+	if e != nil || fmt.Sprint(rows) != fmt.Sprintf("[[%v %v apple] [%v %v banana]]", ids[0], ids[0], ids[1], ids[1]) {
+		t.Errorf("unexpected %v, %v", rows, e)
+	}
+
+	for _, test := range []struct {
+		sql      string
+		values   map[string]interface{}
+		expected string
+	}{
+		{"select name from items where rowid = :r", map[string]interface{}{"r": ids[1].String()}, "[[banana]]"},
+		{fmt.Sprintf("select name from items where id > 0 and '%v' = rowid", ids[0]), nil, "[[apple]]"},
+		{"select name from items where rowid = :r and id = 1", map[string]interface{}{"r": ids[1].String()}, "[]"},
+		{"select name from items where rowid = :r", map[string]interface{}{"r": nil}, "[]"},
+		{"select name from items where rowid = '0001.7FFFFFFF.0000'", nil, "[]"},
+		{"select name from items where rowid <> :r", map[string]interface{}{"r": ids[1].String()}, "[[apple]]"},
+	} {
+		if _, rows, e := query(test.sql, test.values); e != nil || fmt.Sprint(rows) != test.expected {
+			t.Errorf("%v: expected %v, got %v, %v", test.sql, test.expected, rows, e)
+		}
+	}
+
+	// a deleted row is not found by its ROWID
+	table, _, _ := db.Catalog.Table("SYS", "ITEMS")
+	txn := db.Database.Transactions().Begin(store.ReadCommitted)
+	if e = table.Delete(txn, ids[1]); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = txn.Commit(); e != nil {
+		t.Fatal(e)
+	}
+	if _, rows, e = query("select name from items where rowid = :r", map[string]interface{}{"r": ids[1].String()}); e != nil || len(rows) != 0 {
+		t.Errorf("expected no rows, got %v, %v", rows, e)
+	}
+
+	if _, _, e = query("select name from items where rowid = 'x'", nil); e == nil {
+		t.Error("expected an invalid ROWID to fail")
+	}
+	if _, _, e = query("select rowid from dual", nil); e == nil {
+		t.Error("expected DUAL to have no ROWID")
+	}
+}
+
+func TestFlashback(t *testing.T) {
+	db := startDatabase(t)
+	txns := db.Database.Transactions()
+
+	if _, e := Run("create table items (id integer, name varchar(20))", nil); e != nil {
+		t.Fatal(e)
+	}
+	ids := insert(t, db, "ITEMS", []interface{}{number("1"), "apple"}, []interface{}{number("2"), "banana"})
+	inserted, at := txns.SCN(), time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	table, _, _ := db.Catalog.Table("SYS", "ITEMS")
+	txn := txns.Begin(store.ReadCommitted)
+	row, _ := types.EncodeValue("cherry")
+	if e := table.Update(txn, ids[0], store.EncodeRow([][]byte{[]byte("1"), row})); e != nil {
+		t.Fatal(e)
+	}
+	if e := table.Delete(txn, ids[1]); e != nil {
+		t.Fatal(e)
+	}
+	changed, e := txn.Commit()
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, test := range []struct {
+		sql      string
+		values   map[string]interface{}
+		expected string
+	}{
+		{"select name from items", nil, "[[cherry]]"},
+		{"select name from items as of scn :s", map[string]interface{}{"s": types.NewNumber(int64(inserted))}, "[[apple] [banana]]"},
+		{"select name from items as of timestamp :t", map[string]interface{}{"t": at}, "[[apple] [banana]]"},
+		{"select name from items as of scn :s where rowid = :r", map[string]interface{}{"s": types.NewNumber(int64(inserted)), "r": ids[1].String()}, "[[banana]]"},
+		{"select name from items as of scn :s", map[string]interface{}{"s": types.NewNumber(int64(changed))}, "[[cherry]]"},
+		{"select versions_operation, name from items versions between scn :s and maxvalue where versions_endscn is null",
+			map[string]interface{}{"s": types.NewNumber(int64(inserted))}, "[[U cherry] [D banana]]"},
+	} {
+		if _, rows, e := query(test.sql, test.values); e != nil || fmt.Sprint(rows) != test.expected {
+			t.Errorf("%v: expected %v, got %v, %v", test.sql, test.expected, rows, e)
+		}
+	}
+
+	_, rows, e := query("select versions_startscn, versions_endscn, versions_operation, name from items versions between scn minvalue and maxvalue i where i.id = 1", nil)
+	expected := fmt.Sprintf("[[%v <nil> U cherry] [%v %v I apple]]", changed, inserted, changed)
+	if e != nil || fmt.Sprint(rows) != expected {
+		t.Errorf("expected %v, got %v, %v", expected, rows, e)
+	}
+
+	for _, test := range []struct {
+		sql    string
+		values map[string]interface{}
+	}{
+		{"select versions_operation from items", nil},
+		{"select name from dual as of scn 1", nil},
+		{"select name from items as of scn :s", map[string]interface{}{"s": nil}},
+		{"select name from items as of scn -1", nil},
+		{"select name from items as of scn id", nil},
+		{"select name from items as of timestamp true", nil},
+		{"select versions_nothing from items versions between scn minvalue and maxvalue", nil},
+	} {
+		if _, rows, e := query(test.sql, test.values); e == nil {
+			t.Errorf("%v: expected an error, got %v", test.sql, rows)
+		}
+	}
+}
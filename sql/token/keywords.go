@@ -0,0 +1,140 @@
+package token
+
+// Dialect says where a keyword comes from. A word may come from more than one.
+type Dialect = int
+
+const (
+	DialectGoDB       Dialect = 1 << iota // part of GoDB's own statements
+	DialectANSI                           // reserved in ANSI SQL
+	DialectOracle                         // reserved in Oracle
+	DialectSQL2011                        // reserved in SQL:2011
+	DialectPostgreSQL                     // reserved in PostgreSQL
+)
+
+// Keyword describes a word that has a meaning in SQL.
+type Keyword struct {
+	Word     string
+	Reserved bool // cannot be used as a name unless it is quoted
+	Dialects Dialect
+}
+
+// LookupKeyword returns the keyword for an upper case word, or nil if the word is not a keyword.
+func LookupKeyword(word string) *Keyword {
+	return keywords[word]
+}
+
+// IsReserved reports whether an upper case word can only be used as a name when it is quoted.
+func IsReserved(word string) bool {
+	kw := keywords[word]
+	return kw != nil && kw.Reserved
+}
+
+var keywords = make(map[string]*Keyword)
+
+func init() {
+	register(DialectGoDB, godbWords)
+	register(DialectANSI, ansiWords)
+	register(DialectOracle, oracleWords)
+	register(DialectSQL2011, sql2011Words)
+	register(DialectPostgreSQL, postgresWords)
+
+	for _, word := range reservedWords {
+		keywords[word].Reserved = true
+	}
+}
+
+func register(dialect Dialect, words []string) {
+	for _, word := range words {
+		kw := keywords[word]
+		if kw == nil {
+			kw = &Keyword{Word: word}
+			keywords[word] = kw
+		}
+		kw.Dialects |= dialect
+	}
+}
+
+// reservedWords are the keywords GoDB reserves: the ANSI reserved words, the words that start a
+// statement or name what it works on, and the words that start or separate clauses. Everything
+// else, including most of the SQL:2011 list, stays usable as a name. Oracle's pseudo-columns
+// (LEVEL, ROWID, ROWNUM, SYSDATE, UID) are names here, and the parser gives them their meaning.
+var reservedWords = []string{
+	"ALL", "AND", "ANY", "AS", "BETWEEN", "BY", "CHAR", "CHECK", "COLUMN", "CONNECT", "CURRENT",
+	"DATE", "DECIMAL", "DEFAULT", "DISTINCT", "ELSE", "EXISTS", "FLOAT", "FOR", "GROUP", "HAVING",
+	"IN", "INTEGER", "INTERSECT", "INTO", "IS", "LIKE", "NOT", "NULL", "OF", "ON", "OR", "ORDER",
+	"ROW", "ROWS", "SMALLINT", "START", "THEN", "TO", "UNION", "UNIQUE", "VALUES", "VARCHAR",
+	"WHENEVER", "WHERE", "SELECT", "WITH", "FROM", "INSERT", "UPDATE", "DELETE", "ALTER", "CREATE",
+	"DROP", "GRANT", "REVOKE", "SET", "INDEX", "TABLE", "VIEW", "MINUS", "EXCEPT", "USING", "JOIN",
+	"INNER", "CROSS", "NATURAL", "LEFT", "RIGHT", "FULL", "OUTER", "WHEN", "END", "NULLS", "ASC",
+	"DESC", "ESCAPE", "MODEL",
+}
+
+var godbWords = []string{
+	"SELECT", "WITH", "FROM", "INSERT", "UPDATE", "MERGE", "UPSERT", "DELETE", "COMMIT", "ROLLBACK",
+	"SAVEPOINT", "ALTER", "CREATE", "DROP", "GRANT", "REVOKE", "SET", "DATABASE", "INDEX", "SEQUENCE",
+	"SESSION", "SYSTEM", "TABLE", "TABLESPACE", "TRIGGER", "USER", "VIEW", "CONTROLFILE", "FUNCTION",
+	"ROLE", "SCHEMA", "SYNONYM", "CONSTRAINT", "TRANSACTION", "ANALYSE", "AUDIT", "COMMENT",
+	"EXPLAIN", "RENAME", "TRUNCATE", "MODEL", "NULLS",
+}
+
+var ansiWords = []string{
+	"ALL", "AND", "ANY", "AS", "BETWEEN", "BY", "CHAR", "CHECK", "COLUMN", "CONNECT", "CURRENT",
+	"DATE", "DECIMAL", "DEFAULT", "DISTINCT", "ELSE", "EXISTS", "FLOAT", "FOR", "GROUP", "HAVING",
+	"IN", "INTEGER", "INTERSECT", "INTO", "IS", "LIKE", "NOT", "NULL", "OF", "ON", "OR", "ORDER",
+	"ROW", "ROWS", "SMALLINT", "START", "THEN", "TO", "UNION", "UNIQUE", "VALUES", "VARCHAR",
+	"WHENEVER", "WHERE",
+}
+
+var oracleWords = []string{
+	"ACCESS", "ADD", "ASC", "AUDIT", "CLUSTER", "COMMENT", "COMPRESS", "DESC", "EXCLUSIVE", "EXPLAIN",
+	"FILE", "IDENTIFIED", "IMMEDIATE", "INCREMENT", "INITIAL", "LEVEL", "LOCK", "LONG", "MAXEXTENTS",
+	"MINUS", "MLSLABEL", "MODE", "MODIFY", "NOAUDIT", "NOCOMPRESS", "NOWAIT", "NUMBER", "OFFLINE",
+	"ONLINE", "OPTION", "PCTFREE", "PRIOR", "PRIVILEGES", "PUBLIC", "RAW", "RENAME", "RESOURCE",
+	"ROWID", "ROWNUM", "SHARE", "SIZE", "SUCCESSFUL", "SYSDATE", "UID", "VALIDATE", "VARCHAR2",
+}
+
+var sql2011Words = []string{
+	"ABS", "ALLOCATE", "ARE", "ARRAY", "ARRAY_AGG", "ARRAY_MAX_CARDINALITY", "ASENSITIVE",
+	"ASYMMETRIC", "AT", "ATOMIC", "AUTHORIZATION", "AVG", "BEGIN", "BEGIN_FRAME", "BEGIN_PARTITION",
+	"BIGINT", "BINARY", "BLOB", "BOOLEAN", "BOTH", "CALL", "CALLED", "CARDINALITY", "CASCADED",
+	"CASE", "CAST", "CEIL", "CEILING", "CHARACTER", "CHARACTER_LENGTH", "CHAR_LENGTH", "CLOB",
+	"CLOSE", "COALESCE", "COLLATE", "COLLECT", "CONDITION", "CONTAINS", "CONVERT", "CORR",
+	"CORRESPONDING", "COUNT", "COVAR_POP", "COVAR_SAMP", "CROSS", "CUBE", "CUME_DIST",
+	"CURRENT_CATALOG", "CURRENT_DATE", "CURRENT_DEFAULT_TRANSFORM_GROUP", "CURRENT_PATH",
+	"CURRENT_ROLE", "CURRENT_ROW", "CURRENT_SCHEMA", "CURRENT_TIME", "CURRENT_TIMESTAMP",
+	"CURRENT_TRANSFORM_GROUP_FOR_TYPE", "CURRENT_USER", "CURSOR", "CYCLE", "DATALINK", "DAY",
+	"DEALLOCATE", "DEC", "DECLARE", "DENSE_RANK", "DEREF", "DESCRIBE", "DETERMINISTIC", "DISCONNECT",
+	"DLNEWCOPY", "DLPREVIOUSCOPY", "DLURLCOMPLETE", "DLURLCOMPLETEONLY", "DLURLCOMPLETEWRITE",
+	"DLURLPATH", "DLURLPATHONLY", "DLURLPATHWRITE", "DLURLSCHEME", "DLURLSERVER", "DLVALUE", "DOUBLE",
+	"DYNAMIC", "EACH", "ELEMENT", "END", "END_FRAME", "END_PARTITION", "EQUALS", "ESCAPE", "EVERY",
+	"EXCEPT", "EXEC", "EXECUTE", "EXP", "EXTERNAL", "EXTRACT", "FALSE", "FETCH", "FILTER",
+	"FIRST_VALUE", "FLOOR", "FOREIGN", "FRAME_ROW", "FREE", "FULL", "FUSION", "GET", "GLOBAL",
+	"GROUPING", "GROUPS", "HOLD", "HOUR", "IDENTITY", "IMPORT", "INDICATOR", "INNER", "INOUT",
+	"INSENSITIVE", "INT", "INTERSECTION", "INTERVAL", "JOIN", "LAG", "LANGUAGE", "LARGE",
+	"LAST_VALUE", "LATERAL", "LEAD", "LEADING", "LEFT", "LIKE_REGEX", "LN", "LOCAL", "LOCALTIME",
+	"LOCALTIMESTAMP", "LOWER", "MATCH", "MAX", "MEMBER", "METHOD", "MIN", "MINUTE", "MOD", "MODIFIES",
+	"MODULE", "MONTH", "MULTISET", "NATIONAL", "NATURAL", "NCHAR", "NCLOB", "NEW", "NO", "NONE",
+	"NORMALIZE", "NTH_VALUE", "NTILE", "NULLIF", "NUMERIC", "OCCURRENCES_REGEX", "OCTET_LENGTH",
+	"OFFSET", "OLD", "ONLY", "OPEN", "OUT", "OUTER", "OVER", "OVERLAPS", "OVERLAY", "PARAMETER",
+	"PARTITION", "PERCENT", "PERCENTILE_CONT", "PERCENTILE_DISC", "PERCENT_RANK", "PERIOD", "PORTION",
+	"POSITION", "POSITION_REGEX", "POWER", "PRECEDES", "PRECISION", "PREPARE", "PRIMARY", "PROCEDURE",
+	"RANGE", "RANK", "READS", "REAL", "RECURSIVE", "REF", "REFERENCES", "REFERENCING", "REGR_AVGX",
+	"REGR_AVGY", "REGR_COUNT", "REGR_INTERCEPT", "REGR_R2", "REGR_SLOPE", "REGR_SXX", "REGR_SXY",
+	"REGR_SYY", "RELEASE", "RESULT", "RETURN", "RETURNS", "RIGHT", "ROLLUP", "ROW_NUMBER", "SCOPE",
+	"SCROLL", "SEARCH", "SECOND", "SENSITIVE", "SESSION_USER", "SIMILAR", "SOME", "SPECIFIC",
+	"SPECIFICTYPE", "SQL", "SQLEXCEPTION", "SQLSTATE", "SQLWARNING", "SQRT", "STATIC", "STDDEV_POP",
+	"STDDEV_SAMP", "SUBMULTISET", "SUBSTRING", "SUBSTRING_REGEX", "SUCCEEDS", "SUM", "SYMMETRIC",
+	"SYSTEM_TIME", "SYSTEM_USER", "TABLESAMPLE", "TIME", "TIMESTAMP", "TIMEZONE_HOUR",
+	"TIMEZONE_MINUTE", "TRAILING", "TRANSLATE", "TRANSLATE_REGEX", "TRANSLATION", "TREAT", "TRIM",
+	"TRIM_ARRAY", "TRUE", "TRUNCATE", "UESCAPE", "UNKNOWN", "UNNEST", "UPPER", "USING", "VALUE",
+	"VALUE_OF", "VARBINARY", "VARYING", "VAR_POP", "VAR_SAMP", "VERSIONING", "WHEN", "WIDTH_BUCKET",
+	"WINDOW", "WITHIN", "WITHOUT", "XML", "XMLAGG", "XMLATTRIBUTES", "XMLBINARY", "XMLCAST",
+	"XMLCOMMENT", "XMLCONCAT", "XMLDOCUMENT", "XMLELEMENT", "XMLEXISTS", "XMLFOREST", "XMLITERATE",
+	"XMLNAMESPACES", "XMLPARSE", "XMLPI", "XMLQUERY", "XMLSERIALIZE", "XMLTABLE", "XMLTEXT",
+	"XMLVALIDATE", "YEAR",
+}
+
+var postgresWords = []string{
+	"ANALYSE", "COLLATION", "CONCURRENTLY", "DEFERRABLE", "DO", "FREEZE", "ILIKE", "INITIALLY",
+	"ISNULL", "LIMIT", "NOTNULL", "PLACING", "RETURNING", "VARIADIC", "VERBOSE",
+}
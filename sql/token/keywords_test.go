@@ -0,0 +1,30 @@
+package token
+
+import "testing"
+
+func TestKeywords(t *testing.T) {
+	if kw := LookupKeyword("SELECT"); kw == nil || !kw.Reserved || kw.Dialects&DialectGoDB == 0 {
+		t.Errorf("SELECT: %+v", kw)
+	}
+	if kw := LookupKeyword("LEVEL"); kw == nil || kw.Reserved || kw.Dialects != DialectOracle {
+		t.Errorf("LEVEL: %+v", kw)
+	}
+	if kw := LookupKeyword("ILIKE"); kw == nil || kw.Reserved || kw.Dialects != DialectPostgreSQL {
+		t.Errorf("ILIKE: %+v", kw)
+	}
+	if !IsReserved("JOIN") || !IsReserved("NULL") || IsReserved("COUNT") || IsReserved("ORDERS") {
+		t.Error("unexpected reserved words")
+	}
+	if LookupKeyword("select") != nil || LookupKeyword("ORDERS") != nil {
+		t.Error("only upper case keywords are found")
+	}
+
+	// reserved words that are quoted are names
+	p, e := Tokenize(`select "SELECT" from [from]`)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if p[0].Quoted || !p[1].Quoted || p[2].Quoted || !p[3].Quoted {
+		t.Error("quoted tokens not marked")
+	}
+}
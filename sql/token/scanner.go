@@ -0,0 +1,162 @@
+package token
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// DefaultMaxTokenSize is the largest token a Scanner accepts unless told otherwise.
+const DefaultMaxTokenSize = 16 * 1024 * 1024
+
+// scanChunk is the least a Scanner asks its reader for at a time.
+const scanChunk = 32 * 1024
+
+// A token that ends this close to the end of the text read so far might continue past it:
+// "1e" may turn out to be "1e+5", and "<" may be "<=". Such a token is scanned again with more text.
+const scanLookahead = 16
+
+// Text that opens a comment or string whose end may not have been read yet. Until it is, the
+// tokenizer would take the opening for something shorter: "/" and "*", or the name $TAG.
+var scanOpenerRE = regexp.MustCompile(`(?i)^[[:space:]]*(/\*|q'|\$[^-$[:space:][:cntrl:]]*\$)`)
+
+// Scanner reads tokens one at a time from an io.Reader. It holds only the token being scanned and
+// the text read ahead of it, so a script can be tokenized while it is still arriving. The tokens,
+// their positions and bind numbering are the same as Tokenize would produce for the whole text.
+//
+// /sql does not use it yet: a request's sql is decoded whole with the rest of the body, and only its
+// data is read as it arrives.
+type Scanner struct {
+	reader  io.Reader
+	tdata   tokenizer
+	eof     bool  // the reader has no more text
+	err     error // once Next fails, it keeps failing
+	maxSize int
+	chunk   int
+}
+
+func NewScanner(reader io.Reader) *Scanner {
+	return &Scanner{
+		reader:  reader,
+		tdata:   tokenizer{pos: Position{Line: 1, Column: 1}},
+		maxSize: DefaultMaxTokenSize,
+		chunk:   scanChunk,
+	}
+}
+
+// SetMaxTokenSize limits how many bytes, including leading white space, a single token may take.
+// A longer token makes Next return an error.
+func (s *Scanner) SetMaxTokenSize(size int) {
+	s.maxSize = size
+}
+
+// Next returns the next token, or io.EOF when the text is used up.
+func (s *Scanner) Next() (*Token, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
+	tkn, err := s.next()
+
+	if err != nil {
+		s.err = err
+	}
+
+	return tkn, err
+}
+
+func (s *Scanner) next() (*Token, error) {
+
+	tdata := &s.tdata
+
+	for {
+
+		tdata.slice = tdata.chars[tdata.idx:]
+
+		if strings.TrimSpace(tdata.slice) == "" {
+			if s.eof {
+				return nil, io.EOF
+			}
+			// step over the white space so it doesn't take up room in the window
+			tdata.position(len(tdata.chars))
+			tdata.idx = len(tdata.chars)
+		} else {
+			idx, pos, binds := tdata.idx, tdata.pos, tdata.binds
+
+			if tdata.scan() {
+				tkn := tdata.tokens[0]
+				tdata.tokens = tdata.tokens[:0]
+				if s.eof || (tdata.idx <= len(tdata.chars)-scanLookahead && !s.truncated(tkn)) {
+					return tkn, nil
+				}
+				// the token may go on past the window; scan it again once there is more text
+				tdata.idx, tdata.pos, tdata.binds = idx, pos, binds
+			} else if s.eof {
+				return nil, tdata.invalid()
+			}
+		}
+
+		if err := s.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// truncated reports whether the token is only the start of a comment or string that goes on past
+// the text read so far.
+func (s *Scanner) truncated(tkn *Token) bool {
+	switch tkn.TokenType {
+	case TypeString, TypeComment, TypeHint:
+		return false
+	}
+	return scanOpenerRE.MatchString(s.tdata.slice)
+}
+
+// fill drops the text that has been scanned and reads more. It reads at least half as much as it
+// keeps, so a long token is copied only a few times as it grows.
+func (s *Scanner) fill() error {
+
+	tdata := &s.tdata
+	rest := tdata.chars[tdata.idx:]
+
+	if len(rest) >= s.maxSize {
+		start := tdata.position(tdata.idx + leadingSpace(rest))
+		return fmt.Errorf("SQL token is longer than %v bytes (%v)", s.maxSize, start)
+	}
+
+	size := s.chunk
+	if len(rest) > size {
+		size = len(rest)
+	}
+	if len(rest)+size > s.maxSize {
+		size = s.maxSize - len(rest)
+	}
+
+	least := len(rest)/2 + 1
+	if least > size {
+		least = size
+	}
+
+	buf := make([]byte, size)
+	n := 0
+
+	for n < least {
+		read, err := s.reader.Read(buf[n:])
+		n += read
+		if err == io.EOF {
+			s.eof = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	tdata.base += tdata.idx
+	tdata.chars = rest + string(buf[:n])
+	tdata.maxIdx = len(tdata.chars) - 1
+	tdata.idx = 0
+
+	return nil
+}
@@ -0,0 +1,89 @@
+package token
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// scanAll reads every token, one byte at a time, so tokens are split across reads wherever possible.
+func scanAll(sql string, chunk int) (Tokens, error) {
+	s := NewScanner(iotest.OneByteReader(strings.NewReader(sql)))
+	s.chunk = chunk
+
+	var tokens Tokens
+	for {
+		tkn, e := s.Next()
+		if e == io.EOF {
+			return tokens, nil
+		}
+		if e != nil {
+			return nil, e
+		}
+		tokens = append(tokens, tkn)
+	}
+}
+
+func TestScannerMatchesTokenize(t *testing.T) {
+
+	tests := []string{
+		`select*from dual`,
+		`/* this is a comment */select[issue]from"something"where ix=1e+7`,
+		"  -- single line comment\nselect a, b -- another\n  from dual   \n",
+		`select * from something where x = q'[mary's horse]' foo bar`,
+		`$tag$xyz; $ta $tag$ || $⌘$†π¬˚$⌘$`,
+		"select †, 'it''s', 'a\\'b' from t where a<=b and c <> :name and d = ? and e = ?",
+		`begin x := 1..10; y := 12.5e-3 ** 2; end;`,
+		`select /*+ full(t) */ 0x1f, .5, 1. from t`,
+		``,
+		"   \n\t ",
+	}
+
+	for _, sql := range tests {
+		expected, e := Tokenize(sql)
+		if e != nil {
+			t.Fatal(e)
+		}
+		for _, chunk := range []int{1, 7, scanChunk} {
+			actual, e := scanAll(sql, chunk)
+			if e != nil {
+				t.Fatalf("%v: %v", sql, e)
+			}
+			if len(actual) != len(expected) {
+				t.Fatalf("%v: expected %v tokens, got %v", sql, len(expected), len(actual))
+			}
+			for i := range expected {
+				if fmt.Sprint(*actual[i]) != fmt.Sprint(*expected[i]) {
+					t.Errorf("%v: expected %v, got %v", sql, *expected[i], *actual[i])
+				}
+			}
+		}
+	}
+}
+
+func TestScannerErrors(t *testing.T) {
+
+	_, expected := Tokenize("select a\n from `t`")
+	_, e := scanAll("select a\n from `t`", 3)
+	if e == nil || e.Error() != expected.Error() {
+		t.Errorf("expected %v, got %v", expected, e)
+	}
+
+	if _, e = scanAll(`select 'unterminated`, 4); e == nil {
+		t.Error("expected error for unterminated string")
+	}
+
+	s := NewScanner(strings.NewReader("select '" + strings.Repeat("x", 1000) + "' from dual"))
+	s.SetMaxTokenSize(100)
+	if tkn, e := s.Next(); e != nil || tkn.Value != "SELECT" {
+		t.Fatalf("expected SELECT, got %v %v", tkn, e)
+	}
+	if _, e = s.Next(); e == nil || !strings.Contains(e.Error(), "line 1, column 8") {
+		t.Errorf("expected token size error, got %v", e)
+	}
+	if _, e2 := s.Next(); e2 != e {
+		t.Error("expected the error to repeat")
+	}
+}
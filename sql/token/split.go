@@ -0,0 +1,218 @@
+package token
+
+import (
+	"strings"
+)
+
+// Statement is one statement of a script, without its terminating semicolon.
+type Statement struct {
+	Text   string   // the original text of the statement, including any leading comments
+	Start  Position // where Text starts in the script
+	End    Position // where Text ends in the script
+	Tokens Tokens   // the tokens of the statement; positional binds are numbered from 1 in each statement
+}
+
+// block kinds on the splitter's stack
+const (
+	blockDeclare = iota // DECLARE, or the IS/AS of a function, procedure, package or type body
+	blockBegin
+	blockCase
+	blockIf
+	blockLoop
+)
+
+// Split cuts a script into statements at each semicolon that is not inside a PL block.
+//
+// Comments, strings, q-strings and $tag$ strings are recognized by the tokenizer, so semicolons
+// inside them never split a statement. A semicolon inside BEGIN...END, CASE...END, IF...END IF,
+// LOOP...END LOOP, or the declarations that lead to a BEGIN, does not end the statement either.
+func Split(script string) ([]*Statement, error) {
+
+	tokens, err := Tokenize(script)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []*Statement
+	var stack []int
+
+	first := 0      // index of the first token of the current statement
+	header := false // a FUNCTION, PROCEDURE, PACKAGE or BODY header is waiting for its IS or AS
+	keyword := ""   // the first keyword of the current statement
+
+	for i := 0; i < len(tokens); i++ {
+		tkn := tokens[i]
+
+		if tkn.TokenType == TypePunctuation && tkn.Value == ";" {
+			header = false
+			if len(stack) == 0 {
+				statements = appendStatement(statements, script, tokens[first:i])
+				first = i + 1
+				keyword = ""
+			}
+			continue
+		}
+
+		if tkn.TokenType != TypeToken || tkn.Quoted {
+			continue
+		}
+
+		word := tkn.Value.(string)
+		if keyword == "" {
+			keyword = word
+		}
+
+		switch word {
+
+		case "DECLARE":
+			if keyword == "DECLARE" && len(stack) == 0 {
+				stack = append(stack, blockDeclare)
+			}
+
+		case "FUNCTION", "PROCEDURE", "PACKAGE", "BODY":
+			// the unit a CREATE makes, or a function or procedure of a declaration section; elsewhere
+			// these are just names, as in SELECT package FROM t WHERE x IS NULL
+			header = unitHeader(tokens[first:i+1]) ||
+				(word == "FUNCTION" || word == "PROCEDURE") && len(stack) > 0 && stack[len(stack)-1] == blockDeclare
+
+		case "IS", "AS":
+			// the body of a unit, unless it is given as a string: AS $$ ... $$
+			if header && (i+1 == len(tokens) || tokens[i+1].TokenType != TypeString) {
+				stack = append(stack, blockDeclare)
+			}
+			header = false
+
+		case "BEGIN":
+			if keyword == "BEGIN" && len(stack) == 0 && startsTransaction(tokens[i+1:]) {
+				// BEGIN; or BEGIN TRANSACTION starts a transaction, not a block
+				continue
+			}
+			if len(stack) > 0 && stack[len(stack)-1] == blockDeclare {
+				// the BEGIN that belongs to a declaration section shares its END
+				stack[len(stack)-1] = blockBegin
+			} else {
+				stack = append(stack, blockBegin)
+			}
+
+		case "CASE":
+			stack = append(stack, blockCase)
+
+		case "IF":
+			if inPLBlock(stack) {
+				stack = append(stack, blockIf)
+			}
+
+		case "LOOP":
+			if inPLBlock(stack) {
+				stack = append(stack, blockLoop)
+			}
+
+		case "END":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			// END IF, END LOOP and END CASE close what they name; don't open it again
+			if i+1 < len(tokens) && tokens[i+1].TokenType == TypeToken {
+				switch tokens[i+1].Value {
+				case "IF", "LOOP", "CASE":
+					i++
+				}
+			}
+		}
+	}
+
+	return appendStatement(statements, script, tokens[first:]), nil
+}
+
+// appendStatement adds a statement made of the given tokens, unless there is nothing but comments.
+func appendStatement(statements []*Statement, script string, tokens Tokens) []*Statement {
+
+	empty := true
+	for _, tkn := range tokens {
+		if tkn.TokenType != TypeComment {
+			empty = false
+			break
+		}
+	}
+
+	if empty {
+		return statements
+	}
+
+	stmt := &Statement{
+		Start:  tokens[0].Start,
+		End:    tokens[len(tokens)-1].End,
+		Tokens: make(Tokens, len(tokens)),
+	}
+	stmt.Text = script[stmt.Start.Offset:stmt.End.Offset]
+
+	// positional binds were numbered across the whole script
+	binds := 0
+	for i, tkn := range tokens {
+		if _, ok := tkn.Value.(int); ok && tkn.TokenType == TypeBind {
+			binds++
+			renumbered := *tkn
+			renumbered.Value = binds
+			tkn = &renumbered
+		}
+		stmt.Tokens[i] = tkn
+	}
+
+	return append(statements, stmt)
+}
+
+// startsTransaction reports whether the tokens after BEGIN make it a transaction statement.
+func startsTransaction(rest Tokens) bool {
+	for _, tkn := range rest {
+		switch {
+		case tkn.TokenType == TypeComment:
+			continue
+		case tkn.TokenType == TypePunctuation && tkn.Value == ";":
+			return true
+		case tkn.TokenType == TypeToken && (tkn.Value == "TRANSACTION" || tkn.Value == "WORK"):
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// unitHeader reports whether a statement so far is CREATE [OR REPLACE] followed by FUNCTION,
+// PROCEDURE, PACKAGE, PACKAGE BODY or TYPE BODY.
+func unitHeader(tokens Tokens) bool {
+	var words []string
+	for _, tkn := range tokens {
+		if tkn.TokenType == TypeComment || tkn.TokenType == TypeHint {
+			continue
+		}
+		if tkn.TokenType != TypeToken || tkn.Quoted {
+			return false
+		}
+		words = append(words, tkn.Value.(string))
+	}
+
+	if len(words) < 2 || words[0] != "CREATE" {
+		return false
+	}
+	words = words[1:]
+	if len(words) > 2 && words[0] == "OR" && words[1] == "REPLACE" {
+		words = words[2:]
+	}
+
+	switch strings.Join(words, " ") {
+	case "FUNCTION", "PROCEDURE", "PACKAGE", "PACKAGE BODY", "TYPE BODY":
+		return true
+	}
+	return false
+}
+
+// inPLBlock reports whether IF and LOOP are statements here rather than, say, DROP TABLE IF EXISTS.
+func inPLBlock(stack []int) bool {
+	for _, block := range stack {
+		if block == blockBegin || block == blockDeclare {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+package token
+
+import (
+	"testing"
+)
+
+func checkSplit(t *testing.T, script string, expected ...string) []*Statement {
+	stmts, e := Split(script)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if len(stmts) != len(expected) {
+		for _, stmt := range stmts {
+			t.Logf("statement: %q", stmt.Text)
+		}
+		t.Fatalf("expected %v statements, got %v", len(expected), len(stmts))
+	}
+
+	for k, v := range expected {
+		if stmts[k].Text != v {
+			t.Errorf("statement %v: expected %q, got %q", k, v, stmts[k].Text)
+		}
+	}
+
+	return stmts
+}
+
+func TestSplit(t *testing.T) {
+
+	checkSplit(t, `select 1 from dual; select 2 from dual;`,
+		`select 1 from dual`,
+		`select 2 from dual`)
+
+	checkSplit(t, `select 1 from dual;; ;  select 2 from dual`,
+		`select 1 from dual`,
+		`select 2 from dual`)
+
+	checkSplit(t, `insert into t values ('a;b'); insert into t values (q'[c;d]'); -- trailing; comment
+		/* a; comment */ select $$;$$ from dual /* another; */ ;`,
+		`insert into t values ('a;b')`,
+		`insert into t values (q'[c;d]')`,
+		"-- trailing; comment\n\t\t/* a; comment */ select $$;$$ from dual /* another; */")
+
+	checkSplit(t, `begin
+  update t set x = case when y > 0 then 1 else 2 end;
+  if x > 1 then
+    delete from t;
+  end if;
+  for i in 1..10 loop
+    insert into t values (i);
+  end loop;
+end;
+commit;`,
+		`begin
+  update t set x = case when y > 0 then 1 else 2 end;
+  if x > 1 then
+    delete from t;
+  end if;
+  for i in 1..10 loop
+    insert into t values (i);
+  end loop;
+end`,
+		`commit`)
+
+	checkSplit(t, `declare
+  x number;
+  cursor c is select * from t;
+begin
+  begin
+    null;
+  end;
+end;
+drop table if exists t;
+begin;
+begin transaction;`,
+		`declare
+  x number;
+  cursor c is select * from t;
+begin
+  begin
+    null;
+  end;
+end`,
+		`drop table if exists t`,
+		`begin`,
+		`begin transaction`)
+
+	checkSplit(t, `create or replace procedure p(a in number) is
+  y number;
+begin
+  y := a;
+end p;
+create or replace package k as
+  procedure p;
+  function f return number;
+end k;
+create or replace package body k as
+  procedure p is begin null; end;
+  function f return number is
+  begin
+    return 1;
+  end;
+end k;
+create function g() returns int as $body$ select 1; $body$ language sql;
+create view v as select * from t;`,
+		`create or replace procedure p(a in number) is
+  y number;
+begin
+  y := a;
+end p`,
+		`create or replace package k as
+  procedure p;
+  function f return number;
+end k`,
+		`create or replace package body k as
+  procedure p is begin null; end;
+  function f return number is
+  begin
+    return 1;
+  end;
+end k`,
+		`create function g() returns int as $body$ select 1; $body$ language sql`,
+		`create view v as select * from t`)
+
+	// FUNCTION, PACKAGE and the like are only headers where a unit is made or declared
+	checkSplit(t, `create table t as select package from u where x is null; select 1 from dual`,
+		`create table t as select package from u where x is null`,
+		`select 1 from dual`)
+
+	checkSplit(t, `select function, procedure from t where body is not null; select 1 from dual;
+declare
+  function f return number is
+  begin
+    return 1;
+  end;
+begin
+  null;
+end;
+create type body tp as
+  member function m return number is begin return 1; end;
+end;
+select 2 from dual`,
+		`select function, procedure from t where body is not null`,
+		`select 1 from dual`,
+		`declare
+  function f return number is
+  begin
+    return 1;
+  end;
+begin
+  null;
+end`,
+		`create type body tp as
+  member function m return number is begin return 1; end;
+end`,
+		`select 2 from dual`)
+}
+
+func TestSplitPositions(t *testing.T) {
+
+	stmts := checkSplit(t, "select ? from dual;\nselect ?, ? from dual",
+		`select ? from dual`,
+		`select ?, ? from dual`)
+
+	if stmts[1].Start != (Position{20, 2, 1}) || stmts[1].End != (Position{41, 2, 22}) {
+		t.Errorf("unexpected span %v - %v", stmts[1].Start, stmts[1].End)
+	}
+
+	var binds []interface{}
+	for _, tkn := range stmts[1].Tokens {
+		if tkn.TokenType == TypeBind {
+			binds = append(binds, tkn.Value)
+		}
+	}
+
+	if len(binds) != 2 || binds[0] != 1 || binds[1] != 2 {
+		t.Errorf("positional binds should restart in each statement: %v", binds)
+	}
+
+	if _, e := Split("select 1; select `2`"); e == nil {
+		t.Error("expected tokenizer error")
+	}
+}
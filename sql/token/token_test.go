@@ -2,7 +2,7 @@ package token
 
 import (
 	"fmt"
-	"math/big"
+	"github.com/djbckr/godb/sql/types"
 	"testing"
 )
 
@@ -12,8 +12,9 @@ func check(t *testing.T, p Tokens, values ...Token) {
 		switch vv := values[k].Value.(type) {
 		case string:
 			test = vv != v.Value
-		case big.Float:
-			test = vv.Cmp(v.Value.(*big.Float)) == 0
+		case *types.Number:
+			n, ok := v.Value.(*types.Number)
+			test = !ok || vv.Cmp(n) != 0
 		}
 
 		if test ||
@@ -32,10 +33,10 @@ func TestTokenize(t *testing.T) {
 	}
 
 	check(t, p,
-		Token{"SELECT", TypeToken},
-		Token{"*", TypePunctuation},
-		Token{"FROM", TypeToken},
-		Token{"DUAL", TypeToken})
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "*", TokenType: TypePunctuation},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "DUAL", TokenType: TypeToken})
 
 	p, e = Tokenize(`/* this is a comment */select[issue]from"something"where ix='fubar'`)
 
@@ -44,15 +45,15 @@ func TestTokenize(t *testing.T) {
 	}
 
 	check(t, p,
-		Token{" this is a comment ", TypeComment},
-		Token{"SELECT", TypeToken},
-		Token{"issue", TypeToken},
-		Token{"FROM", TypeToken},
-		Token{"something", TypeToken},
-		Token{"WHERE", TypeToken},
-		Token{"IX", TypeToken},
-		Token{"=", TypePunctuation},
-		Token{"fubar", TypeString})
+		Token{Value: " this is a comment ", TokenType: TypeComment},
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "issue", TokenType: TypeToken},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "something", TokenType: TypeToken},
+		Token{Value: "WHERE", TokenType: TypeToken},
+		Token{Value: "IX", TokenType: TypeToken},
+		Token{Value: "=", TokenType: TypePunctuation},
+		Token{Value: "fubar", TokenType: TypeString})
 
 	p, e = Tokenize(`/* this is a comment */select[issue]from"something"where ix=1e7`)
 
@@ -60,17 +61,17 @@ func TestTokenize(t *testing.T) {
 		t.Error(e)
 	}
 
-	n, _, _ := big.NewFloat(0).Parse("1e7", 0)
+	n, _ := types.ParseNumber("1e7")
 	check(t, p,
-		Token{" this is a comment ", TypeComment},
-		Token{"SELECT", TypeToken},
-		Token{"issue", TypeToken},
-		Token{"FROM", TypeToken},
-		Token{"something", TypeToken},
-		Token{"WHERE", TypeToken},
-		Token{"IX", TypeToken},
-		Token{"=", TypePunctuation},
-		Token{n, TypeNumber})
+		Token{Value: " this is a comment ", TokenType: TypeComment},
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "issue", TokenType: TypeToken},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "something", TokenType: TypeToken},
+		Token{Value: "WHERE", TokenType: TypeToken},
+		Token{Value: "IX", TokenType: TypeToken},
+		Token{Value: "=", TokenType: TypePunctuation},
+		Token{Value: n, TokenType: TypeNumber})
 
 	p, e = Tokenize(`  -- single line comment
 alter table "schema"."tbål" add column (colname Number, colx string) `)
@@ -80,21 +81,21 @@ alter table "schema"."tbål" add column (colname Number, colx string) `)
 	}
 
 	check(t, p,
-		Token{" single line comment", TypeComment},
-		Token{"ALTER", TypeToken},
-		Token{"TABLE", TypeToken},
-		Token{"schema", TypeToken},
-		Token{".", TypePunctuation},
-		Token{"tbål", TypeToken},
-		Token{"ADD", TypeToken},
-		Token{"COLUMN", TypeToken},
-		Token{"(", TypePunctuation},
-		Token{"COLNAME", TypeToken},
-		Token{"NUMBER", TypeToken},
-		Token{",", TypePunctuation},
-		Token{"COLX", TypeToken},
-		Token{"STRING", TypeToken},
-		Token{")", TypePunctuation}	)
+		Token{Value: " single line comment", TokenType: TypeComment},
+		Token{Value: "ALTER", TokenType: TypeToken},
+		Token{Value: "TABLE", TokenType: TypeToken},
+		Token{Value: "schema", TokenType: TypeToken},
+		Token{Value: ".", TokenType: TypePunctuation},
+		Token{Value: "tbål", TokenType: TypeToken},
+		Token{Value: "ADD", TokenType: TypeToken},
+		Token{Value: "COLUMN", TokenType: TypeToken},
+		Token{Value: "(", TokenType: TypePunctuation},
+		Token{Value: "COLNAME", TokenType: TypeToken},
+		Token{Value: "NUMBER", TokenType: TypeToken},
+		Token{Value: ",", TokenType: TypePunctuation},
+		Token{Value: "COLX", TokenType: TypeToken},
+		Token{Value: "STRING", TokenType: TypeToken},
+		Token{Value: ")", TokenType: TypePunctuation}	)
 
 	p, e = Tokenize(`with x as (select * from dual)
 select * from x`)
@@ -104,19 +105,19 @@ select * from x`)
 	}
 
 	check(t, p,
-		Token{"WITH", TypeToken},
-		Token{"X", TypeToken},
-		Token{"AS", TypeToken},
-		Token{"(", TypePunctuation},
-		Token{"SELECT", TypeToken},
-		Token{"*", TypePunctuation},
-		Token{"FROM", TypeToken},
-		Token{"DUAL", TypeToken},
-		Token{")", TypePunctuation},
-		Token{"SELECT", TypeToken},
-		Token{"*", TypePunctuation},
-		Token{"FROM", TypeToken},
-		Token{"X", TypeToken},
+		Token{Value: "WITH", TokenType: TypeToken},
+		Token{Value: "X", TokenType: TypeToken},
+		Token{Value: "AS", TokenType: TypeToken},
+		Token{Value: "(", TokenType: TypePunctuation},
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "*", TokenType: TypePunctuation},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "DUAL", TokenType: TypeToken},
+		Token{Value: ")", TokenType: TypePunctuation},
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "*", TokenType: TypePunctuation},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "X", TokenType: TypeToken},
 		)
 
 	p, e = Tokenize(`$$
@@ -127,11 +128,11 @@ $$`)
 		t.Error(e)
 	}
 
-	check(t, p, Token{"\nbegin do something end;\n", TypeString})
+	check(t, p, Token{Value: "\nbegin do something end;\n", TokenType: TypeString})
 
 	p, e = Tokenize("`This is a test string`")
 
-	if e == nil || e.Error() != "Invalid SQL text found near: `This is a test string`" {
+	if e == nil || e.Error() != "Invalid SQL text found near: `This is a test string` (line 1, column 1)" {
 		t.Error(e)
 	}
 
@@ -142,7 +143,7 @@ $$`)
 		t.Error(e)
 	}
 
-	check(t, p, Token{"", TypeString})
+	check(t, p, Token{Value: "", TokenType: TypeString})
 
 	p, e = Tokenize(`$tag$xyz$tag$`)
 
@@ -150,7 +151,7 @@ $$`)
 		t.Error(e)
 	}
 
-	check(t, p, Token{"xyz", TypeString})
+	check(t, p, Token{Value: "xyz", TokenType: TypeString})
 
 	p, e = Tokenize(`$⌘$†π¬˚$⌘$`)
 
@@ -158,7 +159,7 @@ $$`)
 		t.Error(e)
 	}
 
-	check(t, p, Token{"†π¬˚", TypeString})
+	check(t, p, Token{Value: "†π¬˚", TokenType: TypeString})
 
 
 	p, e = Tokenize(`create table [foo$$]
@@ -169,24 +170,24 @@ $$`)
 		t.Error(e)
 	}
 
-	n1, _, _ := big.NewFloat(0).Parse("33", 0)
-	n2, _, _ := big.NewFloat(0).Parse("23", 0)
+	n1, _ := types.ParseNumber("33")
+	n2, _ := types.ParseNumber("23")
 	check(t, p,
-		Token{"CREATE",TypeToken},
-		Token{"TABLE",TypeToken},
-		Token{"foo$$",TypeToken},
-		Token{"(",TypePunctuation},
-		Token{"COL1",TypeToken},
-		Token{"NUMBER",TypeToken},
-		Token{",",TypePunctuation},
-		Token{"COL2",TypeToken},
-		Token{"VARCHAR",TypeToken},
-		Token{"(",TypePunctuation},
-		Token{n1,TypeNumber},
-		Token{",",TypePunctuation},
-		Token{n2,TypeNumber},
-		Token{")",TypePunctuation},
-		Token{")",TypePunctuation}	)
+		Token{Value: "CREATE", TokenType: TypeToken},
+		Token{Value: "TABLE", TokenType: TypeToken},
+		Token{Value: "foo$$", TokenType: TypeToken},
+		Token{Value: "(", TokenType: TypePunctuation},
+		Token{Value: "COL1", TokenType: TypeToken},
+		Token{Value: "NUMBER", TokenType: TypeToken},
+		Token{Value: ",", TokenType: TypePunctuation},
+		Token{Value: "COL2", TokenType: TypeToken},
+		Token{Value: "VARCHAR", TokenType: TypeToken},
+		Token{Value: "(", TokenType: TypePunctuation},
+		Token{Value: n1, TokenType: TypeNumber},
+		Token{Value: ",", TokenType: TypePunctuation},
+		Token{Value: n2, TokenType: TypeNumber},
+		Token{Value: ")", TokenType: TypePunctuation},
+		Token{Value: ")", TokenType: TypePunctuation}	)
 
 	p, e = Tokenize(`$$begin do something end;$$`)
 
@@ -194,7 +195,7 @@ $$`)
 		t.Error(e)
 	}
 
-	check(t, p, Token{"begin do something end;", TypeString})
+	check(t, p, Token{Value: "begin do something end;", TokenType: TypeString})
 
 	p, e = Tokenize(`select * from something where x = q'[mary's horse]' foo bar`)
 
@@ -203,16 +204,16 @@ $$`)
 	}
 
 	check(t, p,
-		Token{"SELECT", TypeToken},
-		Token{"*", TypePunctuation},
-		Token{"FROM", TypeToken},
-		Token{"SOMETHING", TypeToken},
-		Token{"WHERE", TypeToken},
-		Token{"X", TypeToken},
-		Token{"=", TypePunctuation},
-		Token{"mary's horse", TypeString},
-		Token{"FOO", TypeToken},
-		Token{"BAR", TypeToken},
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "*", TokenType: TypePunctuation},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "SOMETHING", TokenType: TypeToken},
+		Token{Value: "WHERE", TokenType: TypeToken},
+		Token{Value: "X", TokenType: TypeToken},
+		Token{Value: "=", TokenType: TypePunctuation},
+		Token{Value: "mary's horse", TokenType: TypeString},
+		Token{Value: "FOO", TokenType: TypeToken},
+		Token{Value: "BAR", TokenType: TypeToken},
 		)
 
 	p, e = Tokenize(`select * from something where x = q'[mary's horse]'`)
@@ -222,14 +223,14 @@ $$`)
 	}
 
 	check(t, p,
-		Token{"SELECT", TypeToken},
-		Token{"*", TypePunctuation},
-		Token{"FROM", TypeToken},
-		Token{"SOMETHING", TypeToken},
-		Token{"WHERE", TypeToken},
-		Token{"X", TypeToken},
-		Token{"=", TypePunctuation},
-		Token{"mary's horse", TypeString},
+		Token{Value: "SELECT", TokenType: TypeToken},
+		Token{Value: "*", TokenType: TypePunctuation},
+		Token{Value: "FROM", TokenType: TypeToken},
+		Token{Value: "SOMETHING", TokenType: TypeToken},
+		Token{Value: "WHERE", TokenType: TypeToken},
+		Token{Value: "X", TokenType: TypeToken},
+		Token{Value: "=", TokenType: TypePunctuation},
+		Token{Value: "mary's horse", TokenType: TypeString},
 	)
 
 	p, e = Tokenize(`q'[]'`)
@@ -238,6 +239,127 @@ $$`)
 		t.Error(e)
 	}
 
-	check(t, p, Token{"", TypeString})
+	check(t, p, Token{Value: "", TokenType: TypeString})
 
 }
+
+func TestPositions(t *testing.T) {
+
+	p, e := Tokenize("select a,\n  'x''y' /* c */\n\tfrom [tbl] -- done")
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	expected := []struct {
+		start, end Position
+	}{
+		{Position{0, 1, 1}, Position{6, 1, 7}},     // select
+		{Position{7, 1, 8}, Position{8, 1, 9}},     // a
+		{Position{8, 1, 9}, Position{9, 1, 10}},    // ,
+		{Position{12, 2, 3}, Position{15, 2, 6}},   // 'x'
+		{Position{15, 2, 6}, Position{18, 2, 9}},   // 'y'
+		{Position{19, 2, 10}, Position{26, 2, 17}}, // /* c */
+		{Position{28, 3, 2}, Position{32, 3, 6}},   // from
+		{Position{33, 3, 7}, Position{38, 3, 12}},  // [tbl]
+		{Position{39, 3, 13}, Position{46, 3, 20}}, // -- done
+	}
+
+	if len(p) != len(expected) {
+		t.Fatalf("expected %v tokens, got %v", len(expected), len(p))
+	}
+
+	for k, v := range expected {
+		if p[k].Start != v.start || p[k].End != v.end {
+			t.Errorf("token %v (%v): expected %v-%v, got %v-%v", k, p[k].Value, v.start, v.end, p[k].Start, p[k].End)
+		}
+	}
+
+	p, e = Tokenize("select †\n  from dual\n where x = `1`")
+
+	if e == nil || e.Error() != "Invalid SQL text found near: `1` (line 3, column 12)" {
+		t.Error(e)
+	}
+}
+
+func TestBinds(t *testing.T) {
+
+	p, e := Tokenize(`select * from t where updated >= :updated and x = :1 and y=?and z = ? || ':no'`)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var binds []interface{}
+	for _, tkn := range p {
+		if tkn.TokenType == TypeBind {
+			binds = append(binds, tkn.Value)
+		}
+	}
+
+	if fmt.Sprint(binds) != "[updated 1 1 2]" {
+		t.Errorf("unexpected binds %v", binds)
+	}
+
+	if _, ok := binds[1].(string); !ok {
+		t.Error(":1 should be a named bind")
+	}
+	if _, ok := binds[2].(int); !ok {
+		t.Error("? should be a positional bind")
+	}
+
+	p, e = Tokenize(`a : b`)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	check(t, p,
+		Token{Value: "A", TokenType: TypeToken},
+		Token{Value: ":", TokenType: TypePunctuation},
+		Token{Value: "B", TokenType: TypeToken})
+}
+
+func TestOperators(t *testing.T) {
+
+	p, e := Tokenize(`a<=b >= c<>d != e ^= f||g := h => i ** j 1..10 < = x.y`)
+
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	n1, _ := types.ParseNumber("1")
+	n10, _ := types.ParseNumber("10")
+	check(t, p,
+		Token{Value: "A", TokenType: TypeToken},
+		Token{Value: "<=", TokenType: TypeOperator},
+		Token{Value: "B", TokenType: TypeToken},
+		Token{Value: ">=", TokenType: TypeOperator},
+		Token{Value: "C", TokenType: TypeToken},
+		Token{Value: "<>", TokenType: TypeOperator},
+		Token{Value: "D", TokenType: TypeToken},
+		Token{Value: "!=", TokenType: TypeOperator},
+		Token{Value: "E", TokenType: TypeToken},
+		Token{Value: "^=", TokenType: TypeOperator},
+		Token{Value: "F", TokenType: TypeToken},
+		Token{Value: "||", TokenType: TypeOperator},
+		Token{Value: "G", TokenType: TypeToken},
+		Token{Value: ":=", TokenType: TypeOperator},
+		Token{Value: "H", TokenType: TypeToken},
+		Token{Value: "=>", TokenType: TypeOperator},
+		Token{Value: "I", TokenType: TypeToken},
+		Token{Value: "**", TokenType: TypeOperator},
+		Token{Value: "J", TokenType: TypeToken},
+		Token{Value: n1, TokenType: TypeNumber},
+		Token{Value: "..", TokenType: TypeOperator},
+		Token{Value: n10, TokenType: TypeNumber},
+		Token{Value: "<", TokenType: TypePunctuation},
+		Token{Value: "=", TokenType: TypePunctuation},
+		Token{Value: "X", TokenType: TypeToken},
+		Token{Value: ".", TokenType: TypePunctuation},
+		Token{Value: "Y", TokenType: TypeToken})
+
+	if len(p) != 27 {
+		t.Errorf("expected 27 tokens, got %v", len(p))
+	}
+}
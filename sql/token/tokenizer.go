@@ -1,10 +1,9 @@
 package token
 
 import (
-	"errors"
 	"fmt"
+	"github.com/djbckr/godb/sql/types"
 	"io"
-	"math/big"
 	"regexp"
 	"strings"
 )
@@ -56,21 +55,40 @@ const (
 	TypeNumber
 	TypeComment
 	TypeHint
+	TypeOperator
+	TypeBind // Value is the name of a :name bind (string), or the 1-based position of a ? bind (int)
 )
 
+// Position is a location in the SQL text.
+type Position struct {
+	Offset int // byte offset from the start of the text
+	Line   int // starting at 1
+	Column int // starting at 1, counted in characters
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("line %v, column %v", p.Line, p.Column)
+}
+
 type Token struct {
-	Value     interface{}
+	Value     interface{} // string, or *types.Number for TypeNumber, or int for a positional TypeBind
 	TokenType int
+	Start     Position // the first character of the token, including quotes and comment markers
+	End       Position // just past the last character of the token
+	Quoted    bool     // a TypeToken written as "name" or [name]; it is never a keyword
 }
 
 type Tokens = []*Token
 
 type tokenizer struct {
-	idx    int    // current string pointer
-	maxIdx int    // total length of string - 1
-	chars  string // the unchanged string
-	slice  string // points to string[idx:]
-	tokens Tokens // result tokens
+	idx    int      // current string pointer
+	maxIdx int      // total length of string - 1
+	chars  string   // the unchanged string
+	base   int      // offset of chars in the whole text; only a Scanner moves it
+	slice  string   // points to string[idx:]
+	tokens Tokens   // result tokens
+	pos    Position // the position last handed out; positions are only ever asked for in order
+	binds  int      // number of positional binds seen so far
 }
 
 func Tokenize(sql string) (parsed Tokens, err error) {
@@ -79,43 +97,67 @@ func Tokenize(sql string) (parsed Tokens, err error) {
 		idx:    0,
 		chars:  sql,
 		maxIdx: len(sql) - 1,
+		pos:    Position{Line: 1, Column: 1},
 	}
 
-	for tdata.idx < tdata.maxIdx {
+	for tdata.idx <= tdata.maxIdx {
 
 		tdata.slice = tdata.chars[tdata.idx:]
-		switch {
-		case processRE(&tdata, lineHintRE, TypeHint, false):
-		case processRE(&tdata, blockHintRE, TypeHint, false):
-		case processRE(&tdata, lineCommentRE, TypeComment, false):
-		case processRE(&tdata, blockCommentRE, TypeComment, false):
-		case captureString(&tdata, captureQString):
-		case captureString(&tdata, captureDollarString):
-		case captureString(&tdata, captureSingleQuote):
-		case processRE(&tdata, tokenRE, TypeToken, true):
-		case processRE(&tdata, doubleQuoteRE, TypeToken, false):
-		case processRE(&tdata, brackQuoteRE, TypeToken, false):
-		case processNumber(&tdata):
-		case processPunctuation(&tdata):
-		default:
-			if len(tdata.slice) > 30 {
-				tdata.slice = tdata.slice[:30]
-			}
-			return nil, errors.New(fmt.Sprintf("Invalid SQL text found near: %v", tdata.slice))
+
+		// only trailing white space is left
+		if strings.TrimSpace(tdata.slice) == "" {
+			break
+		}
+
+		if !tdata.scan() {
+			return nil, tdata.invalid()
 		}
 	}
 
 	return tdata.tokens, nil
 }
 
+// scan appends the token at the start of the slice, and reports whether one was found.
+func (tdata *tokenizer) scan() bool {
+	switch {
+	case processRE(tdata, lineHintRE, TypeHint, false):
+	case processRE(tdata, blockHintRE, TypeHint, false):
+	case processRE(tdata, lineCommentRE, TypeComment, false):
+	case processRE(tdata, blockCommentRE, TypeComment, false):
+	case captureString(tdata, captureQString):
+	case captureString(tdata, captureDollarString):
+	case captureString(tdata, captureSingleQuote):
+	case processRE(tdata, tokenRE, TypeToken, true):
+	case processQuoted(tdata, doubleQuoteRE):
+	case processQuoted(tdata, brackQuoteRE):
+	case processOperator(tdata):
+	case processBind(tdata):
+	case processNumber(tdata):
+	case processPunctuation(tdata):
+	default:
+		return false
+	}
+	return true
+}
+
+// invalid builds the error for text at the start of the slice that is not a token.
+func (tdata *tokenizer) invalid() error {
+	start := tdata.position(tdata.idx + leadingSpace(tdata.slice))
+	near := strings.TrimSpace(tdata.slice)
+	if len(near) > 30 {
+		near = near[:30]
+	}
+	return fmt.Errorf("Invalid SQL text found near: %v (%v)", near, start)
+}
+
 // In all below RE's, skip leading space; makes it easy to skip white space where needed
 
-// find /*+ capture this */ ; . includes newline
-var blockHintRE = regexp.MustCompile(`(?s)^[[:space:]]*?/\*\+(.*)\*/`)
+// find /*+ capture this */ ; . includes newline; stop at the first */
+var blockHintRE = regexp.MustCompile(`(?s)^[[:space:]]*?/\*\+(.*?)\*/`)
 // find --+ capture this to end of line
 var lineHintRE = regexp.MustCompile(`(?s)^[[:space:]]*--\+([ \t\S]*)(?:[\n\r])?`)
-// find /* capture this */ ; . includes newline
-var blockCommentRE = regexp.MustCompile(`(?s)^[[:space:]]*?/\*(.*)\*/`)
+// find /* capture this */ ; . includes newline; stop at the first */
+var blockCommentRE = regexp.MustCompile(`(?s)^[[:space:]]*?/\*(.*?)\*/`)
 // find -- capture this to end of line
 var lineCommentRE = regexp.MustCompile(`(?s)^[[:space:]]*--([ \t\S]*)(?:[\n\r])?`)
 // Unquoted (double, bracket) tokens are case-insensitive: first letter may be $, A-Z, and any unicode character
@@ -137,27 +179,63 @@ func processRE(tdata *tokenizer, re *regexp.Regexp, typ int, upper bool) bool {
 		if upper {
 			str = strings.ToUpper(str)
 		}
-		tdata.tokens = append(tdata.tokens, &Token{
-			Value:     str,
-			TokenType: typ,
-		})
-		tdata.idx += rslt[1]
+		tdata.emit(str, typ, rslt[1])
 		return true
 	}
 
 	return false
 }
 
+// processQuoted takes a "name" or [name] token, which keeps its case and is never a keyword.
+func processQuoted(tdata *tokenizer, re *regexp.Regexp) bool {
+	if !processRE(tdata, re, TypeToken, false) {
+		return false
+	}
+	tdata.tokens[len(tdata.tokens)-1].Quoted = true
+	return true
+}
+
+// emit appends a token whose text, including any leading white space, is the first n bytes of the slice.
+func (tdata *tokenizer) emit(value interface{}, typ int, n int) {
+	tdata.tokens = append(tdata.tokens, &Token{
+		Value:     value,
+		TokenType: typ,
+		Start:     tdata.position(tdata.idx + leadingSpace(tdata.slice[:n])),
+		End:       tdata.position(tdata.idx + n),
+	})
+	tdata.idx += n
+}
+
+// position converts an offset into chars to a Position by counting lines and characters from the
+// last position handed out.
+func (tdata *tokenizer) position(offset int) Position {
+	for _, c := range tdata.chars[tdata.pos.Offset-tdata.base : offset] {
+		if c == newLine {
+			tdata.pos.Line++
+			tdata.pos.Column = 1
+		} else {
+			tdata.pos.Column++
+		}
+	}
+	tdata.pos.Offset = tdata.base + offset
+	return tdata.pos
+}
+
+// leadingSpace counts the white space bytes at the start of str, as matched by [[:space:]]
+func leadingSpace(str string) int {
+	i := 0
+	for i < len(str) && strings.IndexByte(" \t\n\v\f\r", str[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
 type specStrFn = func(string) *stringCaptureResult
 
 func captureString(tdata *tokenizer, fn specStrFn) bool {
 	rslt := fn(tdata.slice)
 	if rslt != nil {
-		tdata.tokens = append(tdata.tokens, &Token{
-			Value:     rslt.contents,
-			TokenType: TypeString,
-		})
-		tdata.idx += rslt.end
+		tdata.emit(rslt.contents, TypeString, rslt.end)
 		return true
 	}
 	return false
@@ -178,18 +256,55 @@ func processPunctuation(tdata *tokenizer) bool {
 		char == amp || char == caret || char == percent || char == pound || char == atSign ||
 		char == bang || char == tilde || char == pipe || char == backslash || char == colon ||
 		char == semicolon || char == comma || char == period {
-		tdata.tokens = append(tdata.tokens, &Token{
-			Value:     string(char),
-			TokenType: TypePunctuation,
-		})
-		tdata.idx += matches[3]
+		tdata.emit(string(char), TypePunctuation, matches[3])
 		return true
 	}
 
 	return false
 }
 
-var validNumRE = regexp.MustCompile(`(?i)^[[:space:]]*((?:[-+0-9]|inf)[-+._0-9a-z]*)`)
+// Compound operators; single character operators are punctuation. The characters must be adjacent:
+// "< =" is two punctuation tokens, not the <= operator.
+var operatorRE = regexp.MustCompile(`^[[:space:]]*(<=|>=|<>|!=|\^=|\|\||:=|=>|\*\*|\.\.)`)
+
+func processOperator(tdata *tokenizer) bool {
+
+	matches := operatorRE.FindStringSubmatchIndex(tdata.slice)
+
+	if matches == nil {
+		return false
+	}
+
+	tdata.emit(tdata.slice[matches[2]:matches[3]], TypeOperator, matches[1])
+
+	return true
+}
+
+// A named bind is a colon immediately followed by a name or a number: :updated or :1
+var namedBindRE = regexp.MustCompile(`(?i)^[[:space:]]*:([$_A-Z0-9\x{0080}-\x{FFEE}]+)`)
+
+// A positional bind is a question mark
+var positionalBindRE = regexp.MustCompile(`^[[:space:]]*\?`)
+
+func processBind(tdata *tokenizer) bool {
+
+	if matches := namedBindRE.FindStringSubmatchIndex(tdata.slice); matches != nil {
+		tdata.emit(tdata.slice[matches[2]:matches[3]], TypeBind, matches[1])
+		return true
+	}
+
+	if matches := positionalBindRE.FindStringIndex(tdata.slice); matches != nil {
+		tdata.binds++
+		tdata.emit(tdata.binds, TypeBind, matches[1])
+		return true
+	}
+
+	return false
+}
+
+// A number never includes a sign; a leading + or - is an operator. Otherwise "x-1" would tokenize
+// as x followed by the number -1.
+var validNumRE = regexp.MustCompile(`(?i)^[[:space:]]*(0x[0-9a-f_]+|(?:[0-9][_0-9]*(?:\.[_0-9]*)?|\.[0-9][_0-9]*)(?:e[-+]?[0-9]+)?)`)
 
 func processNumber(tdata *tokenizer) bool {
 
@@ -199,18 +314,21 @@ func processNumber(tdata *tokenizer) bool {
 		return false
 	}
 
-	ss := tdata.slice[matches[2]:matches[3]]
-	n, _, e := big.NewFloat(0).Parse(ss, 0)
+	end := matches[3]
+
+	// in 1..10 the first period belongs to the .. operator
+	if strings.HasSuffix(tdata.slice[:end], ".") && strings.HasPrefix(tdata.slice[end:], ".") {
+		end--
+	}
+
+	ss := tdata.slice[matches[2]:end]
+	n, e := types.ParseNumber(ss)
 
 	if e != nil {
 		return false
 	}
 
-	tdata.tokens = append(tdata.tokens, &Token{
-		Value:     n,
-		TokenType: TypeNumber,
-	})
-	tdata.idx += matches[3]
+	tdata.emit(n, TypeNumber, end)
 
 	return true
 }
@@ -378,8 +496,18 @@ var reDollarTag = regexp.MustCompile(`(?mU)\$([^-$[:space:][:cntrl:]]*)\$`)
 
 func captureDollarString(str string) *stringCaptureResult {
 
+	// the opening tag must come first; otherwise a $$ later in the text would swallow everything before it
+	start := leadingSpace(str)
+	if start == len(str) || str[start] != dollar {
+		return nil
+	}
+
 	rslt := reDollarTag.FindAllStringSubmatchIndex(str, -1)
 
+	if rslt != nil && rslt[0][0] != start {
+		return nil
+	}
+
 	if rslt == nil {
 		return nil
 	}
@@ -0,0 +1,421 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxDigits is the most digits a Number may have on either side of the decimal point.
+const MaxDigits = 64000
+
+// Number is an exact decimal number: an integer scaled by a power of ten. Unlike a float it
+// holds 0.1 exactly. The scale, the number of digits after the decimal point, is kept as written,
+// so 1.50 prints as 1.50. The zero value is 0. A Number is never changed once made; every
+// operation returns a new one.
+type Number struct {
+	unscaled big.Int
+	scale    int
+}
+
+// RoundingMode says which way to go when digits are dropped.
+type RoundingMode = int
+
+const (
+	HalfUp   RoundingMode = iota // to nearest, ties away from zero; what NUMBER(p,s) columns do
+	HalfEven                     // to nearest, ties to the even neighbour
+	HalfDown                     // to nearest, ties toward zero
+	Up                           // away from zero
+	Down                         // toward zero; truncation
+	Ceiling                      // toward positive infinity
+	Floor                        // toward negative infinity
+)
+
+var ErrDivisionByZero = errors.New("division by zero")
+var ErrOverflow = fmt.Errorf("numeric overflow: more than %v digits before the decimal point", MaxDigits)
+
+// NewNumber returns the whole number n.
+func NewNumber(n int64) *Number {
+	x := &Number{}
+	x.unscaled.SetInt64(n)
+	return x
+}
+
+// NewScaledNumber returns unscaled / 10^scale, as Unscaled and Scale give it back. A negative scale
+// adds zeros to the right.
+func NewScaledNumber(unscaled *big.Int, scale int) (*Number, error) {
+	if scale > 2*MaxDigits || scale < -2*MaxDigits {
+		return nil, ErrOverflow
+	}
+	x := &Number{scale: scale}
+	x.unscaled.Set(unscaled)
+	return x.normalize().fit()
+}
+
+// ParseNumber reads a decimal number such as -12.50, .5, 1e-7 or 1_000, or a hexadecimal whole
+// number such as 0x1F. Underscores between digits are ignored.
+func ParseNumber(text string) (*Number, error) {
+
+	invalid := fmt.Errorf("invalid number: %v", text)
+
+	s := strings.ReplaceAll(text, "_", "")
+
+	negative := false
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	x := &Number{}
+
+	if len(s) > 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		if _, ok := x.unscaled.SetString(s[2:], 16); !ok {
+			return nil, invalid
+		}
+	} else {
+		mantissa, exponent := s, 0
+		if e := strings.IndexAny(s, "eE"); e >= 0 {
+			mantissa = s[:e]
+			var err error
+			if exponent, err = strconv.Atoi(s[e+1:]); err != nil {
+				return nil, invalid
+			}
+			if exponent > 2*MaxDigits || exponent < -2*MaxDigits {
+				return nil, ErrOverflow
+			}
+		}
+
+		whole, fraction := mantissa, ""
+		if p := strings.IndexByte(mantissa, '.'); p >= 0 {
+			whole, fraction = mantissa[:p], mantissa[p+1:]
+		}
+
+		digits := whole + fraction
+		if digits == "" || strings.Trim(digits, "0123456789") != "" {
+			return nil, invalid
+		}
+
+		x.unscaled.SetString(digits, 10)
+		x.scale = len(fraction) - exponent
+
+		if x.scale < 0 {
+			x.unscaled.Mul(&x.unscaled, pow10(-x.scale))
+			x.scale = 0
+		}
+	}
+
+	if negative {
+		x.unscaled.Neg(&x.unscaled)
+	}
+
+	return x.fit()
+}
+
+// Scale is the number of digits after the decimal point.
+func (x *Number) Scale() int {
+	return x.scale
+}
+
+// Unscaled is x without its decimal point: x is Unscaled / 10^Scale.
+func (x *Number) Unscaled() *big.Int {
+	return new(big.Int).Set(&x.unscaled)
+}
+
+// Precision is the number of significant digits, not counting leading zeros. 0 has precision 1.
+func (x *Number) Precision() int {
+	return digits(&x.unscaled)
+}
+
+// Sign returns -1, 0 or 1.
+func (x *Number) Sign() int {
+	return x.unscaled.Sign()
+}
+
+// IsInt reports whether x has no fraction, whatever its scale.
+func (x *Number) IsInt() bool {
+	if x.scale == 0 {
+		return true
+	}
+	r := new(big.Int).Rem(&x.unscaled, pow10(x.scale))
+	return r.Sign() == 0
+}
+
+// Int64 returns x as an int64, if x is a whole number that fits.
+func (x *Number) Int64() (int64, bool) {
+	if !x.IsInt() {
+		return 0, false
+	}
+	n := new(big.Int).Quo(&x.unscaled, pow10(x.scale))
+	if !n.IsInt64() {
+		return 0, false
+	}
+	return n.Int64(), true
+}
+
+// Float64 returns the float64 closest to x.
+func (x *Number) Float64() float64 {
+	f, _ := new(big.Float).SetPrec(64).SetString(x.String())
+	v, _ := f.Float64()
+	return v
+}
+
+// Cmp compares values, ignoring scale: 1.5 and 1.50 are equal.
+func (x *Number) Cmp(y *Number) int {
+	a, b := align(x, y)
+	return a.Cmp(b)
+}
+
+func (x *Number) Neg() *Number {
+	z := &Number{scale: x.scale}
+	z.unscaled.Neg(&x.unscaled)
+	return z
+}
+
+func (x *Number) Abs() *Number {
+	z := &Number{scale: x.scale}
+	z.unscaled.Abs(&x.unscaled)
+	return z
+}
+
+// Add returns x + y, with the larger of the two scales.
+func (x *Number) Add(y *Number) (*Number, error) {
+	a, b := align(x, y)
+	z := &Number{scale: maxInt(x.scale, y.scale)}
+	z.unscaled.Add(a, b)
+	return z.fit()
+}
+
+// Sub returns x - y, with the larger of the two scales.
+func (x *Number) Sub(y *Number) (*Number, error) {
+	a, b := align(x, y)
+	z := &Number{scale: maxInt(x.scale, y.scale)}
+	z.unscaled.Sub(a, b)
+	return z.fit()
+}
+
+// Mul returns x * y. The scale is the sum of the scales, as long as that is no more than MaxDigits.
+func (x *Number) Mul(y *Number) (*Number, error) {
+	z := &Number{scale: x.scale + y.scale}
+	z.unscaled.Mul(&x.unscaled, &y.unscaled)
+	return z.fit()
+}
+
+// Quo returns x / y rounded to the given scale.
+func (x *Number) Quo(y *Number, scale int, mode RoundingMode) (*Number, error) {
+	if y.Sign() == 0 {
+		return nil, ErrDivisionByZero
+	}
+	if scale > MaxDigits {
+		scale = MaxDigits
+	}
+
+	// x/y at the given scale is (x.unscaled * 10^shift) / y.unscaled
+	num := new(big.Int).Set(&x.unscaled)
+	den := new(big.Int).Set(&y.unscaled)
+	shift := scale + y.scale - x.scale
+	if shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+
+	z := &Number{scale: scale}
+	z.unscaled.Set(roundQuo(num, den, mode))
+	return z.normalize().fit()
+}
+
+// Round returns x with the given number of digits after the decimal point, adding zeros or
+// dropping digits as needed. A negative scale rounds to the left of the decimal point: 1234.5
+// rounded to scale -2 is 1200.
+func (x *Number) Round(scale int, mode RoundingMode) *Number {
+	if scale >= x.scale {
+		z := &Number{scale: scale}
+		z.unscaled.Mul(&x.unscaled, pow10(scale-x.scale))
+		return z
+	}
+
+	z := &Number{scale: scale}
+	z.unscaled.Set(roundQuo(&x.unscaled, pow10(x.scale-scale), mode))
+	return z.normalize()
+}
+
+// Constrain fits x to a NUMBER(precision, scale) column: it rounds half up to the scale, then
+// fails if more than precision - scale digits are left before the decimal point. A scale larger
+// than the precision leaves no digits before the point, and needs scale - precision zeros right
+// after it: NUMBER(2, 5) holds 0.00012 but not 0.0012. A precision of 0 means any number of
+// digits, as in a plain NUMBER column.
+func (x *Number) Constrain(precision, scale int) (*Number, error) {
+	z := x.Round(scale, HalfUp)
+
+	if precision > 0 && z.Sign() != 0 {
+		tooLarge := z.integerDigits() > precision-scale
+		if scale > precision {
+			// z has exactly scale digits after the point, so those not taken by its own digits are zeros
+			tooLarge = z.integerDigits() > 0 || z.scale-digits(&z.unscaled) < scale-precision
+		}
+		if tooLarge {
+			return nil, fmt.Errorf("value %v is too large for NUMBER(%v, %v)", x, precision, scale)
+		}
+	}
+
+	return z, nil
+}
+
+// String returns x in plain decimal notation, which ParseNumber reads back to the same value and scale.
+func (x *Number) String() string {
+	s := new(big.Int).Abs(&x.unscaled).Text(10)
+
+	if x.scale > 0 {
+		if len(s) <= x.scale {
+			s = strings.Repeat("0", x.scale-len(s)+1) + s
+		}
+		s = s[:len(s)-x.scale] + "." + s[len(s)-x.scale:]
+	}
+
+	if x.unscaled.Sign() < 0 {
+		return "-" + s
+	}
+	return s
+}
+
+// MarshalText writes x as String does; XML output uses it.
+func (x *Number) MarshalText() ([]byte, error) {
+	return []byte(x.String()), nil
+}
+
+func (x *Number) UnmarshalText(text []byte) error {
+	y, err := ParseNumber(string(text))
+	if err != nil {
+		return err
+	}
+	*x = *y
+	return nil
+}
+
+// MarshalJSON writes x as a JSON number with every digit, rather than rounding it to a float.
+func (x *Number) MarshalJSON() ([]byte, error) {
+	return x.MarshalText()
+}
+
+// UnmarshalJSON reads a JSON number, or a string holding one.
+func (x *Number) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if len(text) >= 2 && text[0] == '"' && text[len(text)-1] == '"' {
+		text = text[1 : len(text)-1]
+	}
+	return x.UnmarshalText([]byte(text))
+}
+
+// normalize turns a negative scale, left by rounding to the left of the decimal point, into trailing zeros.
+func (x *Number) normalize() *Number {
+	if x.scale < 0 {
+		x.unscaled.Mul(&x.unscaled, pow10(-x.scale))
+		x.scale = 0
+	}
+	return x
+}
+
+// fit rounds away digits past MaxDigits after the decimal point, and fails if there are more than
+// MaxDigits before it.
+func (x *Number) fit() (*Number, error) {
+	if x.scale > MaxDigits {
+		x = x.Round(MaxDigits, HalfUp)
+	}
+	if x.integerDigits() > MaxDigits {
+		return nil, ErrOverflow
+	}
+	return x, nil
+}
+
+// integerDigits counts the digits before the decimal point.
+func (x *Number) integerDigits() int {
+	// quick check: a number of n bits has at most n * log10(2) + 1 digits
+	if int(float64(x.unscaled.BitLen())*math.Log10(2))+1 <= x.scale {
+		return 0
+	}
+	return maxInt(digits(&x.unscaled)-x.scale, 0)
+}
+
+// align returns the unscaled values of x and y brought to the same scale.
+func align(x, y *Number) (*big.Int, *big.Int) {
+	switch {
+	case x.scale < y.scale:
+		return new(big.Int).Mul(&x.unscaled, pow10(y.scale-x.scale)), &y.unscaled
+	case x.scale > y.scale:
+		return &x.unscaled, new(big.Int).Mul(&y.unscaled, pow10(x.scale-y.scale))
+	}
+	return &x.unscaled, &y.unscaled
+}
+
+// roundQuo divides num by den, rounding the quotient to a whole number.
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+
+	sign := num.Sign() * den.Sign()
+
+	// compare the remainder with half the divisor
+	twice := new(big.Int).Abs(r)
+	half := twice.Lsh(twice, 1).Cmp(new(big.Int).Abs(den))
+
+	var away bool
+	switch mode {
+	case HalfUp:
+		away = half >= 0
+	case HalfEven:
+		away = half > 0 || (half == 0 && q.Bit(0) == 1)
+	case HalfDown:
+		away = half > 0
+	case Up:
+		away = true
+	case Down:
+		away = false
+	case Ceiling:
+		away = sign > 0
+	case Floor:
+		away = sign < 0
+	}
+
+	if away {
+		q.Add(q, big.NewInt(int64(sign)))
+	}
+	return q
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// digits counts the decimal digits of i; 0 has one digit.
+func digits(i *big.Int) int {
+	if i.Sign() == 0 {
+		return 1
+	}
+	return len(new(big.Int).Abs(i).Text(10))
+}
+
+var smallPowers [20]*big.Int
+
+func init() {
+	p := big.NewInt(1)
+	for i := range smallPowers {
+		smallPowers[i] = new(big.Int).Set(p)
+		p.Mul(p, big.NewInt(10))
+	}
+}
+
+// pow10 returns 10^n; the result must not be changed.
+func pow10(n int) *big.Int {
+	if n < len(smallPowers) {
+		return smallPowers[n]
+	}
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
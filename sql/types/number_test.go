@@ -0,0 +1,225 @@
+package types
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func number(t *testing.T, text string) *Number {
+	n, e := ParseNumber(text)
+	if e != nil {
+		t.Fatalf("%v: %v", text, e)
+	}
+	return n
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected string
+		scale    int
+	}{
+		{"0", "0", 0},
+		{"0.1", "0.1", 1},
+		{"-12.50", "-12.50", 2},
+		{".5", "0.5", 1},
+		{"5.", "5", 0},
+		{"+7", "7", 0},
+		{"1e7", "10000000", 0},
+		{"1.5E-3", "0.0015", 4},
+		{"12.5e1", "125", 0},
+		{"1_000.000_1", "1000.0001", 4},
+		{"0x1F", "31", 0},
+		{"123456789012345678901234567890.123456789012345678901234567890", "123456789012345678901234567890.123456789012345678901234567890", 30},
+	}
+
+	for _, test := range tests {
+		n := number(t, test.text)
+		if n.String() != test.expected || n.Scale() != test.scale {
+			t.Errorf("%v: expected %v scale %v, got %v scale %v", test.text, test.expected, test.scale, n, n.Scale())
+		}
+		// the text form reads back to the same number
+		if again := number(t, n.String()); again.String() != n.String() || again.Scale() != n.Scale() {
+			t.Errorf("%v does not round-trip: %v", n, again)
+		}
+	}
+
+	for _, text := range []string{"", ".", "1.2.3", "1e", "1ex", "abc", "0x", "0xg", "--1"} {
+		if _, e := ParseNumber(text); e == nil {
+			t.Errorf("expected error: %q", text)
+		}
+	}
+
+	if _, e := ParseNumber("1e64001"); e != ErrOverflow {
+		t.Errorf("expected overflow, got %v", e)
+	}
+	if n := number(t, "1e-64001"); n.Sign() != 0 || n.Scale() != MaxDigits {
+		t.Errorf("expected digits past MaxDigits to be rounded away, got scale %v", n.Scale())
+	}
+}
+
+func TestScaledNumber(t *testing.T) {
+	for _, text := range []string{"0", "-12.50", "0.0015", "123456789012345678901234567890.123456789012345678901234567890"} {
+		n := number(t, text)
+		if m, e := NewScaledNumber(n.Unscaled(), n.Scale()); e != nil || m.String() != text || m.Scale() != n.Scale() {
+			t.Errorf("%v: got %v, %v", text, m, e)
+		}
+	}
+
+	if n, e := NewScaledNumber(big.NewInt(-15), -2); e != nil || n.String() != "-1500" {
+		t.Errorf("expected -1500, got %v, %v", n, e)
+	}
+	if _, e := NewScaledNumber(big.NewInt(1), -MaxDigits); e != ErrOverflow {
+		t.Errorf("expected an overflow, got %v", e)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := number(t, "0.1"), number(t, "0.2")
+
+	sum, _ := a.Add(b)
+	if sum.String() != "0.3" || sum.Cmp(number(t, "0.30")) != 0 {
+		t.Errorf("0.1 + 0.2 = %v", sum)
+	}
+
+	diff, _ := number(t, "1").Sub(number(t, "0.001"))
+	if diff.String() != "0.999" {
+		t.Errorf("1 - 0.001 = %v", diff)
+	}
+
+	product, _ := number(t, "-1.5").Mul(number(t, "2.25"))
+	if product.String() != "-3.375" {
+		t.Errorf("-1.5 * 2.25 = %v", product)
+	}
+
+	quotient, _ := number(t, "1").Quo(number(t, "3"), 5, HalfUp)
+	if quotient.String() != "0.33333" {
+		t.Errorf("1 / 3 = %v", quotient)
+	}
+
+	quotient, _ = number(t, "-2").Quo(number(t, "3"), 2, HalfUp)
+	if quotient.String() != "-0.67" {
+		t.Errorf("-2 / 3 = %v", quotient)
+	}
+
+	if _, e := a.Quo(NewNumber(0), 2, HalfUp); e != ErrDivisionByZero {
+		t.Errorf("expected division by zero, got %v", e)
+	}
+
+	if n, ok := number(t, "42.000").Int64(); !ok || n != 42 {
+		t.Error("expected 42")
+	}
+	if _, ok := number(t, "42.5").Int64(); ok {
+		t.Error("42.5 is not an int")
+	}
+	if number(t, "1.25").Float64() != 1.25 {
+		t.Error("expected 1.25")
+	}
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		text     string
+		mode     RoundingMode
+		expected string
+	}{
+		{"2.5", HalfUp, "3"},
+		{"-2.5", HalfUp, "-3"},
+		{"2.5", HalfEven, "2"},
+		{"3.5", HalfEven, "4"},
+		{"2.5", HalfDown, "2"},
+		{"2.51", HalfDown, "3"},
+		{"2.1", Up, "3"},
+		{"-2.9", Down, "-2"},
+		{"-2.1", Ceiling, "-2"},
+		{"-2.1", Floor, "-3"},
+		{"2.0", Floor, "2"},
+	}
+
+	for _, test := range tests {
+		if actual := number(t, test.text).Round(0, test.mode).String(); actual != test.expected {
+			t.Errorf("%v rounded with mode %v: expected %v, got %v", test.text, test.mode, test.expected, actual)
+		}
+	}
+
+	if actual := number(t, "1234.5").Round(-2, HalfUp).String(); actual != "1200" {
+		t.Errorf("expected 1200, got %v", actual)
+	}
+	if actual := number(t, "1.5").Round(3, HalfUp).String(); actual != "1.500" {
+		t.Errorf("expected 1.500, got %v", actual)
+	}
+}
+
+func TestConstrain(t *testing.T) {
+	tests := []struct {
+		text      string
+		precision int
+		scale     int
+		expected  string
+	}{
+		{"123.456", 5, 2, "123.46"},
+		{"-999.994", 5, 2, "-999.99"},
+		{"1.5", 10, 2, "1.50"},
+		{"0.00001", 3, 2, "0.00"},
+		{"12345.6", 0, 0, "12346"},
+		{"12345", 5, -2, "12300"},
+		{"7", 0, 3, "7.000"},
+		{"0.00012", 2, 5, "0.00012"},
+		{"-0.00099", 2, 5, "-0.00099"},
+		{"0.000994", 2, 5, "0.00099"},
+		{"0.000004", 2, 5, "0.00000"},
+		{"0.0005", 1, 4, "0.0005"},
+	}
+
+	for _, test := range tests {
+		n, e := number(t, test.text).Constrain(test.precision, test.scale)
+		if e != nil {
+			t.Errorf("%v NUMBER(%v, %v): %v", test.text, test.precision, test.scale, e)
+		} else if n.String() != test.expected {
+			t.Errorf("%v NUMBER(%v, %v): expected %v, got %v", test.text, test.precision, test.scale, test.expected, n)
+		}
+	}
+
+	for _, text := range []string{"999.995", "1000", "-1234.5"} {
+		if _, e := number(t, text).Constrain(5, 2); e == nil || !strings.Contains(e.Error(), "NUMBER(5, 2)") {
+			t.Errorf("%v should not fit NUMBER(5, 2): %v", text, e)
+		}
+	}
+
+	// with a scale larger than the precision, only scale - precision leading zeros are left
+	for _, text := range []string{"0.0012", "0.000995", "1", "-0.001"} {
+		if _, e := number(t, text).Constrain(2, 5); e == nil || !strings.Contains(e.Error(), "NUMBER(2, 5)") {
+			t.Errorf("%v should not fit NUMBER(2, 5): %v", text, e)
+		}
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	row := struct {
+		Price *Number `json:"price" xml:"price"`
+	}{number(t, "12345678901234567890.10")}
+
+	data, e := json.Marshal(row)
+	if e != nil || string(data) != `{"price":12345678901234567890.10}` {
+		t.Errorf("json: %s %v", data, e)
+	}
+
+	row.Price = nil
+	if e = json.Unmarshal([]byte(`{"price":"0.1"}`), &row); e != nil || row.Price.String() != "0.1" {
+		t.Errorf("json: %v %v", row.Price, e)
+	}
+	if e = json.Unmarshal([]byte(`{"price":1e-2}`), &row); e != nil || row.Price.String() != "0.01" {
+		t.Errorf("json: %v %v", row.Price, e)
+	}
+
+	data, e = xml.Marshal(struct {
+		XMLName xml.Name `xml:"data"`
+		Price   *Number  `xml:"price"`
+	}{Price: number(t, "-0.50")})
+	if e != nil || string(data) != `<data><price>-0.50</price></data>` {
+		t.Errorf("xml: %s %v", data, e)
+	}
+}
@@ -0,0 +1,179 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+A timestamp format is made of these elements, and of other characters that stand for themselves:
+
+  YYYY        year, four digits
+  MM          month, 01 to 12
+  DD          day of the month
+  HH, HH24    hour, 00 to 23
+  HH12        hour, 01 to 12, which needs AM or PM
+  MI, mm      minute
+  SS, ss      second
+  FF          fraction of a second, 1 to 9 digits
+  AM, PM      AM or PM, in either case
+
+so that 2020-01-01T13:05:00 is in the format YYYY-MM-DDTHH:mm:SS. A timestamp has no time zone; it
+is taken to be UTC.
+*/
+
+// timestampElements are the elements of a format, longest first where one starts another.
+var timestampElements = []string{"HH24", "HH12", "YYYY", "MM", "DD", "HH", "MI", "mm", "SS", "ss", "FF", "AM", "PM"}
+
+// defaultTimestampFormats are tried in turn when no format is given.
+var defaultTimestampFormats = []string{
+	"YYYY-MM-DDTHH:MI:SS.FF", "YYYY-MM-DDTHH:MI:SS", "YYYY-MM-DD HH:MI:SS.FF", "YYYY-MM-DD HH:MI:SS", "YYYY-MM-DD",
+}
+
+// timestampItem is an element of a format, or text that stands for itself.
+type timestampItem struct {
+	element string
+	literal string
+}
+
+// CheckTimestampFormat reports whether a format can be used to read timestamps.
+func CheckTimestampFormat(format string) error {
+	_, err := parseTimestampFormat(format)
+	return err
+}
+
+// ParseTimestamp reads a timestamp in a format. An empty format takes a date, or a date and time
+// with a T or a space between them, as in 2020-01-01T13:05:00.5.
+func ParseTimestamp(text, format string) (time.Time, error) {
+	if format == "" {
+		for _, format := range defaultTimestampFormats {
+			if t, err := ParseTimestamp(text, format); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid timestamp: %v", text)
+	}
+
+	items, err := parseTimestampFormat(format)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	year, month, day := 1, 1, 1
+	var hour, minute, second, nanos int
+	pm := -1 // -1 without AM or PM, otherwise 0 for AM and 1 for PM
+	rest := text
+	for _, item := range items {
+		if item.literal != "" {
+			if !strings.HasPrefix(rest, item.literal) {
+				return time.Time{}, fmt.Errorf("%v is not in the format %v", text, format)
+			}
+			rest = rest[len(item.literal):]
+			continue
+		}
+
+		if item.element == "AM" || item.element == "PM" {
+			if len(rest) < 2 || !strings.EqualFold(rest[:2], "AM") && !strings.EqualFold(rest[:2], "PM") {
+				return time.Time{}, fmt.Errorf("%v is not in the format %v", text, format)
+			}
+			pm = 0
+			if strings.EqualFold(rest[:2], "PM") {
+				pm = 1
+			}
+			rest = rest[2:]
+			continue
+		}
+
+		width, most := 2, 2
+		switch item.element {
+		case "YYYY":
+			width, most = 4, 4
+		case "FF":
+			width, most = 1, 9
+		}
+		n := 0
+		for n < most && n < len(rest) && rest[n] >= '0' && rest[n] <= '9' {
+			n++
+		}
+		if n < width {
+			return time.Time{}, fmt.Errorf("%v is not in the format %v", text, format)
+		}
+		value := 0
+		for _, c := range rest[:n] {
+			value = value*10 + int(c-'0')
+		}
+
+		switch item.element {
+		case "YYYY":
+			year = value
+		case "MM":
+			month = value
+		case "DD":
+			day = value
+		case "HH", "HH24", "HH12":
+			hour = value
+		case "MI", "mm":
+			minute = value
+		case "SS", "ss":
+			second = value
+		case "FF":
+			for i := n; i < 9; i++ {
+				value *= 10
+			}
+			nanos = value
+		}
+		rest = rest[n:]
+	}
+	if rest != "" {
+		return time.Time{}, fmt.Errorf("%v is not in the format %v", text, format)
+	}
+
+	if pm >= 0 {
+		if hour < 1 || hour > 12 {
+			return time.Time{}, fmt.Errorf("invalid timestamp: %v", text)
+		}
+		hour = hour%12 + 12*pm
+	}
+	t := time.Date(year, time.Month(month), day, hour, minute, second, nanos, time.UTC)
+	// time.Date takes February 30 for March 2, and 24:00 for the next day
+	if t.Year() != year || int(t.Month()) != month || t.Day() != day || t.Hour() != hour || t.Minute() != minute || t.Second() != second {
+		return time.Time{}, fmt.Errorf("invalid timestamp: %v", text)
+	}
+	return t, nil
+}
+
+func parseTimestampFormat(format string) ([]timestampItem, error) {
+	var items []timestampItem
+	hour12, meridian := false, false
+
+	for rest := format; rest != ""; {
+		element := ""
+		for _, e := range timestampElements {
+			if strings.HasPrefix(rest, e) {
+				element = e
+				break
+			}
+		}
+
+		if element == "" {
+			if n := len(items); n > 0 && items[n-1].element == "" {
+				items[n-1].literal += rest[:1]
+			} else {
+				items = append(items, timestampItem{literal: rest[:1]})
+			}
+			rest = rest[1:]
+			continue
+		}
+
+		items = append(items, timestampItem{element: element})
+		rest = rest[len(element):]
+		hour12 = hour12 || element == "HH12"
+		meridian = meridian || element == "AM" || element == "PM"
+	}
+
+	if hour12 != meridian {
+		return nil, fmt.Errorf("invalid timestamp format %v: HH12 goes with AM or PM", format)
+	}
+	return items, nil
+}
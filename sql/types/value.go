@@ -0,0 +1,127 @@
+package types
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+/*
+A value is nil for NULL, or a string, *Number, time.Time or bool. Its type is named as the fields of
+a /sql response and the binds of a request name it: string, number, timestamp or boolean.
+*/
+
+// The types of value.
+const (
+	TypeString    = "string"
+	TypeNumber    = "number"
+	TypeTimestamp = "timestamp"
+	TypeBoolean   = "boolean"
+)
+
+// CheckType reports whether a value can be given as a type, in a format; a format is only for a
+// timestamp. An empty type leaves a value as the string it is given as.
+func CheckType(typ, format string) error {
+	switch typ {
+	case "", TypeString, TypeNumber, TypeBoolean:
+		if format != "" {
+			return errors.New("only a timestamp has a format")
+		}
+		return nil
+	case TypeTimestamp:
+		return CheckTimestampFormat(format)
+	}
+	return fmt.Errorf("unknown type %v: a value is a string, number, timestamp or boolean", typ)
+}
+
+// ParseValue reads a value of a type from its text, in a format for a timestamp.
+func ParseValue(text, typ, format string) (interface{}, error) {
+	if err := CheckType(typ, format); err != nil {
+		return nil, err
+	}
+
+	switch typ {
+	case TypeNumber:
+		return ParseNumber(text)
+	case TypeTimestamp:
+		return ParseTimestamp(text, format)
+	case TypeBoolean:
+		switch strings.ToLower(text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean: %v", text)
+	}
+	return text, nil
+}
+
+// TypeOf is the type of the values of a column of a data type, such as VARCHAR or NUMBER.
+func TypeOf(dataType string) (string, error) {
+	name, _, _ := strings.Cut(dataType, " ")
+	switch name {
+	case "NUMBER", "NUMERIC", "DECIMAL", "DEC", "INTEGER", "INT", "SMALLINT", "BIGINT", "FLOAT", "REAL", "DOUBLE":
+		return TypeNumber, nil
+	case "VARCHAR", "VARCHAR2", "CHAR", "CHARACTER", "NCHAR", "NVARCHAR2", "NATIONAL", "TEXT", "CLOB", "NCLOB", "UUID":
+		return TypeString, nil
+	case "DATE", "TIMESTAMP":
+		return TypeTimestamp, nil
+	case "BOOLEAN", "BOOL":
+		return TypeBoolean, nil
+	}
+	return "", fmt.Errorf("columns of type %v are not supported", dataType)
+}
+
+/*
+A value is kept in a row as:
+
+  string     its UTF-8 bytes
+  number     its text, as Number.String gives it, so that its scale is kept
+  timestamp  12 bytes: the seconds since 1970 in 8, then the nanoseconds in 4, big-endian, in UTC
+  boolean    1 byte: 1 for true, 0 for false
+
+NULL is left to the row, which tells it apart from an empty value.
+*/
+
+// EncodeValue is how a value is kept in a row. value must not be nil.
+func EncodeValue(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case *Number:
+		return []byte(v.String()), nil
+	case time.Time:
+		b := binary.BigEndian.AppendUint64(nil, uint64(v.Unix()))
+		return binary.BigEndian.AppendUint32(b, uint32(v.Nanosecond())), nil
+	case bool:
+		if v {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	}
+	return nil, fmt.Errorf("a value cannot be %T", value)
+}
+
+// DecodeValue reads a value of a type as EncodeValue keeps it.
+func DecodeValue(b []byte, typ string) (interface{}, error) {
+	switch typ {
+	case TypeString:
+		return string(b), nil
+	case TypeNumber:
+		return ParseNumber(string(b))
+	case TypeTimestamp:
+		if len(b) != 12 {
+			return nil, errors.New("a timestamp is damaged")
+		}
+		return time.Unix(int64(binary.BigEndian.Uint64(b)), int64(binary.BigEndian.Uint32(b[8:]))).UTC(), nil
+	case TypeBoolean:
+		if len(b) != 1 {
+			return nil, errors.New("a boolean is damaged")
+		}
+		return b[0] != 0, nil
+	}
+	return nil, fmt.Errorf("unknown type %v", typ)
+}
@@ -0,0 +1,152 @@
+package types
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		format   string
+		expected time.Time
+	}{
+		{"2020-01-01T00:00:00", "YYYY-MM-DDTHH:mm:SS", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"2020-02-29T23:59:58", "YYYY-MM-DDTHH:mm:ss", time.Date(2020, 2, 29, 23, 59, 58, 0, time.UTC)},
+		{"31/12/1999 11:05 pm", "DD/MM/YYYY HH12:MI AM", time.Date(1999, 12, 31, 23, 5, 0, 0, time.UTC)},
+		{"12:00 AM 2021-03-04", "HH12:MI PM YYYY-MM-DD", time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC)},
+		{"20200102 030405.25", "YYYYMMDD HH24MISS.FF", time.Date(2020, 1, 2, 3, 4, 5, 250000000, time.UTC)},
+		{"2020-01-02", "", time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{"2020-01-02 03:04:05", "", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{"2020-01-02T03:04:05.123456789", "", time.Date(2020, 1, 2, 3, 4, 5, 123456789, time.UTC)},
+	} {
+		ts, e := ParseTimestamp(test.text, test.format)
+		if e != nil || !ts.Equal(test.expected) {
+			t.Errorf("%v in %v: expected %v, got %v, %v", test.text, test.format, test.expected, ts, e)
+		}
+	}
+
+	for _, test := range []struct {
+		text   string
+		format string
+	}{
+		{"2020-01-01", "YYYY-MM-DDTHH:mm:SS"},
+		{"2020-01-01T00:00:00Z", "YYYY-MM-DDTHH:mm:SS"},
+		{"2020-02-30", "YYYY-MM-DD"},
+		{"2020-01-01 24:00:00", "YYYY-MM-DD HH:MI:SS"},
+		{"2020-13-01", ""},
+		{"13:00 PM", "HH12:MI PM"},
+		{"yesterday", ""},
+	} {
+		if ts, e := ParseTimestamp(test.text, test.format); e == nil {
+			t.Errorf("%v in %v: expected an error, got %v", test.text, test.format, ts)
+		}
+	}
+
+	if e := CheckTimestampFormat("HH12:MI"); e == nil {
+		t.Error("expected HH12 without AM or PM to be refused")
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	for _, test := range []struct {
+		text     string
+		typ      string
+		expected interface{}
+	}{
+		{"12.50", "", "12.50"},
+		{"12.50", TypeString, "12.50"},
+		{"TRUE", TypeBoolean, true},
+		{"false", TypeBoolean, false},
+		{"2020-01-01", TypeTimestamp, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if value, e := ParseValue(test.text, test.typ, ""); e != nil || value != test.expected {
+			t.Errorf("%v as %v: expected %v, got %v, %v", test.text, test.typ, test.expected, value, e)
+		}
+	}
+	if value, e := ParseValue("12.50", TypeNumber, ""); e != nil || value.(*Number).String() != "12.50" {
+		t.Errorf("expected the number 12.50, got %v, %v", value, e)
+	}
+
+	for _, test := range []struct {
+		text   string
+		typ    string
+		format string
+	}{
+		{"twelve", TypeNumber, ""},
+		{"yes", TypeBoolean, ""},
+		{"1", "integer", ""},
+		{"1", TypeNumber, "999"},
+	} {
+		if value, e := ParseValue(test.text, test.typ, test.format); e == nil {
+			t.Errorf("%v as %v: expected an error, got %v", test.text, test.typ, value)
+		}
+	}
+}
+
+func TestEncodeValue(t *testing.T) {
+	n, _ := ParseNumber("-12.50")
+	for _, test := range []struct {
+		value interface{}
+		typ   string
+	}{
+		{"", TypeString},
+		{"日本", TypeString},
+		{n, TypeNumber},
+		{time.Date(1900, 1, 1, 0, 0, 0, 1, time.UTC), TypeTimestamp},
+		{time.Date(2020, 2, 29, 23, 59, 58, 999999999, time.UTC), TypeTimestamp},
+		{true, TypeBoolean},
+		{false, TypeBoolean},
+	} {
+		b, e := EncodeValue(test.value)
+		if e != nil {
+			t.Fatalf("%v: %v", test.value, e)
+		}
+		value, e := DecodeValue(b, test.typ)
+		if e != nil {
+			t.Fatalf("%v: %v", test.value, e)
+		}
+		switch v := value.(type) {
+		case *Number:
+			if v.String() != n.String() {
+				t.Errorf("expected %v, got %v", n, v)
+			}
+		case time.Time:
+			if !v.Equal(test.value.(time.Time)) {
+				t.Errorf("expected %v, got %v", test.value, v)
+			}
+		default:
+			if value != test.value {
+				t.Errorf("expected %v, got %v", test.value, value)
+			}
+		}
+	}
+
+	if _, e := EncodeValue(1); e == nil {
+		t.Error("expected an error encoding an int")
+	}
+	if _, e := DecodeValue([]byte{1, 2}, TypeTimestamp); e == nil {
+		t.Error("expected an error decoding a short timestamp")
+	}
+
+	for _, test := range []struct {
+		dataType string
+		expected string
+	}{
+		{"NUMBER", TypeNumber},
+		{"INTEGER", TypeNumber},
+		{"DOUBLE PRECISION", TypeNumber},
+		{"VARCHAR", TypeString},
+		{"NATIONAL CHARACTER VARYING", TypeString},
+		{"UUID", TypeString},
+		{"TIMESTAMP WITH TIME ZONE", TypeTimestamp},
+		{"BOOLEAN", TypeBoolean},
+	} {
+		if typ, e := TypeOf(test.dataType); e != nil || typ != test.expected {
+			t.Errorf("%v: expected %v, got %v, %v", test.dataType, test.expected, typ, e)
+		}
+	}
+	if _, e := TypeOf("BLOB"); e == nil {
+		t.Error("expected BLOB to be refused")
+	}
+}
@@ -0,0 +1,702 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrKeyNotFound is returned when deleting an index entry that is not there.
+var ErrKeyNotFound = errors.New("index entry not found")
+
+/*
+A B+tree index keeps its entries in the leaves, in key order, with each leaf linked to its
+neighbours. The pages above only steer a search. The root never moves: when it splits, its contents
+go to two new pages and it becomes their parent, so the root page identifies the index.
+
+After the page header the body of every page holds:
+
+  offset size
+       0    1  level; 0 for a leaf
+       1    1  1 if the index is unique (root only)
+       2    2  number of key columns (root only)
+       4    4  bit i set if column i is DESC (root only)
+       8    2  number of cells
+      10    2  where cells start; they fill the page from the end down to here
+      12    4  leaf: next leaf, or 0; other pages: the child for keys below the first cell's
+      16    4  leaf: previous leaf, or 0
+      20       cell offsets, 2 bytes each, in key order
+
+A cell is a 2 byte key length and the key; above the leaves a 4 byte child page follows, which holds
+the keys from this cell's up to the next one's.
+
+The key of an entry is the encoded column values (see key.go) followed by the RowID, big-endian so
+that entries with the same values are in RowID order. Every entry is then different, and deleting
+one finds exactly that entry.
+
+Pages that become empty are not merged with their neighbours; a scan passes over them.
+*/
+
+const (
+	btLevel      = 0
+	btUnique     = 1
+	btColumns    = 2
+	btDesc       = 4
+	btCount      = 8
+	btFreeEnd    = 10
+	btLink       = 12
+	btPrev       = 16
+	btHeaderSize = 20
+)
+
+type btNode struct {
+	*Page
+}
+
+func (n btNode) level() int {
+	return int(n.Body()[btLevel])
+}
+
+func (n btNode) leaf() bool {
+	return n.level() == 0
+}
+
+func (n btNode) count() int {
+	return int(binary.LittleEndian.Uint16(n.Body()[btCount:]))
+}
+
+func (n btNode) freeEnd() int {
+	return int(binary.LittleEndian.Uint16(n.Body()[btFreeEnd:]))
+}
+
+func (n btNode) link() PageNo {
+	return binary.LittleEndian.Uint32(n.Body()[btLink:])
+}
+
+func (n btNode) setLink(no PageNo) {
+	binary.LittleEndian.PutUint32(n.Body()[btLink:], no)
+}
+
+func (n btNode) prev() PageNo {
+	return binary.LittleEndian.Uint32(n.Body()[btPrev:])
+}
+
+func (n btNode) setPrev(no PageNo) {
+	binary.LittleEndian.PutUint32(n.Body()[btPrev:], no)
+}
+
+func (n btNode) offset(i int) int {
+	return int(binary.LittleEndian.Uint16(n.Body()[btHeaderSize+2*i:]))
+}
+
+func (n btNode) cell(i int) []byte {
+	body := n.Body()
+	offset := n.offset(i)
+	size := 2 + int(binary.LittleEndian.Uint16(body[offset:]))
+	if !n.leaf() {
+		size += 4
+	}
+	return body[offset : offset+size]
+}
+
+func (n btNode) key(i int) []byte {
+	return cellKey(n.cell(i))
+}
+
+// child returns the i-th child of a page above the leaves; there is one more child than cells.
+func (n btNode) child(i int) PageNo {
+	if i == 0 {
+		return n.link()
+	}
+	return cellChild(n.cell(i - 1))
+}
+
+// search returns the first cell whose key is not below key.
+func (n btNode) search(key []byte) int {
+	return sort.Search(n.count(), func(i int) bool { return bytes.Compare(n.key(i), key) >= 0 })
+}
+
+// searchAfter returns the first cell whose key is above key.
+func (n btNode) searchAfter(key []byte) int {
+	return sort.Search(n.count(), func(i int) bool { return bytes.Compare(n.key(i), key) > 0 })
+}
+
+func (n btNode) freeSpace() int {
+	used := btHeaderSize + 2*n.count()
+	for i := 0; i < n.count(); i++ {
+		used += len(n.cell(i))
+	}
+	return len(n.Body()) - used
+}
+
+// insertCell puts a cell at position i, if the page has room.
+func (n btNode) insertCell(i int, cell []byte) bool {
+	if n.freeSpace() < len(cell)+2 {
+		return false
+	}
+
+	count := n.count()
+	if n.freeEnd()-len(cell) < btHeaderSize+2*(count+1) {
+		n.build(n.cells())
+	}
+
+	body := n.Body()
+	offset := n.freeEnd() - len(cell)
+	copy(body[offset:], cell)
+	binary.LittleEndian.PutUint16(body[btFreeEnd:], uint16(offset))
+
+	offsets := body[btHeaderSize:]
+	copy(offsets[2*(i+1):2*(count+1)], offsets[2*i:2*count])
+	binary.LittleEndian.PutUint16(offsets[2*i:], uint16(offset))
+	binary.LittleEndian.PutUint16(body[btCount:], uint16(count+1))
+	return true
+}
+
+func (n btNode) removeCell(i int) {
+	body := n.Body()
+	count := n.count()
+	offsets := body[btHeaderSize:]
+	copy(offsets[2*i:], offsets[2*(i+1):2*count])
+	binary.LittleEndian.PutUint16(body[btCount:], uint16(count-1))
+}
+
+// cells returns copies of the cells, in order.
+func (n btNode) cells() [][]byte {
+	cells := make([][]byte, n.count())
+	for i := range cells {
+		cells[i] = append([]byte(nil), n.cell(i)...)
+	}
+	return cells
+}
+
+// build replaces the cells of the page; the rest of the header stays.
+func (n btNode) build(cells [][]byte) {
+	body := n.Body()
+	end := len(body)
+	for i, cell := range cells {
+		end -= len(cell)
+		copy(body[end:], cell)
+		binary.LittleEndian.PutUint16(body[btHeaderSize+2*i:], uint16(end))
+	}
+	binary.LittleEndian.PutUint16(body[btCount:], uint16(len(cells)))
+	binary.LittleEndian.PutUint16(body[btFreeEnd:], uint16(end))
+}
+
+func (n btNode) init(level int) {
+	n.Body()[btLevel] = byte(level)
+	n.build(nil)
+}
+
+func makeCell(key []byte, child PageNo, leaf bool) []byte {
+	cell := make([]byte, 2, 2+len(key)+4)
+	binary.LittleEndian.PutUint16(cell, uint16(len(key)))
+	cell = append(cell, key...)
+	if !leaf {
+		cell = binary.LittleEndian.AppendUint32(cell, child)
+	}
+	return cell
+}
+
+func cellKey(cell []byte) []byte {
+	return cell[2 : 2+binary.LittleEndian.Uint16(cell)]
+}
+
+func cellChild(cell []byte) PageNo {
+	return binary.LittleEndian.Uint32(cell[len(cell)-4:])
+}
+
+func appendRowID(key []byte, id RowID) []byte {
+	key = binary.BigEndian.AppendUint16(key, id.File)
+	key = binary.BigEndian.AppendUint32(key, id.Page)
+	return binary.BigEndian.AppendUint16(key, id.Slot)
+}
+
+func entryRowID(key []byte) RowID {
+	b := key[len(key)-rowIDSize:]
+	return RowID{
+		File: binary.BigEndian.Uint16(b[0:]),
+		Page: binary.BigEndian.Uint32(b[2:]),
+		Slot: binary.BigEndian.Uint16(b[6:]),
+	}
+}
+
+// KeyRange limits a scan of an index. From and To hold values for the leading columns of the key,
+// and take in every key that starts with them unless they are exclusive. A nil bound is open.
+type KeyRange struct {
+	From          []interface{}
+	To            []interface{}
+	FromExclusive bool
+	ToExclusive   bool
+}
+
+// BTree is an index: entries of column values and the RowID of the row that has them.
+type BTree struct {
+	mu     sync.Mutex
+	pool   *BufferPool
+	file   *DataFile
+	root   PageNo
+	desc   []bool
+	unique bool
+}
+
+// CreateBTree starts an empty index in the datafile, with a key of len(desc) columns; desc[i] is
+// true for a DESC column. Its root page identifies it for OpenBTree.
+func CreateBTree(pool *BufferPool, df *DataFile, desc []bool, unique bool) (*BTree, error) {
+	if len(desc) == 0 || len(desc) > MaxIndexColumns {
+		return nil, fmt.Errorf("an index has 1 to %v columns, not %v", MaxIndexColumns, len(desc))
+	}
+
+	page, err := pool.Allocate(df, PageIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	root := btNode{page}
+	root.init(0)
+
+	body := root.Body()
+	if unique {
+		body[btUnique] = 1
+	}
+	binary.LittleEndian.PutUint16(body[btColumns:], uint16(len(desc)))
+	var bits uint32
+	for i, d := range desc {
+		if d {
+			bits |= 1 << i
+		}
+	}
+	binary.LittleEndian.PutUint32(body[btDesc:], bits)
+
+	if err = pool.Unpin(df, page.No, true); err != nil {
+		return nil, err
+	}
+
+	return &BTree{pool: pool, file: df, root: page.No, desc: append([]bool(nil), desc...), unique: unique}, nil
+}
+
+// OpenBTree opens the index whose root is the given page.
+func OpenBTree(pool *BufferPool, df *DataFile, root PageNo) (*BTree, error) {
+	t := &BTree{pool: pool, file: df, root: root}
+
+	n, err := t.pin(root)
+	if err != nil {
+		return nil, err
+	}
+	defer t.unpin(root)
+
+	body := n.Body()
+	columns := int(binary.LittleEndian.Uint16(body[btColumns:]))
+	if n.Type() != PageIndex || columns == 0 || columns > MaxIndexColumns {
+		return nil, fmt.Errorf("%v: page %v is not the root of an index", df.Path(), root)
+	}
+
+	t.unique = body[btUnique] == 1
+	bits := binary.LittleEndian.Uint32(body[btDesc:])
+	for i := 0; i < columns; i++ {
+		t.desc = append(t.desc, bits&(1<<i) != 0)
+	}
+
+	return t, nil
+}
+
+// Root is the page that identifies the index.
+func (t *BTree) Root() PageNo {
+	return t.root
+}
+
+// DataFile is the datafile the index is in.
+func (t *BTree) DataFile() *DataFile {
+	return t.file
+}
+
+// Unique reports whether the index allows a key only once.
+func (t *BTree) Unique() bool {
+	return t.unique
+}
+
+// maxEntry is the longest entry key; four cells always fit in a page, so a split always works.
+func (t *BTree) maxEntry() int {
+	return (t.file.PageSize()-PageHeaderSize-btHeaderSize)/4 - 2 - 2 - 4
+}
+
+// Insert adds an entry for a row. A unique index returns ErrUniqueViolation if another row has the
+// same values, unless they are all NULL.
+func (t *BTree) Insert(values []interface{}, id RowID) error {
+	key, allNull, err := t.fullKey(values)
+	if err != nil {
+		return err
+	}
+	entry := appendRowID(key, id)
+	if len(entry) > t.maxEntry() {
+		return fmt.Errorf("index key of %v bytes is longer than the %v allowed", len(entry), t.maxEntry())
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err = t.file.writable(); err != nil {
+		return err
+	}
+
+	if t.unique && !allNull {
+		found, _, err := t.collect(key, prefixEnd(key), false)
+		if err != nil {
+			return err
+		}
+		if len(found) > 0 {
+			return ErrUniqueViolation
+		}
+	}
+
+	leaf, path, err := t.descend(entry, false)
+	if err != nil {
+		return err
+	}
+
+	i := leaf.search(entry)
+	if i < leaf.count() && bytes.Equal(leaf.key(i), entry) {
+		t.unpin(leaf.No)
+		return fmt.Errorf("index already has an entry for row %v", id)
+	}
+
+	cell := makeCell(entry, 0, true)
+	node := leaf
+	for {
+		if node.insertCell(i, cell) {
+			return t.unpinChanged(node.No)
+		}
+
+		sep, right, err := t.split(node, i, cell)
+		if e := t.unpinChanged(node.No); err == nil {
+			err = e
+		}
+		if err != nil || right == 0 {
+			return err
+		}
+
+		// the parent takes a cell for the new page
+		parent := path[len(path)-1]
+		path = path[:len(path)-1]
+		if node, err = t.pin(parent); err != nil {
+			return err
+		}
+		i = node.searchAfter(sep)
+		cell = makeCell(sep, right, false)
+	}
+}
+
+// Delete removes the entry for a row.
+func (t *BTree) Delete(values []interface{}, id RowID) error {
+	key, _, err := t.fullKey(values)
+	if err != nil {
+		return err
+	}
+	entry := appendRowID(key, id)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err = t.file.writable(); err != nil {
+		return err
+	}
+
+	leaf, _, err := t.descend(entry, false)
+	if err != nil {
+		return err
+	}
+
+	i := leaf.search(entry)
+	if i == leaf.count() || !bytes.Equal(leaf.key(i), entry) {
+		t.unpin(leaf.No)
+		return ErrKeyNotFound
+	}
+
+	leaf.removeCell(i)
+	return t.unpinChanged(leaf.No)
+}
+
+// Lookup returns the rows whose keys start with the values, in key order.
+func (t *BTree) Lookup(values []interface{}) ([]RowID, error) {
+	var ids []RowID
+	err := t.Scan(KeyRange{From: values, To: values}, false, func(id RowID) error {
+		ids = append(ids, id)
+		return nil
+	})
+	return ids, err
+}
+
+// Scan calls fn with the rows whose keys are in the range, in key order, or the reverse order.
+// fn may change the index; whether it sees entries added ahead of the scan depends on where they
+// land.
+func (t *BTree) Scan(r KeyRange, reverse bool, fn func(RowID) error) error {
+	var from, to []byte
+
+	if r.From != nil {
+		key, _, err := encodeKey(r.From, t.desc)
+		if err != nil {
+			return err
+		}
+		from = key
+		if r.FromExclusive {
+			if from = prefixEnd(key); from == nil {
+				return nil
+			}
+		}
+	}
+
+	if r.To != nil {
+		key, _, err := encodeKey(r.To, t.desc)
+		if err != nil {
+			return err
+		}
+		to = prefixEnd(key)
+		if r.ToExclusive {
+			if to = key; len(to) == 0 {
+				return nil
+			}
+		}
+	}
+
+	for {
+		t.mu.Lock()
+		entries, more, err := t.collect(from, to, reverse)
+		t.mu.Unlock()
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err = fn(entryRowID(entry)); err != nil {
+				return err
+			}
+		}
+
+		if !more {
+			return nil
+		}
+
+		// carry on from just past the last entry, wherever it is now
+		last := entries[len(entries)-1]
+		if reverse {
+			to = last
+		} else {
+			from = append(last, 0)
+		}
+	}
+}
+
+// collect returns the entries from the first leaf that has any in [from, to), or the last one when
+// reversed; more is false when there are no others in the range. A nil bound is open.
+func (t *BTree) collect(from, to []byte, reverse bool) (entries [][]byte, more bool, err error) {
+	var leaf btNode
+	if reverse {
+		leaf, _, err = t.descend(to, true)
+	} else {
+		leaf, _, err = t.descend(from, false)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	for {
+		var next PageNo
+		if reverse {
+			i := leaf.count() - 1
+			if to != nil {
+				i = leaf.search(to) - 1
+			}
+			for ; i >= 0; i-- {
+				key := leaf.key(i)
+				if from != nil && bytes.Compare(key, from) < 0 {
+					t.unpin(leaf.No)
+					return entries, false, nil
+				}
+				entries = append(entries, append([]byte(nil), key...))
+			}
+			next = leaf.prev()
+		} else {
+			i := leaf.search(from)
+			for ; i < leaf.count(); i++ {
+				key := leaf.key(i)
+				if to != nil && bytes.Compare(key, to) >= 0 {
+					t.unpin(leaf.No)
+					return entries, false, nil
+				}
+				entries = append(entries, append([]byte(nil), key...))
+			}
+			next = leaf.link()
+		}
+		t.unpin(leaf.No)
+
+		if next == 0 {
+			return entries, false, nil
+		}
+		if len(entries) > 0 {
+			return entries, true, nil
+		}
+
+		if leaf, err = t.pin(next); err != nil {
+			return nil, false, err
+		}
+	}
+}
+
+// descend pins the leaf for key and returns the pages above it, root first. The leaf is the one
+// that holds key, or where it would go; when before is true it is the leaf that holds the entries
+// just below key, and a nil key means the end of the index rather than the start.
+func (t *BTree) descend(key []byte, before bool) (btNode, []PageNo, error) {
+	var path []PageNo
+	no := t.root
+
+	for {
+		n, err := t.pin(no)
+		if err != nil {
+			return btNode{}, nil, err
+		}
+		if n.leaf() {
+			return n, path, nil
+		}
+
+		var i int
+		switch {
+		case !before:
+			i = n.searchAfter(key)
+		case key == nil:
+			i = n.count()
+		default:
+			i = n.search(key)
+		}
+
+		path = append(path, no)
+		next := n.child(i)
+		t.unpin(no)
+		no = next
+	}
+}
+
+// split divides a full page and its new cell between it and a new page to its right, and returns
+// the key of the new page's first entry and its number for the parent. When the root splits it
+// stays the root, and split returns 0 for the page.
+func (t *BTree) split(n btNode, i int, cell []byte) (sep []byte, no PageNo, err error) {
+	cells := n.cells()
+	cells = append(cells[:i], append([][]byte{cell}, cells[i:]...)...)
+
+	// half the bytes on each side
+	total, k := 0, 0
+	for _, c := range cells {
+		total += len(c)
+	}
+	for half := 0; k < len(cells)-1 && half < total/2; k++ {
+		half += len(cells[k])
+	}
+	if k == 0 {
+		k = 1
+	}
+
+	leaf := n.leaf()
+	left, right := cells[:k], cells[k:]
+	sep = append([]byte(nil), cellKey(right[0])...)
+	var rightFirst PageNo
+	if !leaf {
+		// the middle cell moves up; its child starts the right page
+		if len(right) == 1 {
+			k--
+			left, right = cells[:k], cells[k:]
+			sep = append([]byte(nil), cellKey(right[0])...)
+		}
+		rightFirst = cellChild(right[0])
+		right = right[1:]
+	}
+
+	rp, err := t.pool.Allocate(t.file, PageIndex)
+	if err != nil {
+		return nil, 0, err
+	}
+	rn := btNode{rp}
+	rn.init(n.level())
+	rn.build(right)
+	defer func() {
+		if e := t.unpinChanged(rn.No); err == nil {
+			err = e
+		}
+	}()
+
+	if n.No == t.root {
+		lp, err := t.pool.Allocate(t.file, PageIndex)
+		if err != nil {
+			return nil, 0, err
+		}
+		ln := btNode{lp}
+		ln.init(n.level())
+		ln.build(left)
+		if leaf {
+			ln.setLink(rn.No)
+			rn.setPrev(ln.No)
+		} else {
+			ln.setLink(n.link())
+			rn.setLink(rightFirst)
+		}
+		if err = t.unpinChanged(ln.No); err != nil {
+			return nil, 0, err
+		}
+
+		n.Body()[btLevel] = byte(n.level() + 1)
+		n.setLink(ln.No)
+		n.setPrev(0)
+		n.build([][]byte{makeCell(sep, rn.No, false)})
+		return sep, 0, nil
+	}
+
+	n.build(left)
+	if !leaf {
+		rn.setLink(rightFirst)
+		return sep, rn.No, nil
+	}
+
+	if next := n.link(); next != 0 {
+		nn, err := t.pin(next)
+		if err != nil {
+			return nil, 0, err
+		}
+		nn.setPrev(rn.No)
+		if err = t.unpinChanged(next); err != nil {
+			return nil, 0, err
+		}
+		rn.setLink(next)
+	}
+	rn.setPrev(n.No)
+	n.setLink(rn.No)
+
+	return sep, rn.No, nil
+}
+
+// fullKey encodes a value for every column of the index.
+func (t *BTree) fullKey(values []interface{}) ([]byte, bool, error) {
+	if len(values) != len(t.desc) {
+		return nil, false, fmt.Errorf("index has %v columns, not %v", len(t.desc), len(values))
+	}
+	return encodeKey(values, t.desc)
+}
+
+func (t *BTree) pin(no PageNo) (btNode, error) {
+	page, err := t.pool.Get(t.file, no)
+	if err != nil {
+		return btNode{}, err
+	}
+	return btNode{page}, nil
+}
+
+// unpin gives back a page the index only read. A page pinned by the index is always in the pool, so
+// this cannot fail.
+func (t *BTree) unpin(no PageNo) {
+	_ = t.pool.Unpin(t.file, no, false)
+}
+
+// unpinChanged gives back a page the index changed. If the change cannot be kept, as when the file
+// has been made read only, it is lost and the error says why.
+func (t *BTree) unpinChanged(no PageNo) error {
+	return t.pool.Unpin(t.file, no, true)
+}
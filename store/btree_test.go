@@ -0,0 +1,294 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/djbckr/godb/sql/types"
+)
+
+func createBTree(t *testing.T, desc []bool, unique bool) (*BTree, *BufferPool, *DataFile) {
+	df := createDataFile(t, MinPageSize)
+	bp := NewBufferPool(16)
+	tree, e := CreateBTree(bp, df, desc, unique)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return tree, bp, df
+}
+
+func scanIDs(t *testing.T, tree *BTree, r KeyRange, reverse bool) []RowID {
+	var ids []RowID
+	if e := tree.Scan(r, reverse, func(id RowID) error {
+		ids = append(ids, id)
+		return nil
+	}); e != nil {
+		t.Fatal(e)
+	}
+	return ids
+}
+
+func rowFor(n int) RowID {
+	return RowID{File: 1, Page: PageNo(n/100 + 1), Slot: uint16(n % 100)}
+}
+
+func TestKeyOrder(t *testing.T) {
+	number := func(s string) *types.Number {
+		n, e := types.ParseNumber(s)
+		if e != nil {
+			t.Fatal(e)
+		}
+		return n
+	}
+
+	// each value sorts before the next
+	ordered := []interface{}{
+		number("-1000"), -20, number("-12.5"), number("-12.05"), -1, number("-0.001"), 0,
+		number("0.00012"), number("0.05"), 0.5, 1, number("1.000001"), 9, 10, int64(100), number("1e30"),
+		time.Unix(-100, 0), time.Unix(0, 0), time.Unix(100, 5),
+		"", "\x00", "A", "Aa", "B", []byte{0xFF},
+		nil,
+	}
+
+	for _, desc := range []bool{false, true} {
+		var keys [][]byte
+		for _, v := range ordered {
+			key, _, e := encodeKey([]interface{}{v}, []bool{desc})
+			if e != nil {
+				t.Fatal(e)
+			}
+			keys = append(keys, key)
+		}
+		for i := 1; i < len(keys); i++ {
+			c := bytes.Compare(keys[i-1], keys[i])
+			if desc {
+				c = -c
+			}
+			if c >= 0 {
+				t.Errorf("desc %v: %v does not sort before %v", desc, ordered[i-1], ordered[i])
+			}
+		}
+	}
+
+	// equal numbers are equal keys, whatever their scale
+	a, _, _ := encodeKey([]interface{}{number("1.50")}, []bool{false})
+	b, _, _ := encodeKey([]interface{}{1.5}, []bool{false})
+	if !bytes.Equal(a, b) {
+		t.Error("1.50 and 1.5 should have the same key")
+	}
+
+	if _, _, e := encodeKey([]interface{}{struct{}{}}, []bool{false}); e == nil {
+		t.Error("expected error for a value of an unknown type")
+	}
+	if prefixEnd([]byte{1, 0xFF}) == nil || prefixEnd([]byte{0xFF, 0xFF}) != nil {
+		t.Error("unexpected prefix end")
+	}
+}
+
+func TestBTreeInsertLookupDelete(t *testing.T) {
+	tree, bp, df := createBTree(t, []bool{false}, false)
+	rnd := rand.New(rand.NewSource(1))
+
+	// enough entries for a tree of three levels
+	const n = 5000
+	for _, i := range rnd.Perm(n) {
+		if e := tree.Insert([]interface{}{i / 2}, rowFor(i)); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	if ids, e := tree.Lookup([]interface{}{1234}); e != nil || len(ids) != 2 || ids[0] != rowFor(2468) || ids[1] != rowFor(2469) {
+		t.Errorf("unexpected lookup %v, %v", ids, e)
+	}
+	if ids, _ := tree.Lookup([]interface{}{n}); len(ids) != 0 {
+		t.Errorf("expected nothing, got %v", ids)
+	}
+	if e := tree.Insert([]interface{}{1234}, rowFor(2468)); e == nil {
+		t.Error("expected error adding an entry twice")
+	}
+
+	for i := 0; i < n; i += 3 {
+		if e := tree.Delete([]interface{}{i / 2}, rowFor(i)); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := tree.Delete([]interface{}{0}, rowFor(0)); e != ErrKeyNotFound {
+		t.Errorf("expected ErrKeyNotFound, got %v", e)
+	}
+
+	// the index survives reopening
+	if e := bp.Flush(nil); e != nil {
+		t.Fatal(e)
+	}
+	tree, e := OpenBTree(NewBufferPool(16), df, tree.Root())
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	ids := scanIDs(t, tree, KeyRange{}, false)
+	if len(ids) != n-(n+2)/3 {
+		t.Fatalf("expected %v entries, got %v", n-(n+2)/3, len(ids))
+	}
+	j := 0
+	for i := 0; i < n; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		if ids[j] != rowFor(i) {
+			t.Fatalf("entry %v: expected %v, got %v", j, rowFor(i), ids[j])
+		}
+		j++
+	}
+}
+
+func TestBTreeRanges(t *testing.T) {
+	tree, _, _ := createBTree(t, []bool{false}, false)
+	for i := 0; i < 1000; i++ {
+		if e := tree.Insert([]interface{}{i}, rowFor(i)); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	check := func(r KeyRange, first, last int) {
+		for _, reverse := range []bool{false, true} {
+			ids := scanIDs(t, tree, r, reverse)
+			if len(ids) != last-first+1 {
+				t.Errorf("%+v reverse %v: expected %v entries, got %v", r, reverse, last-first+1, len(ids))
+				continue
+			}
+			if reverse {
+				first, last = last, first
+			}
+			if ids[0] != rowFor(first) || ids[len(ids)-1] != rowFor(last) {
+				t.Errorf("%+v reverse %v: got %v to %v", r, reverse, ids[0], ids[len(ids)-1])
+			}
+			if reverse {
+				first, last = last, first
+			}
+		}
+	}
+
+	check(KeyRange{}, 0, 999)
+	check(KeyRange{From: []interface{}{100}, To: []interface{}{200}}, 100, 200)
+	check(KeyRange{From: []interface{}{100}, To: []interface{}{200}, FromExclusive: true, ToExclusive: true}, 101, 199)
+	check(KeyRange{From: []interface{}{990}}, 990, 999)
+	check(KeyRange{To: []interface{}{5}, ToExclusive: true}, 0, 4)
+
+	if ids := scanIDs(t, tree, KeyRange{From: []interface{}{500}, To: []interface{}{400}}, false); len(ids) != 0 {
+		t.Errorf("expected an empty range, got %v", len(ids))
+	}
+
+	// a scan can stop early, and can change the index as it goes
+	stop := errors.New("stop")
+	count := 0
+	e := tree.Scan(KeyRange{}, false, func(id RowID) error {
+		if count++; count == 600 {
+			return stop
+		}
+		return tree.Delete([]interface{}{int(id.Page-1)*100 + int(id.Slot)}, id)
+	})
+	if e != stop {
+		t.Errorf("expected the scan to stop, got %v", e)
+	}
+	check(KeyRange{}, 599, 999)
+}
+
+func TestBTreeComposite(t *testing.T) {
+	// (name ASC, amount DESC)
+	tree, _, _ := createBTree(t, []bool{false, true}, false)
+
+	rows := []struct {
+		name   interface{}
+		amount int
+	}{{"b", 1}, {"a", 5}, {"b", 9}, {"a", 7}, {nil, 3}, {"c", 2}, {"b", 4}}
+	for i, row := range rows {
+		if e := tree.Insert([]interface{}{row.name, row.amount}, rowFor(i)); e != nil {
+			t.Fatal(e)
+		}
+	}
+
+	order := func(ids []RowID) []int {
+		var slots []int
+		for _, id := range ids {
+			slots = append(slots, int(id.Slot))
+		}
+		return slots
+	}
+
+	// a, by amount DESC: 7 then 5; b: 9, 4, 1; c; then NULL
+	expected := []int{3, 1, 2, 6, 0, 5, 4}
+	if got := order(scanIDs(t, tree, KeyRange{}, false)); !equalInts(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	if ids, _ := tree.Lookup([]interface{}{"b"}); !equalInts(order(ids), []int{2, 6, 0}) {
+		t.Errorf("unexpected rows for b: %v", order(ids))
+	}
+	if ids, _ := tree.Lookup([]interface{}{"b", 4}); !equalInts(order(ids), []int{6}) {
+		t.Errorf("unexpected rows for b, 4: %v", order(ids))
+	}
+
+	if e := tree.Insert([]interface{}{"a"}, rowFor(9)); e == nil {
+		t.Error("expected error inserting a partial key")
+	}
+	if _, e := CreateBTree(tree.pool, tree.file, nil, false); e == nil {
+		t.Error("expected error creating an index without columns")
+	}
+}
+
+func TestBTreeUnique(t *testing.T) {
+	tree, bp, df := createBTree(t, []bool{false, false}, true)
+
+	if e := tree.Insert([]interface{}{"x", 1}, rowFor(1)); e != nil {
+		t.Fatal(e)
+	}
+	e := tree.Insert([]interface{}{"x", 1}, rowFor(2))
+	var coded *Error
+	if !errors.As(e, &coded) || coded.Code != 1 || e != ErrUniqueViolation {
+		t.Errorf("expected a unique violation, got %v", e)
+	}
+
+	// a different key, keys that are all NULL, or the key again after a delete are fine
+	for i, values := range [][]interface{}{{"x", 2}, {nil, nil}, {nil, nil}} {
+		if e = tree.Insert(values, rowFor(3+i)); e != nil {
+			t.Errorf("%v: %v", values, e)
+		}
+	}
+	if e = tree.Insert([]interface{}{nil, 1}, rowFor(6)); e != nil {
+		t.Fatal(e)
+	}
+	if e = tree.Insert([]interface{}{nil, 1}, rowFor(7)); e != ErrUniqueViolation {
+		t.Errorf("keys that are only partly NULL should clash, got %v", e)
+	}
+	if e = tree.Delete([]interface{}{"x", 1}, rowFor(1)); e != nil {
+		t.Fatal(e)
+	}
+	if e = tree.Insert([]interface{}{"x", 1}, rowFor(2)); e != nil {
+		t.Errorf("key should be free again: %v", e)
+	}
+
+	_ = bp.Flush(nil)
+	if reopened, e := OpenBTree(NewBufferPool(4), df, tree.Root()); e != nil || !reopened.Unique() || len(reopened.desc) != 2 {
+		t.Errorf("index not reopened as unique with 2 columns: %v", e)
+	}
+
+	long := string(bytes.Repeat([]byte("z"), tree.maxEntry()))
+	if e = tree.Insert([]interface{}{long, 1}, rowFor(8)); e == nil {
+		t.Error("expected error for a key too long")
+	}
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,557 @@
+package store
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrBufferPoolFull is returned when every page in the pool is pinned and another is needed.
+var ErrBufferPoolFull = errors.New("buffer pool full: every page is pinned")
+
+/*
+The pool evicts by LIRS (Jiang and Zhang, "LIRS: An Efficient Low Inter-reference Recency Set
+Replacement Policy", 2002). Pages seen twice within a short span are LIR pages and stay cached; the
+rest are HIR pages, which get a small share of the pool and go first. Unlike LRU, a scan over a large
+table only churns the HIR share and leaves the working set alone.
+
+The stack holds pages in order of recency: LIR pages, resident HIR pages, and a bounded number of
+HIR pages no longer in the pool whose recency is still remembered. Its bottom is always an LIR page.
+The queue holds the resident HIR pages in the order they are evicted.
+
+A page is read with the pool unlocked. Its frame is taken first, pinned and marked as loading, so
+that it is not evicted, and another Get of the page waits for the read rather than reading it too.
+*/
+
+type bufferKey struct {
+	file *DataFile
+	no   PageNo
+}
+
+type buffer struct {
+	key      bufferKey
+	page     *Page // nil once evicted, and while it is read
+	pins     int
+	dirty    bool
+	since    uint64        // the LSN of the first change since the page was last written
+	logging  int           // pins being given back whose changes are being logged
+	loading  chan struct{} // closed once the page is read; nil unless it is being read
+	lir      bool
+	inStack  *list.Element
+	inQueue  *list.Element
+	resident bool
+}
+
+// BufferStats counts what the pool has done since it was made or the counters were reset.
+type BufferStats struct {
+	Hits       uint64 // Get found the page in the pool
+	Misses     uint64 // Get had to read the page
+	Evictions  uint64 // pages dropped to make room
+	WriteBacks uint64 // dirty pages written to their file
+	Capacity   int
+	Resident   int // pages in the pool now
+	Pinned     int
+	Dirty      int
+}
+
+// HitRatio is the share of Get calls that found the page in the pool.
+func (s BufferStats) HitRatio() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// BufferPool caches pages of datafiles. A page handed out by Get or Allocate is pinned, so it
+// stays in the pool, until it is given back with Unpin.
+type BufferPool struct {
+	mu          sync.Mutex
+	capacity    int
+	lirCapacity int
+	lirCount    int
+	resident    int
+	buffers     map[bufferKey]*buffer
+	stack       *list.List // of *buffer; front is the most recent
+	queue       *list.List // of *buffer; front is evicted first
+	stats       BufferStats
+	redo        *RedoLog
+}
+
+// NewBufferPool makes a pool holding up to capacity pages. One page in a hundred, and at least one,
+// is kept for HIR pages.
+func NewBufferPool(capacity int) *BufferPool {
+	if capacity < 2 {
+		capacity = 2
+	}
+	hir := capacity / 100
+	if hir < 1 {
+		hir = 1
+	}
+	return &BufferPool{
+		capacity:    capacity,
+		lirCapacity: capacity - hir,
+		buffers:     make(map[bufferKey]*buffer),
+		stack:       list.New(),
+		queue:       list.New(),
+	}
+}
+
+// SetRedoLog makes the pool log each change to a page as it is unpinned, and flush the redo log up
+// to a page's LSN before writing the page.
+func (bp *BufferPool) SetRedoLog(r *RedoLog) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.redo = r
+}
+
+// redoLog is the log the pool logs changes to, or nil.
+func (bp *BufferPool) redoLog() *RedoLog {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	return bp.redo
+}
+
+// Get returns a page from the pool, reading it on a miss, and pins it.
+func (bp *BufferPool) Get(df *DataFile, no PageNo) (*Page, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if df.Status() == FileOffline {
+		return nil, ErrOffline
+	}
+
+	key := bufferKey{df, no}
+	b := bp.buffers[key]
+	for b != nil && b.loading != nil {
+		loading := b.loading
+		bp.mu.Unlock()
+		<-loading
+		bp.mu.Lock()
+		// the read may have failed, and the page been dropped
+		b = bp.buffers[key]
+	}
+
+	if b != nil && b.resident {
+		bp.stats.Hits++
+		bp.hit(b)
+		b.pins++
+		return b.page, nil
+	}
+
+	bp.stats.Misses++
+
+	b, err := bp.load(key, nil)
+	if err != nil {
+		return nil, err
+	}
+	loading := make(chan struct{})
+	b.loading = loading
+
+	bp.mu.Unlock()
+	page, err := df.Read(no)
+	bp.mu.Lock()
+
+	b.loading = nil
+	close(loading)
+	if err != nil {
+		bp.drop(b)
+		return nil, err
+	}
+	b.page = page
+
+	return page, nil
+}
+
+// Allocate allocates a new page in the datafile and puts it in the pool, pinned.
+func (bp *BufferPool) Allocate(df *DataFile, typ PageType) (*Page, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	if err := bp.makeRoom(); err != nil {
+		return nil, err
+	}
+
+	page, err := df.Allocate(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = bp.load(bufferKey{df, page.No}, page); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// Unpin gives back a page from Get or Allocate. If the caller changed it, dirty must be true: the
+// change is logged in the redo log, and the page is written back before it leaves the pool. A change
+// to a page of a file that is read only, or that cannot be logged, is thrown away, and Unpin returns
+// the error.
+func (bp *BufferPool) Unpin(df *DataFile, no PageNo, dirty bool) error {
+	bp.mu.Lock()
+	b := bp.buffers[bufferKey{df, no}]
+	if b == nil || !b.resident || b.pins == 0 || b.loading != nil {
+		bp.mu.Unlock()
+		return fmt.Errorf("%v: page %v is not pinned", df.Path(), no)
+	}
+	redo := bp.redo
+	if dirty {
+		b.logging++
+	}
+	bp.mu.Unlock()
+
+	// the page is still pinned, so it stays put while it is logged; the pool is not locked, since
+	// the log may take a checkpoint to make room
+	var err error
+	var lsn uint64
+	if dirty {
+		if err = df.writable(); err == nil && redo != nil {
+			lsn, err = redo.Log(0, df, b.page, 0, len(b.page.Body()))
+		}
+	}
+
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	b.pins--
+	if dirty {
+		b.logging--
+	}
+
+	if err != nil {
+		// the page is read again from the file next time
+		if b.pins == 0 {
+			bp.drop(b)
+		}
+		return err
+	}
+	if dirty && !b.dirty {
+		b.dirty, b.since = true, lsn
+	}
+
+	return nil
+}
+
+// Flush writes every dirty page of the datafile, or of every file if df is nil.
+func (bp *BufferPool) Flush(df *DataFile) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for _, b := range bp.buffers {
+		if b.resident && b.dirty && (df == nil || b.key.file == df) {
+			if err := bp.writeBack(b); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// flushUnpinned writes every dirty page that is not pinned, for a checkpoint, and returns the files
+// it wrote to. A pinned page may be in the middle of a change that is not logged yet, so it is left
+// alone; flushUnpinned returns the LSN of the oldest change left unwritten on such a page, or 0 if
+// there is none. A page whose only pins are being given back is done with, and is written: the log
+// may be taking the checkpoint to make room for its change, which is logged whole right after.
+func (bp *BufferPool) flushUnpinned() ([]*DataFile, uint64, error) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	var files []*DataFile
+	written := make(map[*DataFile]bool)
+	var oldest uint64
+	for _, b := range bp.buffers {
+		switch {
+		case !b.resident || !b.dirty:
+		case b.pins > b.logging:
+			if oldest == 0 || b.since < oldest {
+				oldest = b.since
+			}
+		default:
+			if err := bp.writeBack(b); err != nil {
+				return nil, 0, err
+			}
+			if !written[b.key.file] {
+				written[b.key.file] = true
+				files = append(files, b.key.file)
+			}
+		}
+	}
+
+	return files, oldest, nil
+}
+
+// Discard drops a page from the pool without writing it, as when the page has been freed.
+func (bp *BufferPool) Discard(df *DataFile, no PageNo) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	b := bp.buffers[bufferKey{df, no}]
+	if b == nil {
+		return nil
+	}
+	if b.pins > 0 {
+		return fmt.Errorf("%v: page %v is pinned", df.Path(), no)
+	}
+
+	bp.drop(b)
+	return nil
+}
+
+// Evict writes every dirty page of the datafile and drops all its pages from the pool, as when the
+// file is taken offline or closed.
+func (bp *BufferPool) Evict(df *DataFile) error {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	for _, b := range bp.buffers {
+		if b.key.file == df && b.resident && b.pins > 0 {
+			return fmt.Errorf("%v: page %v is pinned", df.Path(), b.key.no)
+		}
+	}
+	for _, b := range bp.buffers {
+		if b.key.file != df {
+			continue
+		}
+		if b.resident && b.dirty {
+			if err := bp.writeBack(b); err != nil {
+				return err
+			}
+		}
+		bp.drop(b)
+	}
+
+	return nil
+}
+
+// Stats returns the counters and what the pool holds now.
+func (bp *BufferPool) Stats() BufferStats {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	stats := bp.stats
+	stats.Capacity = bp.capacity
+	stats.Resident = bp.resident
+	for _, b := range bp.buffers {
+		if b.resident && b.pins > 0 {
+			stats.Pinned++
+		}
+		if b.resident && b.dirty {
+			stats.Dirty++
+		}
+	}
+	return stats
+}
+
+// ResetStats sets the counters back to zero.
+func (bp *BufferPool) ResetStats() {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.stats = BufferStats{}
+}
+
+// hit moves a resident page up after it is used again.
+func (bp *BufferPool) hit(b *buffer) {
+	switch {
+	case b.lir:
+		bp.toTop(b)
+		bp.prune()
+
+	case b.inStack != nil:
+		// used again while its last use is still on the stack: it joins the LIR set
+		bp.queue.Remove(b.inQueue)
+		b.inQueue = nil
+		b.lir = true
+		bp.lirCount++
+		bp.toTop(b)
+		bp.demoteBottom()
+
+	default:
+		bp.toTop(b)
+		bp.queue.MoveToBack(b.inQueue)
+	}
+}
+
+// load puts a page into the pool and pins it. The page is nil for one that is about to be read.
+func (bp *BufferPool) load(key bufferKey, page *Page) (*buffer, error) {
+	if err := bp.makeRoom(); err != nil {
+		return nil, err
+	}
+
+	// what the pool remembers of the page; making room may have made it forget
+	b := bp.buffers[key]
+	if b == nil {
+		b = &buffer{key: key}
+		bp.buffers[key] = b
+	}
+	b.page = page
+	b.resident = true
+	b.pins = 1
+	b.dirty = false
+	bp.resident++
+
+	switch {
+	case bp.lirCount < bp.lirCapacity:
+		// while the pool fills up every page is LIR
+		b.lir = true
+		bp.lirCount++
+		bp.toTop(b)
+
+	case b.inStack != nil:
+		// its last use is still on the stack, so it was reused within the LIR span
+		b.lir = true
+		bp.lirCount++
+		bp.toTop(b)
+		bp.demoteBottom()
+
+	default:
+		bp.toTop(b)
+		b.inQueue = bp.queue.PushBack(b)
+	}
+
+	bp.trimHistory()
+
+	return b, nil
+}
+
+// makeRoom evicts a page if the pool is full: the first unpinned resident HIR page, or failing
+// that the least recent unpinned LIR page.
+func (bp *BufferPool) makeRoom() error {
+	if bp.resident < bp.capacity {
+		return nil
+	}
+
+	var victim *buffer
+	for e := bp.queue.Front(); e != nil && victim == nil; e = e.Next() {
+		if b := e.Value.(*buffer); b.pins == 0 {
+			victim = b
+		}
+	}
+	for e := bp.stack.Back(); e != nil && victim == nil; e = e.Prev() {
+		if b := e.Value.(*buffer); b.lir && b.pins == 0 {
+			victim = b
+		}
+	}
+	if victim == nil {
+		return ErrBufferPoolFull
+	}
+
+	if victim.dirty {
+		if err := bp.writeBack(victim); err != nil {
+			return err
+		}
+	}
+
+	bp.stats.Evictions++
+	bp.resident--
+	victim.page = nil
+	victim.resident = false
+
+	if victim.inQueue != nil {
+		bp.queue.Remove(victim.inQueue)
+		victim.inQueue = nil
+	}
+	if victim.lir {
+		victim.lir = false
+		bp.lirCount--
+	}
+
+	// an evicted page is only remembered while its recency is on the stack
+	if victim.inStack == nil {
+		delete(bp.buffers, victim.key)
+	}
+	bp.prune()
+
+	return nil
+}
+
+func (bp *BufferPool) writeBack(b *buffer) error {
+	// the log goes first, so every change on disk can be found in it
+	if bp.redo != nil {
+		if err := bp.redo.Flush(b.page.LSN()); err != nil {
+			return err
+		}
+	}
+	if err := b.key.file.Write(b.page); err != nil {
+		return err
+	}
+	b.dirty = false
+	bp.stats.WriteBacks++
+	return nil
+}
+
+func (bp *BufferPool) toTop(b *buffer) {
+	if b.inStack != nil {
+		bp.stack.MoveToFront(b.inStack)
+	} else {
+		b.inStack = bp.stack.PushFront(b)
+	}
+}
+
+// demoteBottom turns the least recent LIR page into a resident HIR page, now that another page
+// has joined the LIR set.
+func (bp *BufferPool) demoteBottom() {
+	if bp.lirCount <= bp.lirCapacity {
+		return
+	}
+	bottom := bp.stack.Back().Value.(*buffer)
+	bottom.lir = false
+	bp.lirCount--
+	bp.stack.Remove(bottom.inStack)
+	bottom.inStack = nil
+	bottom.inQueue = bp.queue.PushBack(bottom)
+	bp.prune()
+}
+
+// prune removes HIR pages from the bottom of the stack, so the bottom is an LIR page.
+func (bp *BufferPool) prune() {
+	for e := bp.stack.Back(); e != nil; e = bp.stack.Back() {
+		b := e.Value.(*buffer)
+		if b.lir {
+			return
+		}
+		bp.stack.Remove(e)
+		b.inStack = nil
+		if !b.resident {
+			delete(bp.buffers, b.key)
+		}
+	}
+}
+
+// trimHistory bounds how many evicted pages the stack remembers to the capacity of the pool.
+func (bp *BufferPool) trimHistory() {
+	for len(bp.buffers)-bp.resident > bp.capacity {
+		for e := bp.stack.Back(); e != nil; e = e.Prev() {
+			if b := e.Value.(*buffer); !b.resident {
+				bp.remove(b)
+				break
+			}
+		}
+	}
+}
+
+// drop takes a page out of the pool without writing it.
+func (bp *BufferPool) drop(b *buffer) {
+	if b.resident {
+		bp.resident--
+	}
+	if b.lir {
+		bp.lirCount--
+	}
+	bp.remove(b)
+}
+
+// remove forgets a page entirely.
+func (bp *BufferPool) remove(b *buffer) {
+	if b.inStack != nil {
+		bp.stack.Remove(b.inStack)
+		b.inStack = nil
+	}
+	if b.inQueue != nil {
+		bp.queue.Remove(b.inQueue)
+		b.inQueue = nil
+	}
+	delete(bp.buffers, b.key)
+	bp.prune()
+}
@@ -0,0 +1,242 @@
+package store
+
+import (
+	"math/rand"
+	"sync"
+	"testing"
+)
+
+// pagesOf allocates n data pages and returns their numbers.
+func pagesOf(t *testing.T, df *DataFile, n int) []PageNo {
+	var pages []PageNo
+	for i := 0; i < n; i++ {
+		p, e := df.Allocate(PageData)
+		if e != nil {
+			t.Fatal(e)
+		}
+		p.Body()[0] = byte(i)
+		if e = df.Write(p); e != nil {
+			t.Fatal(e)
+		}
+		pages = append(pages, p.No)
+	}
+	return pages
+}
+
+func touch(t *testing.T, bp *BufferPool, df *DataFile, no PageNo) *Page {
+	p, e := bp.Get(df, no)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = bp.Unpin(df, no, false); e != nil {
+		t.Fatal(e)
+	}
+	return p
+}
+
+func TestBufferPoolHits(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pages := pagesOf(t, df, 3)
+	bp := NewBufferPool(10)
+
+	for i := 0; i < 3; i++ {
+		for j, no := range pages {
+			if p := touch(t, bp, df, no); p.Body()[0] != byte(j) {
+				t.Errorf("page %v has the wrong contents", no)
+			}
+		}
+	}
+
+	stats := bp.Stats()
+	if stats.Misses != 3 || stats.Hits != 6 || stats.Resident != 3 || stats.Pinned != 0 || stats.HitRatio() < 0.66 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	bp.ResetStats()
+	if stats = bp.Stats(); stats.Hits != 0 || stats.Misses != 0 || stats.Resident != 3 {
+		t.Errorf("unexpected stats after reset %+v", stats)
+	}
+}
+
+// A single scan over many pages must not push the pages that are used again and again out of the
+// pool, as it would with LRU.
+func TestBufferPoolScanResistance(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pages := pagesOf(t, df, 200)
+	hot, cold := pages[:5], pages[5:]
+	bp := NewBufferPool(10)
+
+	for i := 0; i < 3; i++ {
+		for _, no := range hot {
+			touch(t, bp, df, no)
+		}
+	}
+
+	for _, no := range cold {
+		touch(t, bp, df, no)
+	}
+
+	bp.ResetStats()
+	for _, no := range hot {
+		touch(t, bp, df, no)
+	}
+
+	if stats := bp.Stats(); stats.Hits != uint64(len(hot)) || stats.Resident > 10 {
+		t.Errorf("hot pages were evicted by the scan: %+v", stats)
+	}
+}
+
+// A page used twice while its earlier use is still on the stack becomes an LIR page and stays.
+func TestBufferPoolPromotion(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pages := pagesOf(t, df, 30)
+	bp := NewBufferPool(4)
+
+	// fill the LIR set with pages 0-2, then use page 3 twice in a row while it is HIR
+	for _, no := range pages[:4] {
+		touch(t, bp, df, no)
+	}
+	touch(t, bp, df, pages[3])
+
+	for _, no := range pages[10:] {
+		touch(t, bp, df, no)
+	}
+
+	bp.ResetStats()
+	touch(t, bp, df, pages[3])
+	if stats := bp.Stats(); stats.Hits != 1 {
+		t.Error("expected the promoted page to stay in the pool")
+	}
+}
+
+func TestBufferPoolPinsAndDirtyPages(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pages := pagesOf(t, df, 4)
+	bp := NewBufferPool(2)
+
+	p, _ := bp.Get(df, pages[0])
+	p.Body()[0] = 99
+	if _, e := bp.Get(df, pages[1]); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e := bp.Get(df, pages[2]); e != ErrBufferPoolFull {
+		t.Errorf("expected a full pool, got %v", e)
+	}
+	if e := bp.Unpin(df, pages[3], false); e == nil {
+		t.Error("expected error unpinning a page that is not pinned")
+	}
+
+	if e := bp.Unpin(df, pages[0], true); e != nil {
+		t.Fatal(e)
+	}
+	if stats := bp.Stats(); stats.Dirty != 1 || stats.Pinned != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	// the dirty page is written when it is evicted
+	touch(t, bp, df, pages[2])
+	if onDisk, _ := df.Read(pages[0]); onDisk.Body()[0] != 99 {
+		t.Error("dirty page not written back on eviction")
+	}
+
+	if e := bp.Unpin(df, pages[1], false); e != nil {
+		t.Fatal(e)
+	}
+
+	// Flush writes pages that stay in the pool
+	p, _ = bp.Get(df, pages[1])
+	p.Body()[0] = 77
+	_ = bp.Unpin(df, pages[1], true)
+	if e := bp.Flush(df); e != nil {
+		t.Fatal(e)
+	}
+	if onDisk, _ := df.Read(pages[1]); onDisk.Body()[0] != 77 {
+		t.Error("dirty page not flushed")
+	}
+	if stats := bp.Stats(); stats.Dirty != 0 || stats.WriteBacks != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	if p, e := bp.Allocate(df, PageIndex); e != nil || p.Type() != PageIndex {
+		t.Fatal(e)
+	} else if e = bp.Discard(df, p.No); e == nil {
+		t.Error("a pinned page should not be discarded")
+	}
+}
+
+func TestBufferPoolRandom(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pages := pagesOf(t, df, 100)
+	bp := NewBufferPool(16)
+	rnd := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 5000; i++ {
+		// mostly a small working set, sometimes anything
+		n := rnd.Intn(20)
+		if rnd.Intn(4) == 0 {
+			n = rnd.Intn(len(pages))
+		}
+		if p := touch(t, bp, df, pages[n]); p.Body()[0] != byte(n) {
+			t.Fatalf("page %v has the wrong contents", pages[n])
+		}
+		if stats := bp.Stats(); stats.Resident > 16 {
+			t.Fatalf("pool holds %v pages", stats.Resident)
+		}
+		if len(bp.buffers)-bp.resident > 16 || bp.lirCount > bp.lirCapacity {
+			t.Fatalf("pool bookkeeping is off: %v buffers, %v resident, %v LIR", len(bp.buffers), bp.resident, bp.lirCount)
+		}
+	}
+
+	if stats := bp.Stats(); stats.HitRatio() < 0.5 {
+		t.Errorf("hit ratio too low: %+v", stats)
+	}
+}
+
+func TestBufferPoolConcurrentGets(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pages := pagesOf(t, df, 8)
+	bp := NewBufferPool(16)
+
+	// each page is read once, however many ask for it at the same time
+	got := make([][]*Page, 16)
+	var wg sync.WaitGroup
+	for g := range got {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for _, no := range pages {
+				p, e := bp.Get(df, no)
+				if e != nil {
+					t.Error(e)
+					return
+				}
+				got[g] = append(got[g], p)
+				if e = bp.Unpin(df, no, false); e != nil {
+					t.Error(e)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	stats := bp.Stats()
+	if stats.Misses != uint64(len(pages)) || stats.Hits != uint64((len(got)-1)*len(pages)) || stats.Pinned != 0 {
+		t.Errorf("unexpected %+v", stats)
+	}
+	for g := range got {
+		for i, p := range got[g] {
+			if p != got[0][i] || p.Body()[0] != byte(i) {
+				t.Fatalf("page %v differs between readers", pages[i])
+			}
+		}
+	}
+
+	// a read that fails leaves nothing behind
+	if _, e := bp.Get(df, df.PageCount()+10); e == nil {
+		t.Error("expected an error reading past the end of the file")
+	}
+	if after := bp.Stats(); after.Resident != stats.Resident || after.Pinned != 0 {
+		t.Errorf("expected the failed read to leave the pool as it was, got %+v", after)
+	}
+}
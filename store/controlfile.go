@@ -0,0 +1,387 @@
+package store
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sync"
+)
+
+var controlFileMagic = []byte("GODBCTRL")
+
+const controlFileVersion = 1
+
+/*
+The control file records the structure of the database: its identity, the redo log groups, the
+tablespaces and their datafiles, and the SCN of the last checkpoint. The database keeps several
+copies, on different disks, and writes every change to all of them, so that losing one is not
+losing the database. Each copy is written whole, to a new file that then takes the place of the
+old one:
+
+  offset size
+       0    8  "GODBCTRL"
+       8    2  version
+      10    2  reserved
+      12    4  CRC-32C of everything after it
+      16    8  sequence: how many times the control file has been written
+      24    4  length of the body
+      28       the body
+
+The body is a run of uvarints and strings, each string a uvarint length and its bytes:
+
+  DBID, name, checkpoint SCN, highest transaction ID as of the checkpoint, 1 if the redo log is to
+    be made anew when the database opens
+  number of log groups; for each: size, number of members, and the path of each
+  number of tablespaces; for each: name, kind, page size, status, 1 if it is the default of its
+    kind, number of datafiles, and the number and path of each
+
+When the database opens, every copy must be there and be the same. If one is lost or damaged, copy
+a good one over it; if all are, CREATE CONTROLFILE makes the control file again from the datafiles.
+*/
+
+const controlHeader = 28
+
+// ControlData is what the control file records.
+type ControlData struct {
+	DBID          uint64
+	Name          string
+	CheckpointSCN SCN
+	LastTxn       uint64 // the highest transaction ID as of the checkpoint
+	ResetLogs     bool   // the redo log is to be made anew when the database opens
+	LogGroups     []LogGroup
+	Tablespaces   []TablespaceInfo
+}
+
+// ControlFile keeps the copies of the control file.
+type ControlFile struct {
+	mu    sync.Mutex
+	paths []string
+	seq   uint64
+	data  ControlData
+}
+
+// NewDBID makes a random database ID.
+func NewDBID() uint64 {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	// 0 stands for no database
+	return binary.LittleEndian.Uint64(b[:]) | 1
+}
+
+// CreateControlFile writes a new control file to every path. Unless reuse is true, none of them may
+// exist yet.
+func CreateControlFile(paths []string, data ControlData, reuse bool) (*ControlFile, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one control file is needed")
+	}
+	if !reuse {
+		for _, path := range paths {
+			if _, err := os.Stat(path); err == nil {
+				return nil, fmt.Errorf("control file %v already exists", path)
+			}
+		}
+	}
+
+	cf := &ControlFile{paths: paths}
+	if err := cf.write(data); err != nil {
+		return nil, err
+	}
+	return cf, nil
+}
+
+// OpenControlFile reads the control file, and checks that every copy is there and the same.
+func OpenControlFile(paths []string) (*ControlFile, error) {
+	if len(paths) == 0 {
+		return nil, errors.New("at least one control file is needed")
+	}
+
+	var first []byte
+	for i, path := range paths {
+		raw, err := readControlCopy(path)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			first = raw
+			continue
+		}
+		if !bytes.Equal(raw, first) {
+			return nil, fmt.Errorf("control file %v is at sequence %v, and %v at %v; they must be the same",
+				path, binary.LittleEndian.Uint64(raw[16:]), paths[0], binary.LittleEndian.Uint64(first[16:]))
+		}
+	}
+
+	data, err := decodeControl(first[controlHeader:])
+	if err != nil {
+		return nil, fmt.Errorf("control file %v: %w", paths[0], err)
+	}
+
+	return &ControlFile{paths: paths, seq: binary.LittleEndian.Uint64(first[16:]), data: *data}, nil
+}
+
+// RebuildControlFile makes the control file again from the datafiles, when every copy is lost. It
+// needs every datafile of the database, and the log groups, which the datafiles do not know about.
+// The tablespaces are as the datafiles record them, and the checkpoint is the latest of theirs.
+func RebuildControlFile(paths []string, name string, groups []LogGroup, resetLogs bool, datafiles []string, reuse bool) (*ControlFile, error) {
+	if len(datafiles) == 0 {
+		return nil, errors.New("at least one datafile is needed")
+	}
+
+	data := ControlData{Name: name, ResetLogs: resetLogs, LogGroups: groups}
+	byName := make(map[string]int)
+	numbers := make(map[FileNo]string)
+
+	for _, path := range datafiles {
+		df, err := OpenDataFile(path)
+		if err != nil {
+			return nil, err
+		}
+		owner, no, pageSize, status := df.Owner(), df.No(), df.PageSize(), df.Status()
+		checkpoint, lastTxn := df.Checkpoint()
+		_ = df.Close()
+
+		switch {
+		case owner.DBID == 0 || owner.Tablespace == "":
+			return nil, fmt.Errorf("%v does not belong to a tablespace", path)
+		case data.DBID != 0 && owner.DBID != data.DBID:
+			return nil, fmt.Errorf("%v belongs to another database than %v", path, datafiles[0])
+		case numbers[no] != "":
+			return nil, fmt.Errorf("%v and %v are both datafile %v", numbers[no], path, no)
+		}
+		data.DBID = owner.DBID
+		numbers[no] = path
+		if checkpoint > data.CheckpointSCN {
+			data.CheckpointSCN = checkpoint
+		}
+		if lastTxn > data.LastTxn {
+			data.LastTxn = lastTxn
+		}
+
+		i, ok := byName[owner.Tablespace]
+		if !ok {
+			i = len(data.Tablespaces)
+			byName[owner.Tablespace] = i
+			data.Tablespaces = append(data.Tablespaces, TablespaceInfo{
+				Name:     owner.Tablespace,
+				Kind:     owner.Kind,
+				PageSize: pageSize,
+				Status:   status,
+				Default:  owner.Default,
+			})
+		}
+		ts := &data.Tablespaces[i]
+		if ts.Kind != owner.Kind || ts.PageSize != pageSize {
+			return nil, fmt.Errorf("%v does not agree with the other datafiles of tablespace %v", path, ts.Name)
+		}
+		ts.Files = append(ts.Files, DataFileInfo{no, path})
+	}
+
+	return CreateControlFile(paths, data, reuse)
+}
+
+// Data returns a copy of what the control file records.
+func (cf *ControlFile) Data() ControlData {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	data, _ := decodeControl(encodeControl(&cf.data))
+	return *data
+}
+
+// Sequence is how many times the control file has been written.
+func (cf *ControlFile) Sequence() uint64 {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.seq
+}
+
+// Update changes what the control file records, and writes it to every copy.
+func (cf *ControlFile) Update(change func(data *ControlData)) error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+
+	data, _ := decodeControl(encodeControl(&cf.data))
+	change(data)
+	return cf.write(*data)
+}
+
+// write writes the next sequence of the control file to every copy.
+func (cf *ControlFile) write(data ControlData) error {
+	body := encodeControl(&data)
+	raw := make([]byte, controlHeader, controlHeader+len(body))
+	copy(raw, controlFileMagic)
+	binary.LittleEndian.PutUint16(raw[8:], controlFileVersion)
+	binary.LittleEndian.PutUint64(raw[16:], cf.seq+1)
+	binary.LittleEndian.PutUint32(raw[24:], uint32(len(body)))
+	raw = append(raw, body...)
+	binary.LittleEndian.PutUint32(raw[12:], crc32.Checksum(raw[16:], crcTable))
+
+	for _, path := range cf.paths {
+		if err := writeControlCopy(path, raw); err != nil {
+			return fmt.Errorf("control file %v: %w", path, err)
+		}
+	}
+
+	cf.seq++
+	cf.data = data
+	return nil
+}
+
+// writeControlCopy writes a copy to a new file and moves it into place, so a crash leaves either
+// the old copy or the new one.
+func writeControlCopy(path string, raw []byte) error {
+	tmp := path + ".new"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(raw)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+	}
+	return err
+}
+
+// readControlCopy reads a copy and checks its checksum.
+func readControlCopy(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < controlHeader || !bytes.Equal(raw[:8], controlFileMagic) {
+		return nil, fmt.Errorf("%v: not a control file", path)
+	}
+	if version := binary.LittleEndian.Uint16(raw[8:]); version != controlFileVersion {
+		return nil, fmt.Errorf("%v: unsupported control file version %v", path, version)
+	}
+	length := int(binary.LittleEndian.Uint32(raw[24:]))
+	if len(raw) != controlHeader+length || crc32.Checksum(raw[16:], crcTable) != binary.LittleEndian.Uint32(raw[12:]) {
+		return nil, fmt.Errorf("%v: control file is damaged", path)
+	}
+	return raw, nil
+}
+
+func encodeControl(data *ControlData) []byte {
+	var b []byte
+	str := func(s string) {
+		b = binary.AppendUvarint(b, uint64(len(s)))
+		b = append(b, s...)
+	}
+	flag := func(f bool) {
+		if f {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+	}
+
+	b = binary.AppendUvarint(b, data.DBID)
+	str(data.Name)
+	b = binary.AppendUvarint(b, data.CheckpointSCN)
+	b = binary.AppendUvarint(b, data.LastTxn)
+	flag(data.ResetLogs)
+
+	b = binary.AppendUvarint(b, uint64(len(data.LogGroups)))
+	for _, group := range data.LogGroups {
+		b = binary.AppendUvarint(b, uint64(group.Size))
+		b = binary.AppendUvarint(b, uint64(len(group.Members)))
+		for _, member := range group.Members {
+			str(member)
+		}
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(data.Tablespaces)))
+	for _, ts := range data.Tablespaces {
+		str(ts.Name)
+		b = binary.AppendUvarint(b, uint64(ts.Kind))
+		b = binary.AppendUvarint(b, uint64(ts.PageSize))
+		b = binary.AppendUvarint(b, uint64(ts.Status))
+		flag(ts.Default)
+		b = binary.AppendUvarint(b, uint64(len(ts.Files)))
+		for _, file := range ts.Files {
+			b = binary.AppendUvarint(b, uint64(file.No))
+			str(file.Path)
+		}
+	}
+
+	return b
+}
+
+var errBadControl = errors.New("control file body is damaged")
+
+func decodeControl(b []byte) (*ControlData, error) {
+	bad := false
+	num := func() uint64 {
+		n, size := binary.Uvarint(b)
+		if size <= 0 {
+			bad = true
+			b = nil
+			return 0
+		}
+		b = b[size:]
+		return n
+	}
+	count := func() int {
+		// every element takes a byte at least
+		n := num()
+		if n > uint64(len(b)) {
+			bad = true
+			return 0
+		}
+		return int(n)
+	}
+	str := func() string {
+		n := count()
+		s := string(b[:n])
+		b = b[n:]
+		return s
+	}
+
+	data := &ControlData{}
+	data.DBID = num()
+	data.Name = str()
+	data.CheckpointSCN = num()
+	data.LastTxn = num()
+	data.ResetLogs = num() == 1
+
+	for i, n := 0, count(); i < n && !bad; i++ {
+		group := LogGroup{Size: int64(num())}
+		for j, m := 0, count(); j < m && !bad; j++ {
+			group.Members = append(group.Members, str())
+		}
+		data.LogGroups = append(data.LogGroups, group)
+	}
+
+	for i, n := 0, count(); i < n && !bad; i++ {
+		ts := TablespaceInfo{Name: str()}
+		ts.Kind = TablespaceKind(num())
+		ts.PageSize = int(num())
+		ts.Status = FileStatus(num())
+		ts.Default = num() == 1
+		for j, m := 0, count(); j < m && !bad; j++ {
+			file := DataFileInfo{No: FileNo(num())}
+			file.Path = str()
+			ts.Files = append(ts.Files, file)
+		}
+		data.Tablespaces = append(data.Tablespaces, ts)
+	}
+
+	if bad || len(b) != 0 {
+		return nil, errBadControl
+	}
+	return data, nil
+}
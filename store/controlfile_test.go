@@ -0,0 +1,130 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestControlFile(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "control1.ctl"), filepath.Join(dir, "control2.ctl")}
+	data := ControlData{
+		DBID:          NewDBID(),
+		Name:          "ORCL",
+		CheckpointSCN: 17,
+		LastTxn:       5,
+		LogGroups: []LogGroup{
+			{Members: []string{"/redo/1a.log", "/redo/1b.log"}, Size: MinLogSize},
+			{Members: []string{"/redo/2a.log", "/redo/2b.log"}, Size: MinLogSize},
+		},
+		Tablespaces: []TablespaceInfo{
+			{Name: "SYSTEM", PageSize: DefaultPageSize, Files: []DataFileInfo{{1, "/data/system.dbf"}}},
+			{Name: "USERS", PageSize: MinPageSize, Status: FileReadOnly, Default: true,
+				Files: []DataFileInfo{{2, "/data/users1.dbf"}, {3, "/data/users2.dbf"}}},
+			{Name: "UNDO", Kind: UndoTablespace, PageSize: DefaultPageSize, Default: true, Files: []DataFileInfo{{4, "/data/undo.dbf"}}},
+		},
+	}
+
+	cf, e := CreateControlFile(paths, data, false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = CreateControlFile(paths, data, false); e == nil {
+		t.Error("expected an error creating a control file that exists")
+	}
+
+	if e = cf.Update(func(d *ControlData) { d.CheckpointSCN = 99 }); e != nil {
+		t.Fatal(e)
+	}
+	if cf, e = OpenControlFile(paths); e != nil {
+		t.Fatal(e)
+	}
+	data.CheckpointSCN = 99
+	if got := cf.Data(); !reflect.DeepEqual(got, data) || cf.Sequence() != 2 {
+		t.Errorf("expected %+v at sequence 2, got %+v at %v", data, got, cf.Sequence())
+	}
+
+	// a copy left behind by a failed write is caught
+	stale, _ := os.ReadFile(paths[1])
+	_ = cf.Update(func(d *ControlData) { d.CheckpointSCN = 100 })
+	_ = os.WriteFile(paths[1], stale, 0600)
+	if _, e = OpenControlFile(paths); e == nil {
+		t.Error("expected an error opening copies that differ")
+	}
+
+	// so is a damaged one, and a missing one
+	raw, _ := os.ReadFile(paths[0])
+	raw[40] ^= 0xFF
+	_ = os.WriteFile(paths[1], raw, 0600)
+	if _, e = OpenControlFile(paths); e == nil {
+		t.Error("expected an error opening a damaged copy")
+	}
+	_ = os.Remove(paths[1])
+	if _, e = OpenControlFile(paths); e == nil {
+		t.Error("expected an error opening a missing copy")
+	}
+}
+
+func TestRebuildControlFile(t *testing.T) {
+	s, dir := newTablespaces(t)
+	file := func(name string) DataFileSpec { return DataFileSpec{Path: filepath.Join(dir, name), Next: MinPageSize} }
+
+	_, _ = s.Create("SYSTEM", PermanentTablespace, MinPageSize, []DataFileSpec{file("system.dbf")})
+	users, _ := s.Create("USERS", PermanentTablespace, MinPageSize, []DataFileSpec{file("users1.dbf"), file("users2.dbf")})
+	undo, e := s.Create("UNDO", UndoTablespace, MinPageSize, []DataFileSpec{file("undo.dbf")})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_ = s.SetDefault("USERS")
+	_ = s.SetDefault("UNDO")
+	_ = users.SetStatus(FileReadOnly)
+	_ = undo.DataFiles()[0].SetCheckpoint(12, 30)
+
+	groups := []LogGroup{{Members: []string{"a.log"}, Size: MinLogSize}, {Members: []string{"b.log"}, Size: MinLogSize}}
+	before := ControlData{DBID: 42, Name: "ORCL", CheckpointSCN: 12, LastTxn: 30, LogGroups: groups, Tablespaces: s.Info()}
+	if e = s.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	var datafiles []string
+	for _, name := range []string{"system.dbf", "users1.dbf", "users2.dbf", "undo.dbf"} {
+		datafiles = append(datafiles, filepath.Join(dir, name))
+	}
+	control := []string{filepath.Join(dir, "control.ctl")}
+	cf, e := RebuildControlFile(control, "ORCL", groups, false, datafiles, false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if after := cf.Data(); !reflect.DeepEqual(after, before) {
+		t.Errorf("expected %+v, got %+v", before, after)
+	}
+
+	// the tablespaces open from what it records
+	reopened, e := OpenTablespaces(NewBufferPool(8), 42, cf.Data().Tablespaces)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer reopened.Close()
+	if ts := reopened.Default(PermanentTablespace); ts == nil || ts.Name() != "USERS" || ts.Status() != FileReadOnly {
+		t.Error("expected USERS as the read only default tablespace")
+	}
+	if _, e = reopened.Create("MORE", PermanentTablespace, 0, []DataFileSpec{file("more.dbf")}); e != nil {
+		t.Fatal(e)
+	}
+	if df := reopened.byName["MORE"].files[0]; df.No() != 5 {
+		t.Errorf("expected the next datafile to be 5, got %v", df.No())
+	}
+
+	// every datafile must belong to the same database
+	other, _ := CreateDataFile(filepath.Join(dir, "other.dbf"), 9, MinPageSize)
+	_ = other.SetOwner(FileOwner{DBID: 7, Tablespace: "USERS"})
+	_ = other.Close()
+	if _, e = RebuildControlFile(control, "ORCL", groups, false, append(datafiles, other.Path()), true); e == nil {
+		t.Error("expected an error rebuilding from another database's datafile")
+	}
+	if _, e = OpenTablespaces(NewBufferPool(8), 43, cf.Data().Tablespaces); e == nil {
+		t.Error("expected an error opening the datafiles of another database")
+	}
+}
@@ -0,0 +1,388 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+/*
+A database is its control file, its redo log, and its tablespaces. CreateDatabase makes all of them;
+OpenDatabase opens them from the control file, replays the redo log into the datafiles, checks that
+everything belongs together, and rolls back the transactions that a crash cut short.
+
+Every database has the SYSTEM tablespace, which holds the dictionary, and an undo tablespace. The
+dictionary is the first segment made in the database, so it is a heap that always starts at the
+same page: the first after the free-space map in the first datafile of SYSTEM. What goes in it is up
+to the caller; it is where the rest of the catalog is found from.
+
+At a checkpoint every datafile and the control file record the SCN and the highest transaction ID
+reached. When the database opens again, transaction IDs carry on from the highest of those and of
+the transactions in the redo log, so that a new transaction is never taken for one whose changes are
+already in the datafiles.
+*/
+
+// SystemTablespace is the tablespace that holds the dictionary.
+const SystemTablespace = "SYSTEM"
+
+const dictionaryPage PageNo = 2
+
+// MinUndoPages is the least the undo takes, however small its datafile starts.
+const MinUndoPages = 64
+
+// TablespaceSpec says what to call a tablespace made along with the database, and its datafiles.
+type TablespaceSpec struct {
+	Name  string
+	Files []DataFileSpec
+}
+
+// DatabaseSpec says how to make a database.
+type DatabaseSpec struct {
+	Name         string
+	ControlFiles []string
+	LogGroups    []LogGroup
+	System       []DataFileSpec // the datafiles of SYSTEM
+	Default      TablespaceSpec // where tables and indexes go unless told otherwise; SYSTEM if there is no name
+	Temp         TablespaceSpec // where temporary work goes; none if there is no name
+	Undo         TablespaceSpec // its first datafile holds the undo, which takes all of the file as it is made
+	PoolPages    int            // the size of the buffer pool
+}
+
+// Database is an open database.
+type Database struct {
+	name         string
+	dbid         uint64
+	pool         *BufferPool
+	control      *ControlFile
+	redo         *RedoLog
+	tablespaces  *Tablespaces
+	transactions *Transactions
+	dictionary   *Heap
+}
+
+// CreateDatabase makes a new database. None of its files may exist yet. If it fails, whatever it
+// made is deleted again.
+func CreateDatabase(spec DatabaseSpec) (*Database, error) {
+	switch {
+	case len(spec.ControlFiles) == 0:
+		return nil, errors.New("at least one control file is needed")
+	case len(spec.System) == 0:
+		return nil, fmt.Errorf("the %v tablespace needs at least one datafile", SystemTablespace)
+	case spec.Undo.Name == "":
+		return nil, errors.New("an undo tablespace is needed")
+	}
+	for _, path := range spec.ControlFiles {
+		if _, err := os.Stat(path); err == nil {
+			return nil, fmt.Errorf("control file %v already exists", path)
+		}
+	}
+
+	db := &Database{name: spec.Name, dbid: NewDBID(), pool: NewBufferPool(spec.PoolPages)}
+	var err error
+	if db.redo, err = CreateRedoLog(spec.LogGroups); err != nil {
+		return nil, err
+	}
+	db.pool.SetRedoLog(db.redo)
+	db.redo.SetCheckpointer(db.writeChanges)
+	db.tablespaces = NewTablespaces(db.pool, db.dbid)
+
+	if err = db.create(spec); err != nil {
+		made := db.tablespaces.Info()
+		_ = db.Close()
+		for _, ts := range made {
+			for _, file := range ts.Files {
+				_ = os.Remove(file.Path)
+			}
+		}
+		for _, group := range spec.LogGroups {
+			for _, member := range group.Members {
+				_ = os.Remove(member)
+			}
+		}
+		for _, path := range spec.ControlFiles {
+			_ = os.Remove(path)
+		}
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *Database) create(spec DatabaseSpec) error {
+	system, err := db.tablespaces.Create(SystemTablespace, PermanentTablespace, 0, spec.System)
+	if err != nil {
+		return err
+	}
+	if db.dictionary, err = system.CreateHeap(); err != nil {
+		return err
+	}
+	if db.dictionary.First() != dictionaryPage {
+		return fmt.Errorf("the dictionary starts at page %v, not %v", db.dictionary.First(), dictionaryPage)
+	}
+
+	if spec.Default.Name == "" {
+		spec.Default.Name = SystemTablespace
+	} else if _, err = db.tablespaces.Create(spec.Default.Name, PermanentTablespace, 0, spec.Default.Files); err != nil {
+		return err
+	}
+	if spec.Temp.Name != "" {
+		if _, err = db.tablespaces.Create(spec.Temp.Name, TemporaryTablespace, 0, spec.Temp.Files); err != nil {
+			return err
+		}
+	}
+	undoSpace, err := db.tablespaces.Create(spec.Undo.Name, UndoTablespace, 0, spec.Undo.Files)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{spec.Default.Name, spec.Temp.Name, spec.Undo.Name} {
+		if name != "" {
+			if err = db.tablespaces.SetDefault(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	pages, err := unusedPages(undoSpace.DataFiles()[0])
+	if err != nil {
+		return err
+	}
+	// one of them holds the state of the undo
+	if pages--; pages < MinUndoPages {
+		pages = MinUndoPages
+	}
+	undo, err := undoSpace.CreateUndo(pages)
+	if err != nil {
+		return err
+	}
+	db.transactions = NewTransactions(undo)
+	db.transactions.SetRedoLog(db.redo)
+
+	data := ControlData{DBID: db.dbid, Name: db.name, LogGroups: spec.LogGroups, Tablespaces: db.tablespaces.Info()}
+	if db.control, err = CreateControlFile(spec.ControlFiles, data, false); err != nil {
+		return err
+	}
+
+	return db.Checkpoint()
+}
+
+// unusedPages counts the pages of a datafile that are neither in use nor part of the free-space map.
+func unusedPages(df *DataFile) (int, error) {
+	used, err := df.UsedPages()
+	if err != nil {
+		return 0, err
+	}
+	span := PageNo(df.bodySize()) + 1
+	maps := (df.PageCount() - 1 + span - 1) / span
+	return int(df.PageCount()-1-maps) - used, nil
+}
+
+// OpenDatabase opens the database that the control file describes. Every copy of the control file
+// must be the same, and every datafile must be where it says. The redo log is replayed into the
+// datafiles, or made anew if the control file says so.
+func OpenDatabase(controlFiles []string, poolPages int) (*Database, error) {
+	control, err := OpenControlFile(controlFiles)
+	if err != nil {
+		return nil, err
+	}
+	data := control.Data()
+
+	db := &Database{name: data.Name, dbid: data.DBID, pool: NewBufferPool(poolPages), control: control}
+	if db.tablespaces, err = OpenTablespaces(db.pool, data.DBID, data.Tablespaces); err != nil {
+		return nil, err
+	}
+
+	if err = db.open(data); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *Database) open(data ControlData) error {
+	var err error
+	var committed map[uint64]bool
+	lastTxn := data.LastTxn
+
+	if data.ResetLogs {
+		for _, group := range data.LogGroups {
+			for _, member := range group.Members {
+				if err = os.Remove(member); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+		}
+		if db.redo, err = CreateRedoLog(data.LogGroups); err != nil {
+			return err
+		}
+		if err = db.control.Update(func(d *ControlData) { d.ResetLogs = false }); err != nil {
+			return err
+		}
+	} else {
+		if db.redo, err = OpenRedoLog(data.LogGroups); err != nil {
+			return err
+		}
+		files := make(map[FileNo]*DataFile)
+		for _, df := range db.dataFiles() {
+			files[df.No()] = df
+		}
+		if committed, err = db.redo.Recover(files); err != nil {
+			return err
+		}
+		if db.redo.LastTxn() > lastTxn {
+			lastTxn = db.redo.LastTxn()
+		}
+	}
+	db.pool.SetRedoLog(db.redo)
+	db.redo.SetCheckpointer(db.writeChanges)
+
+	system, err := db.tablespaces.Get(SystemTablespace)
+	if err != nil {
+		return err
+	}
+	if db.dictionary, err = OpenHeap(db.pool, system.DataFiles()[0], dictionaryPage); err != nil {
+		return err
+	}
+
+	undoSpace := db.tablespaces.Default(UndoTablespace)
+	if undoSpace == nil {
+		return errors.New("the database has no undo tablespace")
+	}
+	undo, err := OpenUndo(db.pool, undoSpace.DataFiles()[0])
+	if err != nil {
+		return err
+	}
+	db.transactions = NewTransactions(undo)
+	db.transactions.SetRedoLog(db.redo)
+
+	// what the transactions a crash cut short changed is taken back before anything else can see it
+	tables := make(map[tableKey]*Table)
+	err = db.transactions.RollbackCrashed(committed, func(no FileNo, first PageNo) (*Table, error) {
+		if table := tables[tableKey{no, first}]; table != nil {
+			return table, nil
+		}
+		df, err := db.DataFile(no)
+		if err != nil {
+			return nil, err
+		}
+		heap, err := OpenHeap(db.pool, df, first)
+		if err != nil {
+			return nil, err
+		}
+		tables[tableKey{no, first}] = db.transactions.Table(heap)
+		return tables[tableKey{no, first}], nil
+	})
+	if err != nil {
+		return err
+	}
+	db.transactions.Resume(data.CheckpointSCN, lastTxn)
+
+	return db.Checkpoint()
+}
+
+func (db *Database) Name() string {
+	return db.name
+}
+
+func (db *Database) DBID() uint64 {
+	return db.dbid
+}
+
+func (db *Database) Pool() *BufferPool {
+	return db.pool
+}
+
+func (db *Database) ControlFile() *ControlFile {
+	return db.control
+}
+
+func (db *Database) RedoLog() *RedoLog {
+	return db.redo
+}
+
+func (db *Database) Tablespaces() *Tablespaces {
+	return db.tablespaces
+}
+
+func (db *Database) Transactions() *Transactions {
+	return db.transactions
+}
+
+// Dictionary is the heap the catalog is found from.
+func (db *Database) Dictionary() *Heap {
+	return db.dictionary
+}
+
+// Checkpoint writes every change to the datafiles, and records in them and in the control file the
+// SCN and the highest transaction ID reached.
+func (db *Database) Checkpoint() error {
+	files := db.dataFiles()
+	if err := db.redo.Checkpoint(db.pool, files...); err != nil {
+		return err
+	}
+
+	scn, lastTxn := db.transactions.SCN(), db.transactions.LastID()
+	for _, df := range files {
+		if df.Status() == FileOffline {
+			continue
+		}
+		if err := df.SetCheckpoint(scn, lastTxn); err != nil {
+			return err
+		}
+		if err := df.Sync(); err != nil {
+			return err
+		}
+	}
+
+	return db.control.Update(func(data *ControlData) {
+		data.CheckpointSCN, data.LastTxn = scn, lastTxn
+		data.Tablespaces = db.tablespaces.Info()
+	})
+}
+
+// writeChanges writes the changes in the pool to the datafiles, so that the redo log can switch to a
+// group that holds them. It is called in the middle of changes, so unlike Checkpoint it takes no
+// locks other than those of the pool and the log.
+func (db *Database) writeChanges() error {
+	return db.redo.Checkpoint(db.pool)
+}
+
+// Close takes a checkpoint, if the database is far enough along to, and closes its files.
+func (db *Database) Close() error {
+	var first error
+	if db.redo != nil && db.transactions != nil && db.control != nil {
+		first = db.Checkpoint()
+	}
+	if err := db.tablespaces.Close(); err != nil && first == nil {
+		first = err
+	}
+	if db.redo != nil {
+		if err := db.redo.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// DataFile finds a datafile of the database by its number.
+func (db *Database) DataFile(no FileNo) (*DataFile, error) {
+	for _, df := range db.dataFiles() {
+		if df.No() == no {
+			return df, nil
+		}
+	}
+	return nil, fmt.Errorf("there is no datafile %v", no)
+}
+
+// dataFiles returns every datafile of the database.
+func (db *Database) dataFiles() []*DataFile {
+	db.tablespaces.mu.Lock()
+	defer db.tablespaces.mu.Unlock()
+
+	var files []*DataFile
+	for _, ts := range db.tablespaces.byName {
+		files = append(files, ts.DataFiles()...)
+	}
+	return files
+}
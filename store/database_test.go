@@ -0,0 +1,249 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	spec := DatabaseSpec{
+		Name:         "ORCL",
+		ControlFiles: []string{path("control1.ctl"), path("control2.ctl")},
+		LogGroups: []LogGroup{
+			{Members: []string{path("redo1a.log"), path("redo1b.log")}, Size: MinLogSize},
+			{Members: []string{path("redo2a.log"), path("redo2b.log")}, Size: MinLogSize},
+		},
+		System:    []DataFileSpec{{Path: path("system.dbf"), Size: 64 * DefaultPageSize, Next: DefaultPageSize}},
+		Default:   TablespaceSpec{"USERS", []DataFileSpec{{Path: path("users.dbf"), Next: DefaultPageSize}}},
+		Temp:      TablespaceSpec{"TEMP", []DataFileSpec{{Path: path("temp.dbf"), Next: DefaultPageSize}}},
+		Undo:      TablespaceSpec{"UNDO", []DataFileSpec{{Path: path("undo.dbf"), Size: 256 * DefaultPageSize}}},
+		PoolPages: 64,
+	}
+
+	db, e := CreateDatabase(spec)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if ts := db.Tablespaces().Default(PermanentTablespace); ts == nil || ts.Name() != "USERS" {
+		t.Error("expected USERS to be the default tablespace")
+	}
+	if ts := db.Tablespaces().Default(TemporaryTablespace); ts == nil || ts.Name() != "TEMP" {
+		t.Error("expected TEMP to be the temporary tablespace")
+	}
+
+	// a row in the dictionary, committed by a transaction
+	txns := db.Transactions()
+	table := txns.Table(db.Dictionary())
+	txn := txns.Begin(ReadCommitted)
+	id, e := table.Insert(txn, EncodeRow([][]byte{[]byte("SYS")}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	scn, e := txn.Commit()
+	if e != nil {
+		t.Fatal(e)
+	}
+	last := txns.LastID()
+	if e = db.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e = CreateDatabase(spec); e == nil {
+		t.Error("expected an error creating a database over another")
+	}
+	for _, name := range []string{"control1.ctl", "redo1a.log", "system.dbf", "users.dbf", "undo.dbf"} {
+		if _, e = os.Stat(path(name)); e != nil {
+			t.Errorf("expected %v to be left alone: %v", name, e)
+		}
+	}
+
+	if db, e = OpenDatabase(spec.ControlFiles, 64); e != nil {
+		t.Fatal(e)
+	}
+	defer db.Close()
+	txns = db.Transactions()
+	if txns.SCN() != scn || txns.LastID() != last || db.Name() != "ORCL" {
+		t.Errorf("expected to carry on from SCN %v and transaction %v, got %v and %v", scn, last, txns.SCN(), txns.LastID())
+	}
+	s := txns.Snapshot()
+	defer s.Release()
+	if row, e := txns.Table(db.Dictionary()).Fetch(s, id); e != nil || string(row[len(row)-3:]) != "SYS" {
+		t.Errorf("expected the dictionary row, got %q, %v", row, e)
+	}
+	if txn = txns.Begin(ReadCommitted); txn.ID <= last {
+		t.Errorf("expected a transaction after %v, got %v", last, txn.ID)
+	}
+	_ = txn.Rollback()
+	if ts := db.Tablespaces().Default(UndoTablespace); ts == nil || ts.Name() != "UNDO" {
+		t.Error("expected UNDO to be the undo tablespace")
+	}
+}
+
+func TestCreateDatabaseFails(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	spec := DatabaseSpec{
+		Name:         "ORCL",
+		ControlFiles: []string{path("control.ctl")},
+		LogGroups: []LogGroup{
+			{Members: []string{path("redo1.log")}, Size: MinLogSize},
+			{Members: []string{path("redo2.log")}, Size: MinLogSize},
+		},
+		System: []DataFileSpec{{Path: path("system.dbf"), Next: DefaultPageSize}},
+		// too small for the undo, and cannot grow
+		Undo: TablespaceSpec{"UNDO", []DataFileSpec{{Path: path("undo.dbf")}}},
+	}
+
+	if _, e := CreateDatabase(spec); e == nil {
+		t.Fatal("expected an error creating the undo")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected every file made to be deleted, found %v", entries)
+	}
+
+	spec.Undo = TablespaceSpec{}
+	if _, e := CreateDatabase(spec); e == nil {
+		t.Error("expected an error creating a database without undo")
+	}
+}
+
+// crash closes the files of a database without writing what the pool holds or taking a checkpoint.
+func crash(db *Database) {
+	for _, df := range db.dataFiles() {
+		_ = df.Close()
+	}
+	for _, g := range db.redo.groups {
+		for _, file := range g.files {
+			if file != nil {
+				_ = file.Close()
+			}
+		}
+	}
+}
+
+func TestDatabaseCrash(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	spec := DatabaseSpec{
+		Name:         "ORCL",
+		ControlFiles: []string{path("control.ctl")},
+		LogGroups: []LogGroup{
+			{Members: []string{path("redo1.log")}, Size: MinLogSize},
+			{Members: []string{path("redo2.log")}, Size: MinLogSize},
+		},
+		System:    []DataFileSpec{{Path: path("system.dbf"), Next: DefaultPageSize}},
+		Undo:      TablespaceSpec{"UNDO", []DataFileSpec{{Path: path("undo.dbf"), Size: 256 * DefaultPageSize}}},
+		PoolPages: 64,
+	}
+
+	db, e := CreateDatabase(spec)
+	if e != nil {
+		t.Fatal(e)
+	}
+	txns := db.Transactions()
+	table := txns.Table(db.Dictionary())
+
+	// more than the log holds, so that it has to take checkpoints of its own to go on
+	var ids []RowID
+	for i := 0; i < 20; i++ {
+		txn := txns.Begin(ReadCommitted)
+		for j := 0; j < 50; j++ {
+			id, e := table.Insert(txn, EncodeRow([][]byte{[]byte(fmt.Sprintf("row %v", len(ids)))}))
+			if e != nil {
+				t.Fatal(e)
+			}
+			ids = append(ids, id)
+		}
+		if _, e = txn.Commit(); e != nil {
+			t.Fatal(e)
+		}
+	}
+	crash(db)
+
+	if db, e = OpenDatabase(spec.ControlFiles, 64); e != nil {
+		t.Fatal(e)
+	}
+	defer db.Close()
+	txns = db.Transactions()
+	s := txns.Snapshot()
+	defer s.Release()
+	table = txns.Table(db.Dictionary())
+	for i, id := range ids {
+		expected := fmt.Sprintf("row %v", i)
+		if row, e := table.Fetch(s, id); e != nil || !strings.HasSuffix(string(row), expected) {
+			t.Fatalf("expected %q, got %q, %v", expected, row, e)
+		}
+	}
+}
+
+func TestDatabaseCrashRollsBack(t *testing.T) {
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	spec := DatabaseSpec{
+		Name:         "ORCL",
+		ControlFiles: []string{path("control.ctl")},
+		LogGroups: []LogGroup{
+			{Members: []string{path("redo1.log")}, Size: MinLogSize},
+			{Members: []string{path("redo2.log")}, Size: MinLogSize},
+		},
+		System:    []DataFileSpec{{Path: path("system.dbf"), Next: DefaultPageSize}},
+		Undo:      TablespaceSpec{"UNDO", []DataFileSpec{{Path: path("undo.dbf"), Size: 256 * DefaultPageSize}}},
+		PoolPages: 64,
+	}
+
+	db, e := CreateDatabase(spec)
+	if e != nil {
+		t.Fatal(e)
+	}
+	txns := db.Transactions()
+	table := txns.Table(db.Dictionary())
+	txn := txns.Begin(ReadCommitted)
+	kept, e := table.Insert(txn, EncodeRow([][]byte{[]byte("kept")}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = txn.Commit(); e != nil {
+		t.Fatal(e)
+	}
+
+	// changes that did not commit, written to the datafiles by a checkpoint before the crash
+	txn = txns.Begin(ReadCommitted)
+	added, e := table.Insert(txn, EncodeRow([][]byte{[]byte("added")}))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = table.Update(txn, kept, EncodeRow([][]byte{[]byte("changed")})); e != nil {
+		t.Fatal(e)
+	}
+	if e = db.Checkpoint(); e != nil {
+		t.Fatal(e)
+	}
+	crash(db)
+
+	if db, e = OpenDatabase(spec.ControlFiles, 64); e != nil {
+		t.Fatal(e)
+	}
+	defer db.Close()
+	txns = db.Transactions()
+	table = txns.Table(db.Dictionary())
+	s := txns.Snapshot()
+	defer s.Release()
+	if row, e := table.Fetch(s, kept); e != nil || !strings.HasSuffix(string(row), "kept") {
+		t.Errorf("expected the committed row, got %q, %v", row, e)
+	}
+	if row, e := table.Fetch(s, added); e != ErrRowNotFound {
+		t.Errorf("expected the row that did not commit to be gone, got %q, %v", row, e)
+	}
+
+	// the row is not locked by the transaction that was rolled back
+	txn = txns.Begin(ReadCommitted)
+	if e = table.Update(txn, kept, EncodeRow([][]byte{[]byte("again")})); e != nil {
+		t.Error(e)
+	}
+	_ = txn.Rollback()
+}
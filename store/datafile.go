@@ -0,0 +1,694 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrCorruptPage is returned, with the file and page, when a page fails its checksum.
+var ErrCorruptPage = errors.New("corrupt page")
+
+// corruptPage is ErrCorruptPage as it is returned, naming the file and page; errors.Is matches them.
+type corruptPage string
+
+func (e corruptPage) Error() string {
+	return string(e)
+}
+
+func (e corruptPage) Is(target error) bool {
+	return target == ErrCorruptPage
+}
+
+var dataFileMagic = []byte("GODBDATA")
+
+const dataFileVersion = 1
+
+/*
+Page 0 of a datafile is its header. The body holds:
+
+  offset size
+       0    8  "GODBDATA"
+       8    2  version
+      10    2  file number, unique in the database
+      12    4  page size
+      16    4  pages to add when the file is full; 0 if it does not grow
+      20    4  most pages the file may grow to; 0 for no limit
+      24    1  status: FileOnline, FileReadOnly or FileOffline
+      25    1  kind of the tablespace the file belongs to
+      26    1  1 if that is the default tablespace of its kind
+      27    8  database ID
+      35    8  SCN of the last checkpoint the file took part in
+      43    8  highest transaction ID as of that checkpoint
+      51    1  length of the tablespace name
+      52       the tablespace name
+
+The file says what it belongs to so that the control file can be made again from the datafiles.
+
+The free-space map has one byte for every page. Page 1 is the first map page; it covers the pages
+that follow it, as many as its body has bytes. The next map page comes right after those, and so
+on, so a map page is always found at the same place and the file can grow one page at a time.
+
+A map byte of 0 means the page is free. Anything else means it is in use, and says roughly how much
+room it has: 1 + 254 * free bytes / body size.
+*/
+
+const fsmFree = 0
+
+const dataFileHeader = 52 + MaxTablespaceName
+
+// MaxTablespaceName is the most bytes a tablespace name may have.
+const MaxTablespaceName = 128
+
+// FileNo identifies a datafile within the database.
+type FileNo = uint16
+
+// FileStatus says whether a datafile can be read and written.
+type FileStatus = uint8
+
+const (
+	FileOnline   FileStatus = iota
+	FileReadOnly            // can be read but not changed
+	FileOffline             // can be neither read nor changed
+)
+
+// DataFile is a file of fixed-size pages.
+type DataFile struct {
+	path     string
+	file     *os.File
+	no       FileNo
+	pageSize int
+
+	mu     sync.Mutex // guards pages, growth and the free-space map
+	pages  PageNo     // number of pages in the file
+	next   PageNo     // pages to add when full
+	max    PageNo     // most pages to grow to; 0 for no limit
+	fsm    map[PageNo]*Page
+	status atomic.Uint32
+
+	owner      FileOwner
+	checkpoint SCN
+	lastTxn    uint64 // as of the checkpoint
+}
+
+// FileOwner says which database and tablespace a datafile belongs to.
+type FileOwner struct {
+	DBID       uint64
+	Tablespace string
+	Kind       TablespaceKind
+	Default    bool // the default tablespace of its kind
+}
+
+// CreateDataFile makes a new datafile with the given number and page size. The file must not exist yet.
+// It grows a page at a time, without limit, until told otherwise with SetAutoextend.
+func CreateDataFile(path string, no FileNo, pageSize int) (*DataFile, error) {
+	if !validPageSize(pageSize) {
+		return nil, fmt.Errorf("invalid page size %v: must be a power of two from %v to %v", pageSize, MinPageSize, MaxPageSize)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	df := &DataFile{path: path, file: file, no: no, pageSize: pageSize, pages: 2, next: 1, fsm: make(map[PageNo]*Page)}
+
+	fsm := newPage(1, pageSize, PageFSM)
+	df.fsm[1] = fsm
+
+	if err = df.writeHeader(); err == nil {
+		err = df.Write(fsm)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+
+	return df, nil
+}
+
+// OpenDataFile opens an existing datafile.
+func OpenDataFile(path string) (*DataFile, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	df, err := openDataFile(path, file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	return df, nil
+}
+
+func openDataFile(path string, file *os.File) (*DataFile, error) {
+	var start [PageHeaderSize + dataFileHeader]byte
+	if _, err := file.ReadAt(start[:], 0); err != nil {
+		return nil, fmt.Errorf("%v: not a datafile: %v", path, err)
+	}
+
+	body := start[PageHeaderSize:]
+	if !bytes.Equal(body[:8], dataFileMagic) {
+		return nil, fmt.Errorf("%v: not a datafile", path)
+	}
+	if version := binary.LittleEndian.Uint16(body[8:]); version != dataFileVersion {
+		return nil, fmt.Errorf("%v: unsupported datafile version %v", path, version)
+	}
+
+	pageSize := int(binary.LittleEndian.Uint32(body[12:]))
+	if !validPageSize(pageSize) {
+		return nil, corruptPage(fmt.Sprintf("%v: page 0: %v", path, ErrCorruptPage))
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.Size()%int64(pageSize) != 0 {
+		return nil, fmt.Errorf("%v: size %v is not a whole number of %v byte pages", path, info.Size(), pageSize)
+	}
+
+	df := &DataFile{
+		path:     path,
+		file:     file,
+		no:       binary.LittleEndian.Uint16(body[10:]),
+		pageSize: pageSize,
+		pages:    PageNo(info.Size() / int64(pageSize)),
+		next:     binary.LittleEndian.Uint32(body[16:]),
+		max:      binary.LittleEndian.Uint32(body[20:]),
+		fsm:      make(map[PageNo]*Page),
+		owner: FileOwner{
+			DBID:    binary.LittleEndian.Uint64(body[27:]),
+			Kind:    TablespaceKind(body[25]),
+			Default: body[26] == 1,
+		},
+		checkpoint: binary.LittleEndian.Uint64(body[35:]),
+		lastTxn:    binary.LittleEndian.Uint64(body[43:]),
+	}
+	df.status.Store(uint32(body[24]))
+	if n := int(body[51]); n <= MaxTablespaceName {
+		df.owner.Tablespace = string(body[52 : 52+n])
+	}
+
+	// the checksum of the header can only be checked once the page size is known, and is checked
+	// even if the file is offline
+	if _, err = df.readPage(0); err != nil {
+		return nil, err
+	}
+
+	return df, nil
+}
+
+func (df *DataFile) Path() string {
+	return df.path
+}
+
+func (df *DataFile) No() FileNo {
+	return df.no
+}
+
+func (df *DataFile) PageSize() int {
+	return df.pageSize
+}
+
+// PageCount is the number of pages in the file, in use or not.
+func (df *DataFile) PageCount() PageNo {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	return df.pages
+}
+
+// Status says whether the file can be read and written.
+func (df *DataFile) Status() FileStatus {
+	return FileStatus(df.status.Load())
+}
+
+// SetStatus makes the file online, read only or offline, and records it in the header.
+func (df *DataFile) SetStatus(status FileStatus) error {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	old := df.Status()
+	df.status.Store(uint32(status))
+	if err := df.writeHeader(); err != nil {
+		df.status.Store(uint32(old))
+		return err
+	}
+	return df.file.Sync()
+}
+
+// Owner says which database and tablespace the file belongs to.
+func (df *DataFile) Owner() FileOwner {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	return df.owner
+}
+
+// SetOwner records which database and tablespace the file belongs to.
+func (df *DataFile) SetOwner(owner FileOwner) error {
+	if len(owner.Tablespace) > MaxTablespaceName {
+		return fmt.Errorf("tablespace name %v is longer than %v bytes", owner.Tablespace, MaxTablespaceName)
+	}
+
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	old := df.owner
+	df.owner = owner
+	if err := df.writeHeader(); err != nil {
+		df.owner = old
+		return err
+	}
+	return nil
+}
+
+// Checkpoint is the SCN of the last checkpoint the file took part in, and the highest transaction
+// ID as of then.
+func (df *DataFile) Checkpoint() (SCN, uint64) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	return df.checkpoint, df.lastTxn
+}
+
+// SetCheckpoint records that everything up to an SCN, and the transactions up to an ID, is written
+// to the file.
+func (df *DataFile) SetCheckpoint(scn SCN, lastTxn uint64) error {
+	if df.Status() == FileOffline {
+		return ErrOffline
+	}
+
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	oldSCN, oldTxn := df.checkpoint, df.lastTxn
+	df.checkpoint, df.lastTxn = scn, lastTxn
+	if err := df.writeHeader(); err != nil {
+		df.checkpoint, df.lastTxn = oldSCN, oldTxn
+		return err
+	}
+	return nil
+}
+
+// Autoextend returns how many pages the file grows by when it is full, 0 if it does not grow, and
+// the most pages it may grow to, 0 for no limit.
+func (df *DataFile) Autoextend() (next, max PageNo) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+	return df.next, df.max
+}
+
+// SetAutoextend sets how many pages the file grows by when it is full, and the most pages it may
+// grow to. A next of 0 stops it growing; a max of 0 lets it grow without limit.
+func (df *DataFile) SetAutoextend(next, max PageNo) error {
+	if err := df.writable(); err != nil {
+		return err
+	}
+
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	oldNext, oldMax := df.next, df.max
+	df.next, df.max = next, max
+	if err := df.writeHeader(); err != nil {
+		df.next, df.max = oldNext, oldMax
+		return err
+	}
+	return nil
+}
+
+// Resize makes the file a number of pages long. It can only shrink by pages that are free.
+func (df *DataFile) Resize(pages PageNo) error {
+	if err := df.writable(); err != nil {
+		return err
+	}
+
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	if pages < 2 {
+		return fmt.Errorf("%v: a datafile cannot be smaller than 2 pages", df.path)
+	}
+
+	for df.pages < pages {
+		if err := df.extend(); err != nil {
+			return err
+		}
+	}
+	if df.pages == pages {
+		return nil
+	}
+
+	for no := pages; no < df.pages; no++ {
+		if _, idx := df.fsmLocation(no); idx < 0 {
+			continue
+		}
+		entry, err := df.entry(no)
+		if err != nil {
+			return err
+		}
+		if entry != fsmFree {
+			return fmt.Errorf("%v: cannot shrink to %v pages: page %v is in use", df.path, pages, no)
+		}
+	}
+
+	if err := df.file.Truncate(int64(pages) * int64(df.pageSize)); err != nil {
+		return err
+	}
+	for fsmNo := range df.fsm {
+		if fsmNo >= pages {
+			delete(df.fsm, fsmNo)
+		}
+	}
+	df.pages = pages
+
+	return nil
+}
+
+// Read reads a page and checks its checksum.
+func (df *DataFile) Read(no PageNo) (*Page, error) {
+	if df.Status() == FileOffline {
+		return nil, ErrOffline
+	}
+	if no >= df.PageCount() {
+		return nil, fmt.Errorf("%v: page %v is past the end of the file", df.path, no)
+	}
+	return df.readPage(no)
+}
+
+func (df *DataFile) readPage(no PageNo) (*Page, error) {
+	p := &Page{No: no, data: make([]byte, df.pageSize)}
+
+	if _, err := df.file.ReadAt(p.data, int64(no)*int64(df.pageSize)); err != nil {
+		return nil, err
+	}
+
+	if !p.verify() {
+		return nil, corruptPage(fmt.Sprintf("%v: page %v: %v", df.path, no, ErrCorruptPage))
+	}
+
+	return p, nil
+}
+
+// Write stamps the page with its number and checksum, and writes it in its place.
+func (df *DataFile) Write(p *Page) error {
+	if err := df.writable(); err != nil {
+		return err
+	}
+	return df.write(p)
+}
+
+func (df *DataFile) write(p *Page) error {
+	if p.Size() != df.pageSize {
+		return fmt.Errorf("%v: page of %v bytes written to a file of %v byte pages", df.path, p.Size(), df.pageSize)
+	}
+
+	p.seal()
+
+	_, err := df.file.WriteAt(p.data, int64(p.No)*int64(df.pageSize))
+	return err
+}
+
+// Allocate finds a free page, or grows the file, and returns the page empty and already written,
+// with the given type. If the file is full and cannot grow, it returns ErrTablespaceFull.
+func (df *DataFile) Allocate(typ PageType) (*Page, error) {
+	if err := df.writable(); err != nil {
+		return nil, err
+	}
+
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	no, err := df.findFree()
+	if err != nil {
+		return nil, err
+	}
+
+	if no == df.pages {
+		if err = df.grow(); err != nil {
+			return nil, err
+		}
+		if no, err = df.findFree(); err != nil {
+			return nil, err
+		}
+	}
+
+	p := newPage(no, df.pageSize, typ)
+	if err = df.Write(p); err != nil {
+		return nil, err
+	}
+
+	if err = df.setEntry(no, 1); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Free returns a page to the free-space map. What was on it is overwritten.
+func (df *DataFile) Free(no PageNo) error {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	entry, err := df.entry(no)
+	if err != nil {
+		return err
+	}
+	if entry == fsmFree {
+		return fmt.Errorf("%v: page %v is already free", df.path, no)
+	}
+
+	if err = df.Write(newPage(no, df.pageSize, PageFree)); err != nil {
+		return err
+	}
+
+	return df.setEntry(no, fsmFree)
+}
+
+// SetFreeSpace records how many bytes a page in use has room for.
+func (df *DataFile) SetFreeSpace(no PageNo, free int) error {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	entry, err := df.entry(no)
+	if err != nil {
+		return err
+	}
+	if entry == fsmFree {
+		return fmt.Errorf("%v: page %v is not in use", df.path, no)
+	}
+
+	return df.setEntry(no, byte(1+254*clamp(free, df.bodySize())/df.bodySize()))
+}
+
+// FreeSpace returns at least how many bytes a page in use has room for, as far as the map knows;
+// it is rounded down to a 254th of the page body. A free page reports -1.
+func (df *DataFile) FreeSpace(no PageNo) (int, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	entry, err := df.entry(no)
+	if err != nil {
+		return 0, err
+	}
+	if entry == fsmFree {
+		return -1, nil
+	}
+
+	return (int(entry) - 1) * df.bodySize() / 254, nil
+}
+
+// UsedPages counts the pages in use, leaving out the header and the free-space map.
+func (df *DataFile) UsedPages() (int, error) {
+	df.mu.Lock()
+	defer df.mu.Unlock()
+
+	used := 0
+	span := PageNo(df.bodySize()) + 1
+	for fsmNo := PageNo(1); fsmNo < df.pages; fsmNo += span {
+		fsm, err := df.fsmPage(fsmNo)
+		if err != nil {
+			return 0, err
+		}
+		for idx, entry := range fsm.Body() {
+			if fsmNo+1+PageNo(idx) >= df.pages {
+				break
+			}
+			if entry != fsmFree {
+				used++
+			}
+		}
+	}
+	return used, nil
+}
+
+func (df *DataFile) Sync() error {
+	return df.file.Sync()
+}
+
+func (df *DataFile) Close() error {
+	return df.file.Close()
+}
+
+// writable returns an error if the file is read only or offline.
+func (df *DataFile) writable() error {
+	switch df.Status() {
+	case FileReadOnly:
+		return ErrReadOnly
+	case FileOffline:
+		return ErrOffline
+	}
+	return nil
+}
+
+// writeHeader writes page 0, whatever the status of the file.
+func (df *DataFile) writeHeader() error {
+	header := newPage(0, df.pageSize, PageFileHeader)
+	body := header.Body()
+	copy(body, dataFileMagic)
+	binary.LittleEndian.PutUint16(body[8:], dataFileVersion)
+	binary.LittleEndian.PutUint16(body[10:], df.no)
+	binary.LittleEndian.PutUint32(body[12:], uint32(df.pageSize))
+	binary.LittleEndian.PutUint32(body[16:], df.next)
+	binary.LittleEndian.PutUint32(body[20:], df.max)
+	body[24] = df.Status()
+	body[25] = uint8(df.owner.Kind)
+	if df.owner.Default {
+		body[26] = 1
+	}
+	binary.LittleEndian.PutUint64(body[27:], df.owner.DBID)
+	binary.LittleEndian.PutUint64(body[35:], df.checkpoint)
+	binary.LittleEndian.PutUint64(body[43:], df.lastTxn)
+	body[51] = uint8(len(df.owner.Tablespace))
+	copy(body[52:], df.owner.Tablespace)
+	return df.write(header)
+}
+
+func (df *DataFile) bodySize() int {
+	return df.pageSize - PageHeaderSize
+}
+
+// fsmLocation returns the map page that covers a page, and the page's byte in it. A map page
+// itself gets index -1.
+func (df *DataFile) fsmLocation(no PageNo) (PageNo, int) {
+	span := PageNo(df.bodySize()) + 1
+	fsmNo := 1 + (no-1)/span*span
+	return fsmNo, int(no) - int(fsmNo) - 1
+}
+
+func (df *DataFile) fsmPage(fsmNo PageNo) (*Page, error) {
+	if p := df.fsm[fsmNo]; p != nil {
+		return p, nil
+	}
+	p, err := df.readPage(fsmNo)
+	if err != nil {
+		return nil, err
+	}
+	df.fsm[fsmNo] = p
+	return p, nil
+}
+
+// entry returns the map byte of a page that holds data.
+func (df *DataFile) entry(no PageNo) (byte, error) {
+	if no == 0 || no >= df.pages {
+		return 0, fmt.Errorf("%v: page %v is not a data page", df.path, no)
+	}
+	fsmNo, idx := df.fsmLocation(no)
+	if idx < 0 {
+		return 0, fmt.Errorf("%v: page %v is not a data page", df.path, no)
+	}
+	fsm, err := df.fsmPage(fsmNo)
+	if err != nil {
+		return 0, err
+	}
+	return fsm.Body()[idx], nil
+}
+
+func (df *DataFile) setEntry(no PageNo, entry byte) error {
+	fsmNo, idx := df.fsmLocation(no)
+	fsm, err := df.fsmPage(fsmNo)
+	if err != nil {
+		return err
+	}
+	// the map in memory must not say what the file does not
+	old := fsm.Body()[idx]
+	fsm.Body()[idx] = entry
+	if err = df.Write(fsm); err != nil {
+		fsm.Body()[idx] = old
+	}
+	return err
+}
+
+// findFree returns the lowest free page, or the page count if there is none.
+func (df *DataFile) findFree() (PageNo, error) {
+	span := PageNo(df.bodySize()) + 1
+	for fsmNo := PageNo(1); fsmNo < df.pages; fsmNo += span {
+		fsm, err := df.fsmPage(fsmNo)
+		if err != nil {
+			return 0, err
+		}
+		if idx := bytes.IndexByte(fsm.Body(), fsmFree); idx >= 0 {
+			if no := fsmNo + 1 + PageNo(idx); no < df.pages {
+				return no, nil
+			}
+		}
+	}
+	return df.pages, nil
+}
+
+// grow adds the autoextend increment to the end of the file, without going past its maximum size.
+func (df *DataFile) grow() error {
+	if df.next == 0 || (df.max != 0 && df.pages >= df.max) {
+		return ErrTablespaceFull
+	}
+
+	target := df.pages + df.next
+	if df.max != 0 && target > df.max {
+		target = df.max
+	}
+	for df.pages < target {
+		if err := df.extend(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extend adds one page to the end of the file, first adding a map page if one belongs there.
+func (df *DataFile) extend() error {
+	if _, idx := df.fsmLocation(df.pages); idx < 0 {
+		fsm := newPage(df.pages, df.pageSize, PageFSM)
+		if err := df.Write(fsm); err != nil {
+			return err
+		}
+		df.fsm[fsm.No] = fsm
+		df.pages++
+	}
+
+	if err := df.Write(newPage(df.pages, df.pageSize, PageFree)); err != nil {
+		return err
+	}
+	df.pages++
+
+	return nil
+}
+
+func clamp(n, limit int) int {
+	if n < 0 {
+		return 0
+	}
+	if n > limit {
+		return limit
+	}
+	return n
+}
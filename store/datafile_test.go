@@ -0,0 +1,197 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func createDataFile(t *testing.T, pageSize int) *DataFile {
+	df, e := CreateDataFile(filepath.Join(t.TempDir(), "test.dbf"), 7, pageSize)
+	if e != nil {
+		t.Fatal(e)
+	}
+	t.Cleanup(func() { _ = df.Close() })
+	return df
+}
+
+func TestDataFile(t *testing.T) {
+	df := createDataFile(t, DefaultPageSize)
+
+	p, e := df.Allocate(PageData)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if p.No != 2 || p.Type() != PageData || len(p.Body()) != DefaultPageSize-PageHeaderSize {
+		t.Fatalf("unexpected page %v type %v", p.No, p.Type())
+	}
+
+	copy(p.Body(), "hello")
+	p.SetLSN(42)
+	if e = df.Write(p); e != nil {
+		t.Fatal(e)
+	}
+	if e = df.Close(); e != nil {
+		t.Fatal(e)
+	}
+
+	if df, e = OpenDataFile(df.Path()); e != nil {
+		t.Fatal(e)
+	}
+	defer df.Close()
+
+	if df.No() != 7 || df.PageSize() != DefaultPageSize || df.PageCount() != 3 {
+		t.Errorf("unexpected file %v, page size %v, count %v", df.No(), df.PageSize(), df.PageCount())
+	}
+
+	if p, e = df.Read(2); e != nil {
+		t.Fatal(e)
+	}
+	if string(p.Body()[:5]) != "hello" || p.LSN() != 42 || p.Type() != PageData {
+		t.Error("page not read back")
+	}
+
+	if _, e = df.Read(3); e == nil {
+		t.Error("expected error reading past the end")
+	}
+}
+
+func TestPageSizes(t *testing.T) {
+	for _, size := range []int{MinPageSize, 16 * 1024, MaxPageSize} {
+		df := createDataFile(t, size)
+		if p, e := df.Allocate(PageIndex); e != nil || p.Size() != size {
+			t.Errorf("page size %v: %v", size, e)
+		}
+	}
+
+	for _, size := range []int{0, 1024, 3000, 64 * 1024} {
+		if _, e := CreateDataFile(filepath.Join(t.TempDir(), "bad.dbf"), 1, size); e == nil {
+			t.Errorf("page size %v should be rejected", size)
+		}
+	}
+}
+
+func TestAllocateAndFree(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+
+	var pages []PageNo
+	for i := 0; i < 5; i++ {
+		p, e := df.Allocate(PageData)
+		if e != nil {
+			t.Fatal(e)
+		}
+		pages = append(pages, p.No)
+	}
+
+	if e := df.Free(pages[1]); e != nil {
+		t.Fatal(e)
+	}
+	if e := df.Free(pages[1]); e == nil {
+		t.Error("expected error freeing a free page")
+	}
+	if space, _ := df.FreeSpace(pages[1]); space != -1 {
+		t.Error("expected a free page")
+	}
+
+	// the freed page is used again before the file grows
+	count := df.PageCount()
+	if p, _ := df.Allocate(PageLOB); p.No != pages[1] || df.PageCount() != count {
+		t.Errorf("expected page %v to be reused, got %v", pages[1], p.No)
+	}
+
+	for _, no := range []PageNo{0, 1} {
+		if e := df.Free(no); e == nil {
+			t.Errorf("page %v should not be freed", no)
+		}
+	}
+}
+
+func TestFreeSpaceMap(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	body := MinPageSize - PageHeaderSize
+
+	// fill more than the first map page covers, so a second one is added
+	var last *Page
+	for i := 0; i < body+10; i++ {
+		p, e := df.Allocate(PageData)
+		if e != nil {
+			t.Fatal(e)
+		}
+		last = p
+	}
+
+	second := PageNo(1 + body + 1)
+	if last.No != PageNo(body+10+2) || df.PageCount() != last.No+1 {
+		t.Fatalf("unexpected last page %v of %v", last.No, df.PageCount())
+	}
+	if p, e := df.Read(second); e != nil || p.Type() != PageFSM {
+		t.Fatalf("expected a map page at %v: %v", second, e)
+	}
+	if e := df.Free(second); e == nil {
+		t.Error("a map page should not be freed")
+	}
+
+	if e := df.SetFreeSpace(last.No, body/2); e != nil {
+		t.Fatal(e)
+	}
+	if space, _ := df.FreeSpace(last.No); space > body/2 || space < body/2-body/254 {
+		t.Errorf("expected about %v free, got %v", body/2, space)
+	}
+
+	if e := df.Free(3); e != nil {
+		t.Fatal(e)
+	}
+
+	// the map survives reopening
+	path := df.Path()
+	_ = df.Close()
+	df, e := OpenDataFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer df.Close()
+
+	if space, _ := df.FreeSpace(last.No); space < body/2-body/254 {
+		t.Errorf("free space lost: %v", space)
+	}
+	if p, _ := df.Allocate(PageData); p.No != 3 {
+		t.Errorf("expected page 3 to be reused, got %v", p.No)
+	}
+
+	// a change the file refuses is not kept in memory either
+	if e = df.SetStatus(FileReadOnly); e != nil {
+		t.Fatal(e)
+	}
+	if e = df.SetFreeSpace(last.No, 0); !errors.Is(e, ErrReadOnly) {
+		t.Errorf("expected a read only file, got %v", e)
+	}
+	if space, _ := df.FreeSpace(last.No); space < body/2-body/254 {
+		t.Errorf("expected the map to keep %v free, got %v", body/2, space)
+	}
+}
+
+func TestCorruptPage(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	p, _ := df.Allocate(PageData)
+	path := df.Path()
+	_ = df.Close()
+
+	file, _ := os.OpenFile(path, os.O_RDWR, 0)
+	_, _ = file.WriteAt([]byte("scribble"), int64(p.No)*MinPageSize+100)
+	_ = file.Close()
+
+	df, e := OpenDataFile(path)
+	if e != nil {
+		t.Fatal(e)
+	}
+	defer df.Close()
+
+	if _, e = df.Read(p.No); !errors.Is(e, ErrCorruptPage) {
+		t.Errorf("expected a corrupt page, got %v", e)
+	}
+
+	if _, e = OpenDataFile(filepath.Join(t.TempDir(), "missing.dbf")); e == nil {
+		t.Error("expected error opening a missing file")
+	}
+}
@@ -0,0 +1,14 @@
+package store
+
+// Error is a failure that is reported to the client with one of the codes in doc/docs/err.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// ErrUniqueViolation is returned when a row would give a unique index a key it already has.
+var ErrUniqueViolation = &Error{Code: 1, Message: "Unique constraint violation"}
@@ -0,0 +1,682 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrRowNotFound is returned for a RowID that does not name a row of the heap.
+var ErrRowNotFound = errors.New("row not found")
+
+/*
+A heap page is a slotted page. After the page header its body holds:
+
+  offset size
+       0    4  next page of the heap; 0 on the last page
+       4    2  number of slots
+       6    2  where row data starts; rows fill the page from the end down to here
+       8       slot directory, 4 bytes a slot: offset and length of the record, both 0 when empty
+
+Records are found only through their slot, so a page can move them around to gather its free
+space, and a RowID, which names a slot, stays valid.
+
+A record starts with a byte whose low nibble is its kind and whose high nibble counts padding bytes
+at its end. Every record is at least big enough to be replaced by a forwarding record.
+
+  recordRow      the row
+  recordForward  a RowID: the row grew too big for its page and lives there now
+  recordMoved    the row, living away from the slot that its RowID names
+*/
+
+const (
+	heapNext      = 0
+	heapSlotCount = 4
+	heapFreeEnd   = 6
+	heapSlots     = 8
+	slotSize      = 4
+
+	recordRow     = 1
+	recordForward = 2
+	recordMoved   = 3
+
+	minRecordSize = 1 + rowIDSize
+)
+
+type heapPage struct {
+	*Page
+}
+
+func (hp heapPage) init() {
+	body := hp.Body()
+	binary.LittleEndian.PutUint32(body[heapNext:], 0)
+	binary.LittleEndian.PutUint16(body[heapSlotCount:], 0)
+	binary.LittleEndian.PutUint16(body[heapFreeEnd:], uint16(len(body)))
+}
+
+func (hp heapPage) next() PageNo {
+	return binary.LittleEndian.Uint32(hp.Body()[heapNext:])
+}
+
+func (hp heapPage) setNext(no PageNo) {
+	binary.LittleEndian.PutUint32(hp.Body()[heapNext:], no)
+}
+
+func (hp heapPage) slotCount() int {
+	return int(binary.LittleEndian.Uint16(hp.Body()[heapSlotCount:]))
+}
+
+func (hp heapPage) freeEnd() int {
+	return int(binary.LittleEndian.Uint16(hp.Body()[heapFreeEnd:]))
+}
+
+func (hp heapPage) slot(i int) (offset, length int) {
+	s := hp.Body()[heapSlots+i*slotSize:]
+	return int(binary.LittleEndian.Uint16(s)), int(binary.LittleEndian.Uint16(s[2:]))
+}
+
+func (hp heapPage) setSlot(i, offset, length int) {
+	s := hp.Body()[heapSlots+i*slotSize:]
+	binary.LittleEndian.PutUint16(s, uint16(offset))
+	binary.LittleEndian.PutUint16(s[2:], uint16(length))
+}
+
+// record returns the record in a slot, or nil if the slot is empty or does not exist.
+func (hp heapPage) record(i int) []byte {
+	if i >= hp.slotCount() {
+		return nil
+	}
+	offset, length := hp.slot(i)
+	if offset == 0 {
+		return nil
+	}
+	return hp.Body()[offset : offset+length]
+}
+
+// freeSpace is all the room on the page, whether or not it is in one piece.
+func (hp heapPage) freeSpace() int {
+	used := heapSlots + hp.slotCount()*slotSize
+	for i := 0; i < hp.slotCount(); i++ {
+		_, length := hp.slot(i)
+		used += length
+	}
+	return len(hp.Body()) - used
+}
+
+// fit finds the slot insert would store a record in, and whether the page has room for it.
+func (hp heapPage) fit(rec []byte) (int, bool) {
+	slot, count := 0, hp.slotCount()
+	for slot < count {
+		if offset, _ := hp.slot(slot); offset == 0 {
+			break
+		}
+		slot++
+	}
+
+	need := len(rec)
+	if slot == count {
+		need += slotSize
+	}
+	return slot, hp.freeSpace() >= need
+}
+
+// insert stores a record in an empty slot, or a new one, if the page has room.
+func (hp heapPage) insert(rec []byte) (int, bool) {
+	slot, ok := hp.fit(rec)
+	if !ok {
+		return 0, false
+	}
+
+	if count := hp.slotCount(); slot == count {
+		binary.LittleEndian.PutUint16(hp.Body()[heapSlotCount:], uint16(count+1))
+		hp.setSlot(slot, 0, 0)
+	}
+
+	hp.place(slot, rec)
+	return slot, true
+}
+
+// put replaces the record in a slot, if the page has room for the new one.
+func (hp heapPage) put(slot int, rec []byte) bool {
+	offset, length := hp.slot(slot)
+
+	if len(rec) <= length {
+		copy(hp.Body()[offset:], rec)
+		hp.setSlot(slot, offset, len(rec))
+		return true
+	}
+
+	if hp.freeSpace()+length < len(rec) {
+		return false
+	}
+
+	hp.setSlot(slot, 0, 0)
+	hp.place(slot, rec)
+	return true
+}
+
+func (hp heapPage) remove(slot int) {
+	hp.setSlot(slot, 0, 0)
+
+	// empty slots at the end of the directory can go
+	count := hp.slotCount()
+	for count > 0 {
+		if offset, _ := hp.slot(count - 1); offset != 0 {
+			break
+		}
+		count--
+	}
+	binary.LittleEndian.PutUint16(hp.Body()[heapSlotCount:], uint16(count))
+}
+
+// place writes a record below the others, gathering the free space first if it is in pieces.
+// The caller has made sure there is room.
+func (hp heapPage) place(slot int, rec []byte) {
+	if hp.freeEnd()-len(rec) < heapSlots+hp.slotCount()*slotSize {
+		hp.compact()
+	}
+	offset := hp.freeEnd() - len(rec)
+	copy(hp.Body()[offset:], rec)
+	hp.setSlot(slot, offset, len(rec))
+	binary.LittleEndian.PutUint16(hp.Body()[heapFreeEnd:], uint16(offset))
+}
+
+// compact moves the records to the end of the page, so the free space is in one piece.
+func (hp heapPage) compact() {
+	body := hp.Body()
+	records := make([][]byte, hp.slotCount())
+	for i := range records {
+		if rec := hp.record(i); rec != nil {
+			records[i] = append([]byte(nil), rec...)
+		}
+	}
+
+	end := len(body)
+	for i, rec := range records {
+		if rec != nil {
+			end -= len(rec)
+			copy(body[end:], rec)
+			hp.setSlot(i, end, len(rec))
+		}
+	}
+	binary.LittleEndian.PutUint16(body[heapFreeEnd:], uint16(end))
+}
+
+// makeRecord puts a kind byte in front of the data, and pads it to the smallest record size.
+func makeRecord(kind byte, data []byte) []byte {
+	pad := 0
+	if 1+len(data) < minRecordSize {
+		pad = minRecordSize - 1 - len(data)
+	}
+	rec := make([]byte, 1+len(data)+pad)
+	rec[0] = kind | byte(pad)<<4
+	copy(rec[1:], data)
+	return rec
+}
+
+func recordKind(rec []byte) byte {
+	return rec[0] & 0x0F
+}
+
+// recordData returns a copy of what follows the kind byte, without padding.
+func recordData(rec []byte) []byte {
+	pad := int(rec[0] >> 4)
+	return append([]byte(nil), rec[1:len(rec)-pad]...)
+}
+
+// Heap is a table's rows, in no particular order, on a chain of pages in one datafile.
+type Heap struct {
+	mu    sync.Mutex
+	pool  *BufferPool
+	file  *DataFile
+	pages []PageNo       // the chain, in order
+	index map[PageNo]int // position of each page in the chain
+	hint  int            // no page before this one has room for a row
+}
+
+// CreateHeap starts an empty heap in the datafile. Its first page identifies it for OpenHeap.
+func CreateHeap(pool *BufferPool, df *DataFile) (*Heap, error) {
+	h := &Heap{pool: pool, file: df, index: make(map[PageNo]int)}
+	if _, err := h.addPage(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// OpenHeap opens the heap that starts at the given page.
+func OpenHeap(pool *BufferPool, df *DataFile, first PageNo) (*Heap, error) {
+	h := &Heap{pool: pool, file: df, index: make(map[PageNo]int)}
+
+	for no := first; ; {
+		if _, seen := h.index[no]; seen {
+			return nil, fmt.Errorf("%v: heap page %v links back into its own chain", df.Path(), no)
+		}
+		hp, err := h.pin(no)
+		if err != nil {
+			return nil, err
+		}
+		next := hp.next()
+		h.unpin(no)
+
+		h.index[no] = len(h.pages)
+		h.pages = append(h.pages, no)
+
+		if next == 0 {
+			return h, nil
+		}
+		no = next
+	}
+}
+
+// First is the page that identifies the heap.
+func (h *Heap) First() PageNo {
+	return h.pages[0]
+}
+
+// DataFile is the datafile the heap is in.
+func (h *Heap) DataFile() *DataFile {
+	return h.file
+}
+
+// MaxRowSize is the largest row that fits in a page of the heap.
+func (h *Heap) MaxRowSize() int {
+	return h.file.PageSize() - PageHeaderSize - heapSlots - slotSize - 1
+}
+
+// Insert adds a row, as made by EncodeRow, and returns its RowID.
+func (h *Heap) Insert(row []byte) (RowID, error) {
+	return h.insert(row, nil)
+}
+
+// insert adds a row, calling before, if given, with the RowID it is to have before the page is
+// changed. If before fails, the row is not added.
+func (h *Heap) insert(row []byte, before func(RowID) error) (RowID, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(row) > h.MaxRowSize() {
+		return RowID{}, fmt.Errorf("row of %v bytes is larger than the %v that fit in a page", len(row), h.MaxRowSize())
+	}
+	if err := h.file.writable(); err != nil {
+		return RowID{}, err
+	}
+
+	return h.store(makeRecord(recordRow, row), PageNo(0), before)
+}
+
+// Fetch returns a copy of a row.
+func (h *Heap) Fetch(id RowID) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hp, slot, err := h.home(id)
+	if err != nil {
+		return nil, err
+	}
+	rec := hp.record(slot)
+	h.unpin(id.Page)
+
+	if recordKind(rec) == recordRow {
+		return recordData(rec), nil
+	}
+
+	target := getRowID(recordData(rec))
+	if hp, err = h.pin(target.Page); err != nil {
+		return nil, err
+	}
+	defer h.unpin(target.Page)
+
+	if rec = hp.record(int(target.Slot)); rec == nil || recordKind(rec) != recordMoved {
+		return nil, fmt.Errorf("%v: row %v forwards to %v, which is not its data", h.file.Path(), id, target)
+	}
+	return recordData(rec), nil
+}
+
+// Update replaces a row. Its RowID stays the same; if the row no longer fits in its page it moves,
+// and its slot forwards to where it went.
+func (h *Heap) Update(id RowID, row []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(row) > h.MaxRowSize() {
+		return fmt.Errorf("row of %v bytes is larger than the %v that fit in a page", len(row), h.MaxRowSize())
+	}
+	if err := h.file.writable(); err != nil {
+		return err
+	}
+
+	hp, slot, err := h.home(id)
+	if err != nil {
+		return err
+	}
+	rec := hp.record(slot)
+
+	var target *RowID
+	if recordKind(rec) == recordForward {
+		t := getRowID(recordData(rec))
+		target = &t
+	}
+
+	// back in its own slot if it fits, which also ends any forwarding
+	if hp.put(slot, makeRecord(recordRow, row)) {
+		if err = h.unpinChanged(id.Page); err != nil {
+			return err
+		}
+		if err = h.noteSpace(id.Page); err != nil {
+			return err
+		}
+		if target != nil {
+			return h.removeRecord(*target)
+		}
+		return nil
+	}
+
+	// where it already lives, if it has moved and still fits there
+	if target != nil {
+		moved, err := h.pin(target.Page)
+		if err != nil {
+			h.unpin(id.Page)
+			return err
+		}
+		if moved.put(int(target.Slot), makeRecord(recordMoved, row)) {
+			h.unpin(id.Page)
+			if err = h.unpinChanged(target.Page); err != nil {
+				return err
+			}
+			return h.noteSpace(target.Page)
+		}
+		h.unpin(target.Page)
+	}
+
+	// somewhere else; the home page is left pinned so it cannot be chosen
+	newID, err := h.store(makeRecord(recordMoved, row), id.Page, nil)
+	if err != nil {
+		h.unpin(id.Page)
+		return err
+	}
+
+	stub := make([]byte, rowIDSize)
+	newID.put(stub)
+	hp.put(slot, makeRecord(recordForward, stub))
+	if err = h.unpinChanged(id.Page); err != nil {
+		return err
+	}
+
+	if err = h.noteSpace(id.Page); err != nil {
+		return err
+	}
+	if target != nil {
+		return h.removeRecord(*target)
+	}
+	return nil
+}
+
+// Delete removes a row.
+func (h *Heap) Delete(id RowID) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.file.writable(); err != nil {
+		return err
+	}
+
+	hp, slot, err := h.home(id)
+	if err != nil {
+		return err
+	}
+	rec := hp.record(slot)
+	h.unpin(id.Page)
+
+	if recordKind(rec) == recordForward {
+		if err = h.removeRecord(getRowID(recordData(rec))); err != nil {
+			return err
+		}
+	}
+
+	return h.removeRecord(id)
+}
+
+// Scan calls fn with every row of the heap and its RowID. A row that has moved is seen after the
+// other rows of its home page. fn may change the heap, but rows it adds may or may not be seen.
+func (h *Heap) Scan(fn func(RowID, []byte) error) error {
+	for i := 0; ; i++ {
+		ids, rows, err := h.pageRows(i)
+		if err != nil || ids == nil {
+			return err
+		}
+		for j := range ids {
+			if err = fn(ids[j], rows[j]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pageRows returns the rows whose home is the i-th page of the chain; nil when there is no such page.
+func (h *Heap) pageRows(i int) ([]RowID, [][]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if i >= len(h.pages) {
+		return nil, nil, nil
+	}
+
+	no := h.pages[i]
+	hp, err := h.pin(no)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ids := []RowID{}
+	var rows [][]byte
+	var forwards []RowID
+
+	for slot := 0; slot < hp.slotCount(); slot++ {
+		rec := hp.record(slot)
+		if rec == nil {
+			continue
+		}
+		id := RowID{File: h.file.No(), Page: no, Slot: uint16(slot)}
+		switch recordKind(rec) {
+		case recordRow:
+			ids = append(ids, id)
+			rows = append(rows, recordData(rec))
+		case recordForward:
+			forwards = append(forwards, id)
+		}
+	}
+	h.unpin(no)
+
+	for _, id := range forwards {
+		target, err := h.forwardedRow(id)
+		if err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		rows = append(rows, target)
+	}
+
+	return ids, rows, nil
+}
+
+func (h *Heap) forwardedRow(id RowID) ([]byte, error) {
+	hp, err := h.pin(id.Page)
+	if err != nil {
+		return nil, err
+	}
+	target := getRowID(recordData(hp.record(int(id.Slot))))
+	h.unpin(id.Page)
+
+	if hp, err = h.pin(target.Page); err != nil {
+		return nil, err
+	}
+	defer h.unpin(target.Page)
+
+	return recordData(hp.record(int(target.Slot))), nil
+}
+
+// home pins the page of a RowID and returns its slot, which holds a row or a forward.
+func (h *Heap) home(id RowID) (heapPage, int, error) {
+	if _, ok := h.index[id.Page]; !ok || id.File != h.file.No() {
+		return heapPage{}, 0, ErrRowNotFound
+	}
+
+	hp, err := h.pin(id.Page)
+	if err != nil {
+		return heapPage{}, 0, err
+	}
+
+	rec := hp.record(int(id.Slot))
+	if rec == nil || recordKind(rec) == recordMoved {
+		h.unpin(id.Page)
+		return heapPage{}, 0, ErrRowNotFound
+	}
+
+	return hp, int(id.Slot), nil
+}
+
+// store puts a record on the first page with room, other than the one given, adding a page if
+// none has room.
+func (h *Heap) store(rec []byte, not PageNo, before func(RowID) error) (RowID, error) {
+	for i := h.hint; i < len(h.pages); i++ {
+		no := h.pages[i]
+		if no == not {
+			continue
+		}
+
+		free, err := h.file.FreeSpace(no)
+		if err != nil {
+			return RowID{}, err
+		}
+		if free < len(rec)+slotSize {
+			if i == h.hint {
+				h.hint++
+			}
+			continue
+		}
+
+		if id, ok, err := h.storeOn(no, rec, before); err != nil || ok {
+			return id, err
+		}
+	}
+
+	no, err := h.addPage()
+	if err != nil {
+		return RowID{}, err
+	}
+
+	id, _, err := h.storeOn(no, rec, before)
+	return id, err
+}
+
+func (h *Heap) storeOn(no PageNo, rec []byte, before func(RowID) error) (RowID, bool, error) {
+	hp, err := h.pin(no)
+	if err != nil {
+		return RowID{}, false, err
+	}
+
+	if before != nil {
+		// find where the record goes and let before run with the page let go, since it may need
+		// the log to take a checkpoint; the heap is locked, so the slot stays free meanwhile
+		slot, ok := hp.fit(rec)
+		h.unpin(no)
+		if !ok {
+			return RowID{}, false, nil
+		}
+		if err = before(RowID{File: h.file.No(), Page: no, Slot: uint16(slot)}); err != nil {
+			return RowID{}, false, err
+		}
+		if hp, err = h.pin(no); err != nil {
+			return RowID{}, false, err
+		}
+	}
+
+	slot, ok := hp.insert(rec)
+	if !ok {
+		h.unpin(no)
+		return RowID{}, false, nil
+	}
+	if err = h.unpinChanged(no); err != nil {
+		return RowID{}, false, err
+	}
+
+	return RowID{File: h.file.No(), Page: no, Slot: uint16(slot)}, true, h.noteSpace(no)
+}
+
+func (h *Heap) removeRecord(id RowID) error {
+	hp, err := h.pin(id.Page)
+	if err != nil {
+		return err
+	}
+	hp.remove(int(id.Slot))
+	if err = h.unpinChanged(id.Page); err != nil {
+		return err
+	}
+
+	if i := h.index[id.Page]; i < h.hint {
+		h.hint = i
+	}
+	return h.noteSpace(id.Page)
+}
+
+// addPage adds a page to the end of the chain.
+func (h *Heap) addPage() (PageNo, error) {
+	page, err := h.pool.Allocate(h.file, PageData)
+	if err != nil {
+		return 0, err
+	}
+	hp := heapPage{page}
+	hp.init()
+	if err = h.unpinChanged(page.No); err != nil {
+		return 0, err
+	}
+
+	if len(h.pages) > 0 {
+		last := h.pages[len(h.pages)-1]
+		lp, err := h.pin(last)
+		if err != nil {
+			return 0, err
+		}
+		lp.setNext(page.No)
+		if err = h.unpinChanged(last); err != nil {
+			return 0, err
+		}
+	}
+
+	h.index[page.No] = len(h.pages)
+	h.pages = append(h.pages, page.No)
+
+	return page.No, h.noteSpace(page.No)
+}
+
+// noteSpace records in the free-space map how much room a page has.
+func (h *Heap) noteSpace(no PageNo) error {
+	hp, err := h.pin(no)
+	if err != nil {
+		return err
+	}
+	free := hp.freeSpace()
+	h.unpin(no)
+	return h.file.SetFreeSpace(no, free)
+}
+
+func (h *Heap) pin(no PageNo) (heapPage, error) {
+	page, err := h.pool.Get(h.file, no)
+	if err != nil {
+		return heapPage{}, err
+	}
+	return heapPage{page}, nil
+}
+
+// unpin gives back a page the heap only read. A page pinned by the heap is always in the pool, so
+// this cannot fail.
+func (h *Heap) unpin(no PageNo) {
+	_ = h.pool.Unpin(h.file, no, false)
+}
+
+// unpinChanged gives back a page the heap changed. If the change cannot be kept, as when the file
+// has been made read only, it is lost and the error says why.
+func (h *Heap) unpinChanged(no PageNo) error {
+	return h.pool.Unpin(h.file, no, true)
+}
@@ -0,0 +1,264 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func createHeap(t *testing.T) (*Heap, *BufferPool, *DataFile) {
+	df := createDataFile(t, MinPageSize)
+	bp := NewBufferPool(8)
+	h, e := CreateHeap(bp, df)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return h, bp, df
+}
+
+func fetch(t *testing.T, h *Heap, id RowID) []byte {
+	row, e := h.Fetch(id)
+	if e != nil {
+		t.Fatalf("fetch %v: %v", id, e)
+	}
+	return row
+}
+
+func TestRowEncoding(t *testing.T) {
+	values := [][]byte{[]byte("one"), nil, {}, bytes.Repeat([]byte("x"), 300), nil, nil, nil, nil, []byte("9")}
+
+	got, e := DecodeRow(EncodeRow(values))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(got) != len(values) {
+		t.Fatalf("expected %v values, got %v", len(values), len(got))
+	}
+	for i := range values {
+		if (values[i] == nil) != (got[i] == nil) || !bytes.Equal(values[i], got[i]) {
+			t.Errorf("value %v: expected %q, got %q", i, values[i], got[i])
+		}
+	}
+
+	row := EncodeRow(values)
+	for _, bad := range [][]byte{nil, row[:len(row)-1], append(row, 0), {0xFF}} {
+		if _, e = DecodeRow(bad); e == nil {
+			t.Errorf("expected error decoding %v", bad)
+		}
+	}
+}
+
+func TestRowID(t *testing.T) {
+	id := RowID{File: 7, Page: 42, Slot: 3}
+	if id.String() != "0007.0000002A.0003" {
+		t.Errorf("unexpected text %v", id)
+	}
+	if back, e := ParseRowID(id.String()); e != nil || back != id {
+		t.Errorf("round trip gave %v, %v", back, e)
+	}
+
+	b := make([]byte, rowIDSize)
+	id.put(b)
+	if getRowID(b) != id {
+		t.Error("RowID not read back from bytes")
+	}
+
+	for _, bad := range []string{"", "7.2A.3", "0007.0000002A", "0007.0000002G.0003", "0007.0000002A.0003.0"} {
+		if _, e := ParseRowID(bad); e == nil {
+			t.Errorf("expected error parsing %q", bad)
+		}
+	}
+}
+
+func TestHeapInsertAndFetch(t *testing.T) {
+	h, bp, df := createHeap(t)
+
+	ids := map[RowID]string{}
+	for i := 0; i < 200; i++ {
+		row := fmt.Sprintf("row %v", i)
+		id, e := h.Insert([]byte(row))
+		if e != nil {
+			t.Fatal(e)
+		}
+		if _, dup := ids[id]; dup {
+			t.Fatalf("RowID %v given twice", id)
+		}
+		ids[id] = row
+	}
+
+	if len(h.pages) < 2 {
+		t.Errorf("expected the rows to need more than one page, got %v", len(h.pages))
+	}
+	for id, row := range ids {
+		if got := fetch(t, h, id); string(got) != row {
+			t.Errorf("%v: expected %q, got %q", id, row, got)
+		}
+	}
+
+	if _, e := h.Insert(make([]byte, h.MaxRowSize()+1)); e == nil {
+		t.Error("expected error inserting a row too large for a page")
+	}
+	if _, e := h.Insert(make([]byte, h.MaxRowSize())); e != nil {
+		t.Errorf("a row of the largest size should fit: %v", e)
+	}
+
+	// the heap survives reopening
+	if e := bp.Flush(nil); e != nil {
+		t.Fatal(e)
+	}
+	h, e := OpenHeap(NewBufferPool(8), df, h.First())
+	if e != nil {
+		t.Fatal(e)
+	}
+	for id, row := range ids {
+		if got := fetch(t, h, id); string(got) != row {
+			t.Errorf("after reopening %v: expected %q, got %q", id, row, got)
+		}
+	}
+}
+
+func TestHeapUpdateForwards(t *testing.T) {
+	h, _, _ := createHeap(t)
+
+	// fill the first page, so a row that grows has to move
+	var ids []RowID
+	for len(h.pages) == 1 {
+		id, e := h.Insert(bytes.Repeat([]byte("a"), 100))
+		if e != nil {
+			t.Fatal(e)
+		}
+		ids = append(ids, id)
+	}
+	id := ids[0]
+
+	big := bytes.Repeat([]byte("b"), 500)
+	if e := h.Update(id, big); e != nil {
+		t.Fatal(e)
+	}
+	if got := fetch(t, h, id); !bytes.Equal(got, big) {
+		t.Error("moved row not fetched through its RowID")
+	}
+
+	// growing again moves it once more, without a chain of forwards
+	bigger := bytes.Repeat([]byte("c"), 1500)
+	if e := h.Update(id, bigger); e != nil {
+		t.Fatal(e)
+	}
+	if got := fetch(t, h, id); !bytes.Equal(got, bigger) {
+		t.Error("row moved twice not fetched through its RowID")
+	}
+
+	// once its page has room, a small row goes home
+	if e := h.Delete(ids[1]); e != nil {
+		t.Fatal(e)
+	}
+	if e := h.Update(id, []byte("d")); e != nil {
+		t.Fatal(e)
+	}
+	if got := fetch(t, h, id); string(got) != "d" {
+		t.Errorf("expected the row back home, got %q", got)
+	}
+
+	count := 0
+	e := h.Scan(func(rid RowID, row []byte) error {
+		count++
+		if rid == id && string(row) != "d" {
+			t.Errorf("scan gave %q for %v", row, rid)
+		}
+		return nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if count != len(ids)-1 {
+		t.Errorf("expected %v rows, scanned %v", len(ids)-1, count)
+	}
+}
+
+func TestHeapDeleteAndScan(t *testing.T) {
+	h, _, _ := createHeap(t)
+
+	var ids []RowID
+	for i := 0; i < 100; i++ {
+		id, e := h.Insert(EncodeRow([][]byte{[]byte(fmt.Sprint(i)), nil}))
+		if e != nil {
+			t.Fatal(e)
+		}
+		ids = append(ids, id)
+	}
+
+	// make one row move before deleting it, and one that stays moved
+	if e := h.Update(ids[0], bytes.Repeat([]byte("x"), 1000)); e != nil {
+		t.Fatal(e)
+	}
+	if e := h.Update(ids[1], bytes.Repeat([]byte("y"), 1000)); e != nil {
+		t.Fatal(e)
+	}
+
+	for i := 0; i < len(ids); i += 2 {
+		if e := h.Delete(ids[i]); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if _, e := h.Fetch(ids[0]); e != ErrRowNotFound {
+		t.Errorf("expected a deleted row not to be found, got %v", e)
+	}
+	if e := h.Delete(ids[0]); e != ErrRowNotFound {
+		t.Errorf("expected a deleted row not to be found, got %v", e)
+	}
+	if _, e := h.Fetch(RowID{File: 7, Page: 999}); e != ErrRowNotFound {
+		t.Errorf("expected a row of another page not to be found, got %v", e)
+	}
+
+	seen := map[RowID]bool{}
+	e := h.Scan(func(id RowID, row []byte) error {
+		if seen[id] {
+			t.Errorf("%v scanned twice", id)
+		}
+		seen[id] = true
+		return nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	for i, id := range ids {
+		if seen[id] != (i%2 == 1) {
+			t.Errorf("row %v: scanned %v", i, seen[id])
+		}
+	}
+
+	// a deleted row's space is used again
+	pages := len(h.pages)
+	for i := 0; i < 50; i++ {
+		if _, e := h.Insert(EncodeRow([][]byte{[]byte("new")})); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if len(h.pages) != pages {
+		t.Errorf("expected the heap to stay at %v pages, it has %v", pages, len(h.pages))
+	}
+}
+
+func TestReadOnlyHeap(t *testing.T) {
+	h, _, df := createHeap(t)
+	id, e := h.Insert([]byte("before"))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = df.SetStatus(FileReadOnly); e != nil {
+		t.Fatal(e)
+	}
+
+	if e = h.Update(id, bytes.Repeat([]byte("after"), 40)); e != ErrReadOnly {
+		t.Errorf("expected the update to be refused, got %v", e)
+	}
+	if e = h.Delete(id); e != ErrReadOnly {
+		t.Errorf("expected the delete to be refused, got %v", e)
+	}
+	if _, e = h.Insert([]byte("more")); e != ErrReadOnly {
+		t.Errorf("expected the insert to be refused, got %v", e)
+	}
+	if row := fetch(t, h, id); string(row) != "before" {
+		t.Errorf("expected the row unchanged, got %q", row)
+	}
+}
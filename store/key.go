@@ -0,0 +1,156 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/djbckr/godb/sql/types"
+)
+
+/*
+An index key is its column values encoded so that comparing the bytes compares the values. Each
+column starts with a tag, which also orders values of different kinds:
+
+  keyNegative  a number below zero: the bytes of the positive form, inverted
+  keyZero      zero
+  keyPositive  a number above zero: exponent, then digits
+  keyTime      a time: nanoseconds since 1970, with the sign bit flipped
+  keyString    text or bytes: 0x00 is written 0x00 0xFF, and 0x00 0x01 ends the value
+  keyNull      NULL, which sorts after everything, as it does in ORDER BY
+
+A number is 0.d1d2d3... * 10^exponent with d1 not 0. The exponent is a uint32 offset by 2^31, and
+each digit d is written as d+1 with a 0 at the end, so a shorter number with the same leading digits
+sorts first.
+
+A DESC column has all of its bytes inverted. Since no encoded value is a prefix of another, the key
+of a few leading columns is a prefix of the keys that start with those values.
+*/
+
+const (
+	keyNegative = 0x10
+	keyZero     = 0x11
+	keyPositive = 0x12
+	keyTime     = 0x20
+	keyString   = 0x30
+	keyNull     = 0xFF
+)
+
+// MaxIndexColumns is the most columns an index key can have.
+const MaxIndexColumns = 32
+
+// encodeKey encodes values for the leading columns of an index; it also reports whether they are all NULL.
+func encodeKey(values []interface{}, desc []bool) ([]byte, bool, error) {
+	if len(values) > len(desc) {
+		return nil, false, fmt.Errorf("index has %v columns, not %v", len(desc), len(values))
+	}
+
+	var key []byte
+	allNull := true
+
+	for i, value := range values {
+		start := len(key)
+
+		switch v := value.(type) {
+		case nil:
+			key = append(key, keyNull)
+		case int:
+			key = appendNumber(key, types.NewNumber(int64(v)))
+		case int64:
+			key = appendNumber(key, types.NewNumber(v))
+		case float64:
+			n, err := types.ParseNumber(strconv.FormatFloat(v, 'f', -1, 64))
+			if err != nil {
+				return nil, false, err
+			}
+			key = appendNumber(key, n)
+		case *types.Number:
+			key = appendNumber(key, v)
+		case time.Time:
+			key = append(key, keyTime)
+			key = binary.BigEndian.AppendUint64(key, uint64(v.UnixNano())^1<<63)
+		case string:
+			key = appendString(key, []byte(v))
+		case []byte:
+			key = appendString(key, v)
+		default:
+			return nil, false, fmt.Errorf("a %T cannot be part of an index key", value)
+		}
+
+		if value != nil {
+			allNull = false
+		}
+		if desc[i] {
+			for j := start; j < len(key); j++ {
+				key[j] = ^key[j]
+			}
+		}
+	}
+
+	return key, allNull, nil
+}
+
+func appendNumber(key []byte, n *types.Number) []byte {
+	if n.Sign() == 0 {
+		return append(key, keyZero)
+	}
+
+	digits := strings.TrimLeft(n.Abs().String(), "0")
+	point := strings.IndexByte(digits, '.')
+	if point < 0 {
+		point = len(digits)
+	} else {
+		digits = digits[:point] + digits[point+1:]
+	}
+
+	// 0.05 is 5 * 10^-1 in this form
+	exponent := point
+	for digits[0] == '0' {
+		digits = digits[1:]
+		exponent--
+	}
+	digits = strings.TrimRight(digits, "0")
+
+	key = append(key, keyPositive)
+	start := len(key)
+	key = binary.BigEndian.AppendUint32(key, uint32(int64(exponent)+1<<31))
+	for i := 0; i < len(digits); i++ {
+		key = append(key, digits[i]-'0'+1)
+	}
+	key = append(key, 0)
+
+	if n.Sign() < 0 {
+		key[start-1] = keyNegative
+		for i := start; i < len(key); i++ {
+			key[i] = ^key[i]
+		}
+	}
+	return key
+}
+
+func appendString(key []byte, s []byte) []byte {
+	key = append(key, keyString)
+	for _, b := range s {
+		if b == 0 {
+			key = append(key, 0, 0xFF)
+		} else {
+			key = append(key, b)
+		}
+	}
+	return append(key, 0, 0x01)
+}
+
+// prefixEnd returns the smallest key that is greater than every key starting with prefix, or nil
+// if there is none.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] != 0xFF {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
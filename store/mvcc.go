@@ -0,0 +1,381 @@
+package store
+
+import (
+	"encoding/binary"
+	"sync"
+)
+
+/*
+A Table keeps every row in its heap as the newest version, with a header saying who wrote it:
+
+  offset size
+       0    8  transaction that wrote this version
+       8    8  undo record holding the version before it; 0 if the row is new
+      16    1  flags: versionDeleted if the row is deleted as of this version, versionUpdated if an
+               update made it
+      17       the row
+
+A snapshot that does not see the transaction that wrote the newest version follows the undo
+chain back to a version it does see. A deleted row stays in the heap, marked, until no snapshot
+can see it any more; Purge then removes it. The same chain, read with the commit SCN of each
+writer, gives every version of a row for a VERSIONS BETWEEN query.
+
+An undo record says how to take back one change:
+
+  offset size
+       0    1  kind: undoInsert, undoUpdate or undoDelete
+       1    8  transaction
+       9    8  the transaction's undo record before this one; 0 for its first
+      17    2  datafile of the table's heap
+      19    4  first page of the table's heap
+      23    8  RowID
+      31       for undoUpdate and undoDelete, the version the change replaced, header and all
+*/
+
+const (
+	versionHeader  = 17
+	versionDeleted = 1
+	versionUpdated = 2
+
+	undoInsert = 1
+	undoUpdate = 2
+	undoDelete = 3
+
+	undoHeader = 31
+)
+
+type tableKey struct {
+	file  FileNo
+	first PageNo
+}
+
+type version struct {
+	txn     uint64
+	undo    uint64
+	deleted bool
+	updated bool
+	row     []byte
+}
+
+func (v *version) encode() []byte {
+	b := make([]byte, versionHeader+len(v.row))
+	binary.LittleEndian.PutUint64(b[0:], v.txn)
+	binary.LittleEndian.PutUint64(b[8:], v.undo)
+	if v.deleted {
+		b[16] = versionDeleted
+	}
+	if v.updated {
+		b[16] |= versionUpdated
+	}
+	copy(b[versionHeader:], v.row)
+	return b
+}
+
+func decodeVersion(b []byte) (*version, error) {
+	if len(b) < versionHeader {
+		return nil, errBadRow
+	}
+	return &version{
+		txn:     binary.LittleEndian.Uint64(b[0:]),
+		undo:    binary.LittleEndian.Uint64(b[8:]),
+		deleted: b[16]&versionDeleted != 0,
+		updated: b[16]&versionUpdated != 0,
+		row:     b[versionHeader:],
+	}, nil
+}
+
+type undoRecord struct {
+	kind  byte
+	txn   uint64
+	prev  uint64
+	table tableKey
+	id    RowID
+	image []byte
+}
+
+func (u *undoRecord) encode() []byte {
+	b := make([]byte, undoHeader+len(u.image))
+	b[0] = u.kind
+	binary.LittleEndian.PutUint64(b[1:], u.txn)
+	binary.LittleEndian.PutUint64(b[9:], u.prev)
+	binary.LittleEndian.PutUint16(b[17:], u.table.file)
+	binary.LittleEndian.PutUint32(b[19:], u.table.first)
+	u.id.put(b[23:])
+	copy(b[undoHeader:], u.image)
+	return b
+}
+
+func decodeUndo(b []byte) *undoRecord {
+	return &undoRecord{
+		kind:  b[0],
+		txn:   binary.LittleEndian.Uint64(b[1:]),
+		prev:  binary.LittleEndian.Uint64(b[9:]),
+		table: tableKey{binary.LittleEndian.Uint16(b[17:]), binary.LittleEndian.Uint32(b[19:])},
+		id:    getRowID(b[23:]),
+		image: b[undoHeader:],
+	}
+}
+
+// Table is a heap whose rows are read through snapshots and changed by transactions.
+type Table struct {
+	mu   sync.Mutex // one change at a time, so a row's lock is checked and taken at once
+	heap *Heap
+	ts   *Transactions
+	key  tableKey
+}
+
+// Table opens a heap for transactions.
+func (ts *Transactions) Table(heap *Heap) *Table {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	key := tableKey{heap.file.No(), heap.First()}
+	if t := ts.tables[key]; t != nil {
+		return t
+	}
+	t := &Table{heap: heap, ts: ts, key: key}
+	ts.tables[key] = t
+	return t
+}
+
+// Heap is the heap the table's rows are in.
+func (t *Table) Heap() *Heap {
+	return t.heap
+}
+
+// Insert adds a row for a transaction.
+func (t *Table) Insert(txn *Txn, row []byte) (RowID, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// the undo goes before the change to the page, as for any other change, so a crash never
+	// leaves a row that nothing can take back
+	v := &version{txn: txn.ID, row: row}
+	return t.heap.insert(v.encode(), func(id RowID) error {
+		_, err := txn.addUndo(&undoRecord{kind: undoInsert, table: t.key, id: id})
+		return err
+	})
+}
+
+// Update replaces a row for a transaction.
+func (t *Table) Update(txn *Txn, id RowID, row []byte) error {
+	return t.change(txn, id, &version{txn: txn.ID, updated: true, row: row})
+}
+
+// Delete removes a row for a transaction.
+func (t *Table) Delete(txn *Txn, id RowID) error {
+	return t.change(txn, id, &version{txn: txn.ID, deleted: true})
+}
+
+func (t *Table) change(txn *Txn, id RowID, next *version) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current, err := t.heap.Fetch(id)
+	if err != nil {
+		return err
+	}
+	v, err := decodeVersion(current)
+	if err != nil {
+		return err
+	}
+
+	if t.ts.isActive(v.txn, txn) {
+		return ErrRowLocked
+	}
+	if txn.snapshot != nil && !t.ts.visible(v.txn, txn.snapshot) {
+		return ErrSerialization
+	}
+	if v.deleted {
+		return ErrRowNotFound
+	}
+
+	kind := byte(undoUpdate)
+	if next.deleted {
+		kind = undoDelete
+	}
+	if next.undo, err = txn.addUndo(&undoRecord{kind: kind, table: t.key, id: id, image: current}); err != nil {
+		return err
+	}
+
+	return t.heap.Update(id, next.encode())
+}
+
+// undo takes back one change of a transaction that is rolling back. A change that is already taken
+// back, as when a rollback cut short by a crash is done again, is left alone.
+func (t *Table) undo(u *undoRecord) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	current, err := t.heap.Fetch(u.id)
+	if err == ErrRowNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if v, err := decodeVersion(current); err != nil || v.txn != u.txn {
+		return err
+	}
+
+	if u.kind == undoInsert {
+		return t.heap.Delete(u.id)
+	}
+	return t.heap.Update(u.id, u.image)
+}
+
+// Fetch returns a row as the snapshot sees it, or ErrRowNotFound if the snapshot does not see it.
+func (t *Table) Fetch(s *Snapshot, id RowID) ([]byte, error) {
+	current, err := t.heap.Fetch(id)
+	if err != nil {
+		return nil, err
+	}
+	return t.resolve(s, current)
+}
+
+// Scan calls fn with every row the snapshot sees.
+func (t *Table) Scan(s *Snapshot, fn func(RowID, []byte) error) error {
+	return t.heap.Scan(func(id RowID, current []byte) error {
+		row, err := t.resolve(s, current)
+		if err == ErrRowNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(id, row)
+	})
+}
+
+// resolve goes back from the newest version of a row to the one the snapshot sees.
+func (t *Table) resolve(s *Snapshot, current []byte) ([]byte, error) {
+	for {
+		v, err := decodeVersion(current)
+		if err != nil {
+			return nil, err
+		}
+
+		if t.ts.visible(v.txn, s) {
+			if v.deleted {
+				return nil, ErrRowNotFound
+			}
+			return v.row, nil
+		}
+
+		if v.undo == 0 {
+			// inserted after the snapshot
+			return nil, ErrRowNotFound
+		}
+		rec, err := t.ts.undo.read(v.undo)
+		if err != nil {
+			return nil, err
+		}
+		current = decodeUndo(rec).image
+	}
+}
+
+// RowVersion is one committed version of a row, as a VERSIONS BETWEEN query lists it.
+type RowVersion struct {
+	StartSCN  SCN  // the commit that made it; 0 if that is older than the oldest SCN remembered
+	EndSCN    SCN  // the commit that replaced or deleted it; 0 if it is current
+	Operation byte // 'I', 'U' or 'D' for the insert, update or delete that made it
+	Txn       uint64
+	Row       []byte // for a delete, the row it deleted
+}
+
+// Versions calls fn with every committed version of every row that was current at some time from
+// one SCN to another, and with every delete in between, newest first for each row.
+func (t *Table) Versions(from, to SCN, fn func(RowID, *RowVersion) error) error {
+	// the snapshot keeps the commits since from in memory while the versions are read
+	s, err := t.ts.SnapshotAt(from)
+	if err != nil {
+		return err
+	}
+	defer s.Release()
+
+	return t.heap.Scan(func(id RowID, current []byte) error {
+		versions, err := t.versions(current, from, to)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if err = fn(id, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// versions goes back from the newest version of a row until it reaches one current at from.
+func (t *Table) versions(current []byte, from, to SCN) ([]*RowVersion, error) {
+	var list []*RowVersion
+	var end SCN
+	var deleted *RowVersion // a delete still to be given the row it deleted
+
+	for {
+		v, err := decodeVersion(current)
+		if err != nil {
+			return nil, err
+		}
+
+		// a version that is not committed yet is not listed, and does not end the one before it
+		if start, committed := t.ts.commitSCN(v.txn); committed {
+			if deleted != nil {
+				deleted.Row = v.row
+				deleted = nil
+			}
+
+			rv := &RowVersion{StartSCN: start, EndSCN: end, Operation: 'I', Txn: v.txn, Row: v.row}
+			if v.updated {
+				rv.Operation = 'U'
+			}
+			if v.deleted {
+				rv.Operation = 'D'
+				if start >= from && start <= to {
+					list = append(list, rv)
+					deleted = rv
+				}
+			} else if start <= to && (end == 0 || end > from) {
+				list = append(list, rv)
+			}
+
+			if start <= from && deleted == nil {
+				return list, nil
+			}
+			end = start
+		}
+
+		if v.undo == 0 {
+			return list, nil
+		}
+		rec, err := t.ts.undo.read(v.undo)
+		if err != nil {
+			return nil, err
+		}
+		current = decodeUndo(rec).image
+	}
+}
+
+// Purge removes deleted rows that no snapshot can see any more.
+func (t *Table) Purge() error {
+	return t.heap.Scan(func(id RowID, current []byte) error {
+		v, err := decodeVersion(current)
+		if err != nil || !v.deleted {
+			return err
+		}
+
+		t.ts.mu.Lock()
+		_, remembered := t.ts.committed[v.txn]
+		gone := t.ts.active[v.txn] == nil && !remembered
+		t.ts.mu.Unlock()
+
+		if !gone {
+			return nil
+		}
+
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		return t.heap.Delete(id)
+	})
+}
@@ -0,0 +1,328 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func newTransactions(t *testing.T, undoPages int) (*Transactions, *Table) {
+	df := createDataFile(t, MinPageSize)
+	pool := NewBufferPool(32)
+	undo, e := CreateUndo(pool, df, undoPages)
+	if e != nil {
+		t.Fatal(e)
+	}
+	heap, e := CreateHeap(pool, df)
+	if e != nil {
+		t.Fatal(e)
+	}
+	ts := NewTransactions(undo)
+	ts.SetRetention(0) // the flashback tests set their own
+	return ts, ts.Table(heap)
+}
+
+// sees checks what a snapshot reads for a row; "" means it does not see the row.
+func sees(t *testing.T, table *Table, s *Snapshot, id RowID, expected string) {
+	t.Helper()
+	row, e := table.Fetch(s, id)
+	if expected == "" {
+		if e != ErrRowNotFound {
+			t.Errorf("SCN %v: expected no row, got %q, %v", s.SCN, row, e)
+		}
+		return
+	}
+	if e != nil || string(row) != expected {
+		t.Errorf("SCN %v: expected %q, got %q, %v", s.SCN, expected, row, e)
+	}
+}
+
+func TestReadConsistency(t *testing.T) {
+	ts, table := newTransactions(t, 16)
+
+	before := ts.Snapshot()
+	t1 := ts.Begin(ReadCommitted)
+	id, e := table.Insert(t1, []byte("v1"))
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// only the writer sees what it has not committed
+	own := t1.Snapshot()
+	sees(t, table, own, id, "v1")
+	own.Release()
+	outside := ts.Snapshot()
+	sees(t, table, outside, id, "")
+	outside.Release()
+
+	if scn, e := t1.Commit(); scn != 1 || ts.SCN() != 1 || e != nil {
+		t.Errorf("expected SCN 1, got %v, %v", scn, e)
+	}
+	sees(t, table, before, id, "")
+
+	s1 := ts.Snapshot()
+	t2 := ts.Begin(ReadCommitted)
+	if e = table.Update(t2, id, []byte("v2 is longer than v1")); e != nil {
+		t.Fatal(e)
+	}
+	t2.Commit()
+
+	s2 := ts.Snapshot()
+	t3 := ts.Begin(ReadCommitted)
+	if e = table.Delete(t3, id); e != nil {
+		t.Fatal(e)
+	}
+	t3.Commit()
+	s3 := ts.Snapshot()
+
+	// each snapshot keeps seeing the row as it was when it was taken
+	sees(t, table, before, id, "")
+	sees(t, table, s1, id, "v1")
+	sees(t, table, s2, id, "v2 is longer than v1")
+	sees(t, table, s3, id, "")
+
+	count := func(s *Snapshot) int {
+		n := 0
+		if e := table.Scan(s, func(RowID, []byte) error { n++; return nil }); e != nil {
+			t.Fatal(e)
+		}
+		return n
+	}
+	if count(s1) != 1 || count(s3) != 0 {
+		t.Errorf("scans saw %v and %v rows", count(s1), count(s3))
+	}
+
+	// the deleted row stays for the snapshots that see it, then goes
+	if e = table.Purge(); e != nil {
+		t.Fatal(e)
+	}
+	sees(t, table, s1, id, "v1")
+	for _, s := range []*Snapshot{before, s1, s2, s3} {
+		s.Release()
+	}
+	if e = table.Purge(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = table.heap.Fetch(id); e != ErrRowNotFound {
+		t.Errorf("expected the deleted row to be purged, got %v", e)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	ts, table := newTransactions(t, 16)
+
+	setup := ts.Begin(ReadCommitted)
+	a, _ := table.Insert(setup, []byte("a"))
+	b, _ := table.Insert(setup, []byte("b"))
+	setup.Commit()
+
+	txn := ts.Begin(ReadCommitted)
+	if e := table.Update(txn, a, []byte("a1")); e != nil {
+		t.Fatal(e)
+	}
+	if e := table.Update(txn, a, []byte("a2")); e != nil {
+		t.Fatal(e)
+	}
+	if e := table.Delete(txn, b); e != nil {
+		t.Fatal(e)
+	}
+	c, _ := table.Insert(txn, []byte("c"))
+	if e := txn.Rollback(); e != nil {
+		t.Fatal(e)
+	}
+
+	s := ts.Snapshot()
+	defer s.Release()
+	sees(t, table, s, a, "a")
+	sees(t, table, s, b, "b")
+	if _, e := table.heap.Fetch(c); e != ErrRowNotFound {
+		t.Errorf("expected the inserted row to be gone, got %v", e)
+	}
+
+	// the rows are free for others again
+	other := ts.Begin(ReadCommitted)
+	if e := table.Update(other, a, []byte("x")); e != nil {
+		t.Errorf("row still locked after rollback: %v", e)
+	}
+	other.Commit()
+}
+
+func TestWriteConflicts(t *testing.T) {
+	ts, table := newTransactions(t, 16)
+
+	setup := ts.Begin(ReadCommitted)
+	id, _ := table.Insert(setup, []byte("0"))
+	setup.Commit()
+
+	serializable := ts.Begin(Serializable)
+	readCommitted := ts.Begin(ReadCommitted)
+
+	writer := ts.Begin(ReadCommitted)
+	if e := table.Update(writer, id, []byte("1")); e != nil {
+		t.Fatal(e)
+	}
+	if e := table.Update(readCommitted, id, []byte("2")); e != ErrRowLocked {
+		t.Errorf("expected the row to be locked, got %v", e)
+	}
+	writer.Commit()
+
+	// a serializable transaction keeps reading as of its start, and cannot change what changed since
+	sees(t, table, serializable.Snapshot(), id, "0")
+	e := table.Update(serializable, id, []byte("3"))
+	var coded *Error
+	if !errors.As(e, &coded) || coded.Code != 3 {
+		t.Errorf("expected a serialization failure, got %v", e)
+	}
+	if e = serializable.Rollback(); e != nil {
+		t.Fatal(e)
+	}
+
+	// read committed reads the latest commit with each statement
+	s := readCommitted.Snapshot()
+	sees(t, table, s, id, "1")
+	s.Release()
+	if e = table.Update(readCommitted, id, []byte("2")); e != nil {
+		t.Error(e)
+	}
+	readCommitted.Commit()
+}
+
+func TestSnapshotTooOld(t *testing.T) {
+	ts, table := newTransactions(t, 2)
+
+	setup := ts.Begin(ReadCommitted)
+	id, _ := table.Insert(setup, []byte("original"))
+	setup.Commit()
+
+	old := ts.Snapshot()
+	defer old.Release()
+
+	// committed undo is written over once the ring comes round
+	var e error
+	for i := 0; i < 200; i++ {
+		txn := ts.Begin(ReadCommitted)
+		if e = table.Update(txn, id, []byte(fmt.Sprintf("version %v", i))); e != nil {
+			t.Fatal(e)
+		}
+		txn.Commit()
+	}
+
+	_, e = table.Fetch(old, id)
+	var coded *Error
+	if !errors.As(e, &coded) || coded.Code != 2 {
+		t.Errorf("expected snapshot too old, got %v", e)
+	}
+
+	// the undo of an open transaction is never written over
+	open := ts.Begin(ReadCommitted)
+	if e = table.Update(open, id, []byte("open")); e != nil {
+		t.Fatal(e)
+	}
+	for i := 0; i < 200 && e == nil; i++ {
+		txn := ts.Begin(ReadCommitted)
+		_, e = table.Insert(txn, []byte(fmt.Sprintf("row %v", i)))
+		txn.Commit()
+	}
+	if e != ErrUndoFull {
+		t.Errorf("expected the undo to fill up, got %v", e)
+	}
+	if e = open.Rollback(); e != nil {
+		t.Fatal(e)
+	}
+	s := ts.Snapshot()
+	defer s.Release()
+	sees(t, table, s, id, "version 199")
+}
+
+func TestFlashback(t *testing.T) {
+	ts, table := newTransactions(t, 16)
+	start := time.Now()
+	clock := start
+	ts.now = func() time.Time { return clock }
+	ts.SetRetention(time.Hour)
+
+	commit := func(txn *Txn) SCN {
+		clock = clock.Add(time.Minute)
+		scn, _ := txn.Commit()
+		return scn
+	}
+	change := func(fn func(txn *Txn) error) {
+		txn := ts.Begin(ReadCommitted)
+		if e := fn(txn); e != nil {
+			t.Fatal(e)
+		}
+		commit(txn)
+	}
+
+	var id, other RowID
+	change(func(txn *Txn) (e error) { id, e = table.Insert(txn, []byte("v1")); return })
+	change(func(txn *Txn) error { return table.Update(txn, id, []byte("v2")) })
+	change(func(txn *Txn) error { return table.Update(txn, id, []byte("v3")) })
+	change(func(txn *Txn) error { return table.Delete(txn, id) })
+	change(func(txn *Txn) (e error) { other, e = table.Insert(txn, []byte("other")); return })
+	open := ts.Begin(ReadCommitted)
+	if e := table.Update(open, other, []byte("not committed")); e != nil {
+		t.Fatal(e)
+	}
+
+	// AS OF reads the table as it was
+	for scn, expected := range []string{"", "v1", "v2", "v3", ""} {
+		s, e := ts.SnapshotAt(SCN(scn))
+		if e != nil {
+			t.Fatal(e)
+		}
+		sees(t, table, s, id, expected)
+		s.Release()
+	}
+	if scn, e := ts.SCNAt(start.Add(150 * time.Second)); e != nil || scn != 2 {
+		t.Errorf("expected SCN 2 after two and a half minutes, got %v, %v", scn, e)
+	}
+	if _, e := ts.SCNAt(start.Add(-time.Second)); e != ErrSnapshotTooOld {
+		t.Errorf("expected a time before the start to be too old, got %v", e)
+	}
+	if _, e := ts.SnapshotAt(6); e == nil {
+		t.Error("expected an error for an SCN not reached yet")
+	}
+
+	versions := func(from, to SCN) string {
+		list := ""
+		e := table.Versions(from, to, func(_ RowID, v *RowVersion) error {
+			list += fmt.Sprintf("%c%v-%v:%s ", v.Operation, v.StartSCN, v.EndSCN, v.Row)
+			return nil
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+		return list
+	}
+
+	// every committed version, and the delete with the row it deleted
+	if list := versions(0, ts.SCN()); list != "D4-0:v3 U3-4:v3 U2-3:v2 I1-2:v1 I5-0:other " {
+		t.Errorf("versions between minvalue and maxvalue: %v", list)
+	}
+	if list := versions(2, 3); list != "U3-4:v3 U2-3:v2 " {
+		t.Errorf("versions between 2 and 3: %v", list)
+	}
+
+	// once the retention period has passed, the history is gone
+	clock = clock.Add(2 * time.Hour)
+	if e := open.Rollback(); e != nil {
+		t.Fatal(e)
+	}
+	if ts.OldestSCN() != 5 {
+		t.Errorf("expected the oldest SCN to be 5, got %v", ts.OldestSCN())
+	}
+	if _, e := ts.SnapshotAt(3); e != ErrSnapshotTooOld {
+		t.Errorf("expected SCN 3 to be too old, got %v", e)
+	}
+	if list := versions(5, 5); list != "I0-0:other " {
+		t.Errorf("versions at the oldest SCN: %v", list)
+	}
+	if e := table.Purge(); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := table.heap.Fetch(id); e != ErrRowNotFound {
+		t.Errorf("expected the deleted row to be purged, got %v", e)
+	}
+}
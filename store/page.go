@@ -0,0 +1,119 @@
+package store
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// PageNo numbers the pages of a datafile from 0.
+type PageNo = uint32
+
+// PageType says what a page holds.
+type PageType = uint8
+
+const (
+	PageFree       PageType = iota // not in use
+	PageFileHeader                 // page 0 of every datafile
+	PageFSM                        // free-space map
+	PageData                       // table rows
+	PageIndex                      // index entries
+	PageLOB                        // large object data
+	PageUndo                       // undo records
+)
+
+// Page sizes are a power of two between these limits; DefaultPageSize is used unless a datafile
+// is created with another.
+const (
+	MinPageSize     = 2 * 1024
+	MaxPageSize     = 32 * 1024
+	DefaultPageSize = 8 * 1024
+)
+
+/*
+Every page starts with a header:
+
+  offset size
+       0    4  checksum: CRC-32C of the rest of the page
+       4    1  page type
+       5    1  flags
+       6    2  reserved
+       8    4  page number, to catch a page written in the wrong place
+      12    4  reserved
+      16    8  LSN of the last change to the page
+*/
+
+const (
+	offChecksum = 0
+	offType     = 4
+	offFlags    = 5
+	offPageNo   = 8
+	offLSN      = 16
+
+	// PageHeaderSize is where the body of a page starts.
+	PageHeaderSize = 24
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Page is one page of a datafile as it is in memory.
+type Page struct {
+	No   PageNo
+	data []byte
+}
+
+func newPage(no PageNo, size int, typ PageType) *Page {
+	p := &Page{No: no, data: make([]byte, size)}
+	p.SetType(typ)
+	return p
+}
+
+func (p *Page) Type() PageType {
+	return p.data[offType]
+}
+
+func (p *Page) SetType(typ PageType) {
+	p.data[offType] = typ
+}
+
+func (p *Page) Flags() uint8 {
+	return p.data[offFlags]
+}
+
+func (p *Page) SetFlags(flags uint8) {
+	p.data[offFlags] = flags
+}
+
+// LSN is the log sequence number of the last change made to the page.
+func (p *Page) LSN() uint64 {
+	return binary.LittleEndian.Uint64(p.data[offLSN:])
+}
+
+func (p *Page) SetLSN(lsn uint64) {
+	binary.LittleEndian.PutUint64(p.data[offLSN:], lsn)
+}
+
+// Body is the part of the page after the header. Changes to it change the page.
+func (p *Page) Body() []byte {
+	return p.data[PageHeaderSize:]
+}
+
+// Size is the size of the whole page, header included.
+func (p *Page) Size() int {
+	return len(p.data)
+}
+
+// seal stamps the page number and checksum, just before the page is written.
+func (p *Page) seal() {
+	binary.LittleEndian.PutUint32(p.data[offPageNo:], p.No)
+	binary.LittleEndian.PutUint32(p.data[offChecksum:], crc32.Checksum(p.data[offChecksum+4:], crcTable))
+}
+
+// verify checks what seal stamped, just after the page is read.
+func (p *Page) verify() bool {
+	return binary.LittleEndian.Uint32(p.data[offPageNo:]) == p.No &&
+		binary.LittleEndian.Uint32(p.data[offChecksum:]) == crc32.Checksum(p.data[offChecksum+4:], crcTable)
+}
+
+func validPageSize(size int) bool {
+	return size >= MinPageSize && size <= MaxPageSize && size&(size-1) == 0
+}
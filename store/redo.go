@@ -0,0 +1,775 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrCheckpointNotComplete is returned when the log must switch to a group that still holds
+// changes not yet written to the datafiles. A checkpoint frees the group.
+var ErrCheckpointNotComplete = errors.New("checkpoint not complete: the next log group is still needed for recovery")
+
+var redoMagic = []byte("GODBREDO")
+
+const redoVersion = 1
+
+/*
+The redo log records every change to a page before the page itself is written, so that after a
+crash the changes can be made again. It is a ring of groups: records go to the current group
+until it is full, then the log switches to the next one and writes over it. Each group has one or
+more members, files that all get the same writes, so that losing one loses nothing.
+
+A member starts with a header block:
+
+  offset size
+       0    4  checksum: CRC-32C of bytes 4 to 36
+       4    8  "GODBREDO"
+      12    2  version
+      14    2  group number, from 1
+      16    4  sequence number of the log this group now holds; 0 if it has never been used
+      20    8  LSN of its first record
+      28    8  size of the member
+
+Records follow the header one after another:
+
+  offset size
+       0    4  length of the payload
+       4    4  CRC-32C of the payload
+       8    8  LSN
+      16    4  sequence number, as in the header
+      20    1  kind
+      21    8  transaction; 0 for changes made outside of one
+      29    2  datafile
+      31    4  page
+      35    2  where the data goes in the page
+      37       data
+
+A record ends the log if it does not check out, or has the sequence number of an earlier use of the
+group. A crash in the middle of writing a record leaves exactly that.
+
+The first change to a page after a checkpoint logs the whole page, later ones only the bytes that
+changed since it was last logged. A page torn by a crash as it was written is then rebuilt from its
+image.
+
+Recovery repeats every change the log holds, in order, to pages that are older than the change, but
+for the changes to a datafile from before it was last dropped: the file may be gone, or its number
+given to another. The
+undo, whose pages are brought up to date with the rest, then says which transactions had not
+finished; recovery returns the transactions it found commit records of, since one of those may have
+committed just before the crash, before the undo could forget it.
+*/
+
+const (
+	redoHeaderSize = 512
+
+	redoRecordHeader  = 8
+	redoPayloadHeader = 29
+
+	redoChange = 1 // bytes of a page
+	redoImage  = 2 // a whole page, but for its checksum
+	redoCommit = 3 // a transaction committed
+	redoDrop   = 4 // a datafile was dropped
+
+	// MinLogSize is the smallest member; it holds at least the image of the largest page.
+	MinLogSize = 64 * 1024
+)
+
+// LogGroup is a group of the redo log: the paths of its members and the size of each.
+type LogGroup struct {
+	Members []string
+	Size    int64
+}
+
+type logGroup struct {
+	LogGroup
+	no       int
+	files    []*os.File // nil for a member that cannot be used
+	best     *os.File   // the member holding the most of the log
+	seq      uint32
+	firstLSN uint64
+	lastLSN  uint64 // 0 if the group has no records
+	end      int64  // where the records end
+}
+
+type redoRecord struct {
+	lsn    uint64
+	kind   byte
+	txn    uint64
+	file   FileNo
+	page   PageNo
+	offset int
+	data   []byte
+}
+
+type pageKey struct {
+	file FileNo
+	page PageNo
+}
+
+// RedoLog writes changes to the log groups, and replays them after a crash.
+type RedoLog struct {
+	mu           sync.Mutex
+	groups       []*logGroup
+	current      int
+	pos          int64 // where the next record goes in the current group
+	written      int64 // how much of the current group is on disk
+	buffer       []byte
+	nextLSN      uint64
+	flushed      uint64             // every record up to this LSN is on disk
+	checkpoint   uint64             // every change up to this LSN is in the datafiles
+	lastTxn      uint64             // the highest transaction Recover found
+	imaged       map[pageKey][]byte // each page changed since the checkpoint, as it was last logged
+	checkpointer func() error
+}
+
+// CreateRedoLog makes the members of a new log, which starts in the first group. There must be at
+// least two groups; none of the member files may exist yet.
+func CreateRedoLog(groups []LogGroup) (*RedoLog, error) {
+	if err := checkGroups(groups); err != nil {
+		return nil, err
+	}
+
+	r := &RedoLog{nextLSN: 1, imaged: make(map[pageKey][]byte)}
+	for i, group := range groups {
+		g := &logGroup{LogGroup: group, no: i + 1}
+		r.groups = append(r.groups, g)
+		if i == 0 {
+			g.seq, g.firstLSN = 1, 1
+		}
+
+		for _, path := range group.Members {
+			file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+			if err != nil {
+				_ = r.Close()
+				return nil, err
+			}
+			g.files = append(g.files, file)
+			if err = file.Truncate(group.Size); err != nil {
+				_ = r.Close()
+				return nil, err
+			}
+		}
+		g.best = g.files[0]
+
+		err := r.writeHeader(g)
+		if err == nil {
+			err = r.syncMembers(g)
+		}
+		if err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+
+	r.pos, r.written = redoHeaderSize, redoHeaderSize
+	return r, nil
+}
+
+// OpenRedoLog opens the members of a log and finds where it ends. A member that is missing or
+// behind the others of its group is made whole from them. Recover should be called before anything
+// is logged.
+func OpenRedoLog(groups []LogGroup) (*RedoLog, error) {
+	if err := checkGroups(groups); err != nil {
+		return nil, err
+	}
+
+	r := &RedoLog{imaged: make(map[pageKey][]byte)}
+	for i, group := range groups {
+		g := &logGroup{LogGroup: group, no: i + 1}
+		r.groups = append(r.groups, g)
+		if err := r.openGroup(g); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+		if g.seq > r.groups[r.current].seq {
+			r.current = i
+		}
+	}
+
+	current := r.groups[r.current]
+	if current.seq == 0 {
+		_ = r.Close()
+		return nil, errors.New("redo log has never been used; it was not created completely")
+	}
+
+	r.pos, r.written = current.end, current.end
+	r.nextLSN = current.firstLSN
+	if current.lastLSN != 0 {
+		r.nextLSN = current.lastLSN + 1
+	}
+	r.flushed = r.nextLSN - 1
+
+	return r, nil
+}
+
+func checkGroups(groups []LogGroup) error {
+	if len(groups) < 2 {
+		return errors.New("redo log needs at least two groups")
+	}
+	for i, group := range groups {
+		if len(group.Members) == 0 {
+			return fmt.Errorf("redo log group %v has no members", i+1)
+		}
+		if group.Size < MinLogSize {
+			return fmt.Errorf("redo log group %v is smaller than %v bytes", i+1, MinLogSize)
+		}
+	}
+	return nil
+}
+
+// openGroup opens the members of a group, picks the one that holds the most, and copies it over the others.
+func (r *RedoLog) openGroup(g *logGroup) error {
+	type state struct {
+		seq         uint32
+		first, last uint64
+		end         int64
+	}
+	states := make([]state, len(g.Members))
+
+	for i, path := range g.Members {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+		if err != nil {
+			g.files = append(g.files, nil)
+			continue
+		}
+		g.files = append(g.files, file)
+
+		s := &states[i]
+		if s.seq, s.first, err = readRedoHeader(file, g); err != nil {
+			continue
+		}
+		s.end, s.last, _ = readRecords(file, g.Size, s.seq, nil)
+
+		if g.best == nil || s.seq > g.seq || (s.seq == g.seq && s.end > g.end) {
+			g.best, g.seq, g.firstLSN, g.lastLSN, g.end = file, s.seq, s.first, s.last, s.end
+		}
+	}
+
+	if g.best == nil {
+		return fmt.Errorf("redo log group %v has no member that can be read", g.no)
+	}
+
+	for i, file := range g.files {
+		if file == nil || file == g.best {
+			continue
+		}
+		if s := states[i]; s.seq == g.seq && s.end == g.end {
+			continue
+		}
+		if err := copyMember(g.best, file, g.end); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range g.files {
+		if file == nil {
+			continue
+		}
+		if info, err := file.Stat(); err != nil {
+			return err
+		} else if info.Size() < g.Size {
+			if err = file.Truncate(g.Size); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func copyMember(from, to *os.File, end int64) error {
+	buf := make([]byte, 64*1024)
+	for at := int64(0); at < end; at += int64(len(buf)) {
+		if end-at < int64(len(buf)) {
+			buf = buf[:end-at]
+		}
+		if _, err := from.ReadAt(buf, at); err != nil {
+			return err
+		}
+		if _, err := to.WriteAt(buf, at); err != nil {
+			return err
+		}
+	}
+	return to.Sync()
+}
+
+func readRedoHeader(file *os.File, g *logGroup) (uint32, uint64, error) {
+	var h [36]byte
+	if _, err := file.ReadAt(h[:], 0); err != nil {
+		return 0, 0, err
+	}
+
+	if binary.LittleEndian.Uint32(h[0:]) != crc32.Checksum(h[4:], crcTable) || !bytes.Equal(h[4:12], redoMagic) ||
+		binary.LittleEndian.Uint16(h[12:]) != redoVersion || int(binary.LittleEndian.Uint16(h[14:])) != g.no {
+		return 0, 0, fmt.Errorf("%v: not a member of redo log group %v", file.Name(), g.no)
+	}
+
+	return binary.LittleEndian.Uint32(h[16:]), binary.LittleEndian.Uint64(h[20:]), nil
+}
+
+func (r *RedoLog) writeHeader(g *logGroup) error {
+	var h [36]byte
+	copy(h[4:], redoMagic)
+	binary.LittleEndian.PutUint16(h[12:], redoVersion)
+	binary.LittleEndian.PutUint16(h[14:], uint16(g.no))
+	binary.LittleEndian.PutUint32(h[16:], g.seq)
+	binary.LittleEndian.PutUint64(h[20:], g.firstLSN)
+	binary.LittleEndian.PutUint64(h[28:], uint64(g.Size))
+	binary.LittleEndian.PutUint32(h[0:], crc32.Checksum(h[4:], crcTable))
+
+	return r.writeMembers(g, h[:], 0)
+}
+
+// readRecords reads the records of a member until one does not check out, calling fn with each
+// if fn is not nil. It returns where the good records end and the last LSN.
+func readRecords(file *os.File, size int64, seq uint32, fn func(*redoRecord) error) (int64, uint64, error) {
+	in := bufio.NewReaderSize(io.NewSectionReader(file, redoHeaderSize, size-redoHeaderSize), 64*1024)
+	end, last := int64(redoHeaderSize), uint64(0)
+
+	var head [redoRecordHeader]byte
+	for seq != 0 {
+		if _, err := io.ReadFull(in, head[:]); err != nil {
+			break
+		}
+		length := int64(binary.LittleEndian.Uint32(head[0:]))
+		if length < redoPayloadHeader || end+redoRecordHeader+length > size {
+			break
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(in, payload); err != nil {
+			break
+		}
+		if binary.LittleEndian.Uint32(head[4:]) != crc32.Checksum(payload, crcTable) ||
+			binary.LittleEndian.Uint32(payload[8:]) != seq {
+			break
+		}
+
+		rec := &redoRecord{
+			lsn:    binary.LittleEndian.Uint64(payload[0:]),
+			kind:   payload[12],
+			txn:    binary.LittleEndian.Uint64(payload[13:]),
+			file:   binary.LittleEndian.Uint16(payload[21:]),
+			page:   binary.LittleEndian.Uint32(payload[23:]),
+			offset: int(binary.LittleEndian.Uint16(payload[27:])),
+			data:   payload[redoPayloadHeader:],
+		}
+		if rec.lsn <= last {
+			break
+		}
+
+		if fn != nil {
+			if err := fn(rec); err != nil {
+				return end, last, err
+			}
+		}
+		end += redoRecordHeader + length
+		last = rec.lsn
+	}
+
+	return end, last, nil
+}
+
+// SetCheckpointer gives the log a way to take a checkpoint, which it then does when it must switch to
+// a group whose changes are not all in the datafiles yet, rather than fail.
+func (r *RedoLog) SetCheckpointer(fn func() error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkpointer = fn
+}
+
+// Log records a change a transaction made to a page, which the caller has pinned, and stamps the
+// page with the change's LSN. offset and length are where the change is in the page body; only the
+// bytes in there that differ from when the page was last logged go in the record. If none do,
+// nothing is logged and the page keeps its LSN.
+func (r *RedoLog) Log(txn uint64, df *DataFile, page *Page, offset, length int) (uint64, error) {
+	key := pageKey{df.No(), page.No}
+
+	return r.appendRoom(func() (uint64, error) {
+		kind, start, end := byte(redoChange), PageHeaderSize+offset, PageHeaderSize+offset+length
+		logged := r.imaged[key]
+		if logged == nil {
+			kind, start, end = redoImage, offType, len(page.data)
+		} else {
+			for start < end && page.data[start] == logged[start] {
+				start++
+			}
+			for end > start && page.data[end-1] == logged[end-1] {
+				end--
+			}
+			if start == end {
+				return page.LSN(), nil
+			}
+		}
+
+		lsn, err := r.append(kind, txn, key, start, page.data[start:end])
+		if err != nil {
+			return 0, err
+		}
+
+		if logged == nil {
+			logged = make([]byte, len(page.data))
+			r.imaged[key] = logged
+		}
+		copy(logged[start:end], page.data[start:end])
+		page.SetLSN(lsn)
+		return lsn, nil
+	})
+}
+
+// Commit records that a transaction committed, and returns once the record is on disk.
+func (r *RedoLog) Commit(txn uint64) error {
+	_, err := r.appendRoom(func() (uint64, error) {
+		lsn, err := r.append(redoCommit, txn, pageKey{}, 0, nil)
+		if err != nil {
+			return 0, err
+		}
+		return lsn, r.flush(lsn)
+	})
+	return err
+}
+
+// DropFile records that a datafile was dropped, and returns once the record is on disk.
+func (r *RedoLog) DropFile(no FileNo) error {
+	_, err := r.appendRoom(func() (uint64, error) {
+		lsn, err := r.append(redoDrop, 0, pageKey{file: no}, 0, nil)
+		if err != nil {
+			return 0, err
+		}
+		for key := range r.imaged {
+			if key.file == no {
+				delete(r.imaged, key)
+			}
+		}
+		return lsn, r.flush(lsn)
+	})
+	return err
+}
+
+// appendRoom runs fn, which appends to the log, with the log locked. If the log cannot switch to the
+// next group until a checkpoint, and it has been given a way to take one, it does so and runs fn
+// again. The checkpoint needs the buffer pool, so the pool must not be locked by the caller.
+func (r *RedoLog) appendRoom(fn func() (uint64, error)) (uint64, error) {
+	for checkpointed := false; ; checkpointed = true {
+		r.mu.Lock()
+		lsn, err := fn()
+		checkpointer := r.checkpointer
+		r.mu.Unlock()
+
+		if err != ErrCheckpointNotComplete || checkpointer == nil || checkpointed {
+			return lsn, err
+		}
+		if err = checkpointer(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// Flush returns once every record up to the LSN is on disk.
+func (r *RedoLog) Flush(lsn uint64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flush(lsn)
+}
+
+// FlushedLSN is the last LSN on disk.
+func (r *RedoLog) FlushedLSN() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.flushed
+}
+
+// Checkpoint writes the dirty pages of the pool and syncs the datafiles they are in, and any others
+// given, so that the log groups holding their changes can be used again. The changes on pages that are pinned are not written, and
+// the groups holding them are kept.
+func (r *RedoLog) Checkpoint(pool *BufferPool, files ...*DataFile) error {
+	r.mu.Lock()
+	lsn := r.nextLSN - 1
+	err := r.flush(lsn)
+	// pages changed from here on are logged whole again, in case they are torn as they are written
+	r.imaged = make(map[pageKey][]byte)
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	written, oldest, err := pool.flushUnpinned()
+	if err != nil {
+		return err
+	}
+	synced := make(map[*DataFile]bool)
+	for _, df := range append(written, files...) {
+		if synced[df] {
+			continue
+		}
+		synced[df] = true
+		if err = df.Sync(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	// a page that was pinned still holds changes that are not in its file
+	if oldest != 0 && oldest <= lsn {
+		lsn = oldest - 1
+	}
+	if lsn > r.checkpoint {
+		r.checkpoint = lsn
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+// Recover replays the log into the datafiles and returns the transactions it found commit records
+// of. Every datafile named in the log must be given.
+func (r *RedoLog) Recover(files map[FileNo]*DataFile) (map[uint64]bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var groups []*logGroup
+	for _, g := range r.groups {
+		if g.seq != 0 {
+			groups = append(groups, g)
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].seq < groups[j].seq })
+
+	dropped := make(map[FileNo]uint64)
+	for _, g := range groups {
+		_, _, err := readRecords(g.best, g.Size, g.seq, func(rec *redoRecord) error {
+			if rec.kind == redoDrop {
+				dropped[rec.file] = rec.lsn
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pages := make(map[pageKey]*Page)
+	torn := make(map[pageKey]bool)
+	changed := make(map[pageKey]bool)
+	committed := make(map[uint64]bool)
+
+	replay := func(rec *redoRecord) error {
+		if rec.txn > r.lastTxn {
+			r.lastTxn = rec.txn
+		}
+		if rec.kind == redoCommit {
+			committed[rec.txn] = true
+			return nil
+		}
+		if rec.kind == redoDrop || rec.lsn < dropped[rec.file] {
+			return nil
+		}
+
+		df := files[rec.file]
+		if df == nil {
+			return fmt.Errorf("redo log has changes to datafile %v, which was not given", rec.file)
+		}
+		// a datafile is written whole before it goes offline
+		if df.Status() == FileOffline {
+			return nil
+		}
+
+		key := pageKey{rec.file, rec.page}
+		page := pages[key]
+		if page == nil && !torn[key] {
+			var err error
+			if page, err = df.Read(rec.page); errors.Is(err, ErrCorruptPage) {
+				torn[key] = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if torn[key] {
+			if rec.kind != redoImage {
+				return nil
+			}
+			page = newPage(rec.page, df.PageSize(), PageFree)
+			delete(torn, key)
+		}
+		pages[key] = page
+
+		if rec.offset+len(rec.data) > len(page.data) {
+			return fmt.Errorf("redo record %v does not fit in page %v of datafile %v", rec.lsn, rec.page, rec.file)
+		}
+		if page.LSN() < rec.lsn {
+			copy(page.data[rec.offset:], rec.data)
+			page.SetLSN(rec.lsn)
+			changed[key] = true
+		}
+		return nil
+	}
+
+	for _, g := range groups {
+		if _, _, err := readRecords(g.best, g.Size, g.seq, replay); err != nil {
+			return nil, err
+		}
+	}
+
+	for key := range torn {
+		return nil, corruptPage(fmt.Sprintf("%v: page %v cannot be recovered: %v", files[key.file].Path(), key.page, ErrCorruptPage))
+	}
+
+	written := make(map[FileNo]bool)
+	for key := range changed {
+		if err := files[key.file].Write(pages[key]); err != nil {
+			return nil, err
+		}
+		written[key.file] = true
+	}
+	for no := range written {
+		if err := files[no].Sync(); err != nil {
+			return nil, err
+		}
+	}
+
+	r.checkpoint = r.nextLSN - 1
+	r.imaged = make(map[pageKey][]byte)
+	return committed, nil
+}
+
+// LastTxn is the highest transaction that Recover found changes or a commit of, 0 if none.
+func (r *RedoLog) LastTxn() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastTxn
+}
+
+// Close writes what is buffered and closes the members.
+func (r *RedoLog) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var err error
+	if r.nextLSN != 0 {
+		err = r.flush(r.nextLSN - 1)
+	}
+	for _, g := range r.groups {
+		for _, file := range g.files {
+			if file != nil {
+				if e := file.Close(); err == nil {
+					err = e
+				}
+			}
+		}
+	}
+	return err
+}
+
+// append adds a record to the buffer, switching groups first if the current one is too full.
+func (r *RedoLog) append(kind byte, txn uint64, key pageKey, offset int, data []byte) (uint64, error) {
+	size := int64(redoRecordHeader + redoPayloadHeader + len(data))
+	g := r.groups[r.current]
+
+	if redoHeaderSize+size > g.Size {
+		return 0, fmt.Errorf("redo record of %v bytes does not fit in a log group", size)
+	}
+	if r.pos+size > g.Size {
+		if err := r.switchGroup(); err != nil {
+			return 0, err
+		}
+		g = r.groups[r.current]
+	}
+
+	lsn := r.nextLSN
+	rec := make([]byte, size)
+	payload := rec[redoRecordHeader:]
+	binary.LittleEndian.PutUint64(payload[0:], lsn)
+	binary.LittleEndian.PutUint32(payload[8:], g.seq)
+	payload[12] = kind
+	binary.LittleEndian.PutUint64(payload[13:], txn)
+	binary.LittleEndian.PutUint16(payload[21:], key.file)
+	binary.LittleEndian.PutUint32(payload[23:], key.page)
+	binary.LittleEndian.PutUint16(payload[27:], uint16(offset))
+	copy(payload[redoPayloadHeader:], data)
+	binary.LittleEndian.PutUint32(rec[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(rec[4:], crc32.Checksum(payload, crcTable))
+
+	r.buffer = append(r.buffer, rec...)
+	r.pos += size
+	r.nextLSN++
+	g.lastLSN = lsn
+	return lsn, nil
+}
+
+// switchGroup finishes the current group and starts the next one.
+func (r *RedoLog) switchGroup() error {
+	if err := r.flush(r.nextLSN - 1); err != nil {
+		return err
+	}
+
+	current := r.groups[r.current]
+	next := r.groups[(r.current+1)%len(r.groups)]
+	if next.seq != 0 && next.lastLSN > r.checkpoint {
+		return ErrCheckpointNotComplete
+	}
+
+	next.seq = current.seq + 1
+	next.firstLSN = r.nextLSN
+	next.lastLSN = 0
+	if err := r.writeHeader(next); err != nil {
+		return err
+	}
+	if err := r.syncMembers(next); err != nil {
+		return err
+	}
+
+	r.current = (r.current + 1) % len(r.groups)
+	r.pos, r.written = redoHeaderSize, redoHeaderSize
+	return nil
+}
+
+func (r *RedoLog) flush(lsn uint64) error {
+	if lsn <= r.flushed || len(r.buffer) == 0 {
+		return nil
+	}
+
+	g := r.groups[r.current]
+	if err := r.writeMembers(g, r.buffer, r.written); err != nil {
+		return err
+	}
+	if err := r.syncMembers(g); err != nil {
+		return err
+	}
+
+	r.written = r.pos
+	r.buffer = r.buffer[:0]
+	r.flushed = r.nextLSN - 1
+	return nil
+}
+
+func (r *RedoLog) writeMembers(g *logGroup, data []byte, at int64) error {
+	for _, file := range g.files {
+		if file == nil {
+			continue
+		}
+		if _, err := file.WriteAt(data, at); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RedoLog) syncMembers(g *logGroup) error {
+	for _, file := range g.files {
+		if file == nil {
+			continue
+		}
+		if err := file.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,404 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type redoSetup struct {
+	dir    string
+	groups []LogGroup
+	df     *DataFile
+	log    *RedoLog
+	pool   *BufferPool
+}
+
+// newRedoSetup makes a datafile and a log of two groups with two members each.
+func newRedoSetup(t *testing.T) *redoSetup {
+	dir := t.TempDir()
+	s := &redoSetup{dir: dir}
+	for _, g := range []string{"1", "2"} {
+		s.groups = append(s.groups, LogGroup{
+			Members: []string{filepath.Join(dir, "redo"+g+"a.log"), filepath.Join(dir, "redo"+g+"b.log")},
+			Size:    MinLogSize,
+		})
+	}
+
+	var e error
+	if s.df, e = CreateDataFile(filepath.Join(dir, "test.dbf"), 7, MinPageSize); e != nil {
+		t.Fatal(e)
+	}
+	if s.log, e = CreateRedoLog(s.groups); e != nil {
+		t.Fatal(e)
+	}
+	s.pool = NewBufferPool(8)
+	s.pool.SetRedoLog(s.log)
+
+	t.Cleanup(func() { s.crash() })
+	return s
+}
+
+// change writes text into a page for a transaction, and logs it.
+func (s *redoSetup) change(t *testing.T, txn uint64, no PageNo, offset int, text string) uint64 {
+	p, e := s.pool.Get(s.df, no)
+	if e != nil {
+		t.Fatal(e)
+	}
+	copy(p.Body()[offset:], text)
+	lsn, e := s.log.Log(txn, s.df, p, offset, len(text))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = s.pool.Unpin(s.df, no, true); e != nil {
+		t.Fatal(e)
+	}
+	return lsn
+}
+
+// crash drops the pool without writing its pages, and closes the files.
+func (s *redoSetup) crash() {
+	s.pool = nil
+	_ = s.log.Close()
+	_ = s.df.Close()
+}
+
+// recover opens the files again and replays the log, and returns the transactions it found committed.
+func (s *redoSetup) recover(t *testing.T) map[uint64]bool {
+	var e error
+	if s.df, e = OpenDataFile(s.df.Path()); e != nil {
+		t.Fatal(e)
+	}
+	if s.log, e = OpenRedoLog(s.groups); e != nil {
+		t.Fatal(e)
+	}
+	committed, e := s.log.Recover(map[FileNo]*DataFile{7: s.df})
+	if e != nil {
+		t.Fatal(e)
+	}
+	s.pool = NewBufferPool(8)
+	s.pool.SetRedoLog(s.log)
+	return committed
+}
+
+func (s *redoSetup) body(t *testing.T, no PageNo) []byte {
+	p, e := s.df.Read(no)
+	if e != nil {
+		t.Fatal(e)
+	}
+	return p.Body()
+}
+
+func (s *redoSetup) allocate(t *testing.T) PageNo {
+	p, e := s.pool.Allocate(s.df, PageData)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_ = s.pool.Unpin(s.df, p.No, false)
+	return p.No
+}
+
+func TestRedoRecovery(t *testing.T) {
+	s := newRedoSetup(t)
+	no := s.allocate(t)
+
+	s.change(t, 1, no, 0, "hello")
+	if e := s.log.Commit(1); e != nil {
+		t.Fatal(e)
+	}
+	lsn := s.change(t, 2, no, 10, "world")
+	if e := s.log.Flush(lsn); e != nil {
+		t.Fatal(e)
+	}
+	if s.log.FlushedLSN() != lsn {
+		t.Errorf("expected LSN %v on disk, got %v", lsn, s.log.FlushedLSN())
+	}
+
+	s.crash()
+	if raw, _ := os.ReadFile(filepath.Join(s.dir, "test.dbf")); raw[int(no)*MinPageSize+PageHeaderSize] != 0 {
+		t.Fatal("the page should not have been written")
+	}
+
+	// every change is made again; the one that did not commit is left for undo
+	committed := s.recover(t)
+	if len(committed) != 1 || !committed[1] {
+		t.Errorf("expected transaction 1 to have committed, got %v", committed)
+	}
+	if body := s.body(t, no); string(body[:5]) != "hello" || string(body[10:15]) != "world" {
+		t.Errorf("changes not recovered: %q", body[:15])
+	}
+
+	// recovering again changes nothing, and the log carries on where it was
+	s.crash()
+	s.recover(t)
+	if next := s.change(t, 3, no, 20, "again"); next != lsn+1 {
+		t.Errorf("expected LSN %v, got %v", lsn+1, next)
+	}
+}
+
+func TestRedoDroppedFile(t *testing.T) {
+	s := newRedoSetup(t)
+	no := s.allocate(t)
+
+	// changes to a datafile that was dropped are not made again, even once its number is used again
+	dropped, e := CreateDataFile(filepath.Join(s.dir, "dropped.dbf"), 8, MinPageSize)
+	if e != nil {
+		t.Fatal(e)
+	}
+	page, e := s.pool.Allocate(dropped, PageData)
+	if e != nil {
+		t.Fatal(e)
+	}
+	copy(page.Body(), "old")
+	_ = s.pool.Unpin(dropped, page.No, true)
+	if e = s.pool.Evict(dropped); e != nil {
+		t.Fatal(e)
+	}
+	_ = dropped.Close()
+	if e = s.log.DropFile(8); e != nil {
+		t.Fatal(e)
+	}
+
+	again, e := CreateDataFile(filepath.Join(s.dir, "again.dbf"), 8, MinPageSize)
+	if e != nil {
+		t.Fatal(e)
+	}
+	page, e = s.pool.Allocate(again, PageData)
+	if e != nil {
+		t.Fatal(e)
+	}
+	copy(page.Body()[4:], "new")
+	_ = s.pool.Unpin(again, page.No, true)
+	lsn := s.change(t, 1, no, 0, "kept")
+	if e = s.log.Flush(lsn); e != nil {
+		t.Fatal(e)
+	}
+
+	s.crash()
+	_ = again.Close()
+	if again, e = OpenDataFile(again.Path()); e != nil {
+		t.Fatal(e)
+	}
+	defer again.Close()
+	log, e := OpenRedoLog(s.groups)
+	if e != nil {
+		t.Fatal(e)
+	}
+	s.log = log
+	if _, e = log.Recover(map[FileNo]*DataFile{7: s.df}); e == nil {
+		t.Error("expected an error for the datafile that took the dropped one's number")
+	}
+	if s.df, e = OpenDataFile(s.df.Path()); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = log.Recover(map[FileNo]*DataFile{7: s.df, 8: again}); e != nil {
+		t.Fatal(e)
+	}
+	if body := s.body(t, no); string(body[:4]) != "kept" {
+		t.Errorf("change not recovered: %q", body[:4])
+	}
+	p, e := again.Read(page.No)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if body := p.Body(); string(body[:7]) != "\x00\x00\x00\x00new" {
+		t.Errorf("expected only the changes after the drop, got %q", body[:7])
+	}
+}
+
+func TestRedoTornRecord(t *testing.T) {
+	for _, cut := range []string{"change", "commit"} {
+		s := newRedoSetup(t)
+		no := s.allocate(t)
+
+		s.change(t, 1, no, 0, "first")
+		_ = s.log.Commit(1)
+
+		start := s.log.pos
+		s.change(t, 2, no, 0, "second")
+		middle := s.log.pos
+		_ = s.log.Commit(2)
+		end := s.log.pos
+
+		// the crash comes part-way through writing a record to both members
+		at := (start + middle) / 2
+		if cut == "commit" {
+			at = (middle + end) / 2
+		}
+		s.crash()
+		for _, path := range s.groups[0].Members {
+			if e := os.Truncate(path, at); e != nil {
+				t.Fatal(e)
+			}
+		}
+
+		committed := s.recover(t)
+		body := s.body(t, no)
+		if len(committed) != 1 || !committed[1] {
+			t.Errorf("cut in %v: expected only transaction 1 to have committed, got %v", cut, committed)
+		}
+		switch cut {
+		case "change":
+			if string(body[:5]) != "first" {
+				t.Errorf("cut in change: page %q", body[:6])
+			}
+		case "commit":
+			if string(body[:6]) != "second" {
+				t.Errorf("cut in commit: page %q", body[:6])
+			}
+		}
+
+		// new records go where the torn one was
+		lsn := s.change(t, 4, no, 0, "third")
+		_ = s.log.Commit(4)
+		s.crash()
+		s.recover(t)
+		if body = s.body(t, no); string(body[:5]) != "third" || lsn == 0 {
+			t.Errorf("cut in %v: later change not recovered: %q", cut, body[:5])
+		}
+	}
+}
+
+func TestRedoMirroredMembers(t *testing.T) {
+	s := newRedoSetup(t)
+	no := s.allocate(t)
+
+	s.change(t, 1, no, 0, "kept")
+	_ = s.log.Commit(1)
+	end := s.log.pos
+	s.crash()
+
+	// one member lost the end of its last write, the other is fine
+	a, b := s.groups[0].Members[0], s.groups[0].Members[1]
+	if e := os.Truncate(a, end-3); e != nil {
+		t.Fatal(e)
+	}
+	if committed := s.recover(t); !committed[1] || string(s.body(t, no)[:4]) != "kept" {
+		t.Errorf("expected the commit from the good member, committed %v", committed)
+	}
+	s.crash()
+
+	ca, _ := os.ReadFile(a)
+	cb, _ := os.ReadFile(b)
+	if !bytes.Equal(ca[:end], cb[:end]) {
+		t.Error("the short member was not made whole")
+	}
+
+	// a member that is gone altogether comes back from the other
+	if e := os.Remove(b); e != nil {
+		t.Fatal(e)
+	}
+	s.recover(t)
+	s.change(t, 2, no, 0, "more")
+	_ = s.log.Commit(2)
+	s.crash()
+
+	if e := os.Remove(a); e != nil {
+		t.Fatal(e)
+	}
+	if committed := s.recover(t); !committed[2] || string(s.body(t, no)[:4]) != "more" {
+		t.Errorf("expected the change from the rebuilt member, committed %v", committed)
+	}
+}
+
+func TestRedoSwitchAndCheckpoint(t *testing.T) {
+	s := newRedoSetup(t)
+	no := s.allocate(t)
+
+	write := func(i uint64) error {
+		p, _ := s.pool.Get(s.df, no)
+		defer s.pool.Unpin(s.df, no, true)
+		binary.LittleEndian.PutUint64(p.Body(), i)
+		_, e := s.log.Log(i, s.df, p, 0, 8)
+		if e == nil {
+			e = s.log.Commit(i)
+		}
+		return e
+	}
+
+	// without a checkpoint the log fills both groups and cannot go on
+	i := uint64(1)
+	var e error
+	for ; i < 10000; i++ {
+		if e = write(i); e != nil {
+			break
+		}
+	}
+	if e != ErrCheckpointNotComplete {
+		t.Fatalf("expected the log to be full, got %v after %v records", e, i)
+	}
+	if s.log.groups[1].seq != 2 {
+		t.Errorf("expected the second group in use, got sequence %v", s.log.groups[1].seq)
+	}
+
+	if e = s.log.Checkpoint(s.pool, s.df); e != nil {
+		t.Fatal(e)
+	}
+	last := i + 3000
+	for ; i <= last; i++ {
+		if e = write(i); e == ErrCheckpointNotComplete {
+			if e = s.log.Checkpoint(s.pool, s.df); e == nil {
+				e = write(i)
+			}
+		}
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	if s.log.groups[s.log.current].seq < 4 {
+		t.Errorf("expected the log to have switched a few times, at sequence %v", s.log.groups[s.log.current].seq)
+	}
+
+	s.crash()
+	if committed := s.recover(t); !committed[last] {
+		t.Errorf("expected transaction %v to have committed, got %v", last, committed)
+	}
+	if got := binary.LittleEndian.Uint64(s.body(t, no)); got != last {
+		t.Errorf("expected %v after recovery, got %v", last, got)
+	}
+}
+
+func TestRedoTornPage(t *testing.T) {
+	s := newRedoSetup(t)
+	no := s.allocate(t)
+
+	s.change(t, 1, no, 0, "before")
+	_ = s.log.Checkpoint(s.pool, s.df)
+
+	// the first change after the checkpoint logs the whole page
+	s.change(t, 2, no, 100, "after")
+	_ = s.log.Commit(2)
+	_ = s.pool.Flush(s.df)
+	s.crash()
+
+	// the page was torn as it was written
+	file, _ := os.OpenFile(filepath.Join(s.dir, "test.dbf"), os.O_RDWR, 0)
+	_, _ = file.WriteAt(make([]byte, 500), int64(no)*MinPageSize+MinPageSize/2)
+	_ = file.Close()
+
+	s.recover(t)
+	if body := s.body(t, no); string(body[:6]) != "before" || string(body[100:105]) != "after" {
+		t.Errorf("torn page not rebuilt: %q %q", body[:6], body[100:105])
+	}
+}
+
+func TestRedoLogSetup(t *testing.T) {
+	dir := t.TempDir()
+	member := func(name string) []string { return []string{filepath.Join(dir, name)} }
+
+	for _, groups := range [][]LogGroup{
+		{{Members: member("a"), Size: MinLogSize}},
+		{{Members: member("a"), Size: MinLogSize}, {Size: MinLogSize}},
+		{{Members: member("a"), Size: MinLogSize}, {Members: member("b"), Size: 1024}},
+	} {
+		if _, e := CreateRedoLog(groups); e == nil {
+			t.Errorf("expected error creating %+v", groups)
+		}
+	}
+
+	if _, e := OpenRedoLog([]LogGroup{{Members: member("x"), Size: MinLogSize}, {Members: member("y"), Size: MinLogSize}}); e == nil {
+		t.Error("expected error opening a log that was never created")
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var errBadRow = errors.New("row data is damaged")
+
+/*
+A row is its column count, a NULL bitmap, then each column that is not NULL as its length and bytes:
+
+  uvarint          number of columns
+  (n + 7) / 8      NULL bitmap; bit i%8 of byte i/8 is set when column i is NULL
+  for each column that is not NULL:
+    uvarint        length
+    bytes          value
+
+How a value is turned into bytes is up to its type; the heap only keeps the bytes.
+*/
+
+// EncodeRow packs column values into a row. A nil value is NULL; an empty one is not.
+func EncodeRow(values [][]byte) []byte {
+	size := binary.MaxVarintLen64 + (len(values)+7)/8
+	for _, v := range values {
+		size += binary.MaxVarintLen64 + len(v)
+	}
+
+	row := make([]byte, 0, size)
+	row = binary.AppendUvarint(row, uint64(len(values)))
+
+	nulls := len(row)
+	row = append(row, make([]byte, (len(values)+7)/8)...)
+
+	for i, v := range values {
+		if v == nil {
+			row[nulls+i/8] |= 1 << (i % 8)
+			continue
+		}
+		row = binary.AppendUvarint(row, uint64(len(v)))
+		row = append(row, v...)
+	}
+
+	return row
+}
+
+// DecodeRow unpacks a row made by EncodeRow. The values share memory with the row.
+func DecodeRow(row []byte) ([][]byte, error) {
+	count, n := binary.Uvarint(row)
+	if n <= 0 || count > uint64(len(row))*8 {
+		return nil, errBadRow
+	}
+	row = row[n:]
+
+	bitmap := (int(count) + 7) / 8
+	if len(row) < bitmap {
+		return nil, errBadRow
+	}
+	nulls, row := row[:bitmap], row[bitmap:]
+
+	values := make([][]byte, count)
+	for i := range values {
+		if nulls[i/8]&(1<<(i%8)) != 0 {
+			continue
+		}
+		length, n := binary.Uvarint(row)
+		if n <= 0 || length > uint64(len(row)-n) {
+			return nil, errBadRow
+		}
+		values[i] = row[n : n+int(length) : n+int(length)]
+		row = row[n+int(length):]
+	}
+
+	if len(row) != 0 {
+		return nil, errBadRow
+	}
+
+	return values, nil
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RowID is where a row lives: its datafile, page and slot. It stays the same for as long as the
+// row exists, even when an update moves the row's data to another page.
+type RowID struct {
+	File FileNo
+	Page PageNo
+	Slot uint16
+}
+
+// rowIDSize is the size of a RowID in a page.
+const rowIDSize = 8
+
+// String writes the RowID as the hexadecimal file, page and slot: 0001.0000002A.0003. The text
+// sorts in the same order as the rows lie in the datafiles.
+func (id RowID) String() string {
+	return fmt.Sprintf("%04X.%08X.%04X", id.File, id.Page, id.Slot)
+}
+
+// ParseRowID reads the text form of a RowID, as String writes it.
+func ParseRowID(text string) (RowID, error) {
+	parts := strings.Split(text, ".")
+	if len(parts) != 3 || len(parts[0]) != 4 || len(parts[1]) != 8 || len(parts[2]) != 4 {
+		return RowID{}, fmt.Errorf("invalid ROWID: %v", text)
+	}
+
+	var values [3]uint64
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 16, 64)
+		if err != nil {
+			return RowID{}, fmt.Errorf("invalid ROWID: %v", text)
+		}
+		values[i] = v
+	}
+
+	return RowID{File: FileNo(values[0]), Page: PageNo(values[1]), Slot: uint16(values[2])}, nil
+}
+
+func (id RowID) put(b []byte) {
+	binary.LittleEndian.PutUint16(b[0:], id.File)
+	binary.LittleEndian.PutUint32(b[2:], id.Page)
+	binary.LittleEndian.PutUint16(b[6:], id.Slot)
+}
+
+func getRowID(b []byte) RowID {
+	return RowID{
+		File: binary.LittleEndian.Uint16(b[0:]),
+		Page: binary.LittleEndian.Uint32(b[2:]),
+		Slot: binary.LittleEndian.Uint16(b[6:]),
+	}
+}
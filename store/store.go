@@ -1,6 +1,2 @@
+// Package store keeps the database on disk: datafiles of fixed-size pages, and what is built on them.
 package store
-
-func x()  {
-
-}
-
@@ -0,0 +1,644 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// ErrTablespaceFull is returned when a page is needed and no datafile has one free or can grow.
+var ErrTablespaceFull = &Error{Code: 4, Message: "Unable to extend tablespace"}
+
+// ErrReadOnly is returned when changing what is in a read only tablespace.
+var ErrReadOnly = &Error{Code: 5, Message: "Tablespace is read only"}
+
+// ErrOffline is returned when reading or changing what is in a tablespace that is offline.
+var ErrOffline = &Error{Code: 6, Message: "Tablespace is offline"}
+
+// TablespaceKind says what a tablespace holds.
+type TablespaceKind int
+
+const (
+	PermanentTablespace TablespaceKind = iota // tables and indexes
+	TemporaryTablespace                       // what sorts and hash joins spill
+	UndoTablespace                            // undo
+)
+
+// DataFileSpec says where a datafile goes and how big it is, in bytes. The sizes are rounded down
+// to whole pages.
+type DataFileSpec struct {
+	Path    string
+	Size    int64 // how big it starts
+	Reuse   bool  // overwrite a file that is already there
+	Next    int64 // how much it grows by when it is full; 0 if it does not grow
+	MaxSize int64 // how big it may grow; 0 for no limit
+}
+
+/*
+A tablespace is a named set of datafiles of the same page size. A segment - the pages of a table,
+an index or the undo - is made in the first datafile of its tablespace that has room for it, and
+stays in that file, growing as the file does. When that file cannot grow any more the segment is
+full, even if other files of the tablespace have room.
+
+Tablespaces keeps every tablespace of the database by name, along with the defaults: where a table
+or index goes if its statement does not say, where temporary work goes, and which tablespace holds
+the undo.
+*/
+
+// Tablespaces is the set of tablespaces of a database.
+type Tablespaces struct {
+	mu       sync.Mutex
+	pool     *BufferPool
+	dbid     uint64
+	byName   map[string]*Tablespace
+	lastFile FileNo
+	defaults map[TablespaceKind]string
+}
+
+// Tablespace is a named set of datafiles.
+type Tablespace struct {
+	mu       sync.Mutex
+	set      *Tablespaces
+	name     string
+	kind     TablespaceKind
+	pageSize int
+	status   FileStatus
+	files    []*DataFile
+}
+
+// NewTablespaces starts an empty set of tablespaces for the database with the given ID.
+func NewTablespaces(pool *BufferPool, dbid uint64) *Tablespaces {
+	return &Tablespaces{
+		pool:     pool,
+		dbid:     dbid,
+		byName:   make(map[string]*Tablespace),
+		defaults: make(map[TablespaceKind]string),
+	}
+}
+
+// TablespaceInfo describes a tablespace for the control file.
+type TablespaceInfo struct {
+	Name     string
+	Kind     TablespaceKind
+	PageSize int
+	Status   FileStatus
+	Default  bool
+	Files    []DataFileInfo
+}
+
+// DataFileInfo describes a datafile for the control file.
+type DataFileInfo struct {
+	No   FileNo
+	Path string
+}
+
+// OpenTablespaces opens the datafiles of the tablespaces the control file describes, and checks that
+// each belongs where the control file says.
+func OpenTablespaces(pool *BufferPool, dbid uint64, infos []TablespaceInfo) (*Tablespaces, error) {
+	s := NewTablespaces(pool, dbid)
+
+	for _, info := range infos {
+		ts := &Tablespace{set: s, name: info.Name, kind: info.Kind, pageSize: info.PageSize, status: info.Status}
+		s.byName[info.Name] = ts
+		if info.Default {
+			s.defaults[info.Kind] = info.Name
+		}
+
+		for _, file := range info.Files {
+			df, err := OpenDataFile(file.Path)
+			if err != nil {
+				_ = s.Close()
+				return nil, err
+			}
+			ts.files = append(ts.files, df)
+
+			owner := df.Owner()
+			switch {
+			case df.No() != file.No:
+				err = fmt.Errorf("%v is datafile %v, not %v", file.Path, df.No(), file.No)
+			case owner.DBID != dbid:
+				err = fmt.Errorf("%v belongs to another database", file.Path)
+			case owner.Tablespace != info.Name || owner.Kind != info.Kind:
+				err = fmt.Errorf("%v belongs to tablespace %v, not %v", file.Path, owner.Tablespace, info.Name)
+			case df.PageSize() != info.PageSize:
+				err = fmt.Errorf("%v has %v byte pages, not %v", file.Path, df.PageSize(), info.PageSize)
+			case df.Status() != info.Status:
+				// the control file is written after the files, so it may be behind
+				err = df.SetStatus(info.Status)
+			}
+			if err != nil {
+				_ = s.Close()
+				return nil, err
+			}
+
+			if file.No > s.lastFile {
+				s.lastFile = file.No
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// Info describes every tablespace, in the order their first datafiles were made.
+func (s *Tablespaces) Info() []TablespaceInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var infos []TablespaceInfo
+	for name, ts := range s.byName {
+		ts.mu.Lock()
+		info := TablespaceInfo{
+			Name:     name,
+			Kind:     ts.kind,
+			PageSize: ts.pageSize,
+			Status:   ts.status,
+			Default:  s.defaults[ts.kind] == name,
+		}
+		for _, df := range ts.files {
+			info.Files = append(info.Files, DataFileInfo{df.No(), df.Path()})
+		}
+		ts.mu.Unlock()
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Files[0].No < infos[j].Files[0].No })
+	return infos
+}
+
+// Close writes what the pool holds of every datafile and closes them all.
+func (s *Tablespaces) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var first error
+	for _, ts := range s.byName {
+		for _, df := range ts.files {
+			if err := s.closeFile(df, false); err != nil && first == nil {
+				first = err
+			}
+		}
+		ts.files = nil
+	}
+	return first
+}
+
+// Create makes a tablespace and its datafiles. A page size of 0 means DefaultPageSize.
+func (s *Tablespaces) Create(name string, kind TablespaceKind, pageSize int, files []DataFileSpec) (*Tablespace, error) {
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("tablespace %v needs at least one datafile", name)
+	}
+	if len(name) > MaxTablespaceName {
+		return nil, fmt.Errorf("tablespace name %v is longer than %v bytes", name, MaxTablespaceName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.byName[name] != nil {
+		return nil, fmt.Errorf("tablespace %v already exists", name)
+	}
+
+	ts := &Tablespace{set: s, name: name, kind: kind, pageSize: pageSize}
+	for _, spec := range files {
+		df, err := s.createFile(spec, ts)
+		if err != nil {
+			for _, made := range ts.files {
+				_ = made.Close()
+				_ = os.Remove(made.Path())
+			}
+			return nil, err
+		}
+		ts.files = append(ts.files, df)
+	}
+
+	s.byName[name] = ts
+	return ts, nil
+}
+
+// createFile makes a datafile of a tablespace with the next file number, at its starting size.
+func (s *Tablespaces) createFile(spec DataFileSpec, ts *Tablespace) (*DataFile, error) {
+	pageSize := ts.pageSize
+	if spec.Size < 0 || spec.Next < 0 || spec.MaxSize < 0 {
+		return nil, fmt.Errorf("%v: sizes cannot be negative", spec.Path)
+	}
+	if spec.MaxSize != 0 && spec.MaxSize < spec.Size {
+		return nil, fmt.Errorf("%v: the maximum size is less than the size", spec.Path)
+	}
+	if s.lastFile == ^FileNo(0) {
+		return nil, errors.New("the database has as many datafiles as it can")
+	}
+
+	if spec.Reuse {
+		if err := os.Remove(spec.Path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	df, err := CreateDataFile(spec.Path, s.lastFile+1, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	pages := func(size int64) PageNo { return PageNo(size / int64(pageSize)) }
+	err = df.SetOwner(FileOwner{DBID: s.dbid, Tablespace: ts.name, Kind: ts.kind, Default: s.defaults[ts.kind] == ts.name})
+	if err == nil {
+		err = df.SetAutoextend(pages(spec.Next), pages(spec.MaxSize))
+	}
+	if err == nil && pages(spec.Size) > 2 {
+		err = df.Resize(pages(spec.Size))
+	}
+	if err == nil {
+		err = df.Sync()
+	}
+	if err != nil {
+		_ = df.Close()
+		_ = os.Remove(spec.Path)
+		return nil, err
+	}
+
+	s.lastFile++
+	return df, nil
+}
+
+// Get finds a tablespace by name.
+func (s *Tablespaces) Get(name string) (*Tablespace, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(name)
+}
+
+func (s *Tablespaces) get(name string) (*Tablespace, error) {
+	ts := s.byName[name]
+	if ts == nil {
+		return nil, fmt.Errorf("tablespace %v does not exist", name)
+	}
+	return ts, nil
+}
+
+// SetDefault makes a tablespace the default for its kind: for tables and indexes, for temporary
+// work, or for undo.
+func (s *Tablespaces) SetDefault(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	if old := s.byName[s.defaults[ts.kind]]; old != nil && old != ts {
+		if err = old.setOwner(func(o *FileOwner) { o.Default = false }); err != nil {
+			return err
+		}
+	}
+	if err = ts.setOwner(func(o *FileOwner) { o.Default = true }); err != nil {
+		return err
+	}
+	s.defaults[ts.kind] = name
+	return nil
+}
+
+// Default returns the default tablespace of a kind, or nil if there is none.
+func (s *Tablespaces) Default(kind TablespaceKind) *Tablespace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byName[s.defaults[kind]]
+}
+
+// ForSegment finds the tablespace a table or index goes in: the one named, or the default if the
+// name is empty.
+func (s *Tablespaces) ForSegment(name string) (*Tablespace, error) {
+	if name == "" {
+		if ts := s.Default(PermanentTablespace); ts != nil {
+			return ts, nil
+		}
+		return nil, errors.New("there is no default tablespace")
+	}
+
+	ts, err := s.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if ts.kind != PermanentTablespace {
+		return nil, fmt.Errorf("tablespace %v cannot hold tables and indexes", name)
+	}
+	return ts, nil
+}
+
+// Rename gives a tablespace a new name.
+func (s *Tablespaces) Rename(name, newName string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	if s.byName[newName] != nil {
+		return fmt.Errorf("tablespace %v already exists", newName)
+	}
+
+	if err = ts.setOwner(func(o *FileOwner) { o.Tablespace = newName }); err != nil {
+		return err
+	}
+
+	delete(s.byName, name)
+	s.byName[newName] = ts
+	for kind, def := range s.defaults {
+		if def == name {
+			s.defaults[kind] = newName
+		}
+	}
+
+	ts.mu.Lock()
+	ts.name = newName
+	ts.mu.Unlock()
+	return nil
+}
+
+// Drop removes a tablespace. Unless contents is true it must be empty; the datafiles are closed,
+// and deleted too if deleteFiles is true. A default tablespace cannot be dropped.
+func (s *Tablespaces) Drop(name string, contents bool, deleteFiles bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts, err := s.get(name)
+	if err != nil {
+		return err
+	}
+	for _, def := range s.defaults {
+		if def == name {
+			return fmt.Errorf("tablespace %v is a default tablespace and cannot be dropped", name)
+		}
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if !contents {
+		for _, df := range ts.files {
+			if used, err := df.UsedPages(); err != nil {
+				return err
+			} else if used > 0 {
+				return fmt.Errorf("tablespace %v is not empty", name)
+			}
+		}
+	}
+
+	for _, df := range ts.files {
+		if err = s.closeFile(df, deleteFiles); err != nil {
+			return err
+		}
+	}
+	ts.files = nil
+	delete(s.byName, name)
+
+	return nil
+}
+
+// closeFile drops a datafile's pages from the pool and closes it, deleting it if asked to.
+func (s *Tablespaces) closeFile(df *DataFile, deleteFile bool) error {
+	// what is in a file that is offline has already been written
+	if df.Status() != FileOffline {
+		if err := s.pool.Evict(df); err != nil {
+			return err
+		}
+	}
+	// the redo log must not make its changes again after a crash
+	if redo := s.pool.redoLog(); redo != nil {
+		if err := redo.DropFile(df.No()); err != nil {
+			return err
+		}
+	}
+	if err := df.Close(); err != nil {
+		return err
+	}
+	if deleteFile {
+		return os.Remove(df.Path())
+	}
+	return nil
+}
+
+func (ts *Tablespace) Name() string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.name
+}
+
+func (ts *Tablespace) Kind() TablespaceKind {
+	return ts.kind
+}
+
+func (ts *Tablespace) PageSize() int {
+	return ts.pageSize
+}
+
+func (ts *Tablespace) Status() FileStatus {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.status
+}
+
+// DataFiles returns the datafiles of the tablespace, in the order they were added.
+func (ts *Tablespace) DataFiles() []*DataFile {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return append([]*DataFile(nil), ts.files...)
+}
+
+// AddDataFile makes another datafile for the tablespace.
+func (ts *Tablespace) AddDataFile(spec DataFileSpec) (*DataFile, error) {
+	ts.set.mu.Lock()
+	defer ts.set.mu.Unlock()
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.status != FileOnline {
+		return nil, fmt.Errorf("tablespace %v must be online and read write to add a datafile", ts.name)
+	}
+
+	df, err := ts.set.createFile(spec, ts)
+	if err != nil {
+		return nil, err
+	}
+	ts.files = append(ts.files, df)
+	return df, nil
+}
+
+// DropDataFile removes an empty datafile from the tablespace and deletes it. The last datafile of a
+// tablespace cannot be dropped.
+func (ts *Tablespace) DropDataFile(path string) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	i, err := ts.find(path)
+	if err != nil {
+		return err
+	}
+	df := ts.files[i]
+	if len(ts.files) == 1 {
+		return fmt.Errorf("%v is the only datafile of tablespace %v", df.Path(), ts.name)
+	}
+	if used, err := df.UsedPages(); err != nil {
+		return err
+	} else if used > 0 {
+		return fmt.Errorf("%v is not empty", df.Path())
+	}
+
+	if err = ts.set.closeFile(df, true); err != nil {
+		return err
+	}
+	ts.files = append(ts.files[:i], ts.files[i+1:]...)
+	return nil
+}
+
+// Resize makes a datafile of the tablespace a number of bytes long. The path may be empty if the
+// tablespace has only one datafile.
+func (ts *Tablespace) Resize(path string, size int64) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	i, err := ts.find(path)
+	if err != nil {
+		return err
+	}
+	return ts.files[i].Resize(PageNo(size / int64(ts.pageSize)))
+}
+
+// SetAutoextend sets how many bytes a datafile grows by when it is full, 0 to stop it growing, and
+// how big it may grow, 0 for no limit. The path may be empty if the tablespace has only one datafile.
+func (ts *Tablespace) SetAutoextend(path string, next, maxSize int64) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	i, err := ts.find(path)
+	if err != nil {
+		return err
+	}
+	pages := func(size int64) PageNo { return PageNo(size / int64(ts.pageSize)) }
+	return ts.files[i].SetAutoextend(pages(next), pages(maxSize))
+}
+
+// SetStatus brings the tablespace online, or makes it read only or offline. What is in the pool for
+// it is written first, and when it goes offline the pool lets go of its pages.
+func (ts *Tablespace) SetStatus(status FileStatus) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if status == ts.status {
+		return nil
+	}
+	if status != FileOnline && ts.kind != PermanentTablespace {
+		return fmt.Errorf("tablespace %v cannot be taken offline or made read only", ts.name)
+	}
+
+	for _, df := range ts.files {
+		var err error
+		switch {
+		case ts.status == FileOffline:
+			// nothing of it is in the pool
+		case status == FileOffline:
+			err = ts.set.pool.Evict(df)
+		default:
+			err = ts.set.pool.Flush(df)
+		}
+		if err == nil {
+			err = df.SetStatus(status)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	ts.status = status
+	return nil
+}
+
+// CreateHeap makes a heap for a table in the tablespace.
+func (ts *Tablespace) CreateHeap() (heap *Heap, err error) {
+	err = ts.place(PermanentTablespace, func(df *DataFile) (err error) {
+		heap, err = CreateHeap(ts.set.pool, df)
+		return
+	})
+	return
+}
+
+// CreateBTree makes an index in the tablespace.
+func (ts *Tablespace) CreateBTree(desc []bool, unique bool) (tree *BTree, err error) {
+	err = ts.place(PermanentTablespace, func(df *DataFile) (err error) {
+		tree, err = CreateBTree(ts.set.pool, df, desc, unique)
+		return
+	})
+	return
+}
+
+// CreateUndo sets aside pages of an undo tablespace for undo.
+func (ts *Tablespace) CreateUndo(pages int) (undo *Undo, err error) {
+	err = ts.place(UndoTablespace, func(df *DataFile) (err error) {
+		undo, err = CreateUndo(ts.set.pool, df, pages)
+		return
+	})
+	return
+}
+
+// place makes a segment in the first datafile that has room for it.
+func (ts *Tablespace) place(kind TablespaceKind, create func(df *DataFile) error) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.kind != kind {
+		return fmt.Errorf("tablespace %v cannot hold this kind of segment", ts.name)
+	}
+	switch ts.status {
+	case FileReadOnly:
+		return ErrReadOnly
+	case FileOffline:
+		return ErrOffline
+	}
+
+	for _, df := range ts.files {
+		if err := create(df); err != ErrTablespaceFull {
+			return err
+		}
+	}
+	return ErrTablespaceFull
+}
+
+// setOwner changes what the datafiles of the tablespace record about it.
+func (ts *Tablespace) setOwner(change func(o *FileOwner)) error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	for _, df := range ts.files {
+		if df.Status() == FileOffline {
+			continue
+		}
+		owner := df.Owner()
+		change(&owner)
+		if err := df.SetOwner(owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// find returns the index of a datafile by path, or of the only datafile if the path is empty.
+func (ts *Tablespace) find(path string) (int, error) {
+	if path == "" {
+		if len(ts.files) != 1 {
+			return 0, fmt.Errorf("tablespace %v has more than one datafile; say which", ts.name)
+		}
+		return 0, nil
+	}
+	for i, df := range ts.files {
+		if df.Path() == path {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a datafile of tablespace %v", path, ts.name)
+}
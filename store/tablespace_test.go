@@ -0,0 +1,235 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAutoextend(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+
+	// 5 pages to start with, then 4 at a time up to 12
+	if e := df.Resize(5); e != nil {
+		t.Fatal(e)
+	}
+	if e := df.SetAutoextend(4, 12); e != nil {
+		t.Fatal(e)
+	}
+
+	var counts []PageNo
+	var e error
+	for e == nil {
+		if _, e = df.Allocate(PageData); e == nil {
+			counts = append(counts, df.PageCount())
+		}
+	}
+	if e != ErrTablespaceFull {
+		t.Fatalf("expected the file to fill up, got %v", e)
+	}
+	expected := []PageNo{5, 5, 5, 9, 9, 9, 9, 12, 12, 12}
+	if len(counts) != len(expected) {
+		t.Fatalf("expected %v pages allocated, got %v", len(expected), counts)
+	}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Fatalf("expected the file to grow %v, got %v", expected, counts)
+		}
+	}
+
+	// the settings are kept in the file
+	_ = df.Close()
+	if df, e = OpenDataFile(df.Path()); e != nil {
+		t.Fatal(e)
+	}
+	defer df.Close()
+	if next, max := df.Autoextend(); next != 4 || max != 12 {
+		t.Errorf("expected autoextend 4 up to 12, got %v up to %v", next, max)
+	}
+
+	// shrinking only gives back free pages
+	if e = df.Resize(8); e == nil {
+		t.Error("expected an error shrinking over pages in use")
+	}
+	if e = df.Free(11); e != nil {
+		t.Fatal(e)
+	}
+	if e = df.Resize(11); e != nil || df.PageCount() != 11 {
+		t.Errorf("expected 11 pages, got %v, %v", df.PageCount(), e)
+	}
+	if info, _ := os.Stat(df.Path()); info.Size() != 11*MinPageSize {
+		t.Errorf("expected the file to be truncated, it is %v bytes", info.Size())
+	}
+}
+
+func TestDataFileStatus(t *testing.T) {
+	df := createDataFile(t, MinPageSize)
+	pool := NewBufferPool(8)
+	p, _ := pool.Allocate(df, PageData)
+	copy(p.Body(), "before")
+	_ = pool.Unpin(df, p.No, true)
+	_ = pool.Flush(df)
+
+	if e := df.SetStatus(FileReadOnly); e != nil {
+		t.Fatal(e)
+	}
+	if _, e := df.Allocate(PageData); e != ErrReadOnly {
+		t.Errorf("expected read only, got %v", e)
+	}
+
+	// a change to a cached page is thrown away
+	p, e := pool.Get(df, p.No)
+	if e != nil {
+		t.Fatal(e)
+	}
+	copy(p.Body(), "after")
+	if e = pool.Unpin(df, p.No, true); e != ErrReadOnly {
+		t.Errorf("expected read only, got %v", e)
+	}
+	if p, e = pool.Get(df, p.No); e != nil || string(p.Body()[:6]) != "before" {
+		t.Errorf("expected the page as it was, got %q, %v", p.Body()[:6], e)
+	}
+	_ = pool.Unpin(df, p.No, false)
+
+	if e = pool.Evict(df); e != nil {
+		t.Fatal(e)
+	}
+	if e = df.SetStatus(FileOffline); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = pool.Get(df, p.No); e != ErrOffline {
+		t.Errorf("expected offline, got %v", e)
+	}
+	var coded *Error
+	if !errors.As(ErrOffline, &coded) || coded.Code != 6 {
+		t.Error("expected error code 6")
+	}
+}
+
+func newTablespaces(t *testing.T) (*Tablespaces, string) {
+	dir := t.TempDir()
+	s := NewTablespaces(NewBufferPool(32), 42)
+	t.Cleanup(func() { _ = s.Close() })
+	return s, dir
+}
+
+func TestTablespaces(t *testing.T) {
+	s, dir := newTablespaces(t)
+	file := func(name string, size, next, max int64) DataFileSpec {
+		return DataFileSpec{Path: filepath.Join(dir, name), Size: size, Next: next, MaxSize: max}
+	}
+
+	users, e := s.Create("USERS", PermanentTablespace, MinPageSize, []DataFileSpec{file("users1.dbf", 4*MinPageSize, 0, 0)})
+	if e != nil {
+		t.Fatal(e)
+	}
+	undo, e := s.Create("UNDO", UndoTablespace, MinPageSize, []DataFileSpec{file("undo.dbf", 0, MinPageSize, 0)})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if _, e = s.Create("USERS", PermanentTablespace, 0, []DataFileSpec{file("other.dbf", 0, 0, 0)}); e == nil {
+		t.Error("expected an error creating a tablespace twice")
+	}
+	if _, e = s.Create("BAD", PermanentTablespace, 0, []DataFileSpec{file("users1.dbf", 0, 0, 0)}); e == nil {
+		t.Error("expected an error creating a datafile that exists")
+	}
+	if _, e = s.Get("BAD"); e == nil {
+		t.Error("expected the failed tablespace not to exist")
+	}
+
+	// a table goes in the default tablespace unless it says otherwise
+	if _, e = s.ForSegment(""); e == nil {
+		t.Error("expected no default tablespace yet")
+	}
+	_ = s.SetDefault("USERS")
+	if ts, e := s.ForSegment(""); ts != users || e != nil {
+		t.Errorf("expected USERS, got %v", e)
+	}
+	if _, e = s.ForSegment("UNDO"); e == nil {
+		t.Error("expected an error putting a table in the undo tablespace")
+	}
+	if _, e = users.CreateUndo(2); e == nil {
+		t.Error("expected an error putting undo in USERS")
+	}
+	if _, e = undo.CreateUndo(4); e != nil {
+		t.Error(e)
+	}
+
+	// the first file has room for two segments; the next goes in a file that is added
+	for i := 0; i < 2; i++ {
+		if _, e = users.CreateHeap(); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if _, e = users.CreateBTree([]bool{false}, false); e != ErrTablespaceFull {
+		t.Errorf("expected the tablespace to be full, got %v", e)
+	}
+	second, e := users.AddDataFile(file("users2.dbf", 0, MinPageSize, 8*MinPageSize))
+	if e != nil {
+		t.Fatal(e)
+	}
+	tree, e := users.CreateBTree([]bool{false}, false)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if tree.file != second || second.No() != 3 {
+		t.Errorf("expected the index in the second datafile, file %v", second.No())
+	}
+
+	if e = users.Resize("", 8*MinPageSize); e == nil {
+		t.Error("expected an error resizing without saying which file")
+	}
+	if e = users.Resize(second.Path(), 6*MinPageSize); e != nil || second.PageCount() != 6 {
+		t.Errorf("expected 6 pages, got %v, %v", second.PageCount(), e)
+	}
+
+	// read only and offline apply to every file, and stop new segments
+	if e = users.SetStatus(FileReadOnly); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = users.CreateHeap(); e != ErrReadOnly {
+		t.Errorf("expected read only, got %v", e)
+	}
+	if e = tree.Insert([]interface{}{1}, rowFor(1)); e != ErrReadOnly {
+		t.Errorf("expected the index to refuse an entry, got %v", e)
+	}
+	if ids := scanIDs(t, tree, KeyRange{}, false); len(ids) != 0 {
+		t.Errorf("expected the index to stay empty, got %v", ids)
+	}
+	if e = users.SetStatus(FileOffline); e != nil {
+		t.Fatal(e)
+	}
+	for _, df := range users.DataFiles() {
+		if df.Status() != FileOffline {
+			t.Errorf("%v is not offline", df.Path())
+		}
+	}
+	if e = users.SetStatus(FileOnline); e != nil {
+		t.Fatal(e)
+	}
+	if e = undo.SetStatus(FileOffline); e == nil {
+		t.Error("expected an error taking undo offline")
+	}
+
+	if e = s.Rename("USERS", "APP_DATA"); e != nil || users.Name() != "APP_DATA" || s.Default(PermanentTablespace) != users {
+		t.Errorf("rename did not carry over: %v", e)
+	}
+
+	// dropping
+	if e = s.Drop("APP_DATA", true, true); e == nil {
+		t.Error("expected an error dropping the default tablespace")
+	}
+	if e = s.Drop("UNDO", false, false); e == nil {
+		t.Error("expected an error dropping a tablespace that is not empty")
+	}
+	if e = s.Drop("UNDO", true, true); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = os.Stat(filepath.Join(dir, "undo.dbf")); !os.IsNotExist(e) {
+		t.Errorf("expected the datafile to be deleted, got %v", e)
+	}
+	if _, e = s.Get("UNDO"); e == nil {
+		t.Error("expected UNDO to be gone")
+	}
+}
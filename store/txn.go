@@ -0,0 +1,450 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SCN is a system change number. Every commit takes the next one, so an SCN names a moment in the
+// history of the database: the state after all commits up to it and none after.
+type SCN = uint64
+
+// Isolation is how much of other transactions' work a transaction sees.
+type Isolation int
+
+const (
+	// ReadCommitted reads what was committed when each statement began.
+	ReadCommitted Isolation = iota
+	// Serializable reads what was committed when the transaction began, and fails to change a
+	// row that another transaction changed since.
+	Serializable
+)
+
+// ErrSnapshotTooOld is returned when a read needs undo that has been overwritten.
+var ErrSnapshotTooOld = &Error{Code: 2, Message: "Snapshot too old"}
+
+// ErrSerialization is returned when a serializable transaction changes a row that another
+// transaction changed after it began.
+var ErrSerialization = &Error{Code: 3, Message: "Cannot serialize access for this transaction"}
+
+// ErrRowLocked is returned when changing a row that another open transaction has changed.
+var ErrRowLocked = errors.New("row is locked by another transaction")
+
+// DefaultRetention is how long commits are remembered for flashback queries unless told otherwise.
+const DefaultRetention = 15 * time.Minute
+
+// Transactions starts transactions, hands out snapshots and keeps track of what has committed.
+//
+// The commit SCN of every transaction is remembered for the retention period, and for as long as
+// some snapshot is older than it, so that a flashback query can read the database as of any SCN
+// since then. A transaction that is no longer remembered committed at or before the horizon, and is
+// seen by every snapshot that can still be taken.
+type Transactions struct {
+	mu        sync.Mutex
+	undo      *Undo
+	redo      *RedoLog
+	scn       SCN
+	lastID    uint64
+	active    map[uint64]*Txn
+	committed map[uint64]SCN // commit SCNs that some snapshot may still be older than
+	snapshots map[*Snapshot]bool
+	tables    map[tableKey]*Table
+	retention time.Duration
+	horizon   SCN       // every commit up to here is forgotten
+	times     []scnTime // when each remembered commit happened, oldest first
+	now       func() time.Time
+}
+
+type scnTime struct {
+	scn SCN
+	at  time.Time
+}
+
+// Snapshot is a consistent view of the database as of an SCN, plus the changes of the transaction
+// it belongs to, if any. It must be released when done with.
+type Snapshot struct {
+	SCN   SCN
+	txn   uint64
+	ts    *Transactions
+	owned bool // released along with its transaction
+}
+
+// Txn is an open transaction.
+type Txn struct {
+	ID        uint64
+	Isolation Isolation
+
+	ts        *Transactions
+	snapshot  *Snapshot // for Serializable
+	firstUndo uint64
+	lastUndo  uint64 // the chain of undo records, newest first
+}
+
+func NewTransactions(undo *Undo) *Transactions {
+	return &Transactions{
+		undo:      undo,
+		active:    make(map[uint64]*Txn),
+		committed: make(map[uint64]SCN),
+		snapshots: make(map[*Snapshot]bool),
+		tables:    make(map[tableKey]*Table),
+		retention: DefaultRetention,
+		times:     []scnTime{{0, time.Now()}},
+		now:       time.Now,
+	}
+}
+
+// SetRedoLog makes every commit wait for its commit record to be in the redo log.
+func (ts *Transactions) SetRedoLog(r *RedoLog) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.redo = r
+}
+
+// Resume carries on from where the database left off before a restart: the SCN it had reached, and
+// the highest transaction ID it had used, so that a new transaction is never taken for an old one
+// whose changes are in the datafiles. The transactions a crash cut short must be rolled back first,
+// with RollbackCrashed; every change left is then seen by every snapshot, and nothing older than the
+// restart can be flashed back to.
+func (ts *Transactions) Resume(scn SCN, lastID uint64) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.scn, ts.horizon, ts.lastID = scn, scn, lastID
+	ts.times = []scnTime{{scn, ts.now()}}
+}
+
+// LastID is the highest transaction ID used so far.
+func (ts *Transactions) LastID() uint64 {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.lastID
+}
+
+// SetRetention sets how long commits are remembered for flashback queries. The undo holding old
+// versions of rows is kept only as long as there is room for it, so a flashback query may still
+// find that its snapshot is too old.
+func (ts *Transactions) SetRetention(d time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.retention = d
+	ts.forget()
+}
+
+// OldestSCN is the oldest SCN a flashback query can ask for.
+func (ts *Transactions) OldestSCN() SCN {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.horizon
+}
+
+// SCN is the SCN of the last commit.
+func (ts *Transactions) SCN() SCN {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.scn
+}
+
+// Begin starts a transaction.
+func (ts *Transactions) Begin(isolation Isolation) *Txn {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.lastID++
+	txn := &Txn{ID: ts.lastID, Isolation: isolation, ts: ts}
+	if isolation == Serializable {
+		txn.snapshot = ts.newSnapshot(txn.ID)
+		txn.snapshot.owned = true
+	}
+	ts.active[txn.ID] = txn
+	return txn
+}
+
+// Snapshot is a view of what is committed now, for reading outside of a transaction.
+func (ts *Transactions) Snapshot() *Snapshot {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.newSnapshot(0)
+}
+
+// SnapshotAt is a view of what was committed as of an earlier SCN, for a flashback query.
+func (ts *Transactions) SnapshotAt(scn SCN) (*Snapshot, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if scn > ts.scn {
+		return nil, fmt.Errorf("SCN %v has not been reached; the current SCN is %v", scn, ts.scn)
+	}
+	if scn < ts.horizon {
+		return nil, ErrSnapshotTooOld
+	}
+	s := ts.newSnapshot(0)
+	s.SCN = scn
+	return s, nil
+}
+
+// SCNAt is the SCN that was current at a moment: that of the last commit at or before it.
+func (ts *Transactions) SCNAt(at time.Time) (SCN, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if at.After(ts.now()) {
+		return 0, fmt.Errorf("%v is in the future", at)
+	}
+	i := sort.Search(len(ts.times), func(i int) bool { return ts.times[i].at.After(at) })
+	if i == 0 {
+		return 0, ErrSnapshotTooOld
+	}
+	return ts.times[i-1].scn, nil
+}
+
+func (ts *Transactions) newSnapshot(txn uint64) *Snapshot {
+	s := &Snapshot{SCN: ts.scn, txn: txn, ts: ts}
+	ts.snapshots[s] = true
+	return s
+}
+
+// Release lets go of a snapshot. A snapshot of a serializable transaction is released when the
+// transaction ends.
+func (s *Snapshot) Release() {
+	if s.owned {
+		return
+	}
+	s.ts.mu.Lock()
+	defer s.ts.mu.Unlock()
+	s.ts.release(s)
+}
+
+func (ts *Transactions) release(s *Snapshot) {
+	delete(ts.snapshots, s)
+	ts.forget()
+}
+
+// forget drops the commit SCNs that every snapshot is newer than and that are past the retention
+// period; a change by a transaction that is neither open nor remembered is seen by everyone.
+func (ts *Transactions) forget() {
+	oldest := ts.scn
+	for s := range ts.snapshots {
+		if s.SCN < oldest {
+			oldest = s.SCN
+		}
+	}
+	// the SCN that was current when the retention period began
+	since := ts.now().Add(-ts.retention)
+	if i := sort.Search(len(ts.times), func(i int) bool { return ts.times[i].at.After(since) }); i > 0 {
+		if scn := ts.times[i-1].scn; scn < oldest {
+			oldest = scn
+		}
+	} else {
+		oldest = ts.horizon
+	}
+	if oldest <= ts.horizon {
+		return
+	}
+
+	for id, scn := range ts.committed {
+		if scn <= oldest {
+			delete(ts.committed, id)
+		}
+	}
+	ts.horizon = oldest
+
+	// keep the time of the horizon itself, which is the SCN of the moments after it
+	i := sort.Search(len(ts.times), func(i int) bool { return ts.times[i].scn > oldest })
+	ts.times = ts.times[i-1:]
+}
+
+// visible says whether a snapshot sees the changes of a transaction.
+func (ts *Transactions) visible(writer uint64, s *Snapshot) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if writer == s.txn {
+		return true
+	}
+	if ts.active[writer] != nil {
+		return false
+	}
+	if scn, ok := ts.committed[writer]; ok {
+		return scn <= s.SCN
+	}
+	return true
+}
+
+// commitSCN is the SCN at which a transaction committed, 0 if it committed at or before the
+// horizon, or false if it is still open.
+func (ts *Transactions) commitSCN(writer uint64) (SCN, bool) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.active[writer] != nil {
+		return 0, false
+	}
+	return ts.committed[writer], true
+}
+
+// isActive says whether a transaction other than txn is open.
+func (ts *Transactions) isActive(writer uint64, txn *Txn) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return writer != txn.ID && ts.active[writer] != nil
+}
+
+// oldestUndo is the first undo record of the oldest open transaction, which must be kept.
+func (ts *Transactions) oldestUndo() uint64 {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	var oldest uint64
+	for _, txn := range ts.active {
+		if txn.firstUndo != 0 && (oldest == 0 || txn.firstUndo < oldest) {
+			oldest = txn.firstUndo
+		}
+	}
+	return oldest
+}
+
+// Snapshot is what a statement of the transaction reads: as of now for ReadCommitted, as of the
+// start of the transaction for Serializable.
+func (txn *Txn) Snapshot() *Snapshot {
+	if txn.snapshot != nil {
+		return txn.snapshot
+	}
+	txn.ts.mu.Lock()
+	defer txn.ts.mu.Unlock()
+	return txn.ts.newSnapshot(txn.ID)
+}
+
+// Commit makes the transaction's changes visible to snapshots taken from now on, and returns its SCN.
+// A transaction that changed anything is committed only once its commit record is in the redo log;
+// if that cannot be written, it stays open.
+func (txn *Txn) Commit() (SCN, error) {
+	ts := txn.ts
+	ts.mu.Lock()
+	redo := ts.redo
+	ts.mu.Unlock()
+
+	if txn.firstUndo != 0 {
+		if redo != nil {
+			if err := redo.Commit(txn.ID); err != nil {
+				return 0, err
+			}
+		}
+		if err := ts.undo.end(txn.ID); err != nil {
+			return 0, err
+		}
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.scn++
+	ts.committed[txn.ID] = ts.scn
+	ts.times = append(ts.times, scnTime{ts.scn, ts.now()})
+	txn.end()
+	return ts.scn, nil
+}
+
+// Rollback undoes the transaction's changes, newest first.
+func (txn *Txn) Rollback() error {
+	err := txn.rollback(func(key tableKey) (*Table, error) {
+		txn.ts.mu.Lock()
+		defer txn.ts.mu.Unlock()
+		if table := txn.ts.tables[key]; table != nil {
+			return table, nil
+		}
+		return nil, errors.New("undo refers to a table that is not open")
+	})
+	if err != nil {
+		return err
+	}
+
+	txn.ts.mu.Lock()
+	defer txn.ts.mu.Unlock()
+	txn.end()
+	return nil
+}
+
+// RollbackCrashed rolls back, after a restart, the transactions that had not finished when the
+// database stopped: those the undo still lists, but for any that committed is listed. open opens
+// the table of a heap, given its datafile and first page.
+func (ts *Transactions) RollbackCrashed(committed map[uint64]bool, open func(FileNo, PageNo) (*Table, error)) error {
+	crashed := ts.undo.Open()
+	ids := make([]uint64, 0, len(crashed))
+	for id := range crashed {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	for _, id := range ids {
+		if committed[id] {
+			// it committed just before the crash, before the undo could forget it
+			if err := ts.undo.end(id); err != nil {
+				return err
+			}
+			continue
+		}
+		txn := &Txn{ID: id, ts: ts, firstUndo: crashed[id], lastUndo: crashed[id]}
+		err := txn.rollback(func(key tableKey) (*Table, error) {
+			return open(key.file, key.first)
+		})
+		if err != nil {
+			return fmt.Errorf("rolling back transaction %v: %v", id, err)
+		}
+	}
+	return nil
+}
+
+// rollback takes back the changes of the transaction, newest first, and lets the undo forget it.
+func (txn *Txn) rollback(table func(tableKey) (*Table, error)) error {
+	for addr := txn.lastUndo; addr != 0; {
+		rec, err := txn.ts.undo.read(addr)
+		if err != nil {
+			return err
+		}
+		u := decodeUndo(rec)
+
+		t, err := table(u.table)
+		if err != nil {
+			return err
+		}
+		if err = t.undo(u); err != nil {
+			return err
+		}
+
+		addr = u.prev
+		txn.lastUndo = addr
+	}
+
+	if txn.firstUndo == 0 {
+		return nil
+	}
+	return txn.ts.undo.end(txn.ID)
+}
+
+func (txn *Txn) end() {
+	ts := txn.ts
+	delete(ts.active, txn.ID)
+	if txn.snapshot != nil {
+		delete(ts.snapshots, txn.snapshot)
+	}
+	ts.forget()
+}
+
+// addUndo writes an undo record for the transaction and links it into its chain.
+func (txn *Txn) addUndo(u *undoRecord) (uint64, error) {
+	u.txn = txn.ID
+	u.prev = txn.lastUndo
+
+	addr, err := txn.ts.undo.write(txn.ID, u.encode(), txn.ts.oldestUndo())
+	if err != nil {
+		return 0, err
+	}
+
+	if txn.firstUndo == 0 {
+		txn.firstUndo = addr
+	}
+	txn.lastUndo = addr
+	return addr, nil
+}
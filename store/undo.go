@@ -0,0 +1,304 @@
+package store
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUndoFull is returned when a transaction needs more undo than there is room for beside the
+// undo of transactions still open.
+var ErrUndoFull = errors.New("undo tablespace is full: the undo of open transactions takes all of it")
+
+/*
+Undo is a ring of pages in an undo datafile. Records are written one after another, at addresses
+that only ever grow; the address modulo the size of the ring is where the record lies, so a record
+may run from one page into the next. Address 0 is never used, and stands for no record.
+
+Each record starts with its address and length, so a record that has been written over is caught:
+
+  offset size
+       0    8  address
+       8    4  length of what follows
+      12       the record
+
+Once the ring has gone all the way round, new records overwrite the oldest. The undo of an open
+transaction is never overwritten, since it is needed to roll the transaction back; the undo of one
+that has committed is kept only as long as there is room, and a reader that needs it after that is
+told its snapshot is too old.
+
+A page before the ring holds the state of the undo, so that it outlives a restart:
+
+  offset size
+       0    8  address of the next record
+       8    4  number of transactions that have undo and are still open
+      12       for each of them, the transaction and the address of its last record, 8 bytes each
+
+It is changed through the buffer pool like any other page, so the redo log brings it up to date
+after a crash along with the rows it is the undo of. The transactions it lists then are the ones
+that did not finish, and are rolled back before the database opens.
+*/
+
+const (
+	undoRecordHeader = 12
+	undoStateHeader  = 12
+	undoStateEntry   = 16
+)
+
+// Undo holds the before-images of rows that transactions change.
+type Undo struct {
+	mu       sync.Mutex
+	pool     *BufferPool
+	file     *DataFile
+	state    PageNo
+	pages    []PageNo
+	body     uint64
+	capacity uint64
+	head     uint64            // address of the next record
+	open     []uint64          // the transactions in the state page, in its order
+	last     map[uint64]uint64 // the last record of each of them
+}
+
+// CreateUndo sets aside pages of the datafile for undo, and one more for its state.
+func CreateUndo(pool *BufferPool, df *DataFile, pages int) (*Undo, error) {
+	if pages < 2 {
+		return nil, fmt.Errorf("undo needs at least 2 pages, not %v", pages)
+	}
+
+	u := &Undo{pool: pool, file: df, body: uint64(df.PageSize() - PageHeaderSize), head: 1, last: make(map[uint64]uint64)}
+	for i := 0; i <= pages; i++ {
+		page, err := pool.Allocate(df, PageUndo)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			u.state = page.No
+		} else {
+			u.pages = append(u.pages, page.No)
+		}
+		if err = pool.Unpin(df, page.No, false); err != nil {
+			return nil, err
+		}
+	}
+	u.capacity = u.body * uint64(pages)
+
+	if err := u.saveState(u.head, nil, 0, 0); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// OpenUndo takes up the undo pages of the datafile again, after a restart, and the state they were
+// left in.
+func OpenUndo(pool *BufferPool, df *DataFile) (*Undo, error) {
+	u := &Undo{pool: pool, file: df, body: uint64(df.PageSize() - PageHeaderSize), last: make(map[uint64]uint64)}
+	for no := PageNo(1); no < df.PageCount(); no++ {
+		if space, err := df.FreeSpace(no); err != nil || space < 0 {
+			// a map page, or a free one
+			continue
+		}
+		page, err := pool.Get(df, no)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case page.Type() != PageUndo:
+		case u.state == 0:
+			// the first undo page is the state
+			u.state = no
+		default:
+			u.pages = append(u.pages, no)
+		}
+		if err = pool.Unpin(df, no, false); err != nil {
+			return nil, err
+		}
+	}
+	if len(u.pages) < 2 {
+		return nil, fmt.Errorf("%v does not hold undo", df.Path())
+	}
+	u.capacity = u.body * uint64(len(u.pages))
+
+	page, err := pool.Get(df, u.state)
+	if err != nil {
+		return nil, err
+	}
+	body := page.Body()
+	u.head = binary.LittleEndian.Uint64(body[0:])
+	count := int(binary.LittleEndian.Uint32(body[8:]))
+	if u.head != 0 && undoStateHeader+count*undoStateEntry <= len(body) {
+		for i := 0; i < count; i++ {
+			at := undoStateHeader + i*undoStateEntry
+			txn := binary.LittleEndian.Uint64(body[at:])
+			u.open = append(u.open, txn)
+			u.last[txn] = binary.LittleEndian.Uint64(body[at+8:])
+		}
+	}
+	if err = pool.Unpin(df, u.state, false); err != nil {
+		return nil, err
+	}
+	if u.head == 0 || len(u.open) != count {
+		return nil, fmt.Errorf("%v: the state of the undo is damaged", df.Path())
+	}
+
+	return u, nil
+}
+
+// Open returns the transactions that have undo and have not finished, with the address of the last
+// undo record of each. Right after OpenUndo, these are the transactions that a crash cut short.
+func (u *Undo) Open() map[uint64]uint64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	open := make(map[uint64]uint64, len(u.last))
+	for txn, addr := range u.last {
+		open[txn] = addr
+	}
+	return open
+}
+
+// write adds a record of a transaction and returns its address. keep is the oldest address still
+// needed, or 0.
+func (u *Undo) write(txn uint64, rec []byte, keep uint64) (uint64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	size := uint64(undoRecordHeader + len(rec))
+	if size > u.capacity {
+		return 0, fmt.Errorf("undo record of %v bytes is larger than the undo tablespace", size)
+	}
+	if keep != 0 && u.head+size > keep+u.capacity {
+		return 0, ErrUndoFull
+	}
+	if _, ok := u.last[txn]; !ok && undoStateHeader+(len(u.open)+1)*undoStateEntry > int(u.body) {
+		return 0, fmt.Errorf("undo cannot keep track of more than %v open transactions", len(u.open))
+	}
+
+	addr := u.head
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint64(buf[0:], addr)
+	binary.LittleEndian.PutUint32(buf[8:], uint32(len(rec)))
+	copy(buf[undoRecordHeader:], rec)
+
+	if err := u.transfer(addr, buf, true); err != nil {
+		return 0, err
+	}
+
+	open := u.open
+	if _, ok := u.last[txn]; !ok {
+		open = append(open[:len(open):len(open)], txn)
+	}
+	if err := u.saveState(u.head+size, open, txn, addr); err != nil {
+		return 0, err
+	}
+	return addr, nil
+}
+
+// end forgets a transaction that has committed or rolled back.
+func (u *Undo) end(txn uint64) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if _, ok := u.last[txn]; !ok {
+		return nil
+	}
+	var open []uint64
+	for _, other := range u.open {
+		if other != txn {
+			open = append(open, other)
+		}
+	}
+	if err := u.saveState(u.head, open, 0, 0); err != nil {
+		return err
+	}
+	delete(u.last, txn)
+	return nil
+}
+
+// saveState writes the state page, and once it is written, takes it up: head, the transactions that
+// are open, and, if txn is not 0, the new last record of a transaction.
+func (u *Undo) saveState(head uint64, open []uint64, txn, addr uint64) error {
+	last := func(other uint64) uint64 {
+		if other == txn {
+			return addr
+		}
+		return u.last[other]
+	}
+
+	page, err := u.pool.Get(u.file, u.state)
+	if err != nil {
+		return err
+	}
+	body := page.Body()
+	binary.LittleEndian.PutUint64(body[0:], head)
+	binary.LittleEndian.PutUint32(body[8:], uint32(len(open)))
+	for i, other := range open {
+		at := undoStateHeader + i*undoStateEntry
+		binary.LittleEndian.PutUint64(body[at:], other)
+		binary.LittleEndian.PutUint64(body[at+8:], last(other))
+	}
+	if err = u.pool.Unpin(u.file, u.state, true); err != nil {
+		return err
+	}
+
+	u.head, u.open = head, open
+	if txn != 0 {
+		u.last[txn] = addr
+	}
+	return nil
+}
+
+// read returns a copy of the record at an address, or ErrSnapshotTooOld if it has been overwritten.
+func (u *Undo) read(addr uint64) ([]byte, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if addr == 0 || addr >= u.head {
+		return nil, fmt.Errorf("no undo record at %v", addr)
+	}
+	// the ring overwrites a record from its first byte on
+	if u.head > addr+u.capacity {
+		return nil, ErrSnapshotTooOld
+	}
+
+	var header [undoRecordHeader]byte
+	if err := u.transfer(addr, header[:], false); err != nil {
+		return nil, err
+	}
+	length := uint64(binary.LittleEndian.Uint32(header[8:]))
+	if binary.LittleEndian.Uint64(header[0:]) != addr || addr+undoRecordHeader+length > u.head {
+		return nil, fmt.Errorf("undo record at %v is damaged", addr)
+	}
+	rec := make([]byte, length)
+	if err := u.transfer(addr+undoRecordHeader, rec, false); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// transfer copies between buf and the ring starting at an address, page by page.
+func (u *Undo) transfer(addr uint64, buf []byte, write bool) error {
+	for len(buf) > 0 {
+		at := addr % u.capacity
+		no := u.pages[at/u.body]
+		offset := at % u.body
+
+		page, err := u.pool.Get(u.file, no)
+		if err != nil {
+			return err
+		}
+		var n int
+		if write {
+			n = copy(page.Body()[offset:], buf)
+		} else {
+			n = copy(buf, page.Body()[offset:])
+		}
+		if err = u.pool.Unpin(u.file, no, write); err != nil {
+			return err
+		}
+
+		addr += uint64(n)
+		buf = buf[n:]
+	}
+	return nil
+}
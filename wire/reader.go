@@ -0,0 +1,240 @@
+package wire
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/djbckr/godb/sql/types"
+	"io"
+	"math/big"
+	"time"
+)
+
+// Reader reads a response.
+type Reader struct {
+	r    *bufio.Reader
+	head *Head
+	row  []byte
+	done bool
+}
+
+// NewReader makes a reader of the response r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+var errFormat = errors.New("not a GoDB rows response")
+
+// maxRow is the longest row a reader takes, so that a broken response cannot make it run out of memory.
+const maxRow = 1 << 30
+
+// Head reads the head of the response; once it is read, it returns it again.
+func (r *Reader) Head() (*Head, error) {
+	if r.head != nil {
+		return r.head, nil
+	}
+
+	start := make([]byte, len(magic)+1)
+	if _, err := io.ReadFull(r.r, start); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	if string(start[:len(magic)]) != magic {
+		return nil, errFormat
+	}
+	if start[len(magic)] != Version {
+		return nil, fmt.Errorf("version %v of the format is not supported", start[len(magic)])
+	}
+
+	d := &decoder{r: r.r}
+	head := &Head{Code: int(d.varint()), Message: d.string(), SQLID: d.string()}
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		head.Warnings = append(head.Warnings, d.string())
+	}
+	for n := d.count(); n > 0 && d.err == nil; n-- {
+		head.Fields = append(head.Fields, &Field{Name: d.string(), Type: d.string(), Format: d.string()})
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	r.head = head
+	return head, nil
+}
+
+// Next reads the next row, with a value for each field of the head, or returns io.EOF when there are
+// no more. A value is nil for NULL, or a string, bool, int64, *types.Number, time.Time or Enum.
+func (r *Reader) Next() ([]interface{}, error) {
+	head, err := r.Head()
+	if err != nil {
+		return nil, err
+	}
+	if r.done {
+		return nil, io.EOF
+	}
+
+	tag, err := r.r.ReadByte()
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	switch tag {
+	case tagEnd:
+		r.done = true
+		return nil, io.EOF
+	case tagRow:
+	default:
+		return nil, errFormat
+	}
+
+	d := &decoder{r: r.r}
+	size := d.count()
+	if d.err != nil {
+		return nil, d.err
+	}
+	if size > maxRow {
+		return nil, errFormat
+	}
+	if uint64(cap(r.row)) < size {
+		r.row = make([]byte, size)
+	}
+	r.row = r.row[:size]
+	if _, err = io.ReadFull(r.r, r.row); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+
+	row := &byteReader{b: r.row}
+	d = &decoder{r: row}
+	values := make([]interface{}, len(head.Fields))
+	for i := range values {
+		values[i] = d.value()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if row.i != len(row.b) {
+		return nil, errFormat
+	}
+	return values, nil
+}
+
+// decoder reads the parts of a response, keeping the first error.
+type decoder struct {
+	r   io.ByteReader
+	err error
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	d.err = unexpectedEOF(err)
+	return b
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(d.r)
+	d.err = unexpectedEOF(err)
+	return n
+}
+
+func (d *decoder) count() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(d.r)
+	d.err = unexpectedEOF(err)
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	if br, ok := d.r.(*byteReader); ok {
+		if uint64(len(br.b)-br.i) < n {
+			d.err = io.ErrUnexpectedEOF
+			return nil
+		}
+		b := br.b[br.i : br.i+int(n)]
+		br.i += int(n)
+		return b
+	}
+	b := make([]byte, 0, min(n, 4096))
+	for ; n > 0 && d.err == nil; n-- {
+		b = append(b, d.byte())
+	}
+	return b
+}
+
+func (d *decoder) string() string {
+	return string(d.bytes())
+}
+
+func (d *decoder) value() interface{} {
+	switch kind := d.byte(); {
+	case d.err != nil:
+		return nil
+	case kind == kindNull:
+		return nil
+	case kind == kindString:
+		return d.string()
+	case kind == kindFalse:
+		return false
+	case kind == kindTrue:
+		return true
+	case kind == kindInt:
+		return d.varint()
+	case kind == kindNumber:
+		scale := d.varint()
+		negative := d.byte() == 1
+		unscaled := new(big.Int).SetBytes(d.bytes())
+		if negative {
+			unscaled.Neg(unscaled)
+		}
+		if d.err != nil {
+			return nil
+		}
+		n, err := types.NewScaledNumber(unscaled, int(scale))
+		d.err = err
+		return n
+	case kind == kindTimestamp:
+		seconds, nanos, offset := d.varint(), d.count(), d.varint()
+		t := time.Unix(seconds, int64(nanos))
+		if offset == 0 {
+			return t.UTC()
+		}
+		return t.In(time.FixedZone("", int(offset)))
+	case kind == kindEnum:
+		return Enum{Value: int(d.count()), Name: d.string()}
+	}
+	d.err = errFormat
+	return nil
+}
+
+// byteReader reads the values of a row that has been read whole.
+type byteReader struct {
+	b []byte
+	i int
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	if r.i >= len(r.b) {
+		return 0, io.EOF
+	}
+	r.i++
+	return r.b[r.i-1], nil
+}
+
+// unexpectedEOF is err, except that the response ending part way through is io.ErrUnexpectedEOF:
+// only Next returns io.EOF, at the end of the rows.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+// Package wire is the binary format of a /sql response: the server writes it with a Writer, and a
+// client reads it with a Reader. Numbers and timestamps are sent exactly, so that a client gets
+// back every digit of a decimal and every nanosecond of a timestamp, and neither side spends time
+// turning values into text and back.
+package wire
+
+/*
+A client asks for this format with the header
+
+  Accept: application/vnd.godb.rows
+
+A response is the magic "GODB", a version byte, the head, and then the rows, each with its length
+so that a reader can skip it, and a 0 byte at the end:
+
+  head:   code (varint), message (string), sqlid (string),
+          warning count (uvarint), warnings (string each),
+          field count (uvarint), fields (name, type and format strings each)
+  row:    1, length of the values (uvarint), a value for each field
+  end:    0
+
+A string is its length in bytes (uvarint) and then its UTF-8 bytes. A value is a kind byte and then:
+
+  null:      nothing
+  string:    string
+  false:     nothing
+  true:      nothing
+  int:       varint
+  number:    scale (varint), sign byte (0 for 0 or more, 1 for less), magnitude length (uvarint),
+             magnitude (big-endian bytes); the number is magnitude / 10^scale
+  timestamp: seconds since 1970-01-01 UTC (varint), nanoseconds (uvarint), zone offset in seconds
+             east of UTC (varint)
+  enum:      number (uvarint), name (string)
+*/
+
+// MediaType is the media type of the format.
+const MediaType = "application/vnd.godb.rows"
+
+// Version is the version of the format that this package writes and reads.
+const Version = 1
+
+const magic = "GODB"
+
+// the byte before each row, and the one at the end
+const (
+	tagEnd = 0
+	tagRow = 1
+)
+
+// the kinds of values
+const (
+	kindNull = iota
+	kindString
+	kindFalse
+	kindTrue
+	kindInt
+	kindNumber
+	kindTimestamp
+	kindEnum
+)
+
+// Field is a column of the rows.
+type Field struct {
+	Name   string
+	Type   string
+	Format string
+}
+
+// Head is what a response says before its rows.
+type Head struct {
+	Code     int
+	Message  string
+	SQLID    string
+	Warnings []string
+	Fields   []*Field
+}
+
+// Enum is the value of an enum field: its name, and its number.
+type Enum struct {
+	Value int
+	Name  string
+}
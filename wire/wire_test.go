@@ -0,0 +1,117 @@
+package wire
+
+import (
+	"bytes"
+	"github.com/djbckr/godb/sql/types"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWire(t *testing.T) {
+	head := &Head{
+		Message:  "Success",
+		SQLID:    "84c8e7f9",
+		Warnings: []string{"Hint ignored: FULLL"},
+		Fields: []*Field{
+			{Name: "field1", Type: "string"},
+			{Name: "field2", Type: "number"},
+			{Name: "updated", Type: "timestamp", Format: "YYYY-MM-DDTHH:mm:SS"},
+			{Name: "fieldX", Type: "enum"},
+			{Name: "flag", Type: "boolean"},
+			{Name: "count", Type: "integer"},
+		},
+	}
+
+	var rows [][]interface{}
+	for _, text := range []string{"12345678901234567890.125", "-0.50", "0", "1e40"} {
+		n, e := types.ParseNumber(text)
+		if e != nil {
+			t.Fatal(e)
+		}
+		rows = append(rows, []interface{}{text, n, nil, nil, nil, nil})
+	}
+	rows = append(rows,
+		[]interface{}{"", nil, time.Date(2020, 2, 2, 11, 23, 33, 123456789, time.UTC), Enum{Value: 2, Name: "png"}, true, int64(-7)},
+		[]interface{}{"日本", nil, time.Date(1900, 1, 1, 0, 0, 0, 1, time.FixedZone("", -5*3600)), Enum{Name: "none"}, false, int64(1) << 62},
+	)
+
+	var b bytes.Buffer
+	w := NewWriter(&b)
+	if e := w.WriteHead(head); e != nil {
+		t.Fatal(e)
+	}
+	for _, row := range rows {
+		if e := w.WriteRow(row); e != nil {
+			t.Fatal(e)
+		}
+	}
+	if e := w.Close(); e != nil {
+		t.Fatal(e)
+	}
+	if e := w.WriteRow([]interface{}{"too few"}); e == nil {
+		t.Error("expected an error writing a row with too few values")
+	}
+	if e := w.WriteRow([]interface{}{1, 2, 3, 4, 5, 6}); e == nil {
+		t.Error("expected an error writing an int")
+	}
+
+	r := NewReader(bytes.NewReader(b.Bytes()))
+	got, e := r.Head()
+	if e != nil || !reflect.DeepEqual(got, head) {
+		t.Fatalf("expected %+v, got %+v, %v", head, got, e)
+	}
+	for _, row := range rows {
+		values, e := r.Next()
+		if e != nil {
+			t.Fatal(e)
+		}
+		for i, value := range values {
+			expected := row[i]
+			switch v := value.(type) {
+			case *types.Number:
+				n := expected.(*types.Number)
+				if v.String() != n.String() || v.Scale() != n.Scale() {
+					t.Errorf("expected %v, got %v", n, v)
+				}
+			case time.Time:
+				ts := expected.(time.Time)
+				_, offset := v.Zone()
+				_, expectedOffset := ts.Zone()
+				if !v.Equal(ts) || offset != expectedOffset {
+					t.Errorf("expected %v, got %v", ts, v)
+				}
+			default:
+				if value != expected {
+					t.Errorf("expected %#v, got %#v", expected, value)
+				}
+			}
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, e = r.Next(); e != io.EOF {
+			t.Errorf("expected the end, got %v", e)
+		}
+	}
+
+	// a response cut short, or not in the format at all
+	for _, data := range [][]byte{
+		b.Bytes()[:len(b.Bytes())-1],
+		b.Bytes()[:len(b.Bytes())-5],
+		[]byte("GODB"),
+		[]byte(`{"code":0}`),
+		append([]byte("GODB\x02"), b.Bytes()[5:]...),
+	} {
+		r = NewReader(bytes.NewReader(data))
+		for e = nil; e == nil; _, e = r.Next() {
+		}
+		if e == io.EOF {
+			t.Errorf("expected an error reading %q", data)
+		}
+	}
+	if _, e = NewReader(strings.NewReader("")).Head(); e != io.ErrUnexpectedEOF {
+		t.Errorf("expected an unexpected EOF, got %v", e)
+	}
+}
@@ -0,0 +1,122 @@
+package wire
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/djbckr/godb/sql/types"
+	"io"
+	"time"
+)
+
+// Writer writes a response. Each call writes what it is given to the underlying writer at once.
+type Writer struct {
+	w      io.Writer
+	fields int
+	buf    []byte
+	row    []byte
+}
+
+// NewWriter makes a writer of a response to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// WriteHead writes the head of the response. It must come first.
+func (w *Writer) WriteHead(head *Head) error {
+	b := append(w.buf[:0], magic...)
+	b = append(b, Version)
+
+	b = binary.AppendVarint(b, int64(head.Code))
+	b = appendString(b, head.Message)
+	b = appendString(b, head.SQLID)
+	b = binary.AppendUvarint(b, uint64(len(head.Warnings)))
+	for _, warning := range head.Warnings {
+		b = appendString(b, warning)
+	}
+	b = binary.AppendUvarint(b, uint64(len(head.Fields)))
+	for _, field := range head.Fields {
+		b = appendString(b, field.Name)
+		b = appendString(b, field.Type)
+		b = appendString(b, field.Format)
+	}
+	w.fields = len(head.Fields)
+
+	w.buf = b
+	_, err := w.w.Write(b)
+	return err
+}
+
+// WriteRow writes a row, with a value for each field of the head. A value is nil for NULL, or a
+// string, bool, int64, *types.Number, time.Time or Enum.
+func (w *Writer) WriteRow(values []interface{}) error {
+	if len(values) != w.fields {
+		return fmt.Errorf("the row has %v values for %v fields", len(values), w.fields)
+	}
+
+	row := w.row[:0]
+	for _, value := range values {
+		var err error
+		if row, err = appendValue(row, value); err != nil {
+			return err
+		}
+	}
+	w.row = row
+
+	b := append(w.buf[:0], tagRow)
+	b = binary.AppendUvarint(b, uint64(len(row)))
+	b = append(b, row...)
+	w.buf = b
+
+	_, err := w.w.Write(b)
+	return err
+}
+
+// Close ends the response. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	_, err := w.w.Write([]byte{tagEnd})
+	return err
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendValue(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, kindNull), nil
+	case string:
+		return appendString(append(b, kindString), v), nil
+	case bool:
+		if v {
+			return append(b, kindTrue), nil
+		}
+		return append(b, kindFalse), nil
+	case int64:
+		return binary.AppendVarint(append(b, kindInt), v), nil
+	case *types.Number:
+		if v == nil {
+			return append(b, kindNull), nil
+		}
+		unscaled := v.Unscaled()
+		b = binary.AppendVarint(append(b, kindNumber), int64(v.Scale()))
+		if unscaled.Sign() < 0 {
+			b = append(b, 1)
+		} else {
+			b = append(b, 0)
+		}
+		magnitude := unscaled.Bytes()
+		b = binary.AppendUvarint(b, uint64(len(magnitude)))
+		return append(b, magnitude...), nil
+	case time.Time:
+		_, offset := v.Zone()
+		b = binary.AppendVarint(append(b, kindTimestamp), v.Unix())
+		b = binary.AppendUvarint(b, uint64(v.Nanosecond()))
+		return binary.AppendVarint(b, int64(offset)), nil
+	case Enum:
+		b = binary.AppendUvarint(append(b, kindEnum), uint64(v.Value))
+		return appendString(b, v.Name), nil
+	}
+	return nil, fmt.Errorf("cannot write a value of type %T", value)
+}